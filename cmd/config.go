@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/jandubois/monitor/internal/manifest"
+	"github.com/spf13/cobra"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Export and apply probe configs and notification channels as YAML",
+	Long: `Keep probe configs and notification channels in a YAML file, e.g. in git.
+Objects are identified by name, and refer to watchers, probe types,
+channels and escalation policies by name.
+
+These commands use the web service's API. The token is an API token of an
+operator (admin to change notification channels), or the shared auth token.
+
+Credentials in notification channel configs are exported as REDACTED, and
+applying a file keeps the current value of a REDACTED field. To set them
+from a file, refer to environment variables as ${NAME}.`,
+}
+
+var configExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Write the current configuration as YAML",
+	Args:  cobra.NoArgs,
+	RunE:  runConfigExport,
+}
+
+var configApplyCmd = &cobra.Command{
+	Use:   "apply -f <file>",
+	Short: "Change the configuration to match a YAML file",
+	Long: `Compare a YAML file to the web service's configuration, show the changes,
+and make them. Applying the same file again changes nothing. Objects that
+are not in the file are kept unless --prune is given.`,
+	Args: cobra.NoArgs,
+	RunE: runConfigApply,
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configExportCmd, configApplyCmd)
+	configCmd.PersistentFlags().String("url", "", "URL of the web service (or MONITOR_URL env, default http://localhost:8080)")
+	configCmd.PersistentFlags().String("token", "", "API token (or MONITOR_TOKEN env)")
+	configExportCmd.Flags().StringP("output", "o", "-", "File to write, - for standard output")
+	configApplyCmd.Flags().StringP("file", "f", "", "YAML file to apply, - for standard input")
+	configApplyCmd.Flags().Bool("prune", false, "Delete probe configs and notification channels that are not in the file")
+	configApplyCmd.Flags().Bool("dry-run", false, "Show the changes without making them")
+	configApplyCmd.MarkFlagRequired("file")
+}
+
+func configClient(cmd *cobra.Command) *manifest.Client {
+	url, _ := cmd.Flags().GetString("url")
+	if url == "" {
+		url = os.Getenv("MONITOR_URL")
+	}
+	if url == "" {
+		url = "http://localhost:8080"
+	}
+	token, _ := cmd.Flags().GetString("token")
+	if token == "" {
+		token = os.Getenv("MONITOR_TOKEN")
+	}
+	return manifest.NewClient(url, token)
+}
+
+func runConfigExport(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+	output, _ := cmd.Flags().GetString("output")
+
+	state, err := manifest.Fetch(ctx, configClient(cmd))
+	if err != nil {
+		return err
+	}
+	data, err := state.Export().Marshal()
+	if err != nil {
+		return err
+	}
+	if output == "-" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	// Credentials are redacted, but channel URLs may still hold some
+	return os.WriteFile(output, data, 0o600)
+}
+
+func runConfigApply(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+	path, _ := cmd.Flags().GetString("file")
+	prune, _ := cmd.Flags().GetBool("prune")
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+
+	file, err := manifest.Load(path)
+	if err != nil {
+		return err
+	}
+	client := configClient(cmd)
+	state, err := manifest.Fetch(ctx, client)
+	if err != nil {
+		return err
+	}
+	plan, err := manifest.NewPlan(file, state, prune)
+	if err != nil {
+		return err
+	}
+
+	plan.Write(os.Stdout)
+	if dryRun || plan.Empty() {
+		return nil
+	}
+	fmt.Println()
+	return plan.Apply(ctx, client, os.Stdout)
+}
@@ -1,20 +1,26 @@
 package cmd
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log/slog"
 	"os"
 	"os/exec"
 	"strings"
+	"time"
 
 	"github.com/jandubois/monitor/internal/probe"
 	"github.com/jandubois/monitor/internal/probes"
 	"github.com/jandubois/monitor/internal/probes/command"
 	"github.com/jandubois/monitor/internal/probes/debug"
 	"github.com/jandubois/monitor/internal/probes/diskspace"
+	"github.com/jandubois/monitor/internal/probes/dns"
 	"github.com/jandubois/monitor/internal/probes/github"
 	"github.com/jandubois/monitor/internal/probes/gitstatus"
+	httpprobe "github.com/jandubois/monitor/internal/probes/http"
+	"github.com/jandubois/monitor/internal/probes/tcpport"
+	"github.com/jandubois/monitor/internal/probes/tlscert"
 	"github.com/spf13/cobra"
 )
 
@@ -95,6 +101,97 @@ var gitStatusCmd = &cobra.Command{
 	},
 }
 
+// http probe
+var httpCmd = &cobra.Command{
+	Use:   httpprobe.Name,
+	Short: "Check an HTTP(S) endpoint",
+	Run: func(cmd *cobra.Command, args []string) {
+		var opts httpprobe.Options
+		opts.URL, _ = cmd.Flags().GetString("url")
+		opts.Method, _ = cmd.Flags().GetString("method")
+		opts.Headers, _ = cmd.Flags().GetString("headers")
+		opts.Body, _ = cmd.Flags().GetString("body")
+		opts.ExpectedStatus, _ = cmd.Flags().GetString("expected_status")
+		opts.BodyContains, _ = cmd.Flags().GetString("body_contains")
+		opts.BodyRegex, _ = cmd.Flags().GetString("body_regex")
+		opts.JSONAssertions, _ = cmd.Flags().GetString("json_assertions")
+		opts.WarningMs, _ = cmd.Flags().GetInt("warning_ms")
+		opts.CriticalMs, _ = cmd.Flags().GetInt("critical_ms")
+		opts.FollowRedirects, _ = cmd.Flags().GetBool("follow_redirects")
+		opts.MaxRedirects, _ = cmd.Flags().GetInt("max_redirects")
+		opts.Insecure, _ = cmd.Flags().GetBool("insecure")
+		timeoutSeconds, _ := cmd.Flags().GetInt("timeout_seconds")
+		opts.Timeout = time.Duration(timeoutSeconds) * time.Second
+
+		result := httpprobe.Run(context.Background(), opts)
+		outputResult(result)
+	},
+}
+
+// tls-cert probe
+var tlsCertCmd = &cobra.Command{
+	Use:   tlscert.Name,
+	Short: "Check a TLS certificate for expiry and problems",
+	Run: func(cmd *cobra.Command, args []string) {
+		var opts tlscert.Options
+		opts.Host, _ = cmd.Flags().GetString("host")
+		opts.Port, _ = cmd.Flags().GetInt("port")
+		opts.ServerName, _ = cmd.Flags().GetString("server_name")
+		opts.StartTLS, _ = cmd.Flags().GetString("starttls")
+		opts.WarningDays, _ = cmd.Flags().GetFloat64("warning_days")
+		opts.CriticalDays, _ = cmd.Flags().GetFloat64("critical_days")
+		opts.CAFile, _ = cmd.Flags().GetString("ca_file")
+		timeoutSeconds, _ := cmd.Flags().GetInt("timeout_seconds")
+		opts.Timeout = time.Duration(timeoutSeconds) * time.Second
+
+		result := tlscert.Run(context.Background(), opts)
+		outputResult(result)
+	},
+}
+
+// tcp-port probe
+var tcpPortCmd = &cobra.Command{
+	Use:   tcpport.Name,
+	Short: "Check that a TCP or UDP port answers",
+	Run: func(cmd *cobra.Command, args []string) {
+		var opts tcpport.Options
+		opts.Host, _ = cmd.Flags().GetString("host")
+		opts.Port, _ = cmd.Flags().GetInt("port")
+		opts.Protocol, _ = cmd.Flags().GetString("protocol")
+		opts.Banner, _ = cmd.Flags().GetString("banner")
+		opts.Send, _ = cmd.Flags().GetString("send")
+		opts.Expect, _ = cmd.Flags().GetString("expect")
+		opts.WarningMs, _ = cmd.Flags().GetInt("warning_ms")
+		opts.CriticalMs, _ = cmd.Flags().GetInt("critical_ms")
+		timeoutSeconds, _ := cmd.Flags().GetInt("timeout_seconds")
+		opts.Timeout = time.Duration(timeoutSeconds) * time.Second
+
+		result := tcpport.Run(context.Background(), opts)
+		outputResult(result)
+	},
+}
+
+// dns probe
+var dnsCmd = &cobra.Command{
+	Use:   dns.Name,
+	Short: "Query a DNS resolver and check the answer",
+	Run: func(cmd *cobra.Command, args []string) {
+		var opts dns.Options
+		opts.Name, _ = cmd.Flags().GetString("name")
+		opts.Type, _ = cmd.Flags().GetString("type")
+		opts.Server, _ = cmd.Flags().GetString("server")
+		opts.Expected, _ = cmd.Flags().GetString("expected")
+		opts.Match, _ = cmd.Flags().GetString("match")
+		opts.WarningMs, _ = cmd.Flags().GetInt("warning_ms")
+		opts.CriticalMs, _ = cmd.Flags().GetInt("critical_ms")
+		timeoutSeconds, _ := cmd.Flags().GetInt("timeout_seconds")
+		opts.Timeout = time.Duration(timeoutSeconds) * time.Second
+
+		result := dns.Run(context.Background(), opts)
+		outputResult(result)
+	},
+}
+
 func init() {
 	// Add flags to root
 	rootCmd.Flags().BoolP("version", "v", false, "Print version and exit")
@@ -119,11 +216,19 @@ func init() {
 	debugCmd.GroupID = probeGroupID
 	githubCmd.GroupID = probeGroupID
 	gitStatusCmd.GroupID = probeGroupID
+	httpCmd.GroupID = probeGroupID
+	tlsCertCmd.GroupID = probeGroupID
+	tcpPortCmd.GroupID = probeGroupID
+	dnsCmd.GroupID = probeGroupID
 	rootCmd.AddCommand(diskSpaceCmd)
 	rootCmd.AddCommand(commandCmd)
 	rootCmd.AddCommand(debugCmd)
 	rootCmd.AddCommand(githubCmd)
 	rootCmd.AddCommand(gitStatusCmd)
+	rootCmd.AddCommand(httpCmd)
+	rootCmd.AddCommand(tlsCertCmd)
+	rootCmd.AddCommand(tcpPortCmd)
+	rootCmd.AddCommand(dnsCmd)
 
 	// disk-space flags
 	diskSpaceCmd.Flags().String("path", "", "Path to check")
@@ -154,6 +259,53 @@ func init() {
 	gitStatusCmd.Flags().Float64("uncommitted_hours", 1, "Hours after which uncommitted changes are a failure")
 	gitStatusCmd.Flags().Float64("unpushed_hours", 4, "Hours after which unpushed commits are a failure")
 	gitStatusCmd.Flags().Bool("exclude_ai_files", false, "Exclude AI agent files from uncommitted changes check")
+
+	// http flags
+	httpCmd.Flags().String("url", "", "URL to request")
+	httpCmd.Flags().String("method", "GET", "HTTP method")
+	httpCmd.Flags().String("headers", "", "Request headers as a JSON object")
+	httpCmd.Flags().String("body", "", "Request body")
+	httpCmd.Flags().String("expected_status", "200-299", "Comma-separated status codes or ranges that count as success")
+	httpCmd.Flags().String("body_contains", "", "Substring the response body must contain")
+	httpCmd.Flags().String("body_regex", "", "Regular expression the response body must match")
+	httpCmd.Flags().String("json_assertions", "", "Expected values by JSON path, as a JSON object")
+	httpCmd.Flags().Int("warning_ms", 0, "Response time in milliseconds that triggers a warning (0 to disable)")
+	httpCmd.Flags().Int("critical_ms", 0, "Response time in milliseconds that is critical (0 to disable)")
+	httpCmd.Flags().Bool("follow_redirects", true, "Follow redirects")
+	httpCmd.Flags().Int("max_redirects", 10, "Maximum number of redirects to follow")
+	httpCmd.Flags().Bool("insecure", false, "Skip TLS certificate verification")
+	httpCmd.Flags().Int("timeout_seconds", 30, "Request timeout in seconds")
+
+	// tls-cert flags
+	tlsCertCmd.Flags().String("host", "", "Host to connect to")
+	tlsCertCmd.Flags().Int("port", 443, "Port to connect to")
+	tlsCertCmd.Flags().String("server_name", "", "Server name for SNI and hostname verification (defaults to host)")
+	tlsCertCmd.Flags().String("starttls", "", "Protocol to negotiate TLS with: smtp, imap, or postgres")
+	tlsCertCmd.Flags().Float64("warning_days", 30, "Days before expiry that trigger a warning")
+	tlsCertCmd.Flags().Float64("critical_days", 7, "Days before expiry that are critical")
+	tlsCertCmd.Flags().String("ca_file", "", "PEM file with additional trusted CA certificates")
+	tlsCertCmd.Flags().Int("timeout_seconds", 10, "Connection timeout in seconds")
+
+	// tcp-port flags
+	tcpPortCmd.Flags().String("host", "", "Host to connect to")
+	tcpPortCmd.Flags().Int("port", 0, "Port to connect to")
+	tcpPortCmd.Flags().String("protocol", "tcp", "Transport protocol: tcp or udp")
+	tcpPortCmd.Flags().String("banner", "", "Substring the server must send after connecting")
+	tcpPortCmd.Flags().String("send", "", `Data to send (supports \r, \n, \t and \\ escapes)`)
+	tcpPortCmd.Flags().String("expect", "", "Substring the reply must contain")
+	tcpPortCmd.Flags().Int("warning_ms", 0, "Total time in milliseconds that triggers a warning (0 to disable)")
+	tcpPortCmd.Flags().Int("critical_ms", 0, "Total time in milliseconds that is critical (0 to disable)")
+	tcpPortCmd.Flags().Int("timeout_seconds", 10, "Timeout in seconds")
+
+	// dns flags
+	dnsCmd.Flags().String("name", "", "Name to look up")
+	dnsCmd.Flags().String("type", "A", "Record type: A, AAAA, CNAME, MX, or TXT")
+	dnsCmd.Flags().String("server", "", "Resolver to query as host or host:port")
+	dnsCmd.Flags().String("expected", "", "Comma-separated values the answer must contain")
+	dnsCmd.Flags().String("match", "contains", "Whether the answer must contain or equal the expected values")
+	dnsCmd.Flags().Int("warning_ms", 0, "Query time in milliseconds that triggers a warning (0 to disable)")
+	dnsCmd.Flags().Int("critical_ms", 0, "Query time in milliseconds that is critical (0 to disable)")
+	dnsCmd.Flags().Int("timeout_seconds", 5, "Query timeout in seconds")
 }
 
 func printDescriptions() {
@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/jandubois/monitor/internal/db"
+	"github.com/jandubois/monitor/internal/web"
+	"github.com/spf13/cobra"
+)
+
+var userCmd = &cobra.Command{
+	Use:   "user",
+	Short: "Manage user accounts of the web frontend",
+	Long: `Create user accounts and reset their passwords directly in the database,
+e.g. to add the first admin. Once an admin exists, users can also be
+managed in the web frontend.
+
+The password is read from the MONITOR_PASSWORD environment variable, or
+from the first line of standard input.`,
+}
+
+var userAddCmd = &cobra.Command{
+	Use:   "add <username>",
+	Short: "Create a user",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runUserAdd,
+}
+
+var userPasswdCmd = &cobra.Command{
+	Use:   "passwd <username>",
+	Short: "Reset a user's password and end their sessions",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runUserPasswd,
+}
+
+func init() {
+	rootCmd.AddCommand(userCmd)
+	userCmd.AddCommand(userAddCmd, userPasswdCmd)
+	userAddCmd.Flags().String("role", "viewer", "Role: viewer, operator or admin")
+}
+
+func runUserAdd(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+	role, _ := cmd.Flags().GetString("role")
+
+	password, err := readPassword()
+	if err != nil {
+		return err
+	}
+	database, err := db.Connect(ctx, getDatabasePath(cmd))
+	if err != nil {
+		return fmt.Errorf("database connection failed: %w", err)
+	}
+	defer database.Close()
+
+	if _, err := web.CreateUser(ctx, database, args[0], password, role); err != nil {
+		return err
+	}
+	fmt.Printf("Created %s user %s\n", role, args[0])
+	return nil
+}
+
+func runUserPasswd(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	password, err := readPassword()
+	if err != nil {
+		return err
+	}
+	database, err := db.Connect(ctx, getDatabasePath(cmd))
+	if err != nil {
+		return fmt.Errorf("database connection failed: %w", err)
+	}
+	defer database.Close()
+
+	if err := web.SetPassword(ctx, database, args[0], password); err != nil {
+		return err
+	}
+	fmt.Printf("Changed the password of %s\n", args[0])
+	return nil
+}
+
+// readPassword returns MONITOR_PASSWORD, or the first line of stdin.
+func readPassword() (string, error) {
+	if password := os.Getenv("MONITOR_PASSWORD"); password != "" {
+		return password, nil
+	}
+	fmt.Fprint(os.Stderr, "Password: ")
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", fmt.Errorf("read password: %w", err)
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
@@ -20,7 +20,13 @@ var watcherCmd = &cobra.Command{
 	Long: `The watcher service schedules and executes probes, pushing results
 to the central web service via HTTP.
 
-The watcher name defaults to the hostname. Use --name to override.`,
+The watcher name defaults to the hostname. Use --name to override.
+
+--config adds probe configs from a YAML file in the format of
+"monitor config apply", with the notification channels they use. Their
+results go to --results instead of the web service: "-" for JSON lines on
+stdout, a .db file for SQLite, or any other file for JSON lines. With
+--standalone the watcher runs only these configs, without a web service.`,
 	RunE: runWatcher,
 }
 
@@ -33,6 +39,9 @@ func init() {
 	watcherCmd.Flags().String("probes-dir", "./probes", "Directory containing probe executables")
 	watcherCmd.Flags().Int("max-concurrent", 10, "Maximum concurrent probe executions")
 	watcherCmd.Flags().Int("api-port", 8081, "Port for local watcher API (health check, reload)")
+	watcherCmd.Flags().String("config", "", "YAML file of local probe configs")
+	watcherCmd.Flags().Bool("standalone", false, "Run only the local probe configs, without a web service")
+	watcherCmd.Flags().String("results", "-", "Where results of local probe configs go: -, a .db file, or a JSON lines file")
 }
 
 func runWatcher(cmd *cobra.Command, args []string) error {
@@ -54,6 +63,9 @@ func runWatcher(cmd *cobra.Command, args []string) error {
 	probesDir, _ := cmd.Flags().GetString("probes-dir")
 	maxConcurrent, _ := cmd.Flags().GetInt("max-concurrent")
 	apiPort, _ := cmd.Flags().GetInt("api-port")
+	configPath, _ := cmd.Flags().GetString("config")
+	standalone, _ := cmd.Flags().GetBool("standalone")
+	resultsPath, _ := cmd.Flags().GetString("results")
 
 	// Default name to hostname (without domain)
 	if name == "" {
@@ -76,6 +88,9 @@ func runWatcher(cmd *cobra.Command, args []string) error {
 		PushURL:       pushURL,
 		CallbackURL:   callbackURL,
 		AuthToken:     authToken,
+		ConfigPath:    configPath,
+		Standalone:    standalone,
+		ResultsPath:   resultsPath,
 	}
 
 	// Create and run watcher
@@ -89,6 +104,8 @@ func runWatcher(cmd *cobra.Command, args []string) error {
 		"push_url", pushURL,
 		"probes_dir", probesDir,
 		"max_concurrent", maxConcurrent,
+		"config", configPath,
+		"standalone", standalone,
 	)
 	return w.Run(ctx)
 }
@@ -7,6 +7,7 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/jandubois/monitor/internal/config"
 	"github.com/jandubois/monitor/internal/db"
@@ -27,7 +28,19 @@ func init() {
 
 	webCmd.Flags().String("name", "", "Server name for display (defaults to hostname)")
 	webCmd.Flags().Int("port", 8080, "Port to listen on")
-	webCmd.Flags().String("auth-token", "", "Authentication token (or AUTH_TOKEN env)")
+	webCmd.Flags().String("auth-token", "", "Shared token with admin access, optional with user accounts (or AUTH_TOKEN env)")
+	webCmd.Flags().Duration("missed-run-grace", 5*time.Minute, "How long past its scheduled time a probe run counts as missed")
+	webCmd.Flags().Duration("watcher-down-after", 2*time.Minute, "How long a watcher may miss heartbeats before it is reported down")
+	webCmd.Flags().Duration("result-retention", 0, "How long to keep raw probe results before rolling them up hourly, e.g. 720h (0 keeps them forever)")
+	webCmd.Flags().Duration("rollup-retention", 365*24*time.Hour, "How long to keep hourly result rollups (0 keeps them forever)")
+	webCmd.Flags().String("public-url", "", "Base URL users reach the server at, for acknowledgement links in notifications")
+	webCmd.Flags().String("oidc-issuer", "", "OpenID Connect issuer URL for single sign-on (or OIDC_ISSUER env)")
+	webCmd.Flags().String("oidc-client-id", "", "OpenID Connect client ID (or OIDC_CLIENT_ID env)")
+	webCmd.Flags().String("oidc-client-secret", "", "OpenID Connect client secret, empty for public clients (or OIDC_CLIENT_SECRET env)")
+	webCmd.Flags().StringSlice("oidc-scopes", []string{"openid", "profile", "email", "groups"}, "OpenID Connect scopes to request")
+	webCmd.Flags().String("oidc-groups-claim", "groups", "ID token claim that lists the user's groups")
+	webCmd.Flags().StringToString("oidc-group-role", nil, "Role for members of a group, as group=role (repeatable)")
+	webCmd.Flags().String("oidc-default-role", "", "Role for users in no mapped group (empty denies them)")
 }
 
 func runWeb(cmd *cobra.Command, args []string) error {
@@ -47,6 +60,18 @@ func runWeb(cmd *cobra.Command, args []string) error {
 	name, _ := cmd.Flags().GetString("name")
 	port, _ := cmd.Flags().GetInt("port")
 	authToken, _ := cmd.Flags().GetString("auth-token")
+	missedRunGrace, _ := cmd.Flags().GetDuration("missed-run-grace")
+	watcherDownAfter, _ := cmd.Flags().GetDuration("watcher-down-after")
+	resultRetention, _ := cmd.Flags().GetDuration("result-retention")
+	rollupRetention, _ := cmd.Flags().GetDuration("rollup-retention")
+	publicURL, _ := cmd.Flags().GetString("public-url")
+	oidcIssuer, _ := cmd.Flags().GetString("oidc-issuer")
+	oidcClientID, _ := cmd.Flags().GetString("oidc-client-id")
+	oidcClientSecret, _ := cmd.Flags().GetString("oidc-client-secret")
+	oidcScopes, _ := cmd.Flags().GetStringSlice("oidc-scopes")
+	oidcGroupsClaim, _ := cmd.Flags().GetString("oidc-groups-claim")
+	oidcGroupRoles, _ := cmd.Flags().GetStringToString("oidc-group-role")
+	oidcDefaultRole, _ := cmd.Flags().GetString("oidc-default-role")
 
 	if name == "" {
 		name = getShortHostname()
@@ -56,7 +81,20 @@ func runWeb(cmd *cobra.Command, args []string) error {
 		authToken = os.Getenv("AUTH_TOKEN")
 	}
 	if authToken == "" {
-		return fmt.Errorf("auth token required (--auth-token or AUTH_TOKEN)")
+		slog.Warn("no shared auth token (--auth-token or AUTH_TOKEN); only user accounts can sign in")
+	}
+
+	if oidcIssuer == "" {
+		oidcIssuer = os.Getenv("OIDC_ISSUER")
+	}
+	if oidcClientID == "" {
+		oidcClientID = os.Getenv("OIDC_CLIENT_ID")
+	}
+	if oidcClientSecret == "" {
+		oidcClientSecret = os.Getenv("OIDC_CLIENT_SECRET")
+	}
+	if oidcIssuer != "" && publicURL == "" {
+		slog.Warn("single sign-on without --public-url uses the request's host for the redirect URL")
 	}
 
 	// Connect to database
@@ -67,9 +105,23 @@ func runWeb(cmd *cobra.Command, args []string) error {
 	defer database.Close()
 
 	cfg := &config.WebConfig{
-		Name:      name,
-		Port:      port,
-		AuthToken: authToken,
+		Name:             name,
+		Port:             port,
+		AuthToken:        authToken,
+		MissedRunGrace:   missedRunGrace,
+		WatcherDownAfter: watcherDownAfter,
+		ResultRetention:  resultRetention,
+		RollupRetention:  rollupRetention,
+		PublicURL:        publicURL,
+		OIDC: config.OIDCConfig{
+			Issuer:       oidcIssuer,
+			ClientID:     oidcClientID,
+			ClientSecret: oidcClientSecret,
+			Scopes:       oidcScopes,
+			GroupsClaim:  oidcGroupsClaim,
+			GroupRoles:   oidcGroupRoles,
+			DefaultRole:  oidcDefaultRole,
+		},
 	}
 
 	server, err := web.NewServer(database, cfg)
@@ -4,7 +4,9 @@ go 1.24.0
 
 require (
 	github.com/docker/go-units v0.5.0
+	github.com/robfig/cron/v3 v3.0.1
 	github.com/spf13/cobra v1.10.2
+	gopkg.in/yaml.v3 v3.0.1
 	modernc.org/sqlite v1.34.5
 )
 
@@ -1,5 +1,7 @@
 package config
 
+import "time"
+
 // WatcherConfig holds configuration for the watcher service.
 type WatcherConfig struct {
 	Name          string // Unique watcher name (e.g., "nas", "macbook")
@@ -9,11 +11,32 @@ type WatcherConfig struct {
 	PushURL       string // URL of web service push API
 	CallbackURL   string // URL where web service can reach this watcher (for triggers)
 	AuthToken     string // Bearer token for authentication
+	QueuePath     string // Result queue file (defaults to ~/.config/monitor/<name>.queue)
+	ConfigPath    string // YAML file of local probe configs, in addition to the web service's
+	Standalone    bool   // Run only the local probe configs, without a web service
+	ResultsPath   string // Where results of local probe configs go: "-" for JSON lines on stdout, a .db file for SQLite, or a JSON lines file
 }
 
 // WebConfig holds configuration for the web server.
 type WebConfig struct {
-	Name      string // Server name for display in dashboard
-	Port      int
-	AuthToken string
+	Name             string // Server name for display in dashboard
+	Port             int
+	AuthToken        string
+	MissedRunGrace   time.Duration // How long past next_run_at before a run counts as missed
+	WatcherDownAfter time.Duration // How long without a heartbeat before a watcher is reported down
+	ResultRetention  time.Duration // How long to keep raw results before rolling them up (0 = forever)
+	RollupRetention  time.Duration // How long to keep hourly rollups (0 = forever)
+	PublicURL        string        // Base URL users reach the server at, for links in notifications
+	OIDC             OIDCConfig    // Single sign-on; disabled without an issuer
+}
+
+// OIDCConfig configures login with an OpenID Connect identity provider.
+type OIDCConfig struct {
+	Issuer       string // Issuer URL, for discovery and ID token validation
+	ClientID     string
+	ClientSecret string            // Empty for public clients, which rely on PKCE alone
+	Scopes       []string          // Requested scopes; "openid" is always included
+	GroupsClaim  string            // ID token claim listing the user's groups
+	GroupRoles   map[string]string // Group name to role; the highest matching role wins
+	DefaultRole  string            // Role for users in no mapped group; empty denies them
 }
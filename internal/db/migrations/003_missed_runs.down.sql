@@ -0,0 +1,17 @@
+DROP INDEX IF EXISTS idx_missed_runs_config_scheduled;
+
+-- SQLite doesn't support DROP COLUMN directly, so we need to recreate the table
+CREATE TABLE missed_runs_new (
+    id INTEGER PRIMARY KEY,
+    probe_config_id INTEGER NOT NULL REFERENCES probe_configs(id) ON DELETE CASCADE,
+    scheduled_at TEXT,
+    reason TEXT
+);
+
+INSERT INTO missed_runs_new (id, probe_config_id, scheduled_at, reason)
+SELECT id, probe_config_id, scheduled_at, reason FROM missed_runs;
+
+DROP TABLE missed_runs;
+ALTER TABLE missed_runs_new RENAME TO missed_runs;
+
+CREATE INDEX idx_missed_runs_config ON missed_runs(probe_config_id);
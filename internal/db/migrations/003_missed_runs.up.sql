@@ -0,0 +1,4 @@
+-- Track when a missed run was detected and prevent duplicate records
+ALTER TABLE missed_runs ADD COLUMN detected_at TEXT;
+
+CREATE UNIQUE INDEX idx_missed_runs_config_scheduled ON missed_runs(probe_config_id, scheduled_at);
@@ -0,0 +1,2 @@
+ALTER TABLE watchers DROP COLUMN down_since;
+ALTER TABLE watchers DROP COLUMN notification_channels;
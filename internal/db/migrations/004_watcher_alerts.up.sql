@@ -0,0 +1,5 @@
+-- Per-watcher notification channels for heartbeat loss alerts
+ALTER TABLE watchers ADD COLUMN notification_channels TEXT;  -- JSON array of IDs
+
+-- Set when a watcher has been reported down, cleared on recovery
+ALTER TABLE watchers ADD COLUMN down_since TEXT;
@@ -0,0 +1,4 @@
+DROP VIEW IF EXISTS probe_result_history;
+DROP INDEX IF EXISTS idx_rollups_bucket;
+DROP INDEX IF EXISTS idx_rollups_config_bucket;
+DROP TABLE IF EXISTS probe_result_rollups;
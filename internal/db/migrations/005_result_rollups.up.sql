@@ -0,0 +1,40 @@
+-- Hourly rollups of probe results older than the raw retention period
+CREATE TABLE probe_result_rollups (
+    id INTEGER PRIMARY KEY,
+    probe_config_id INTEGER NOT NULL REFERENCES probe_configs(id) ON DELETE CASCADE,
+    bucket_start TEXT NOT NULL,
+    bucket_seconds INTEGER NOT NULL,
+    status TEXT NOT NULL,            -- worst status in the bucket
+    result_count INTEGER NOT NULL,
+    ok_count INTEGER NOT NULL DEFAULT 0,
+    warning_count INTEGER NOT NULL DEFAULT 0,
+    critical_count INTEGER NOT NULL DEFAULT 0,
+    unknown_count INTEGER NOT NULL DEFAULT 0,
+    duration_min_ms INTEGER,
+    duration_max_ms INTEGER,
+    duration_avg_ms REAL,
+    metrics TEXT,                    -- JSON: average of each numeric metric
+    metric_stats TEXT                -- JSON: {"name": {"min", "max", "avg", "count"}}
+);
+
+CREATE UNIQUE INDEX idx_rollups_config_bucket ON probe_result_rollups(probe_config_id, bucket_start);
+CREATE INDEX idx_rollups_bucket ON probe_result_rollups(bucket_start);
+
+-- Raw results and rollups in one shape, for history queries that span the
+-- retention boundary. Rollup rows carry a JSON summary in the rollup column,
+-- and negated ids so they don't collide with the ids of raw results.
+CREATE VIEW probe_result_history AS
+SELECT id, probe_config_id, status, message, metrics, data, duration_ms,
+       scheduled_at, executed_at, recorded_at, NULL AS rollup
+FROM probe_results
+UNION ALL
+SELECT -id, probe_config_id, status, NULL, metrics, NULL, CAST(ROUND(duration_avg_ms) AS INTEGER),
+       NULL, bucket_start, NULL,
+       json_object(
+           'bucket_seconds', bucket_seconds,
+           'count', result_count,
+           'status_counts', json_object('ok', ok_count, 'warning', warning_count,
+                                        'critical', critical_count, 'unknown', unknown_count),
+           'duration_ms', json_object('min', duration_min_ms, 'max', duration_max_ms, 'avg', duration_avg_ms),
+           'metrics', json(COALESCE(metric_stats, '{}')))
+FROM probe_result_rollups;
@@ -0,0 +1,2 @@
+ALTER TABLE probe_configs DROP COLUMN active_windows;
+ALTER TABLE probe_configs DROP COLUMN timezone;
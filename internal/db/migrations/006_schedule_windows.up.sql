@@ -0,0 +1,5 @@
+-- Timezone for cron schedules and active windows (IANA name, default UTC)
+ALTER TABLE probe_configs ADD COLUMN timezone TEXT;
+
+-- Daily windows in which the probe may run, e.g. ["08:00-22:00"]
+ALTER TABLE probe_configs ADD COLUMN active_windows TEXT;  -- JSON array
@@ -0,0 +1,2 @@
+-- Results queued on the watcher awaiting delivery, as of the last heartbeat
+ALTER TABLE watchers ADD COLUMN queue_depth INTEGER NOT NULL DEFAULT 0;
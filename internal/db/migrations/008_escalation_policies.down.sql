@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS escalations;
+ALTER TABLE probe_configs DROP COLUMN escalation_policy_id;
+DROP TABLE IF EXISTS escalation_policies;
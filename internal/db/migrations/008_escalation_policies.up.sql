@@ -0,0 +1,22 @@
+-- Escalation policies: notify channels in stages while a probe stays critical
+CREATE TABLE escalation_policies (
+    id INTEGER PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE,
+    steps TEXT NOT NULL,                    -- JSON: [{"delay_minutes", "channel_ids"}]
+    repeat_minutes INTEGER NOT NULL DEFAULT 0,  -- 0 disables repeats
+    created_at TEXT DEFAULT (datetime('now')),
+    updated_at TEXT
+);
+
+-- Replaces notification_channels when set. No REFERENCES clause so the
+-- column can be dropped again; policy deletion clears it explicitly.
+ALTER TABLE probe_configs ADD COLUMN escalation_policy_id INTEGER;
+
+-- One row per config that is currently escalating
+CREATE TABLE escalations (
+    probe_config_id INTEGER PRIMARY KEY REFERENCES probe_configs(id) ON DELETE CASCADE,
+    started_at TEXT NOT NULL,
+    steps_notified INTEGER NOT NULL DEFAULT 0,
+    last_notified_at TEXT,
+    acknowledged_at TEXT
+);
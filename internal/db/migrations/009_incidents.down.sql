@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_incident_notes_incident;
+DROP TABLE IF EXISTS incident_notes;
+DROP INDEX IF EXISTS idx_incidents_opened;
+DROP INDEX IF EXISTS idx_incidents_open;
+DROP TABLE IF EXISTS incidents;
@@ -0,0 +1,25 @@
+-- Incidents: a config's non-ok period, from the first non-ok result to recovery
+CREATE TABLE incidents (
+    id INTEGER PRIMARY KEY,
+    probe_config_id INTEGER NOT NULL REFERENCES probe_configs(id) ON DELETE CASCADE,
+    status TEXT NOT NULL,               -- critical once critical, otherwise the latest status
+    message TEXT,                       -- message of the result that opened it
+    opened_at TEXT NOT NULL,
+    acknowledged_at TEXT,
+    acknowledged_by TEXT,
+    resolved_at TEXT,
+    ack_token TEXT NOT NULL UNIQUE      -- secret for acknowledgement links in notifications
+);
+
+CREATE UNIQUE INDEX idx_incidents_open ON incidents(probe_config_id) WHERE resolved_at IS NULL;
+CREATE INDEX idx_incidents_opened ON incidents(opened_at DESC);
+
+CREATE TABLE incident_notes (
+    id INTEGER PRIMARY KEY,
+    incident_id INTEGER NOT NULL REFERENCES incidents(id) ON DELETE CASCADE,
+    author TEXT,
+    note TEXT NOT NULL,
+    created_at TEXT DEFAULT (datetime('now'))
+);
+
+CREATE INDEX idx_incident_notes_incident ON incident_notes(incident_id);
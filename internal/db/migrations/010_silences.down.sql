@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_silences_ends;
+DROP TABLE IF EXISTS silences;
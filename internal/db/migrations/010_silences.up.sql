@@ -0,0 +1,17 @@
+-- Silences suppress probe notifications between starts_at and ends_at for
+-- the configs matching all of their set matchers
+CREATE TABLE silences (
+    id INTEGER PRIMARY KEY,
+    starts_at TEXT NOT NULL,
+    ends_at TEXT NOT NULL,
+    comment TEXT,
+    created_by TEXT,
+    probe_config_id INTEGER REFERENCES probe_configs(id) ON DELETE CASCADE,
+    group_path TEXT,                    -- matches the group and its subgroups
+    keyword TEXT,
+    watcher_id INTEGER REFERENCES watchers(id) ON DELETE CASCADE,
+    probe_type TEXT,                    -- probe type name
+    created_at TEXT DEFAULT (datetime('now'))
+);
+
+CREATE INDEX idx_silences_ends ON silences(ends_at);
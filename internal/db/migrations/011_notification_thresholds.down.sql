@@ -0,0 +1,5 @@
+ALTER TABLE probe_configs DROP COLUMN flapping_since;
+ALTER TABLE probe_configs DROP COLUMN confirmed_status;
+ALTER TABLE probe_configs DROP COLUMN flap_threshold;
+ALTER TABLE probe_configs DROP COLUMN flap_window;
+ALTER TABLE probe_configs DROP COLUMN consecutive_results;
@@ -0,0 +1,18 @@
+-- A status change is only notified once this many consecutive results agree
+ALTER TABLE probe_configs ADD COLUMN consecutive_results INTEGER NOT NULL DEFAULT 1;
+
+-- Flap detection: a config is flapping once its last flap_window results
+-- changed status at least flap_threshold times. 0 disables it.
+ALTER TABLE probe_configs ADD COLUMN flap_window INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE probe_configs ADD COLUMN flap_threshold INTEGER NOT NULL DEFAULT 0;
+
+-- Status that notifications were last based on, and start of the current
+-- flapping period
+ALTER TABLE probe_configs ADD COLUMN confirmed_status TEXT;
+ALTER TABLE probe_configs ADD COLUMN flapping_since TEXT;
+
+-- Start from the latest result so existing configs don't notify again
+UPDATE probe_configs SET confirmed_status = (
+    SELECT status FROM probe_results WHERE probe_config_id = probe_configs.id
+    ORDER BY executed_at DESC LIMIT 1
+);
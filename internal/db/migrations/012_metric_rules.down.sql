@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_metric_rules_type;
+DROP INDEX IF EXISTS idx_metric_rules_config;
+DROP TABLE IF EXISTS metric_rules;
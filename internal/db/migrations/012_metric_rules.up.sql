@@ -0,0 +1,18 @@
+-- Alert rules on numeric metrics, evaluated by the web service as results
+-- arrive, e.g. free_percent < 15 for 10m -> warning
+CREATE TABLE metric_rules (
+    id INTEGER PRIMARY KEY,
+    probe_config_id INTEGER REFERENCES probe_configs(id) ON DELETE CASCADE,
+    probe_type TEXT,                        -- probe type name; applies to all its configs
+    metric TEXT NOT NULL,
+    operator TEXT NOT NULL,                 -- <, <=, >, >=, ==, !=
+    threshold REAL NOT NULL,
+    for_seconds INTEGER NOT NULL DEFAULT 0, -- how long the condition must hold
+    status TEXT NOT NULL,                   -- warning or critical
+    mode TEXT NOT NULL DEFAULT 'derive',    -- derive: raise the probe's status; override: replace it
+    created_at TEXT DEFAULT (datetime('now')),
+    updated_at TEXT
+);
+
+CREATE INDEX idx_metric_rules_config ON metric_rules(probe_config_id) WHERE probe_config_id IS NOT NULL;
+CREATE INDEX idx_metric_rules_type ON metric_rules(probe_type) WHERE probe_type IS NOT NULL;
@@ -0,0 +1,36 @@
+-- Flag results whose metrics or duration deviate from their own history by
+-- more than this many standard deviations. 0 disables it.
+ALTER TABLE probe_configs ADD COLUMN anomaly_threshold REAL NOT NULL DEFAULT 0;
+
+-- JSON: [{"metric", "value", "baseline", "stddev", "score"}]
+ALTER TABLE probe_results ADD COLUMN anomalies TEXT;
+
+-- Rolling baseline of each metric of a config: exponentially weighted
+-- moving average and variance
+CREATE TABLE metric_baselines (
+    probe_config_id INTEGER NOT NULL REFERENCES probe_configs(id) ON DELETE CASCADE,
+    metric TEXT NOT NULL,
+    mean REAL NOT NULL,
+    variance REAL NOT NULL,
+    samples INTEGER NOT NULL,
+    updated_at TEXT,
+    PRIMARY KEY (probe_config_id, metric)
+);
+
+DROP VIEW probe_result_history;
+CREATE VIEW probe_result_history AS
+SELECT id, probe_config_id, status, message, metrics, data, duration_ms,
+       scheduled_at, executed_at, recorded_at, NULL AS rollup, anomalies
+FROM probe_results
+UNION ALL
+SELECT -id, probe_config_id, status, NULL, metrics, NULL, CAST(ROUND(duration_avg_ms) AS INTEGER),
+       NULL, bucket_start, NULL,
+       json_object(
+           'bucket_seconds', bucket_seconds,
+           'count', result_count,
+           'status_counts', json_object('ok', ok_count, 'warning', warning_count,
+                                        'critical', critical_count, 'unknown', unknown_count),
+           'duration_ms', json_object('min', duration_min_ms, 'max', duration_max_ms, 'avg', duration_avg_ms),
+           'metrics', json(COALESCE(metric_stats, '{}'))),
+       NULL
+FROM probe_result_rollups;
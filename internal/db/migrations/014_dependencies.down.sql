@@ -0,0 +1,24 @@
+DROP VIEW probe_result_history;
+CREATE VIEW probe_result_history AS
+SELECT id, probe_config_id, status, message, metrics, data, duration_ms,
+       scheduled_at, executed_at, recorded_at, NULL AS rollup, anomalies
+FROM probe_results
+UNION ALL
+SELECT -id, probe_config_id, status, NULL, metrics, NULL, CAST(ROUND(duration_avg_ms) AS INTEGER),
+       NULL, bucket_start, NULL,
+       json_object(
+           'bucket_seconds', bucket_seconds,
+           'count', result_count,
+           'status_counts', json_object('ok', ok_count, 'warning', warning_count,
+                                        'critical', critical_count, 'unknown', unknown_count),
+           'duration_ms', json_object('min', duration_min_ms, 'max', duration_max_ms, 'avg', duration_avg_ms),
+           'metrics', json(COALESCE(metric_stats, '{}'))),
+       NULL
+FROM probe_result_rollups;
+
+ALTER TABLE probe_results DROP COLUMN suppressed_by;
+DROP TABLE IF EXISTS composite_members;
+DELETE FROM probe_configs WHERE composite_mode IS NOT NULL;
+ALTER TABLE probe_configs DROP COLUMN composite_quorum;
+ALTER TABLE probe_configs DROP COLUMN composite_mode;
+DROP TABLE IF EXISTS probe_dependencies;
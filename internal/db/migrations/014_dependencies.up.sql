@@ -0,0 +1,46 @@
+-- While a config that another one depends on fails, the failures of the
+-- dependent config are recorded as suppressed and not notified
+CREATE TABLE probe_dependencies (
+    probe_config_id INTEGER NOT NULL REFERENCES probe_configs(id) ON DELETE CASCADE,
+    depends_on_id INTEGER NOT NULL REFERENCES probe_configs(id) ON DELETE CASCADE,
+    created_at TEXT DEFAULT (datetime('now')),
+    PRIMARY KEY (probe_config_id, depends_on_id),
+    CHECK (probe_config_id <> depends_on_id)
+);
+
+CREATE INDEX idx_probe_dependencies_depends_on ON probe_dependencies(depends_on_id);
+
+-- Composite configs have no watcher; their status is computed from the
+-- latest results of their members
+ALTER TABLE probe_configs ADD COLUMN composite_mode TEXT;  -- all, any or quorum
+ALTER TABLE probe_configs ADD COLUMN composite_quorum INTEGER NOT NULL DEFAULT 0;
+
+CREATE TABLE composite_members (
+    composite_id INTEGER NOT NULL REFERENCES probe_configs(id) ON DELETE CASCADE,
+    member_id INTEGER NOT NULL REFERENCES probe_configs(id) ON DELETE CASCADE,
+    PRIMARY KEY (composite_id, member_id),
+    CHECK (composite_id <> member_id)
+);
+
+CREATE INDEX idx_composite_members_member ON composite_members(member_id);
+
+-- The failing config a suppressed result depends on
+ALTER TABLE probe_results ADD COLUMN suppressed_by INTEGER;
+
+DROP VIEW probe_result_history;
+CREATE VIEW probe_result_history AS
+SELECT id, probe_config_id, status, message, metrics, data, duration_ms,
+       scheduled_at, executed_at, recorded_at, NULL AS rollup, anomalies, suppressed_by
+FROM probe_results
+UNION ALL
+SELECT -id, probe_config_id, status, NULL, metrics, NULL, CAST(ROUND(duration_avg_ms) AS INTEGER),
+       NULL, bucket_start, NULL,
+       json_object(
+           'bucket_seconds', bucket_seconds,
+           'count', result_count,
+           'status_counts', json_object('ok', ok_count, 'warning', warning_count,
+                                        'critical', critical_count, 'unknown', unknown_count),
+           'duration_ms', json_object('min', duration_min_ms, 'max', duration_max_ms, 'avg', duration_avg_ms),
+           'metrics', json(COALESCE(metric_stats, '{}'))),
+       NULL, NULL
+FROM probe_result_rollups;
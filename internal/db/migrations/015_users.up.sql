@@ -0,0 +1,37 @@
+-- User accounts. Passwords are stored as PBKDF2 hashes.
+CREATE TABLE users (
+    id INTEGER PRIMARY KEY,
+    username TEXT UNIQUE NOT NULL,
+    password_hash TEXT NOT NULL,
+    role TEXT NOT NULL,                 -- viewer, operator or admin
+    created_at TEXT DEFAULT (datetime('now')),
+    updated_at TEXT,
+    last_login_at TEXT
+);
+
+-- Login sessions of the web frontend, identified by a cookie. Only the
+-- SHA-256 hash of the cookie value is stored.
+CREATE TABLE sessions (
+    token_hash TEXT PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TEXT DEFAULT (datetime('now')),
+    expires_at TEXT NOT NULL
+);
+
+CREATE INDEX idx_sessions_user ON sessions(user_id);
+
+-- Personal API tokens for scripts. A token can have a lower role than its
+-- user; it never has a higher one.
+CREATE TABLE api_tokens (
+    id INTEGER PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    token_hash TEXT UNIQUE NOT NULL,
+    prefix TEXT NOT NULL,               -- start of the token, to tell them apart
+    role TEXT,                          -- NULL: the user's role
+    created_at TEXT DEFAULT (datetime('now')),
+    expires_at TEXT,
+    last_used_at TEXT
+);
+
+CREATE INDEX idx_api_tokens_user ON api_tokens(user_id);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_users_oidc;
+DELETE FROM users WHERE oidc_subject IS NOT NULL;
+ALTER TABLE users DROP COLUMN oidc_subject;
+ALTER TABLE users DROP COLUMN oidc_issuer;
@@ -0,0 +1,7 @@
+-- Users who sign in with OpenID Connect are linked to the identity
+-- provider by the issuer and subject of their ID token. They have an empty
+-- password_hash, so they can't sign in with a password.
+ALTER TABLE users ADD COLUMN oidc_issuer TEXT;
+ALTER TABLE users ADD COLUMN oidc_subject TEXT;
+
+CREATE UNIQUE INDEX idx_users_oidc ON users(oidc_issuer, oidc_subject);
@@ -0,0 +1,27 @@
+-- Who changed what, for configs, watchers, notification settings and
+-- users. Rows are only ever added.
+CREATE TABLE audit_log (
+    id INTEGER PRIMARY KEY,
+    created_at TEXT NOT NULL DEFAULT (datetime('now')),
+    actor TEXT NOT NULL,                -- username, or auth-token for the shared token
+    actor_role TEXT,
+    action TEXT NOT NULL,               -- create, update, delete, enable, pause, ...
+    target_type TEXT NOT NULL,          -- probe_config, watcher, notification_channel, ...
+    target_id INTEGER,                  -- no foreign key: entries outlive their targets
+    target_name TEXT,
+    before TEXT,                        -- JSON of the changed fields before the change
+    after TEXT                          -- JSON of the changed fields after it
+);
+
+CREATE INDEX idx_audit_log_target ON audit_log(target_type, target_id);
+CREATE INDEX idx_audit_log_created ON audit_log(created_at);
+
+CREATE TRIGGER audit_log_no_update BEFORE UPDATE ON audit_log
+BEGIN
+    SELECT RAISE(ABORT, 'audit_log is append-only');
+END;
+
+CREATE TRIGGER audit_log_no_delete BEFORE DELETE ON audit_log
+BEGIN
+    SELECT RAISE(ABORT, 'audit_log is append-only');
+END;
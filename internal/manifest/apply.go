@@ -0,0 +1,310 @@
+package manifest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+)
+
+var pastTense = map[Action]string{
+	ActionCreate:  "Created",
+	ActionUpdate:  "Updated",
+	ActionReplace: "Replaced",
+	ActionDelete:  "Deleted",
+}
+
+// applier carries the IDs of objects while a plan is applied, including
+// those of objects it creates.
+type applier struct {
+	plan       *Plan
+	client     *Client
+	out        io.Writer
+	changes    map[string]Change // By kind and name
+	channelIDs map[string]int
+	probeIDs   map[string]int
+}
+
+// Apply makes the changes of the plan and reports each one to out. It
+// stops at the first error; applying the file again continues from there.
+// Channels come first so probes can use them, then probes before the
+// composites that contain them, then dependencies, and deletions last.
+func (plan *Plan) Apply(ctx context.Context, c *Client, out io.Writer) error {
+	a := &applier{
+		plan:       plan,
+		client:     c,
+		out:        out,
+		changes:    map[string]Change{},
+		channelIDs: map[string]int{},
+		probeIDs:   map[string]int{},
+	}
+	for _, change := range plan.Changes {
+		a.changes[change.Kind+"/"+change.Name] = change
+	}
+	for _, ch := range plan.state.Channels {
+		a.channelIDs[ch.Name] = ch.ID
+	}
+	for _, p := range plan.state.Probes {
+		a.probeIDs[p.Name] = p.ID
+	}
+
+	for _, ch := range plan.file.NotificationChannels {
+		if err := a.applyChannel(ctx, ch.normalized()); err != nil {
+			return fmt.Errorf("notification channel %q: %w", ch.Name, err)
+		}
+	}
+
+	var composites []Probe
+	for _, p := range plan.file.Probes {
+		p = p.normalized()
+		if p.Composite != nil {
+			composites = append(composites, p)
+			continue
+		}
+		if err := a.applyProbe(ctx, p); err != nil {
+			return fmt.Errorf("probe %q: %w", p.Name, err)
+		}
+	}
+	if err := a.applyComposites(ctx, composites); err != nil {
+		return err
+	}
+
+	for _, p := range plan.file.Probes {
+		if err := a.applyDependencies(ctx, p.normalized()); err != nil {
+			return fmt.Errorf("probe %q: %w", p.Name, err)
+		}
+	}
+
+	for _, change := range plan.Changes {
+		if change.Action != ActionDelete {
+			continue
+		}
+		path := "/api/notification-channels/" + strconv.Itoa(a.channelIDs[change.Name])
+		if change.Kind == KindProbe {
+			path = "/api/probe-configs/" + strconv.Itoa(a.probeIDs[change.Name])
+		}
+		if err := c.do(ctx, http.MethodDelete, path, nil, nil); err != nil {
+			return fmt.Errorf("%s %q: %w", change.Kind, change.Name, err)
+		}
+		a.report(change)
+	}
+	return nil
+}
+
+func (a *applier) report(change Change) {
+	fmt.Fprintf(a.out, "%s %s %q\n", pastTense[change.Action], change.Kind, change.Name)
+}
+
+func (a *applier) applyChannel(ctx context.Context, ch Channel) error {
+	change, ok := a.changes[KindChannel+"/"+ch.Name]
+	if !ok {
+		return nil
+	}
+	config := ch.Config
+	if config == nil {
+		config = map[string]any{}
+	}
+	body := map[string]any{"name": ch.Name, "type": ch.Type, "config": config, "enabled": *ch.Enabled}
+
+	if change.Action == ActionCreate {
+		id, err := a.create(ctx, "/api/notification-channels", body)
+		if err != nil {
+			return err
+		}
+		a.channelIDs[ch.Name] = id
+	} else if err := a.client.do(ctx, http.MethodPut, "/api/notification-channels/"+strconv.Itoa(a.channelIDs[ch.Name]), body, nil); err != nil {
+		return err
+	}
+	a.report(change)
+	return nil
+}
+
+func (a *applier) applyProbe(ctx context.Context, p Probe) error {
+	change, ok := a.changes[KindProbe+"/"+p.Name]
+	if !ok {
+		return nil
+	}
+	if change.Action == ActionReplace {
+		if err := a.deleteProbe(ctx, p.Name); err != nil {
+			return err
+		}
+	}
+
+	body := a.commonFields(p)
+	body["watcher_id"] = a.watcherID(p.Watcher)
+	body["arguments"] = p.Arguments
+	if p.Arguments == nil {
+		body["arguments"] = map[string]any{}
+	}
+	body["interval"] = p.Interval
+	body["timezone"] = optional(p.Timezone)
+	body["active_windows"] = p.ActiveWindows
+	body["timeout_seconds"] = p.TimeoutSeconds
+	body["flap_window"] = p.FlapWindow
+	body["flap_threshold"] = p.FlapThreshold
+	body["anomaly_threshold"] = p.AnomalyThreshold
+
+	if change.Action == ActionUpdate {
+		if err := a.client.do(ctx, http.MethodPut, "/api/probe-configs/"+strconv.Itoa(a.probeIDs[p.Name]), body, nil); err != nil {
+			return err
+		}
+	} else {
+		body["probe_type_id"] = a.plan.probeTypes[p.Name]
+		id, err := a.create(ctx, "/api/probe-configs", body)
+		if err != nil {
+			return err
+		}
+		a.probeIDs[p.Name] = id
+	}
+	a.report(change)
+	return nil
+}
+
+// applyComposites creates and updates composites once their members exist.
+// Composites whose members were replaced need their members set again.
+func (a *applier) applyComposites(ctx context.Context, composites []Probe) error {
+	var pending []Probe
+	for _, p := range composites {
+		_, changed := a.changes[KindProbe+"/"+p.Name]
+		if changed || slices.ContainsFunc(p.Composite.Members, func(m string) bool { return a.replaces(m) }) {
+			pending = append(pending, p)
+		}
+	}
+
+	for len(pending) > 0 {
+		waiting := map[string]bool{}
+		for _, p := range pending {
+			waiting[p.Name] = true
+		}
+		var next []Probe
+		for _, p := range pending {
+			if slices.ContainsFunc(p.Composite.Members, func(m string) bool { return waiting[m] }) {
+				next = append(next, p)
+				continue
+			}
+			if err := a.applyComposite(ctx, p); err != nil {
+				return fmt.Errorf("probe %q: %w", p.Name, err)
+			}
+		}
+		if len(next) == len(pending) {
+			return errors.New("composites contain each other")
+		}
+		pending = next
+	}
+	return nil
+}
+
+func (a *applier) applyComposite(ctx context.Context, p Probe) error {
+	change, ok := a.changes[KindProbe+"/"+p.Name]
+	if !ok {
+		change = Change{Action: ActionUpdate, Kind: KindProbe, Name: p.Name}
+	}
+	if change.Action == ActionReplace {
+		if err := a.deleteProbe(ctx, p.Name); err != nil {
+			return err
+		}
+	}
+
+	body := a.commonFields(p)
+	body["mode"] = p.Composite.Mode
+	body["quorum"] = p.Composite.Quorum
+	body["members"] = a.ids(p.Composite.Members)
+
+	if change.Action == ActionUpdate {
+		if err := a.client.do(ctx, http.MethodPut, "/api/composites/"+strconv.Itoa(a.probeIDs[p.Name]), body, nil); err != nil {
+			return err
+		}
+	} else {
+		id, err := a.create(ctx, "/api/composites", body)
+		if err != nil {
+			return err
+		}
+		a.probeIDs[p.Name] = id
+	}
+	a.report(change)
+	return nil
+}
+
+// applyDependencies sets the dependencies of a probe if they changed, or if
+// the probe or one it depends on was recreated.
+func (a *applier) applyDependencies(ctx context.Context, p Probe) error {
+	change, changed := a.changes[KindProbe+"/"+p.Name]
+	switch {
+	case changed && change.Action == ActionUpdate:
+		changed = slices.ContainsFunc(change.Fields, func(f FieldChange) bool { return f.Field == "depends_on" })
+	case changed:
+		changed = len(p.DependsOn) > 0
+	}
+	if !changed && !slices.ContainsFunc(p.DependsOn, func(d string) bool { return a.replaces(d) }) {
+		return nil
+	}
+	body := map[string]any{"depends_on": a.ids(p.DependsOn)}
+	return a.client.do(ctx, http.MethodPut, "/api/probe-configs/"+strconv.Itoa(a.probeIDs[p.Name])+"/dependencies", body, nil)
+}
+
+// commonFields returns the request fields that probes and composites share.
+func (a *applier) commonFields(p Probe) map[string]any {
+	channels := []int{}
+	for _, name := range p.NotificationChannels {
+		channels = append(channels, a.channelIDs[name])
+	}
+	var policyID *int
+	if id, ok := a.plan.state.policies[p.EscalationPolicy]; ok && p.EscalationPolicy != "" {
+		policyID = &id
+	}
+	return map[string]any{
+		"name":                  p.Name,
+		"enabled":               *p.Enabled,
+		"notification_channels": channels,
+		"escalation_policy_id":  policyID,
+		"consecutive_results":   p.ConsecutiveResults,
+		"group_path":            optional(p.Group),
+		"keywords":              p.Keywords,
+	}
+}
+
+func (a *applier) deleteProbe(ctx context.Context, name string) error {
+	return a.client.do(ctx, http.MethodDelete, "/api/probe-configs/"+strconv.Itoa(a.probeIDs[name]), nil, nil)
+}
+
+// replaces reports whether the plan recreates a probe, which drops it from
+// composites and dependencies.
+func (a *applier) replaces(name string) bool {
+	return a.changes[KindProbe+"/"+name].Action == ActionReplace
+}
+
+func (a *applier) create(ctx context.Context, path string, body any) (int, error) {
+	var created struct {
+		ID int `json:"id"`
+	}
+	if err := a.client.do(ctx, http.MethodPost, path, body, &created); err != nil {
+		return 0, err
+	}
+	return created.ID, nil
+}
+
+func (a *applier) watcherID(name string) *int {
+	if id, ok := a.plan.state.watchers[name]; ok && name != "" {
+		return &id
+	}
+	return nil
+}
+
+func (a *applier) ids(names []string) []int {
+	ids := []int{}
+	for _, name := range names {
+		ids = append(ids, a.probeIDs[name])
+	}
+	return ids
+}
+
+// optional returns nil for an empty string, which the API stores as NULL.
+func optional(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
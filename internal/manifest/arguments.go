@@ -0,0 +1,70 @@
+package manifest
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+
+	"github.com/jandubois/monitor/internal/probe"
+)
+
+// ValidateArguments checks arguments against the schema a probe type
+// describes: required arguments are set, there are no unknown ones, and
+// values have the described type and are one of the allowed values.
+func ValidateArguments(args map[string]any, schema probe.Arguments) error {
+	var errs []error
+	for _, name := range sortedKeys(schema.Required) {
+		if _, ok := args[name]; !ok {
+			errs = append(errs, fmt.Errorf("argument %s is required", name))
+		}
+	}
+	for _, name := range sortedKeys(args) {
+		spec, ok := schema.Required[name]
+		if !ok {
+			spec, ok = schema.Optional[name]
+		}
+		if !ok {
+			errs = append(errs, fmt.Errorf("unknown argument %s", name))
+			continue
+		}
+		if err := checkArgument(args[name], spec); err != nil {
+			errs = append(errs, fmt.Errorf("argument %s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func checkArgument(value any, spec probe.ArgumentSpec) error {
+	switch spec.Type {
+	case "string":
+		s, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("expected a string, got %v", value)
+		}
+		if len(spec.Enum) > 0 && !slices.Contains(spec.Enum, s) {
+			return fmt.Errorf("%q is not one of %v", s, spec.Enum)
+		}
+	case "number":
+		switch value.(type) {
+		case int, int64, uint64, float64:
+		default:
+			return fmt.Errorf("expected a number, got %v", value)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("expected true or false, got %v", value)
+		}
+	}
+	// Probes may describe other types; those aren't checked
+	return nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
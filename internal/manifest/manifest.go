@@ -0,0 +1,348 @@
+// Package manifest keeps probe configs and notification channels in a YAML
+// file. Objects are keyed by name, and refer to each other, to watchers, to
+// probe types and to escalation policies by name, so a file stays valid
+// across installations whose IDs differ.
+package manifest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Defaults the web service applies to omitted fields.
+const (
+	defaultTimeoutSeconds     = 60
+	defaultConsecutiveResults = 1
+)
+
+// File is the declarative form of a monitor's configuration.
+type File struct {
+	NotificationChannels []Channel `yaml:"notification_channels,omitempty"`
+	Probes               []Probe   `yaml:"probes,omitempty"`
+}
+
+// Channel is a notification channel. ${NAME} in the string values of its
+// config is replaced by the environment variable, so that credentials can
+// stay out of the file.
+type Channel struct {
+	Name    string         `yaml:"name"`
+	Type    string         `yaml:"type"`
+	Enabled *bool          `yaml:"enabled,omitempty"` // Defaults to true
+	Config  map[string]any `yaml:"config,omitempty"`
+}
+
+// Probe is a probe config. Composites have a Composite instead of a probe
+// type, schedule and arguments.
+type Probe struct {
+	Name                 string         `yaml:"name"`
+	Type                 string         `yaml:"type,omitempty"`
+	Watcher              string         `yaml:"watcher,omitempty"` // Empty for any watcher with the probe type
+	Group                string         `yaml:"group,omitempty"`   // Group path, such as prod/db
+	Keywords             []string       `yaml:"keywords,omitempty"`
+	Enabled              *bool          `yaml:"enabled,omitempty"`  // Defaults to true
+	Interval             string         `yaml:"interval,omitempty"` // Interval or cron expression
+	Timezone             string         `yaml:"timezone,omitempty"`
+	ActiveWindows        []string       `yaml:"active_windows,omitempty"`
+	TimeoutSeconds       int            `yaml:"timeout_seconds,omitempty"` // Defaults to 60
+	Arguments            map[string]any `yaml:"arguments,omitempty"`
+	NotificationChannels []string       `yaml:"notification_channels,omitempty"`
+	EscalationPolicy     string         `yaml:"escalation_policy,omitempty"`
+	ConsecutiveResults   int            `yaml:"consecutive_results,omitempty"` // Defaults to 1
+	FlapWindow           int            `yaml:"flap_window,omitempty"`
+	FlapThreshold        int            `yaml:"flap_threshold,omitempty"`
+	AnomalyThreshold     float64        `yaml:"anomaly_threshold,omitempty"`
+	DependsOn            []string       `yaml:"depends_on,omitempty"`
+	Composite            *Composite     `yaml:"composite,omitempty"`
+}
+
+// Composite computes a probe's status from other probes.
+type Composite struct {
+	Mode    string   `yaml:"mode"`             // all, any or quorum
+	Quorum  int      `yaml:"quorum,omitempty"` // Members that must be ok in quorum mode
+	Members []string `yaml:"members"`
+}
+
+// Load reads a file; "-" reads standard input.
+func Load(path string) (*File, error) {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	f, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return f, nil
+}
+
+// Parse decodes and validates a file, and expands environment variables in
+// channel configs. Unknown fields are errors, so typos don't go unnoticed.
+func Parse(data []byte) (*File, error) {
+	var f File
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&f); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	if err := f.expandEnv(); err != nil {
+		return nil, err
+	}
+	if err := f.Validate(); err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// envReference is a reference to an environment variable in a channel
+// config. Only the braced form is expanded, so a lone $ stays as it is.
+var envReference = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// expandEnv replaces the references to environment variables in channel
+// configs. Variables that aren't set are errors.
+func (f *File) expandEnv() error {
+	var errs []error
+	for _, c := range f.NotificationChannels {
+		for key, value := range c.Config {
+			expanded, err := expandEnv(value)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("notification channel %q: %s: %w", c.Name, key, err))
+				continue
+			}
+			c.Config[key] = expanded
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func expandEnv(value any) (any, error) {
+	switch value := value.(type) {
+	case string:
+		var missing string
+		expanded := envReference.ReplaceAllStringFunc(value, func(ref string) string {
+			name := envReference.FindStringSubmatch(ref)[1]
+			v, ok := os.LookupEnv(name)
+			if !ok && missing == "" {
+				missing = name
+			}
+			return v
+		})
+		if missing != "" {
+			return nil, fmt.Errorf("environment variable %s is not set", missing)
+		}
+		return expanded, nil
+	case map[string]any:
+		for k, v := range value {
+			expanded, err := expandEnv(v)
+			if err != nil {
+				return nil, err
+			}
+			value[k] = expanded
+		}
+	case []any:
+		for i, v := range value {
+			expanded, err := expandEnv(v)
+			if err != nil {
+				return nil, err
+			}
+			value[i] = expanded
+		}
+	}
+	return value, nil
+}
+
+// Marshal encodes a file as YAML.
+func (f *File) Marshal() ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(f); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Validate checks the structure of a file: names are set and unique, and
+// references between probes resolve. References to channels, watchers,
+// probe types and escalation policies are checked against the web service
+// when planning.
+func (f *File) Validate() error {
+	var errs []error
+	channels := map[string]bool{}
+	for i, c := range f.NotificationChannels {
+		switch {
+		case c.Name == "":
+			errs = append(errs, fmt.Errorf("notification channel %d: name is required", i+1))
+		case channels[c.Name]:
+			errs = append(errs, fmt.Errorf("notification channel %q: duplicate name", c.Name))
+		case c.Type == "":
+			errs = append(errs, fmt.Errorf("notification channel %q: type is required", c.Name))
+		}
+		channels[c.Name] = true
+	}
+
+	probes := map[string]bool{}
+	for i, p := range f.Probes {
+		if p.Name == "" {
+			errs = append(errs, fmt.Errorf("probe %d: name is required", i+1))
+			continue
+		}
+		if probes[p.Name] {
+			errs = append(errs, fmt.Errorf("probe %q: duplicate name", p.Name))
+		}
+		probes[p.Name] = true
+	}
+
+	for _, p := range f.Probes {
+		if p.Name == "" {
+			continue
+		}
+		if err := p.validate(probes); err != nil {
+			errs = append(errs, fmt.Errorf("probe %q: %w", p.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (p *Probe) validate(probes map[string]bool) error {
+	for _, name := range p.DependsOn {
+		if name == p.Name {
+			return errors.New("a probe cannot depend on itself")
+		}
+		if !probes[name] {
+			return fmt.Errorf("dependency %q is not a probe in the file", name)
+		}
+	}
+	if p.Composite == nil {
+		if p.Type == "" {
+			return errors.New("type is required")
+		}
+		if p.Interval == "" {
+			return errors.New("interval is required")
+		}
+		return nil
+	}
+
+	switch {
+	case p.Type != "" || p.Watcher != "" || p.Interval != "" || p.Timezone != "" || len(p.ActiveWindows) > 0 ||
+		p.TimeoutSeconds != 0 || len(p.Arguments) > 0:
+		return errors.New("composites have no type, watcher, schedule, timeout or arguments")
+	case p.FlapWindow != 0 || p.FlapThreshold != 0 || p.AnomalyThreshold != 0:
+		return errors.New("composites have no flap detection or anomaly threshold")
+	case len(p.Composite.Members) == 0:
+		return errors.New("composite members are required")
+	}
+	for _, name := range p.Composite.Members {
+		if name == p.Name {
+			return errors.New("a composite cannot contain itself")
+		}
+		if !probes[name] {
+			return fmt.Errorf("composite member %q is not a probe in the file", name)
+		}
+	}
+	switch p.Composite.Mode {
+	case "all", "any":
+	case "quorum":
+		if p.Composite.Quorum < 1 || p.Composite.Quorum > len(p.Composite.Members) {
+			return fmt.Errorf("quorum must be between 1 and %d", len(p.Composite.Members))
+		}
+	default:
+		return errors.New("composite mode must be all, any or quorum")
+	}
+	return nil
+}
+
+// normalized fills in defaults and sorts unordered lists, so that two
+// probes that configure the same thing compare equal.
+func (p Probe) normalized() Probe {
+	if p.Enabled == nil {
+		p.Enabled = boolPtr(true)
+	}
+	if p.TimeoutSeconds == 0 && p.Composite == nil {
+		p.TimeoutSeconds = defaultTimeoutSeconds
+	}
+	if p.ConsecutiveResults == 0 {
+		p.ConsecutiveResults = defaultConsecutiveResults
+	}
+	if len(p.Arguments) == 0 {
+		p.Arguments = nil
+	}
+	p.Keywords = emptyToNil(p.Keywords)
+	p.ActiveWindows = emptyToNil(p.ActiveWindows)
+	p.NotificationChannels = sorted(p.NotificationChannels)
+	p.DependsOn = sorted(p.DependsOn)
+	if p.Composite != nil {
+		c := *p.Composite
+		c.Members = sorted(c.Members)
+		if c.Mode != "quorum" {
+			c.Quorum = 0
+		}
+		p.Composite = &c
+	}
+	return p
+}
+
+// compact leaves out the fields that have their default value, for export.
+func (p Probe) compact() Probe {
+	p = p.normalized()
+	if *p.Enabled {
+		p.Enabled = nil
+	}
+	if p.TimeoutSeconds == defaultTimeoutSeconds {
+		p.TimeoutSeconds = 0
+	}
+	if p.ConsecutiveResults == defaultConsecutiveResults {
+		p.ConsecutiveResults = 0
+	}
+	return p
+}
+
+func (c Channel) normalized() Channel {
+	if c.Enabled == nil {
+		c.Enabled = boolPtr(true)
+	}
+	if len(c.Config) == 0 {
+		c.Config = nil
+	}
+	return c
+}
+
+func (c Channel) compact() Channel {
+	c = c.normalized()
+	if *c.Enabled {
+		c.Enabled = nil
+	}
+	return c
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+func emptyToNil(s []string) []string {
+	if len(s) == 0 {
+		return nil
+	}
+	return s
+}
+
+func sorted(s []string) []string {
+	if len(s) == 0 {
+		return nil
+	}
+	s = slices.Clone(s)
+	slices.Sort(s)
+	return s
+}
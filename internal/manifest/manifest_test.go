@@ -0,0 +1,149 @@
+package manifest
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jandubois/monitor/internal/probe"
+)
+
+const testFile = `
+notification_channels:
+  - name: ops
+    type: ntfy
+    config:
+      topic: ops
+probes:
+  - name: root-disk
+    type: disk-space
+    watcher: nas
+    group: prod/storage
+    interval: 5m
+    arguments:
+      path: /
+      min_free_percent: 10
+    notification_channels: [ops]
+  - name: web
+    type: http
+    interval: 1m
+    arguments:
+      url: https://example.com
+    depends_on: [root-disk]
+  - name: site
+    composite:
+      mode: all
+      members: [root-disk, web]
+`
+
+func TestParse(t *testing.T) {
+	f, err := Parse([]byte(testFile))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if len(f.NotificationChannels) != 1 || len(f.Probes) != 3 {
+		t.Fatalf("unexpected file %+v", f)
+	}
+	if f.Probes[0].Arguments["min_free_percent"] != 10 || f.Probes[2].Composite.Members[1] != "web" {
+		t.Errorf("unexpected probes %+v", f.Probes)
+	}
+
+	if _, err := Parse(nil); err != nil {
+		t.Errorf("expected an empty file to be valid, got %v", err)
+	}
+	if _, err := Parse([]byte("probes:\n  - name: x\n    intervall: 5m\n")); err == nil {
+		t.Error("expected an unknown field to be rejected")
+	}
+}
+
+func TestParseEnv(t *testing.T) {
+	t.Setenv("TEST_HOOK_SECRET", "s3cret")
+	t.Setenv("TEST_HOOK_TOKEN", "abc")
+	f, err := Parse([]byte(`
+notification_channels:
+  - name: hook
+    type: webhook
+    config:
+      url: https://example.com/$path
+      secret: ${TEST_HOOK_SECRET}
+      headers:
+        Authorization: Bearer ${TEST_HOOK_TOKEN}
+`))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	config := f.NotificationChannels[0].Config
+	headers, _ := config["headers"].(map[string]any)
+	if config["url"] != "https://example.com/$path" || config["secret"] != "s3cret" || headers["Authorization"] != "Bearer abc" {
+		t.Errorf("unexpected config %v", config)
+	}
+
+	_, err = Parse([]byte("notification_channels:\n  - {name: hook, type: webhook, config: {secret: '${TEST_HOOK_UNSET}'}}\n"))
+	if err == nil || !strings.Contains(err.Error(), "TEST_HOOK_UNSET is not set") {
+		t.Errorf("expected an error for an unset variable, got %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := map[string]string{
+		"duplicate name":     "probes:\n  - {name: a, type: http, interval: 1m}\n  - {name: a, type: http, interval: 1m}\n",
+		"no type":            "probes:\n  - {name: a, interval: 1m}\n",
+		"no interval":        "probes:\n  - {name: a, type: http}\n",
+		"unknown dependency": "probes:\n  - {name: a, type: http, interval: 1m, depends_on: [b]}\n",
+		"self dependency":    "probes:\n  - {name: a, type: http, interval: 1m, depends_on: [a]}\n",
+		"composite schedule": "probes:\n  - {name: a, type: http, interval: 1m}\n  - {name: b, interval: 1m, composite: {mode: all, members: [a]}}\n",
+		"composite member":   "probes:\n  - {name: b, composite: {mode: all, members: [a]}}\n",
+		"composite quorum":   "probes:\n  - {name: a, type: http, interval: 1m}\n  - {name: b, composite: {mode: quorum, quorum: 2, members: [a]}}\n",
+		"channel type":       "notification_channels:\n  - {name: ops}\n",
+	}
+	for name, data := range tests {
+		if _, err := Parse([]byte(data)); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestValidateArguments(t *testing.T) {
+	schema := probe.Arguments{
+		Required: map[string]probe.ArgumentSpec{
+			"url": {Type: "string"},
+		},
+		Optional: map[string]probe.ArgumentSpec{
+			"method":  {Type: "string", Enum: []string{"GET", "HEAD"}},
+			"timeout": {Type: "number"},
+			"verify":  {Type: "boolean"},
+		},
+	}
+
+	valid := map[string]any{"url": "https://example.com", "method": "HEAD", "timeout": 5, "verify": false}
+	if err := ValidateArguments(valid, schema); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	invalid := map[string]any{"method": "POST", "timeout": "5s", "verify": "yes", "retries": 3}
+	err := ValidateArguments(invalid, schema)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	for _, want := range []string{"url is required", "unknown argument retries", "method", "timeout", "verify"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q in %v", want, err)
+		}
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected int
+	}{
+		{"1.10.0", "1.9.0", 1},
+		{"1.0.0", "1.0.0", 0},
+		{"1.0", "1.0.1", -1},
+		{"2.0.0-beta", "2.0.0-alpha", 1},
+	}
+	for _, tt := range tests {
+		if got := CompareVersions(tt.a, tt.b); got != tt.expected {
+			t.Errorf("CompareVersions(%q, %q) = %d, expected %d", tt.a, tt.b, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,306 @@
+package manifest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"reflect"
+
+	"github.com/jandubois/monitor/internal/notify"
+	"gopkg.in/yaml.v3"
+)
+
+// Action is what applying a plan does to an object.
+type Action string
+
+const (
+	ActionCreate Action = "create"
+	ActionUpdate Action = "update"
+	// ActionReplace deletes and recreates a probe whose type changes, which
+	// the API can't do in place. The probe loses its results.
+	ActionReplace Action = "replace"
+	ActionDelete  Action = "delete"
+)
+
+// Kinds of objects in a plan.
+const (
+	KindChannel = "notification channel"
+	KindProbe   = "probe"
+)
+
+// Change is a planned change of one object.
+type Change struct {
+	Action Action
+	Kind   string
+	Name   string
+	Fields []FieldChange // Changed fields of updates and replacements
+}
+
+// FieldChange is a field of an object that changes. Before or After is nil
+// if the field is not set.
+type FieldChange struct {
+	Field  string
+	Before any
+	After  any
+}
+
+// Plan is the list of changes that make the web service match a file.
+type Plan struct {
+	Changes []Change
+	// Unmanaged counts the objects on the web service that aren't in the
+	// file and are kept because the plan doesn't prune.
+	Unmanaged int
+
+	file       *File
+	state      *State
+	probeTypes map[string]int // Probe type ID of probes to create, by name
+}
+
+// NewPlan compares a file to the web service's state. With prune, objects
+// that aren't in the file are deleted. It fails if the file refers to
+// watchers, probe types, channels or escalation policies that don't exist,
+// or if arguments don't match the probe type's schema.
+func NewPlan(f *File, s *State, prune bool) (*Plan, error) {
+	// Channels are applied with the credentials that the file redacts
+	file := *f
+	file.NotificationChannels = nil
+	plan := &Plan{file: &file, state: s, probeTypes: map[string]int{}}
+	var errs []error
+
+	channels := map[string]bool{}
+	for _, c := range f.NotificationChannels {
+		channels[c.Name] = true
+		c = c.normalized()
+		remote := s.channel(c.Name)
+		var current map[string]any
+		if remote != nil {
+			current = remote.Config
+		}
+		config, err := keepRedacted(c.Config, current)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("notification channel %q: %w", c.Name, err))
+			continue
+		}
+		c.Config = config
+		file.NotificationChannels = append(file.NotificationChannels, c)
+
+		if remote == nil {
+			plan.add(Change{Action: ActionCreate, Kind: KindChannel, Name: c.Name})
+		} else if fields := diffFields(remote.Channel, c); len(fields) > 0 {
+			redactFields(fields)
+			plan.add(Change{Action: ActionUpdate, Kind: KindChannel, Name: c.Name, Fields: fields})
+		}
+	}
+	for _, remote := range s.Channels {
+		if channels[remote.Name] {
+			continue
+		}
+		if prune {
+			plan.add(Change{Action: ActionDelete, Kind: KindChannel, Name: remote.Name})
+		} else {
+			// Probes in the file may still use channels that are kept
+			channels[remote.Name] = true
+			plan.Unmanaged++
+		}
+	}
+
+	probes := map[string]bool{}
+	for _, p := range f.Probes {
+		probes[p.Name] = true
+		p = p.normalized()
+		change, err := plan.planProbe(p, channels)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("probe %q: %w", p.Name, err))
+			continue
+		}
+		if change != nil {
+			plan.add(*change)
+		}
+	}
+	for _, remote := range s.Probes {
+		if probes[remote.Name] {
+			continue
+		}
+		if prune {
+			plan.add(Change{Action: ActionDelete, Kind: KindProbe, Name: remote.Name})
+		} else {
+			plan.Unmanaged++
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// keepRedacted returns a channel config with the redacted values of an
+// export replaced by the current ones, so that applying an export doesn't
+// change credentials.
+func keepRedacted(config, current map[string]any) (map[string]any, error) {
+	resolved := maps.Clone(config)
+	for key, value := range config {
+		if nested, ok := value.(map[string]any); ok {
+			currentNested, _ := current[key].(map[string]any)
+			kept, err := keepRedacted(nested, currentNested)
+			if err != nil {
+				return nil, err
+			}
+			resolved[key] = kept
+			continue
+		}
+		if value != notify.Redacted {
+			continue
+		}
+		v, ok := current[key]
+		if !ok {
+			return nil, fmt.Errorf("%s is %s, but has no current value; set it, e.g. with ${ENV}", key, notify.Redacted)
+		}
+		resolved[key] = v
+	}
+	return resolved, nil
+}
+
+// redactFields hides the credentials in the config change of a channel, so
+// that plans can be shown and logged.
+func redactFields(fields []FieldChange) {
+	for i := range fields {
+		f := &fields[i]
+		if f.Field != "config" {
+			continue
+		}
+		if before, ok := f.Before.(map[string]any); ok {
+			f.Before = notify.RedactConfig(before)
+		}
+		if after, ok := f.After.(map[string]any); ok {
+			f.After = notify.RedactConfig(after)
+		}
+	}
+}
+
+// planProbe checks the references of a probe and returns its change, or
+// nil if it is up to date.
+func (plan *Plan) planProbe(p Probe, channels map[string]bool) (*Change, error) {
+	s := plan.state
+	for _, name := range p.NotificationChannels {
+		if !channels[name] {
+			return nil, fmt.Errorf("unknown notification channel %q", name)
+		}
+	}
+	if _, ok := s.policies[p.EscalationPolicy]; p.EscalationPolicy != "" && !ok {
+		return nil, fmt.Errorf("unknown escalation policy %q", p.EscalationPolicy)
+	}
+
+	remote := s.probe(p.Name)
+	if p.Composite == nil {
+		// The API can't change the type of a config, so an existing one
+		// keeps its version
+		var t *probeType
+		if remote != nil && remote.Composite == nil && remote.Type == p.Type {
+			t = s.probeType(remote.TypeID)
+		}
+		if _, ok := s.watchers[p.Watcher]; p.Watcher != "" && !ok {
+			return nil, fmt.Errorf("unknown watcher %q", p.Watcher)
+		}
+		if t == nil {
+			var err error
+			if t, err = s.resolveProbeType(p.Type, p.Watcher); err != nil {
+				return nil, err
+			}
+			plan.probeTypes[p.Name] = t.ID
+		}
+		if err := ValidateArguments(p.Arguments, t.Arguments); err != nil {
+			return nil, err
+		}
+	}
+
+	switch {
+	case remote == nil:
+		return &Change{Action: ActionCreate, Kind: KindProbe, Name: p.Name}, nil
+	case (remote.Composite == nil) != (p.Composite == nil) || remote.Type != p.Type:
+		return &Change{Action: ActionReplace, Kind: KindProbe, Name: p.Name, Fields: diffFields(remote.Probe, p)}, nil
+	}
+	if fields := diffFields(remote.Probe, p); len(fields) > 0 {
+		return &Change{Action: ActionUpdate, Kind: KindProbe, Name: p.Name, Fields: fields}, nil
+	}
+	return nil, nil
+}
+
+func (plan *Plan) add(c Change) {
+	plan.Changes = append(plan.Changes, c)
+}
+
+// Empty reports whether applying the plan changes nothing.
+func (plan *Plan) Empty() bool {
+	return len(plan.Changes) == 0
+}
+
+// Write describes the plan for people.
+func (plan *Plan) Write(w io.Writer) {
+	counts := map[Action]int{}
+	for _, c := range plan.Changes {
+		counts[c.Action]++
+		symbol := map[Action]string{ActionCreate: "+", ActionUpdate: "~", ActionReplace: "-/+", ActionDelete: "-"}[c.Action]
+		fmt.Fprintf(w, "%s %s %s %q\n", symbol, c.Action, c.Kind, c.Name)
+		for _, f := range c.Fields {
+			fmt.Fprintf(w, "    %s: %s -> %s\n", f.Field, formatValue(f.Before), formatValue(f.After))
+		}
+	}
+	if plan.Empty() {
+		fmt.Fprintln(w, "No changes.")
+	} else {
+		fmt.Fprintf(w, "Plan: %d to create, %d to update, %d to replace, %d to delete.\n",
+			counts[ActionCreate], counts[ActionUpdate], counts[ActionReplace], counts[ActionDelete])
+	}
+	if plan.Unmanaged > 0 {
+		fmt.Fprintf(w, "%d objects on the web service are not in the file; --prune deletes them.\n", plan.Unmanaged)
+	}
+}
+
+// diffFields compares two objects field by field, in the form they have in
+// a file.
+func diffFields(before, after any) []FieldChange {
+	b, a := fieldMap(before), fieldMap(after)
+	keys := map[string]bool{}
+	for k := range b {
+		keys[k] = true
+	}
+	for k := range a {
+		keys[k] = true
+	}
+	var fields []FieldChange
+	for _, k := range sortedKeys(keys) {
+		if !reflect.DeepEqual(b[k], a[k]) {
+			fields = append(fields, FieldChange{Field: k, Before: b[k], After: a[k]})
+		}
+	}
+	return fields
+}
+
+// fieldMap returns the fields of an object as YAML would decode them, so
+// numbers from the API and from a file compare equal.
+func fieldMap(v any) map[string]any {
+	data, err := yaml.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var m map[string]any
+	yaml.Unmarshal(data, &m)
+	return m
+}
+
+func formatValue(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "(none)"
+	case string:
+		return v
+	case map[string]any, []any:
+		data, _ := json.Marshal(v)
+		return string(data)
+	default:
+		return fmt.Sprint(v)
+	}
+}
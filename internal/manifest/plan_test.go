@@ -0,0 +1,413 @@
+package manifest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// fakeAPI keeps probe configs and notification channels in memory, like
+// the web service does.
+type fakeAPI struct {
+	nextID    int
+	channels  map[int]map[string]any
+	configs   map[int]*apiConfig
+	dependsOn map[int][]int
+	members   map[int][]int
+	changes   []string // Method and path of each change
+}
+
+var fakeProbeTypes = []map[string]any{
+	{"id": 10, "name": "disk-space", "version": "1.0.0", "arguments": map[string]any{
+		"required": map[string]any{"path": map[string]any{"type": "string"}},
+		"optional": map[string]any{"min_free_percent": map[string]any{"type": "number"}},
+	}},
+	{"id": 11, "name": "http", "version": "1.0.0", "arguments": map[string]any{}},
+	{"id": 12, "name": "http", "version": "1.1.0", "arguments": map[string]any{
+		"required": map[string]any{"url": map[string]any{"type": "string"}},
+	}},
+	{"id": 13, "name": "tcp-port", "version": "1.0.0", "arguments": map[string]any{
+		"required": map[string]any{"port": map[string]any{"type": "number"}},
+	}},
+}
+
+func newFakeAPI(t *testing.T) (*fakeAPI, *Client) {
+	f := &fakeAPI{
+		nextID:    100,
+		channels:  map[int]map[string]any{},
+		configs:   map[int]*apiConfig{},
+		dependsOn: map[int][]int{},
+		members:   map[int][]int{},
+	}
+	reply := func(w http.ResponseWriter, v any) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(v)
+	}
+	id := func(r *http.Request) int {
+		id, _ := strconv.Atoi(r.PathValue("id"))
+		return id
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/watchers", func(w http.ResponseWriter, r *http.Request) {
+		reply(w, []map[string]any{{"id": 1, "name": "nas"}})
+	})
+	mux.HandleFunc("GET /api/probe-types", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("watcher") == "1" {
+			reply(w, fakeProbeTypes[:1])
+			return
+		}
+		reply(w, fakeProbeTypes)
+	})
+	mux.HandleFunc("GET /api/escalation-policies", func(w http.ResponseWriter, r *http.Request) {
+		reply(w, []map[string]any{{"id": 5, "name": "on-call"}})
+	})
+	mux.HandleFunc("GET /api/notification-channels", func(w http.ResponseWriter, r *http.Request) {
+		channels := []map[string]any{}
+		for _, id := range sortedIDs(f.channels) {
+			channels = append(channels, f.channels[id])
+		}
+		reply(w, channels)
+	})
+	mux.HandleFunc("GET /api/probe-configs", func(w http.ResponseWriter, r *http.Request) {
+		configs := []*apiConfig{}
+		for _, id := range sortedIDs(f.configs) {
+			configs = append(configs, f.configs[id])
+		}
+		reply(w, configs)
+	})
+	mux.HandleFunc("GET /api/dependency-graph", func(w http.ResponseWriter, r *http.Request) {
+		edges := []map[string]any{}
+		for kind, links := range map[string]map[int][]int{"depends_on": f.dependsOn, "member": f.members} {
+			for _, from := range sortedIDs(links) {
+				for _, to := range links[from] {
+					edges = append(edges, map[string]any{"from": from, "to": to, "kind": kind})
+				}
+			}
+		}
+		reply(w, map[string]any{"edges": edges})
+	})
+
+	saveChannel := func(w http.ResponseWriter, r *http.Request, id int) {
+		var ch map[string]any
+		json.NewDecoder(r.Body).Decode(&ch)
+		ch["id"] = id
+		f.channels[id] = ch
+	}
+	mux.HandleFunc("POST /api/notification-channels", func(w http.ResponseWriter, r *http.Request) {
+		f.nextID++
+		saveChannel(w, r, f.nextID)
+		reply(w, map[string]any{"id": f.nextID})
+	})
+	mux.HandleFunc("PUT /api/notification-channels/{id}", func(w http.ResponseWriter, r *http.Request) {
+		saveChannel(w, r, id(r))
+	})
+	mux.HandleFunc("DELETE /api/notification-channels/{id}", func(w http.ResponseWriter, r *http.Request) {
+		delete(f.channels, id(r))
+	})
+
+	saveConfig := func(w http.ResponseWriter, r *http.Request, id int, composite bool) {
+		var req struct {
+			ProbeTypeID          int            `json:"probe_type_id"`
+			WatcherID            *int           `json:"watcher_id"`
+			Name                 string         `json:"name"`
+			Enabled              bool           `json:"enabled"`
+			Arguments            map[string]any `json:"arguments"`
+			Interval             string         `json:"interval"`
+			Timezone             *string        `json:"timezone"`
+			TimeoutSeconds       int            `json:"timeout_seconds"`
+			NotificationChannels []int          `json:"notification_channels"`
+			EscalationPolicyID   *int           `json:"escalation_policy_id"`
+			ConsecutiveResults   int            `json:"consecutive_results"`
+			GroupPath            *string        `json:"group_path"`
+			Keywords             []string       `json:"keywords"`
+			Mode                 string         `json:"mode"`
+			Quorum               int            `json:"quorum"`
+			Members              []int          `json:"members"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		cfg := f.configs[id]
+		if cfg == nil {
+			cfg = &apiConfig{ID: id, ProbeTypeID: req.ProbeTypeID}
+			for _, t := range fakeProbeTypes {
+				if t["id"] == req.ProbeTypeID {
+					cfg.ProbeTypeName = t["name"].(string)
+				}
+			}
+			f.configs[id] = cfg
+		}
+		cfg.Name, cfg.Enabled, cfg.Arguments, cfg.Interval = req.Name, req.Enabled, req.Arguments, req.Interval
+		cfg.TimeoutSeconds, cfg.NotificationChannels, cfg.EscalationPolicyID = req.TimeoutSeconds, req.NotificationChannels, req.EscalationPolicyID
+		cfg.ConsecutiveResults, cfg.Keywords = req.ConsecutiveResults, req.Keywords
+		cfg.Timezone, cfg.GroupPath, cfg.WatcherName = "", "", ""
+		if req.Timezone != nil {
+			cfg.Timezone = *req.Timezone
+		}
+		if req.GroupPath != nil {
+			cfg.GroupPath = *req.GroupPath
+		}
+		if req.WatcherID != nil {
+			cfg.WatcherName = "nas"
+		}
+		if composite {
+			cfg.ProbeTypeName, cfg.Interval, cfg.CompositeMode, cfg.CompositeQuorum = "composite", "0", req.Mode, req.Quorum
+			f.members[id] = req.Members
+		}
+	}
+	mux.HandleFunc("POST /api/probe-configs", func(w http.ResponseWriter, r *http.Request) {
+		f.nextID++
+		saveConfig(w, r, f.nextID, false)
+		reply(w, map[string]any{"id": f.nextID})
+	})
+	mux.HandleFunc("PUT /api/probe-configs/{id}", func(w http.ResponseWriter, r *http.Request) {
+		saveConfig(w, r, id(r), false)
+	})
+	mux.HandleFunc("POST /api/composites", func(w http.ResponseWriter, r *http.Request) {
+		f.nextID++
+		saveConfig(w, r, f.nextID, true)
+		reply(w, map[string]any{"id": f.nextID})
+	})
+	mux.HandleFunc("PUT /api/composites/{id}", func(w http.ResponseWriter, r *http.Request) {
+		saveConfig(w, r, id(r), true)
+	})
+	mux.HandleFunc("PUT /api/probe-configs/{id}/dependencies", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			DependsOn []int `json:"depends_on"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		f.dependsOn[id(r)] = req.DependsOn
+	})
+	mux.HandleFunc("DELETE /api/probe-configs/{id}", func(w http.ResponseWriter, r *http.Request) {
+		// Like the foreign keys, deleting a config drops its links
+		deleted := id(r)
+		delete(f.configs, deleted)
+		delete(f.dependsOn, deleted)
+		delete(f.members, deleted)
+		for _, links := range []map[int][]int{f.dependsOn, f.members} {
+			for from, to := range links {
+				links[from] = slices.DeleteFunc(to, func(id int) bool { return id == deleted })
+			}
+		}
+	})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer test-token" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if r.Method != http.MethodGet {
+			f.changes = append(f.changes, r.Method+" "+r.URL.Path)
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	return f, NewClient(server.URL, "test-token")
+}
+
+func sortedIDs[V any](m map[int]V) []int {
+	ids := make([]int, 0, len(m))
+	for id := range m {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+// apply plans a file against the fake API and applies it.
+func apply(t *testing.T, c *Client, data string, prune bool) *Plan {
+	t.Helper()
+	ctx := context.Background()
+	f, err := Parse([]byte(data))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	state, err := Fetch(ctx, c)
+	if err != nil {
+		t.Fatalf("Fetch failed: %v", err)
+	}
+	plan, err := NewPlan(f, state, prune)
+	if err != nil {
+		t.Fatalf("NewPlan failed: %v", err)
+	}
+	if err := plan.Apply(ctx, c, &bytes.Buffer{}); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	return plan
+}
+
+func TestApply(t *testing.T) {
+	api, client := newFakeAPI(t)
+
+	plan := apply(t, client, testFile, false)
+	if len(plan.Changes) != 4 {
+		t.Errorf("expected 4 creates, got %+v", plan.Changes)
+	}
+	// Members and dependencies are created first
+	expected := []string{
+		"POST /api/notification-channels",
+		"POST /api/probe-configs",
+		"POST /api/probe-configs",
+		"POST /api/composites",
+		"PUT /api/probe-configs/103/dependencies",
+	}
+	if !slices.Equal(api.changes, expected) {
+		t.Errorf("expected requests %v, got %v", expected, api.changes)
+	}
+	if cfg := api.configs[102]; cfg.ProbeTypeName != "disk-space" || cfg.WatcherName != "nas" || cfg.NotificationChannels[0] != 101 {
+		t.Errorf("unexpected config %+v", cfg)
+	}
+	if cfg := api.configs[103]; cfg.ProbeTypeID != 12 {
+		t.Errorf("expected the newest http version, got type %d", cfg.ProbeTypeID)
+	}
+
+	// Applying the same file again changes nothing
+	api.changes = nil
+	if plan := apply(t, client, testFile, false); !plan.Empty() {
+		t.Errorf("expected no changes, got %+v", plan.Changes)
+	}
+	if len(api.changes) != 0 {
+		t.Errorf("expected no requests, got %v", api.changes)
+	}
+
+	// Export produces the same file
+	state, _ := Fetch(context.Background(), client)
+	exported, _ := state.Export().Marshal()
+	if plan := apply(t, client, string(exported), true); !plan.Empty() {
+		t.Errorf("expected no changes for the export, got %+v\n%s", plan.Changes, exported)
+	}
+
+	// A changed type replaces the probe, and its composite and dependents get it back
+	api.changes = nil
+	changed := strings.Replace(testFile, "    type: http\n    interval: 1m\n    arguments:\n      url: https://example.com\n",
+		"    type: tcp-port\n    interval: 2m\n    arguments:\n      port: 443\n", 1)
+	plan = apply(t, client, changed, false)
+	if len(plan.Changes) != 1 || plan.Changes[0].Action != ActionReplace {
+		t.Fatalf("expected a replacement, got %+v", plan.Changes)
+	}
+	expected = []string{
+		"DELETE /api/probe-configs/103",
+		"POST /api/probe-configs",
+		"PUT /api/composites/104",
+		"PUT /api/probe-configs/105/dependencies",
+	}
+	if !slices.Equal(api.changes, expected) {
+		t.Errorf("expected requests %v, got %v", expected, api.changes)
+	}
+	if !slices.Contains(api.members[104], 105) {
+		t.Errorf("expected the new probe in the composite, got %v", api.members[104])
+	}
+}
+
+func TestChannelCredentials(t *testing.T) {
+	api, client := newFakeAPI(t)
+	t.Setenv("TEST_NTFY_TOKEN", "tk_first")
+	file := "notification_channels:\n  - {name: ops, type: ntfy, config: {topic: ops, token: '${TEST_NTFY_TOKEN}'}}\n"
+	apply(t, client, file, false)
+	if token := api.channels[101]["config"].(map[string]any)["token"]; token != "tk_first" {
+		t.Fatalf("expected the token from the environment, got %v", token)
+	}
+
+	// The export leaves out the token, and applying it keeps the token
+	state, _ := Fetch(context.Background(), client)
+	exported, _ := state.Export().Marshal()
+	if strings.Contains(string(exported), "tk_first") || !strings.Contains(string(exported), "token: REDACTED") {
+		t.Errorf("expected a redacted token in the export:\n%s", exported)
+	}
+	if plan := apply(t, client, string(exported), false); !plan.Empty() {
+		t.Errorf("expected no changes for the export, got %+v", plan.Changes)
+	}
+
+	// Plans don't show credentials
+	t.Setenv("TEST_NTFY_TOKEN", "tk_second")
+	var out bytes.Buffer
+	apply(t, client, file, false).Write(&out)
+	if strings.Contains(out.String(), "tk_") || !strings.Contains(out.String(), `update notification channel "ops"`) {
+		t.Errorf("expected a redacted update in the plan:\n%s", out.String())
+	}
+	if token := api.channels[101]["config"].(map[string]any)["token"]; token != "tk_second" {
+		t.Errorf("expected the new token, got %v", token)
+	}
+
+	// A new channel needs its credentials
+	f, _ := Parse([]byte("notification_channels:\n  - {name: new, type: ntfy, config: {topic: new, token: REDACTED}}\n"))
+	state, _ = Fetch(context.Background(), client)
+	if _, err := NewPlan(f, state, false); err == nil || !strings.Contains(err.Error(), "token is REDACTED") {
+		t.Errorf("expected an error for a redacted token, got %v", err)
+	}
+}
+
+func TestPlan(t *testing.T) {
+	_, client := newFakeAPI(t)
+	apply(t, client, testFile, false)
+
+	ctx := context.Background()
+	state, err := Fetch(ctx, client)
+	if err != nil {
+		t.Fatalf("Fetch failed: %v", err)
+	}
+	f, _ := Parse([]byte(`
+probes:
+  - name: root-disk
+    type: disk-space
+    watcher: nas
+    group: prod/storage
+    interval: 1m
+    arguments:
+      path: /
+      min_free_percent: 10
+    notification_channels: [ops]
+`))
+
+	plan, err := NewPlan(f, state, false)
+	if err != nil {
+		t.Fatalf("NewPlan failed: %v", err)
+	}
+	if len(plan.Changes) != 1 || plan.Unmanaged != 3 {
+		t.Fatalf("expected one update and 3 unmanaged objects, got %+v", plan)
+	}
+	change := plan.Changes[0]
+	if change.Action != ActionUpdate || len(change.Fields) != 1 || change.Fields[0].Field != "interval" {
+		t.Errorf("expected an interval update, got %+v", change)
+	}
+
+	// Pruning deletes the rest, and the kept probe can't use a deleted channel
+	if _, err := NewPlan(f, state, true); err == nil || !strings.Contains(err.Error(), `unknown notification channel "ops"`) {
+		t.Errorf("expected an unknown channel error, got %v", err)
+	}
+	f.Probes[0].NotificationChannels = nil
+	plan, err = NewPlan(f, state, true)
+	if err != nil {
+		t.Fatalf("NewPlan failed: %v", err)
+	}
+	var out bytes.Buffer
+	plan.Write(&out)
+	for _, want := range []string{`- delete probe "web"`, `- delete notification channel "ops"`, "interval: 5m -> 1m", "3 to delete"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("expected %q in the plan:\n%s", want, out.String())
+		}
+	}
+
+	// References and arguments are checked against the web service
+	tests := map[string]string{
+		"unknown watcher":     "probes:\n  - {name: a, type: http, watcher: pi, interval: 1m, arguments: {url: x}}\n",
+		"type not on watcher": "probes:\n  - {name: a, type: http, watcher: nas, interval: 1m, arguments: {url: x}}\n",
+		"unknown type":        "probes:\n  - {name: a, type: ping, interval: 1m}\n",
+		"unknown policy":      "probes:\n  - {name: a, type: http, interval: 1m, arguments: {url: x}, escalation_policy: pager}\n",
+		"bad argument":        "probes:\n  - {name: a, type: http, interval: 1m, arguments: {url: 5}}\n",
+	}
+	for name, data := range tests {
+		f, err := Parse([]byte(data))
+		if err != nil {
+			t.Fatalf("%s: Parse failed: %v", name, err)
+		}
+		if _, err := NewPlan(f, state, false); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
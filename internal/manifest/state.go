@@ -0,0 +1,307 @@
+package manifest
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jandubois/monitor/internal/notify"
+	"github.com/jandubois/monitor/internal/probe"
+)
+
+// State is the configuration the web service has, with the IDs that the
+// file leaves out.
+type State struct {
+	Channels []RemoteChannel
+	Probes   []RemoteProbe
+
+	probeTypes []probeType
+	watchers   map[string]int
+	policies   map[string]int
+}
+
+// RemoteChannel is a notification channel on the web service.
+type RemoteChannel struct {
+	ID int
+	Channel
+}
+
+// RemoteProbe is a probe config on the web service.
+type RemoteProbe struct {
+	ID     int
+	TypeID int
+	Probe
+}
+
+type probeType struct {
+	ID        int             `json:"id"`
+	Name      string          `json:"name"`
+	Version   string          `json:"version"`
+	Arguments probe.Arguments `json:"arguments"`
+	watchers  map[int]bool    // Watchers that have the probe type
+}
+
+// apiConfig is a probe config as the web service lists it.
+type apiConfig struct {
+	ID                   int            `json:"id"`
+	ProbeTypeID          int            `json:"probe_type_id"`
+	ProbeTypeName        string         `json:"probe_type_name"`
+	Name                 string         `json:"name"`
+	Enabled              bool           `json:"enabled"`
+	Arguments            map[string]any `json:"arguments"`
+	Interval             string         `json:"interval"`
+	Timezone             string         `json:"timezone"`
+	ActiveWindows        []string       `json:"active_windows"`
+	TimeoutSeconds       int            `json:"timeout_seconds"`
+	NotificationChannels []int          `json:"notification_channels"`
+	EscalationPolicyID   *int           `json:"escalation_policy_id"`
+	ConsecutiveResults   int            `json:"consecutive_results"`
+	FlapWindow           int            `json:"flap_window"`
+	FlapThreshold        int            `json:"flap_threshold"`
+	AnomalyThreshold     float64        `json:"anomaly_threshold"`
+	CompositeMode        string         `json:"composite_mode"`
+	CompositeQuorum      int            `json:"composite_quorum"`
+	WatcherName          string         `json:"watcher_name"`
+	GroupPath            string         `json:"group_path"`
+	Keywords             []string       `json:"keywords"`
+}
+
+type namedObject struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// Fetch reads the current configuration from the web service. Names must
+// be unique, because the file refers to objects by name.
+func Fetch(ctx context.Context, c *Client) (*State, error) {
+	s := &State{watchers: map[string]int{}, policies: map[string]int{}}
+
+	var watchers []namedObject
+	if err := c.get(ctx, "/api/watchers", &watchers); err != nil {
+		return nil, fmt.Errorf("list watchers: %w", err)
+	}
+	for _, w := range watchers {
+		s.watchers[w.Name] = w.ID
+	}
+
+	if err := c.get(ctx, "/api/probe-types", &s.probeTypes); err != nil {
+		return nil, fmt.Errorf("list probe types: %w", err)
+	}
+	typeIndex := map[int]int{}
+	for i := range s.probeTypes {
+		s.probeTypes[i].watchers = map[int]bool{}
+		typeIndex[s.probeTypes[i].ID] = i
+	}
+	for _, w := range watchers {
+		var available []namedObject
+		if err := c.get(ctx, "/api/probe-types?watcher="+strconv.Itoa(w.ID), &available); err != nil {
+			return nil, fmt.Errorf("list probe types of watcher %s: %w", w.Name, err)
+		}
+		for _, t := range available {
+			if i, ok := typeIndex[t.ID]; ok {
+				s.probeTypes[i].watchers[w.ID] = true
+			}
+		}
+	}
+
+	var policies []namedObject
+	if err := c.get(ctx, "/api/escalation-policies", &policies); err != nil {
+		return nil, fmt.Errorf("list escalation policies: %w", err)
+	}
+	policyNames := map[int]string{}
+	for _, p := range policies {
+		s.policies[p.Name] = p.ID
+		policyNames[p.ID] = p.Name
+	}
+
+	var channels []struct {
+		ID      int            `json:"id"`
+		Name    string         `json:"name"`
+		Type    string         `json:"type"`
+		Config  map[string]any `json:"config"`
+		Enabled bool           `json:"enabled"`
+	}
+	if err := c.get(ctx, "/api/notification-channels", &channels); err != nil {
+		return nil, fmt.Errorf("list notification channels: %w", err)
+	}
+	channelNames := map[int]string{}
+	seen := map[string]bool{}
+	for _, ch := range channels {
+		if seen[ch.Name] {
+			return nil, fmt.Errorf("the web service has several notification channels named %q; rename them to use a file", ch.Name)
+		}
+		seen[ch.Name] = true
+		channelNames[ch.ID] = ch.Name
+		s.Channels = append(s.Channels, RemoteChannel{
+			ID:      ch.ID,
+			Channel: Channel{Name: ch.Name, Type: ch.Type, Enabled: boolPtr(ch.Enabled), Config: ch.Config}.normalized(),
+		})
+	}
+
+	var configs []apiConfig
+	if err := c.get(ctx, "/api/probe-configs", &configs); err != nil {
+		return nil, fmt.Errorf("list probe configs: %w", err)
+	}
+	var graph struct {
+		Edges []struct {
+			From int    `json:"from"`
+			To   int    `json:"to"`
+			Kind string `json:"kind"`
+		} `json:"edges"`
+	}
+	if err := c.get(ctx, "/api/dependency-graph", &graph); err != nil {
+		return nil, fmt.Errorf("get dependency graph: %w", err)
+	}
+
+	configNames := map[int]string{}
+	seen = map[string]bool{}
+	for _, cfg := range configs {
+		if seen[cfg.Name] {
+			return nil, fmt.Errorf("the web service has several probe configs named %q; rename them to use a file", cfg.Name)
+		}
+		seen[cfg.Name] = true
+		configNames[cfg.ID] = cfg.Name
+	}
+	dependsOn := map[int][]string{}
+	members := map[int][]string{}
+	for _, e := range graph.Edges {
+		switch e.Kind {
+		case "depends_on":
+			dependsOn[e.From] = append(dependsOn[e.From], configNames[e.To])
+		case "member":
+			members[e.From] = append(members[e.From], configNames[e.To])
+		}
+	}
+
+	for _, cfg := range configs {
+		p := Probe{
+			Name:               cfg.Name,
+			Type:               cfg.ProbeTypeName,
+			Watcher:            cfg.WatcherName,
+			Group:              cfg.GroupPath,
+			Keywords:           cfg.Keywords,
+			Enabled:            boolPtr(cfg.Enabled),
+			Interval:           cfg.Interval,
+			Timezone:           cfg.Timezone,
+			ActiveWindows:      cfg.ActiveWindows,
+			TimeoutSeconds:     cfg.TimeoutSeconds,
+			Arguments:          cfg.Arguments,
+			ConsecutiveResults: cfg.ConsecutiveResults,
+			FlapWindow:         cfg.FlapWindow,
+			FlapThreshold:      cfg.FlapThreshold,
+			AnomalyThreshold:   cfg.AnomalyThreshold,
+			DependsOn:          dependsOn[cfg.ID],
+		}
+		// Channels that were deleted since are ignored by the web service too
+		for _, id := range cfg.NotificationChannels {
+			if name, ok := channelNames[id]; ok {
+				p.NotificationChannels = append(p.NotificationChannels, name)
+			}
+		}
+		if cfg.EscalationPolicyID != nil {
+			p.EscalationPolicy = policyNames[*cfg.EscalationPolicyID]
+		}
+		if cfg.CompositeMode != "" {
+			p.Type, p.Watcher, p.Interval, p.TimeoutSeconds, p.Arguments = "", "", "", 0, nil
+			p.Composite = &Composite{Mode: cfg.CompositeMode, Quorum: cfg.CompositeQuorum, Members: members[cfg.ID]}
+		}
+		s.Probes = append(s.Probes, RemoteProbe{ID: cfg.ID, TypeID: cfg.ProbeTypeID, Probe: p.normalized()})
+	}
+	return s, nil
+}
+
+// Export returns the configuration as a file. Credentials in channel
+// configs are redacted; applying the file keeps their current values.
+func (s *State) Export() *File {
+	f := &File{}
+	for _, c := range s.Channels {
+		ch := c.Channel.compact()
+		ch.Config = notify.RedactConfig(ch.Config)
+		f.NotificationChannels = append(f.NotificationChannels, ch)
+	}
+	for _, p := range s.Probes {
+		f.Probes = append(f.Probes, p.Probe.compact())
+	}
+	return f
+}
+
+func (s *State) channel(name string) *RemoteChannel {
+	for i := range s.Channels {
+		if s.Channels[i].Name == name {
+			return &s.Channels[i]
+		}
+	}
+	return nil
+}
+
+func (s *State) probe(name string) *RemoteProbe {
+	for i := range s.Probes {
+		if s.Probes[i].Name == name {
+			return &s.Probes[i]
+		}
+	}
+	return nil
+}
+
+func (s *State) probeType(id int) *probeType {
+	for i := range s.probeTypes {
+		if s.probeTypes[i].ID == id {
+			return &s.probeTypes[i]
+		}
+	}
+	return nil
+}
+
+// resolveProbeType picks the newest version of a probe type, among those
+// that the watcher has if one is given.
+func (s *State) resolveProbeType(name, watcher string) (*probeType, error) {
+	watcherID, ok := s.watchers[watcher]
+	if watcher != "" && !ok {
+		return nil, fmt.Errorf("unknown watcher %q", watcher)
+	}
+	var best *probeType
+	for i := range s.probeTypes {
+		t := &s.probeTypes[i]
+		if t.Name != name || (watcher != "" && !t.watchers[watcherID]) {
+			continue
+		}
+		if best == nil || CompareVersions(t.Version, best.Version) > 0 {
+			best = t
+		}
+	}
+	if best == nil {
+		if watcher != "" {
+			return nil, fmt.Errorf("watcher %s has no probe type %q", watcher, name)
+		}
+		return nil, fmt.Errorf("unknown probe type %q", name)
+	}
+	return best, nil
+}
+
+// CompareVersions compares dotted version numbers numerically, falling back
+// to comparing the text of parts that aren't numbers.
+func CompareVersions(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < max(len(as), len(bs)); i++ {
+		var pa, pb string
+		if i < len(as) {
+			pa = as[i]
+		}
+		if i < len(bs) {
+			pb = bs[i]
+		}
+		na, errA := strconv.Atoi(pa)
+		nb, errB := strconv.Atoi(pb)
+		switch {
+		case errA == nil && errB == nil && na != nb:
+			if na < nb {
+				return -1
+			}
+			return 1
+		case (errA != nil || errB != nil) && pa != pb:
+			return strings.Compare(pa, pb)
+		}
+	}
+	return 0
+}
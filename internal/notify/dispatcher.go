@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"fmt"
 	"log/slog"
 	"sync"
 
@@ -51,7 +52,7 @@ func (d *Dispatcher) LoadChannels(ctx context.Context) error {
 			continue
 		}
 
-		channel, err := d.createChannel(channelType, []byte(configJSON))
+		channel, err := NewChannel(channelType, []byte(configJSON))
 		if err != nil {
 			slog.Error("create notification channel failed", "type", channelType, "error", err)
 			continue
@@ -64,7 +65,8 @@ func (d *Dispatcher) LoadChannels(ctx context.Context) error {
 	return nil
 }
 
-func (d *Dispatcher) createChannel(channelType string, configJSON []byte) (Channel, error) {
+// NewChannel creates a notification channel from its type and JSON config.
+func NewChannel(channelType string, configJSON []byte) (Channel, error) {
 	switch channelType {
 	case "ntfy":
 		var cfg NtfyConfig
@@ -78,14 +80,129 @@ func (d *Dispatcher) createChannel(channelType string, configJSON []byte) (Chann
 			return nil, err
 		}
 		return NewPushoverChannel(cfg), nil
+	case "email":
+		var cfg EmailConfig
+		if err := json.Unmarshal(configJSON, &cfg); err != nil {
+			return nil, err
+		}
+		return NewEmailChannel(cfg)
+	case "webhook":
+		var cfg WebhookConfig
+		if err := json.Unmarshal(configJSON, &cfg); err != nil {
+			return nil, err
+		}
+		return NewWebhookChannel(cfg)
 	default:
-		return nil, nil
+		return nil, fmt.Errorf("unknown channel type %q", channelType)
+	}
+}
+
+// Redacted replaces the credentials in redacted channel configs.
+const Redacted = "REDACTED"
+
+// secretConfigKeys are the channel config fields that hold credentials.
+var secretConfigKeys = map[string]bool{
+	"password": true, "token": true, "api_token": true, "user_key": true, "secret": true,
+}
+
+// RedactConfig returns a copy of a channel config with its credentials
+// replaced by Redacted. Header values are replaced too, since they often
+// carry authorization; header names are kept.
+func RedactConfig(config map[string]any) map[string]any {
+	if config == nil {
+		return nil
+	}
+	redacted := make(map[string]any, len(config))
+	for key, value := range config {
+		switch {
+		case secretConfigKeys[key] && value != "":
+			redacted[key] = Redacted
+		case key == "headers":
+			if headers, ok := value.(map[string]any); ok {
+				names := make(map[string]any, len(headers))
+				for name := range headers {
+					names[name] = Redacted
+				}
+				value = names
+			}
+			redacted[key] = value
+		default:
+			redacted[key] = value
+		}
 	}
+	return redacted
+}
+
+// SetChannels replaces the channels, for dispatchers whose channels are
+// not in a database.
+func (d *Dispatcher) SetChannels(channels map[int]Channel) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.channels = channels
+}
+
+// SendTest sends a test notification through the channel with the given ID.
+// The channel is built from its current database row, so it works for
+// disabled channels and for edits that have not been reloaded yet.
+func (d *Dispatcher) SendTest(ctx context.Context, channelID int) error {
+	var name, channelType string
+	var configJSON *string
+	err := d.db.QueryRowContext(ctx, `
+		SELECT name, type, config FROM notification_channels WHERE id = ?
+	`, channelID).Scan(&name, &channelType, &configJSON)
+	if err != nil {
+		return fmt.Errorf("load channel %d: %w", channelID, err)
+	}
+
+	var config []byte
+	if configJSON != nil {
+		config = []byte(*configJSON)
+	}
+	channel, err := NewChannel(channelType, config)
+	if err != nil {
+		return fmt.Errorf("create channel: %w", err)
+	}
+
+	return channel.Send(ctx, &Message{
+		Title:    "Test notification",
+		Body:     fmt.Sprintf("This is a test notification from channel %q.", name),
+		Priority: PriorityNormal,
+		Tags:     []string{"test"},
+	})
 }
 
 // NotifyStatusChange sends notifications for a status change.
 func (d *Dispatcher) NotifyStatusChange(ctx context.Context, channelIDs []int, change *StatusChange) {
-	msg := FormatStatusChange(change)
+	d.Send(ctx, channelIDs, FormatStatusChange(change))
+}
+
+// NotifyMissedRun sends notifications for a probe run that did not happen.
+func (d *Dispatcher) NotifyMissedRun(ctx context.Context, channelIDs []int, missed *MissedRun) {
+	d.Send(ctx, channelIDs, FormatMissedRun(missed))
+}
+
+// NotifyEscalation sends notifications for an escalation step or repeat.
+func (d *Dispatcher) NotifyEscalation(ctx context.Context, channelIDs []int, escalation *Escalation) {
+	d.Send(ctx, channelIDs, FormatEscalation(escalation))
+}
+
+// NotifyFlapping sends notifications for a probe that started or stopped
+// flapping.
+func (d *Dispatcher) NotifyFlapping(ctx context.Context, channelIDs []int, flapping *Flapping) {
+	d.Send(ctx, channelIDs, FormatFlapping(flapping))
+}
+
+// NotifyWatcherHealth sends notifications for a watcher going offline or
+// coming back online.
+func (d *Dispatcher) NotifyWatcherHealth(ctx context.Context, channelIDs []int, change *WatcherHealthChange) {
+	d.Send(ctx, channelIDs, FormatWatcherHealthChange(change))
+}
+
+// Send delivers a message to the given channels asynchronously.
+// Sends outlive ctx cancellation so notifications triggered from an HTTP
+// handler are not cut off when the response is written.
+func (d *Dispatcher) Send(ctx context.Context, channelIDs []int, msg *Message) {
+	ctx = context.WithoutCancel(ctx)
 
 	d.mu.RLock()
 	defer d.mu.RUnlock()
@@ -106,8 +223,7 @@ func (d *Dispatcher) NotifyStatusChange(ctx context.Context, channelIDs []int, c
 			} else {
 				slog.Debug("notification sent",
 					"channel_id", chID,
-					"probe", change.ProbeName,
-					"status", change.NewStatus,
+					"title", msg.Title,
 				)
 			}
 		}(channel, id)
@@ -0,0 +1,293 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Email TLS modes.
+const (
+	EmailTLSStartTLS = "starttls" // Plain connection upgraded via STARTTLS (usually port 587)
+	EmailTLSImplicit = "tls"      // TLS from the first byte (usually port 465)
+	EmailTLSNone     = "none"     // No encryption; only for trusted local relays
+)
+
+// EmailChannel sends notifications via SMTP.
+type EmailChannel struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+	To       []string
+	TLS      string
+	timeout  time.Duration
+}
+
+// EmailConfig is the JSON configuration for an email channel.
+type EmailConfig struct {
+	Host     string   `json:"host"`
+	Port     int      `json:"port,omitempty"`
+	Username string   `json:"username,omitempty"`
+	Password string   `json:"password,omitempty"`
+	From     string   `json:"from"`
+	To       []string `json:"to"`
+	TLS      string   `json:"tls,omitempty"` // "starttls" (default), "tls", or "none"
+}
+
+// NewEmailChannel creates a new email notification channel.
+func NewEmailChannel(cfg EmailConfig) (*EmailChannel, error) {
+	if cfg.Host == "" {
+		return nil, fmt.Errorf("email channel requires host")
+	}
+	if cfg.From == "" {
+		return nil, fmt.Errorf("email channel requires from address")
+	}
+	if len(cfg.To) == 0 {
+		return nil, fmt.Errorf("email channel requires at least one recipient")
+	}
+
+	tlsMode := cfg.TLS
+	if tlsMode == "" {
+		tlsMode = EmailTLSStartTLS
+	}
+	port := cfg.Port
+	switch tlsMode {
+	case EmailTLSStartTLS:
+		if port == 0 {
+			port = 587
+		}
+	case EmailTLSImplicit:
+		if port == 0 {
+			port = 465
+		}
+	case EmailTLSNone:
+		if port == 0 {
+			port = 25
+		}
+	default:
+		return nil, fmt.Errorf("unknown email tls mode %q", cfg.TLS)
+	}
+
+	return &EmailChannel{
+		Host:     cfg.Host,
+		Port:     port,
+		Username: cfg.Username,
+		Password: cfg.Password,
+		From:     cfg.From,
+		To:       cfg.To,
+		TLS:      tlsMode,
+		timeout:  30 * time.Second,
+	}, nil
+}
+
+// Type returns the channel type.
+func (e *EmailChannel) Type() string {
+	return "email"
+}
+
+// Send sends a notification via SMTP.
+func (e *EmailChannel) Send(ctx context.Context, msg *Message) error {
+	body, err := e.buildMessage(msg)
+	if err != nil {
+		return fmt.Errorf("build message: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+
+	addr := net.JoinHostPort(e.Host, strconv.Itoa(e.Port))
+	tlsConfig := &tls.Config{ServerName: e.Host}
+
+	var conn net.Conn
+	if e.TLS == EmailTLSImplicit {
+		dialer := &tls.Dialer{Config: tlsConfig}
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	} else {
+		var dialer net.Dialer
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return fmt.Errorf("connect to %s: %w", addr, err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, e.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("smtp handshake: %w", err)
+	}
+	defer client.Close()
+
+	if e.TLS == EmailTLSStartTLS {
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return fmt.Errorf("starttls: %w", err)
+		}
+	}
+
+	if e.Username != "" {
+		auth := smtp.PlainAuth("", e.Username, e.Password, e.Host)
+		if err := client.Auth(auth); err != nil {
+			return fmt.Errorf("smtp auth: %w", err)
+		}
+	}
+
+	if err := client.Mail(e.From); err != nil {
+		return fmt.Errorf("smtp MAIL FROM: %w", err)
+	}
+	for _, rcpt := range e.To {
+		if err := client.Rcpt(rcpt); err != nil {
+			return fmt.Errorf("smtp RCPT TO %s: %w", rcpt, err)
+		}
+	}
+
+	wc, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("smtp DATA: %w", err)
+	}
+	if _, err := wc.Write(body); err != nil {
+		wc.Close()
+		return fmt.Errorf("write message: %w", err)
+	}
+	if err := wc.Close(); err != nil {
+		return fmt.Errorf("smtp DATA: %w", err)
+	}
+
+	return client.Quit()
+}
+
+var emailHTMLTemplate = template.Must(template.New("email").Parse(`<!DOCTYPE html>
+<html>
+<body style="font-family: sans-serif;">
+<h2 style="color: {{.Color}};">{{.Title}}</h2>
+<p style="white-space: pre-wrap;">{{.Body}}</p>
+{{- if .Tags}}
+<p style="color: #666; font-size: smaller;">{{range $i, $t := .Tags}}{{if $i}}, {{end}}{{$t}}{{end}}</p>
+{{- end}}
+</body>
+</html>
+`))
+
+// buildMessage renders msg as a multipart/alternative MIME message with
+// plain text and HTML parts.
+func (e *EmailChannel) buildMessage(msg *Message) ([]byte, error) {
+	boundary, err := randomBoundary()
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+
+	headers := [][2]string{
+		{"From", e.From},
+		{"To", strings.Join(e.To, ", ")},
+		{"Subject", encodeHeader(msg.Title)},
+		{"Date", time.Now().Format(time.RFC1123Z)},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", fmt.Sprintf("multipart/alternative; boundary=%q", boundary)},
+	}
+	switch msg.Priority {
+	case PriorityHigh, PriorityUrgent:
+		headers = append(headers, [2]string{"X-Priority", "1"}, [2]string{"Importance", "high"})
+	case PriorityLow:
+		headers = append(headers, [2]string{"X-Priority", "5"}, [2]string{"Importance", "low"})
+	}
+	for _, h := range headers {
+		fmt.Fprintf(&buf, "%s: %s\r\n", h[0], headerNewlines.Replace(h[1]))
+	}
+	buf.WriteString("\r\n")
+
+	// Plain text part
+	text := msg.Title + "\n\n" + msg.Body + "\n"
+	if len(msg.Tags) > 0 {
+		text += "\nTags: " + strings.Join(msg.Tags, ", ") + "\n"
+	}
+	if err := writePart(&buf, boundary, "text/plain", text); err != nil {
+		return nil, err
+	}
+
+	// HTML part
+	var html bytes.Buffer
+	err = emailHTMLTemplate.Execute(&html, map[string]any{
+		"Title": msg.Title,
+		"Body":  msg.Body,
+		"Tags":  msg.Tags,
+		"Color": priorityColor(msg.Priority),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("render html: %w", err)
+	}
+	if err := writePart(&buf, boundary, "text/html", html.String()); err != nil {
+		return nil, err
+	}
+
+	fmt.Fprintf(&buf, "--%s--\r\n", boundary)
+	return buf.Bytes(), nil
+}
+
+func writePart(buf *bytes.Buffer, boundary, contentType, content string) error {
+	fmt.Fprintf(buf, "--%s\r\n", boundary)
+	fmt.Fprintf(buf, "Content-Type: %s; charset=UTF-8\r\n", contentType)
+	buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+
+	qp := quotedprintable.NewWriter(buf)
+	if _, err := qp.Write([]byte(strings.ReplaceAll(content, "\n", "\r\n"))); err != nil {
+		return err
+	}
+	if err := qp.Close(); err != nil {
+		return err
+	}
+	buf.WriteString("\r\n")
+	return nil
+}
+
+// headerNewlines replaces line breaks in header values, which would
+// otherwise start new headers. Titles contain probe and watcher names,
+// and watchers choose their own names.
+var headerNewlines = strings.NewReplacer("\r\n", " ", "\r", " ", "\n", " ")
+
+// encodeHeader encodes header values containing non-ASCII characters
+// (e.g. from probe names) as RFC 2047 encoded-words.
+func encodeHeader(s string) string {
+	for _, r := range s {
+		if r > 127 {
+			return mime.QEncoding.Encode("UTF-8", s)
+		}
+	}
+	return s
+}
+
+func randomBoundary() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate boundary: %w", err)
+	}
+	return "monitor-" + hex.EncodeToString(b), nil
+}
+
+func priorityColor(p Priority) string {
+	switch p {
+	case PriorityUrgent:
+		return "#dc2626"
+	case PriorityHigh:
+		return "#d97706"
+	case PriorityLow:
+		return "#6b7280"
+	default:
+		return "#16a34a"
+	}
+}
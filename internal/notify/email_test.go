@@ -0,0 +1,259 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/jandubois/monitor/internal/probe"
+)
+
+// fakeSMTPMessage is a message received by fakeSMTPServer.
+type fakeSMTPMessage struct {
+	From string
+	To   []string
+	Data string
+}
+
+// fakeSMTPServer accepts a single SMTP session and records the message.
+func fakeSMTPServer(t *testing.T) (host string, port int, received <-chan fakeSMTPMessage) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	ch := make(chan fakeSMTPMessage, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		tp := textproto.NewConn(conn)
+		var msg fakeSMTPMessage
+		tp.PrintfLine("220 localhost fake SMTP")
+		for {
+			line, err := tp.ReadLine()
+			if err != nil {
+				return
+			}
+			cmd := strings.ToUpper(line)
+			switch {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				tp.PrintfLine("250 localhost")
+			case strings.HasPrefix(cmd, "MAIL FROM:"):
+				msg.From = strings.Trim(line[len("MAIL FROM:"):], "<> ")
+				tp.PrintfLine("250 OK")
+			case strings.HasPrefix(cmd, "RCPT TO:"):
+				msg.To = append(msg.To, strings.Trim(line[len("RCPT TO:"):], "<> "))
+				tp.PrintfLine("250 OK")
+			case cmd == "DATA":
+				tp.PrintfLine("354 End data with <CR><LF>.<CR><LF>")
+				data, err := io.ReadAll(tp.DotReader())
+				if err != nil {
+					return
+				}
+				msg.Data = string(data)
+				tp.PrintfLine("250 OK")
+			case cmd == "QUIT":
+				tp.PrintfLine("221 Bye")
+				ch <- msg
+				return
+			default:
+				tp.PrintfLine("502 Command not implemented")
+			}
+		}
+	}()
+
+	addr := ln.Addr().(*net.TCPAddr)
+	return addr.IP.String(), addr.Port, ch
+}
+
+func TestNewEmailChannelValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     EmailConfig
+		wantErr bool
+		port    int
+	}{
+		{"missing host", EmailConfig{From: "a@example.com", To: []string{"b@example.com"}}, true, 0},
+		{"missing from", EmailConfig{Host: "smtp.example.com", To: []string{"b@example.com"}}, true, 0},
+		{"missing to", EmailConfig{Host: "smtp.example.com", From: "a@example.com"}, true, 0},
+		{"bad tls mode", EmailConfig{Host: "smtp.example.com", From: "a@example.com", To: []string{"b@example.com"}, TLS: "ssl3"}, true, 0},
+		{"starttls default port", EmailConfig{Host: "smtp.example.com", From: "a@example.com", To: []string{"b@example.com"}}, false, 587},
+		{"implicit tls default port", EmailConfig{Host: "smtp.example.com", From: "a@example.com", To: []string{"b@example.com"}, TLS: "tls"}, false, 465},
+		{"explicit port", EmailConfig{Host: "smtp.example.com", Port: 2525, From: "a@example.com", To: []string{"b@example.com"}, TLS: "none"}, false, 2525},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ch, err := NewEmailChannel(tt.cfg)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if ch.Port != tt.port {
+				t.Errorf("expected port %d, got %d", tt.port, ch.Port)
+			}
+		})
+	}
+}
+
+func TestEmailChannelSend(t *testing.T) {
+	host, port, received := fakeSMTPServer(t)
+
+	ch, err := NewEmailChannel(EmailConfig{
+		Host: host,
+		Port: port,
+		From: "monitor@example.com",
+		To:   []string{"alice@example.com", "bob@example.com"},
+		TLS:  EmailTLSNone,
+	})
+	if err != nil {
+		t.Fatalf("create channel: %v", err)
+	}
+
+	msg := FormatStatusChange(&StatusChange{
+		ProbeName: "disk <root>",
+		OldStatus: probe.StatusOK,
+		NewStatus: probe.StatusCritical,
+		Message:   "5 GB free",
+	})
+	if err := ch.Send(context.Background(), msg); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+
+	got := <-received
+	if got.From != "monitor@example.com" {
+		t.Errorf("expected MAIL FROM monitor@example.com, got %q", got.From)
+	}
+	if len(got.To) != 2 || got.To[0] != "alice@example.com" || got.To[1] != "bob@example.com" {
+		t.Errorf("unexpected recipients: %v", got.To)
+	}
+
+	parsed, err := mail.ReadMessage(bufio.NewReader(strings.NewReader(got.Data)))
+	if err != nil {
+		t.Fatalf("parse message: %v", err)
+	}
+	if subject := parsed.Header.Get("Subject"); subject != "[critical] disk <root>" {
+		t.Errorf("unexpected subject %q", subject)
+	}
+	if parsed.Header.Get("Importance") != "high" {
+		t.Errorf("expected high importance for critical status")
+	}
+
+	mediaType, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatalf("parse content type: %v", err)
+	}
+	if mediaType != "multipart/alternative" {
+		t.Fatalf("expected multipart/alternative, got %q", mediaType)
+	}
+
+	parts := map[string]string{}
+	mr := multipart.NewReader(parsed.Body, params["boundary"])
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("read part: %v", err)
+		}
+		body, _ := io.ReadAll(part)
+		partType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		parts[partType] = string(body)
+	}
+
+	text, ok := parts["text/plain"]
+	if !ok {
+		t.Fatal("expected text/plain part")
+	}
+	if !strings.Contains(text, "ok → critical: 5 GB free") {
+		t.Errorf("unexpected plain text body: %q", text)
+	}
+
+	html, ok := parts["text/html"]
+	if !ok {
+		t.Fatal("expected text/html part")
+	}
+	if !strings.Contains(html, "disk &lt;root&gt;") {
+		t.Errorf("expected escaped probe name in html body: %q", html)
+	}
+}
+
+func TestEmailChannelSendConnectionRefused(t *testing.T) {
+	// Grab a free port and close it so nothing is listening
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+
+	ch, err := NewEmailChannel(EmailConfig{
+		Host: "127.0.0.1",
+		Port: port,
+		From: "monitor@example.com",
+		To:   []string{"alice@example.com"},
+		TLS:  EmailTLSNone,
+	})
+	if err != nil {
+		t.Fatalf("create channel: %v", err)
+	}
+
+	err = ch.Send(context.Background(), &Message{Title: "test", Body: "test"})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if !strings.Contains(err.Error(), "127.0.0.1:"+strconv.Itoa(port)) {
+		t.Errorf("expected address in error, got: %v", err)
+	}
+}
+
+func TestEmailHeaderInjection(t *testing.T) {
+	ch, err := NewEmailChannel(EmailConfig{
+		Host: "localhost",
+		From: "monitor@example.com",
+		To:   []string{"alice@example.com"},
+		TLS:  EmailTLSNone,
+	})
+	if err != nil {
+		t.Fatalf("create channel: %v", err)
+	}
+
+	for _, name := range []string{"disk\r\nBcc: mallory@example.com", "dïsk\nBcc: mallory@example.com"} {
+		data, err := ch.buildMessage(&Message{Title: name, Body: "5 GB free"})
+		if err != nil {
+			t.Fatalf("build message: %v", err)
+		}
+		parsed, err := mail.ReadMessage(bufio.NewReader(strings.NewReader(string(data))))
+		if err != nil {
+			t.Fatalf("parse message: %v", err)
+		}
+		if bcc := parsed.Header.Get("Bcc"); bcc != "" {
+			t.Errorf("title %q injected a Bcc header: %q", name, bcc)
+		}
+		subject, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+		if err != nil || !strings.Contains(subject, "Bcc: mallory@example.com") {
+			t.Errorf("expected the whole title in the subject, got %q (%v)", subject, err)
+		}
+	}
+}
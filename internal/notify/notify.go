@@ -3,6 +3,7 @@ package notify
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/jandubois/monitor/internal/probe"
 )
@@ -19,6 +20,8 @@ type Message struct {
 	Body     string
 	Priority Priority
 	Tags     []string
+	Change   *StatusChange // Originating status change, if any
+	AckURL   string        // Link that acknowledges the incident, if any
 }
 
 // Priority levels for notifications.
@@ -31,12 +34,29 @@ const (
 	PriorityUrgent
 )
 
+// String returns the lowercase priority name.
+func (p Priority) String() string {
+	switch p {
+	case PriorityLow:
+		return "low"
+	case PriorityNormal:
+		return "normal"
+	case PriorityHigh:
+		return "high"
+	case PriorityUrgent:
+		return "urgent"
+	default:
+		return fmt.Sprintf("priority(%d)", int(p))
+	}
+}
+
 // StatusChange represents a probe status transition.
 type StatusChange struct {
-	ProbeName  string
-	OldStatus  probe.Status
-	NewStatus  probe.Status
-	Message    string
+	ProbeName string
+	OldStatus probe.Status
+	NewStatus probe.Status
+	Message   string
+	AckURL    string // Acknowledges the incident the change opened or continues
 }
 
 // FormatStatusChange creates a notification message for a status change.
@@ -69,5 +89,116 @@ func FormatStatusChange(change *StatusChange) *Message {
 		Body:     body,
 		Priority: priority,
 		Tags:     tags,
+		Change:   change,
+		AckURL:   change.AckURL,
+	}
+}
+
+// Escalation represents a probe that is still critical after a stage of
+// its escalation policy has elapsed.
+type Escalation struct {
+	ProbeName string
+	Status    probe.Status
+	Message   string
+	Duration  time.Duration // Time since the probe went critical
+	Repeat    bool          // Reminder to channels that were already notified
+	AckURL    string
+}
+
+// FormatEscalation creates a notification message for an escalation step
+// or repeat.
+func FormatEscalation(e *Escalation) *Message {
+	tags := []string{string(e.Status), "escalation"}
+	if e.Repeat {
+		tags = append(tags, "repeat")
+	}
+	return &Message{
+		Title:    fmt.Sprintf("[%s] %s", e.Status, e.ProbeName),
+		Body:     fmt.Sprintf("Still %s after %s: %s", e.Status, e.Duration.Round(time.Minute), e.Message),
+		Priority: PriorityUrgent,
+		Tags:     tags,
+		Change: &StatusChange{
+			ProbeName: e.ProbeName,
+			NewStatus: e.Status,
+			Message:   e.Message,
+		},
+		AckURL: e.AckURL,
+	}
+}
+
+// Flapping represents a probe that started or stopped changing status too
+// often to notify every change.
+type Flapping struct {
+	ProbeName string
+	Status    probe.Status // Latest status
+	Message   string
+	Changes   int // Status changes within the window
+	Window    int // Number of results considered
+	Stopped   bool
+}
+
+// FormatFlapping creates a notification message for the start or end of
+// flapping.
+func FormatFlapping(f *Flapping) *Message {
+	if f.Stopped {
+		return &Message{
+			Title:    fmt.Sprintf("[%s] %s", f.Status, f.ProbeName),
+			Body:     fmt.Sprintf("Stopped flapping, now %s: %s", f.Status, f.Message),
+			Priority: PriorityNormal,
+			Tags:     []string{string(f.Status), "flapping", "recovery"},
+		}
+	}
+	return &Message{
+		Title: fmt.Sprintf("[flapping] %s", f.ProbeName),
+		Body: fmt.Sprintf("%d status changes in the last %d results; further changes are not notified until it settles. Now %s: %s",
+			f.Changes, f.Window, f.Status, f.Message),
+		Priority: PriorityHigh,
+		Tags:     []string{string(f.Status), "flapping"},
+	}
+}
+
+// MissedRun represents a scheduled probe run that produced no result.
+type MissedRun struct {
+	ProbeName   string
+	ScheduledAt time.Time
+	Reason      string
+}
+
+// FormatMissedRun creates a notification message for a missed run.
+func FormatMissedRun(missed *MissedRun) *Message {
+	return &Message{
+		Title: fmt.Sprintf("[missed] %s", missed.ProbeName),
+		Body: fmt.Sprintf("Run scheduled at %s did not happen: %s",
+			missed.ScheduledAt.UTC().Format("2006-01-02 15:04 MST"), missed.Reason),
+		Priority: PriorityHigh,
+		Tags:     []string{"missed"},
+	}
+}
+
+// WatcherHealthChange represents a watcher going offline or coming back.
+type WatcherHealthChange struct {
+	WatcherName string
+	Online      bool
+	LastSeen    time.Time
+	Downtime    time.Duration // Set on recovery
+}
+
+// FormatWatcherHealthChange creates a notification message for a watcher
+// that stopped or resumed sending heartbeats.
+func FormatWatcherHealthChange(change *WatcherHealthChange) *Message {
+	if change.Online {
+		return &Message{
+			Title:    fmt.Sprintf("[online] watcher %s", change.WatcherName),
+			Body:     fmt.Sprintf("Heartbeats resumed after %s", change.Downtime.Round(time.Second)),
+			Priority: PriorityNormal,
+			Tags:     []string{"watcher", "recovery"},
+		}
+	}
+	return &Message{
+		Title: fmt.Sprintf("[offline] watcher %s", change.WatcherName),
+		Body: fmt.Sprintf("No heartbeat since %s; its probes are not running",
+			change.LastSeen.UTC().Format("2006-01-02 15:04 MST")),
+		Priority: PriorityUrgent,
+		Tags:     []string{"watcher", "offline"},
 	}
 }
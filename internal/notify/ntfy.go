@@ -57,6 +57,17 @@ func (n *NtfyChannel) Send(ctx context.Context, msg *Message) error {
 		payload["tags"] = msg.Tags
 	}
 
+	// The ntfy app sends the request itself, without opening a browser
+	if msg.AckURL != "" {
+		payload["actions"] = []map[string]any{{
+			"action": "http",
+			"label":  "Acknowledge",
+			"url":    msg.AckURL,
+			"method": "POST",
+			"clear":  true,
+		}}
+	}
+
 	switch msg.Priority {
 	case PriorityLow:
 		payload["priority"] = 2
@@ -44,6 +44,10 @@ func (p *PushoverChannel) Send(ctx context.Context, msg *Message) error {
 		"title":   {msg.Title},
 		"message": {msg.Body},
 	}
+	if msg.AckURL != "" {
+		data.Set("url", msg.AckURL)
+		data.Set("url_title", "Acknowledge")
+	}
 
 	switch msg.Priority {
 	case PriorityLow:
@@ -0,0 +1,177 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// WebhookSignatureHeader carries the HMAC-SHA256 signature of the request
+// body when a webhook secret is configured.
+const WebhookSignatureHeader = "X-Monitor-Signature"
+
+// WebhookChannel sends notifications to an arbitrary HTTP endpoint.
+type WebhookChannel struct {
+	URL     string
+	Method  string
+	Headers map[string]string
+	Secret  string // Optional HMAC-SHA256 signing key
+	body    *template.Template
+	client  *http.Client
+}
+
+// WebhookConfig is the JSON configuration for a webhook channel.
+type WebhookConfig struct {
+	URL     string            `json:"url"`
+	Method  string            `json:"method,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    string            `json:"body,omitempty"`   // Go text/template; JSON payload if empty
+	Secret  string            `json:"secret,omitempty"` // Enables X-Monitor-Signature
+}
+
+// WebhookPayload is the data available to webhook body templates. It is also
+// the default JSON payload when no template is configured.
+type WebhookPayload struct {
+	ProbeName string    `json:"probe_name,omitempty"`
+	OldStatus string    `json:"old_status,omitempty"`
+	NewStatus string    `json:"new_status,omitempty"`
+	Message   string    `json:"message,omitempty"`
+	Title     string    `json:"title"`
+	Body      string    `json:"body"`
+	Priority  string    `json:"priority"`
+	Tags      []string  `json:"tags,omitempty"`
+	AckURL    string    `json:"ack_url,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+var webhookFuncs = template.FuncMap{
+	// json renders a value as JSON, for embedding strings safely in JSON bodies.
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+}
+
+// NewWebhookChannel creates a new webhook notification channel.
+func NewWebhookChannel(cfg WebhookConfig) (*WebhookChannel, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("webhook channel requires url")
+	}
+
+	method := strings.ToUpper(cfg.Method)
+	if method == "" {
+		method = http.MethodPost
+	}
+
+	var body *template.Template
+	if cfg.Body != "" {
+		var err error
+		body, err = template.New("webhook").Funcs(webhookFuncs).Parse(cfg.Body)
+		if err != nil {
+			return nil, fmt.Errorf("parse body template: %w", err)
+		}
+	}
+
+	return &WebhookChannel{
+		URL:     cfg.URL,
+		Method:  method,
+		Headers: cfg.Headers,
+		Secret:  cfg.Secret,
+		body:    body,
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+// Type returns the channel type.
+func (wh *WebhookChannel) Type() string {
+	return "webhook"
+}
+
+// Send sends a notification to the webhook URL.
+func (wh *WebhookChannel) Send(ctx context.Context, msg *Message) error {
+	body, err := wh.render(msg)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, wh.Method, wh.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+
+	// Templates usually produce JSON too; override via headers otherwise
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range wh.Headers {
+		req.Header.Set(k, v)
+	}
+	if wh.Secret != "" {
+		req.Header.Set(WebhookSignatureHeader, SignWebhookBody(wh.Secret, body))
+	}
+
+	resp, err := wh.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+func (wh *WebhookChannel) render(msg *Message) ([]byte, error) {
+	payload := newWebhookPayload(msg)
+
+	if wh.body == nil {
+		body, err := json.Marshal(payload)
+		if err != nil {
+			return nil, fmt.Errorf("marshal payload: %w", err)
+		}
+		return body, nil
+	}
+
+	var buf bytes.Buffer
+	if err := wh.body.Execute(&buf, payload); err != nil {
+		return nil, fmt.Errorf("render body template: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func newWebhookPayload(msg *Message) *WebhookPayload {
+	payload := &WebhookPayload{
+		Title:     msg.Title,
+		Body:      msg.Body,
+		Priority:  msg.Priority.String(),
+		Tags:      msg.Tags,
+		AckURL:    msg.AckURL,
+		Timestamp: time.Now().UTC(),
+	}
+	if change := msg.Change; change != nil {
+		payload.ProbeName = change.ProbeName
+		payload.OldStatus = string(change.OldStatus)
+		payload.NewStatus = string(change.NewStatus)
+		payload.Message = change.Message
+	}
+	return payload
+}
+
+// SignWebhookBody returns the signature header value for body, in the form
+// "sha256=<hex>". Receivers recompute it with the shared secret to verify
+// that a request came from the monitor.
+func SignWebhookBody(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,143 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jandubois/monitor/internal/probe"
+)
+
+// capturedRequest records a request received by a test webhook server.
+type capturedRequest struct {
+	Method  string
+	Headers http.Header
+	Body    []byte
+}
+
+func webhookServer(t *testing.T, status int) (*httptest.Server, <-chan capturedRequest) {
+	t.Helper()
+	ch := make(chan capturedRequest, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		ch <- capturedRequest{Method: r.Method, Headers: r.Header.Clone(), Body: body}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server, ch
+}
+
+func testStatusChangeMessage() *Message {
+	return FormatStatusChange(&StatusChange{
+		ProbeName: "nas disk",
+		OldStatus: probe.StatusOK,
+		NewStatus: probe.StatusCritical,
+		Message:   `only 5 GB "free"`,
+	})
+}
+
+func TestWebhookChannelDefaultPayload(t *testing.T) {
+	server, received := webhookServer(t, http.StatusOK)
+
+	ch, err := NewWebhookChannel(WebhookConfig{URL: server.URL})
+	if err != nil {
+		t.Fatalf("create channel: %v", err)
+	}
+	if err := ch.Send(context.Background(), testStatusChangeMessage()); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+
+	req := <-received
+	if req.Method != http.MethodPost {
+		t.Errorf("expected POST, got %s", req.Method)
+	}
+	if req.Headers.Get(WebhookSignatureHeader) != "" {
+		t.Error("expected no signature without secret")
+	}
+
+	var payload WebhookPayload
+	if err := json.Unmarshal(req.Body, &payload); err != nil {
+		t.Fatalf("decode payload: %v", err)
+	}
+	if payload.ProbeName != "nas disk" || payload.OldStatus != "ok" || payload.NewStatus != "critical" {
+		t.Errorf("unexpected payload: %+v", payload)
+	}
+	if payload.Priority != "urgent" {
+		t.Errorf("expected urgent priority, got %q", payload.Priority)
+	}
+}
+
+func TestWebhookChannelTemplateAndHeaders(t *testing.T) {
+	server, received := webhookServer(t, http.StatusOK)
+
+	ch, err := NewWebhookChannel(WebhookConfig{
+		URL:     server.URL,
+		Method:  "put",
+		Headers: map[string]string{"X-Api-Key": "k123"},
+		Body:    `{"text": {{json (printf "%s is %s: %s" .ProbeName (upper .NewStatus) .Message)}}}`,
+	})
+	if err != nil {
+		t.Fatalf("create channel: %v", err)
+	}
+	if err := ch.Send(context.Background(), testStatusChangeMessage()); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+
+	req := <-received
+	if req.Method != http.MethodPut {
+		t.Errorf("expected PUT, got %s", req.Method)
+	}
+	if req.Headers.Get("X-Api-Key") != "k123" {
+		t.Errorf("expected custom header, got %q", req.Headers.Get("X-Api-Key"))
+	}
+
+	var body map[string]string
+	if err := json.Unmarshal(req.Body, &body); err != nil {
+		t.Fatalf("template did not produce valid JSON: %v: %s", err, req.Body)
+	}
+	if want := `nas disk is CRITICAL: only 5 GB "free"`; body["text"] != want {
+		t.Errorf("expected text %q, got %q", want, body["text"])
+	}
+}
+
+func TestWebhookChannelSignature(t *testing.T) {
+	server, received := webhookServer(t, http.StatusOK)
+
+	ch, err := NewWebhookChannel(WebhookConfig{URL: server.URL, Secret: "s3cret"})
+	if err != nil {
+		t.Fatalf("create channel: %v", err)
+	}
+	if err := ch.Send(context.Background(), testStatusChangeMessage()); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+
+	req := <-received
+	got := req.Headers.Get(WebhookSignatureHeader)
+	if want := SignWebhookBody("s3cret", req.Body); got != want {
+		t.Errorf("expected signature %q, got %q", want, got)
+	}
+	if got == SignWebhookBody("wrong", req.Body) {
+		t.Error("signature should depend on the secret")
+	}
+}
+
+func TestWebhookChannelErrors(t *testing.T) {
+	if _, err := NewWebhookChannel(WebhookConfig{}); err == nil {
+		t.Error("expected error for missing url")
+	}
+	if _, err := NewWebhookChannel(WebhookConfig{URL: "http://example.com", Body: "{{.Broken"}); err == nil {
+		t.Error("expected error for invalid template")
+	}
+
+	server, _ := webhookServer(t, http.StatusInternalServerError)
+	ch, err := NewWebhookChannel(WebhookConfig{URL: server.URL})
+	if err != nil {
+		t.Fatalf("create channel: %v", err)
+	}
+	if err := ch.Send(context.Background(), &Message{Title: "test"}); err == nil {
+		t.Error("expected error for 500 response")
+	}
+}
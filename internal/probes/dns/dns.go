@@ -0,0 +1,389 @@
+// Package dns provides the DNS resolution probe.
+package dns
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jandubois/monitor/internal/probe"
+)
+
+// Name is the probe subcommand name.
+const Name = "dns"
+
+// resolvConf is where the default resolver is read from.
+const resolvConf = "/etc/resolv.conf"
+
+// Options are the probe arguments.
+type Options struct {
+	Name       string
+	Type       string // A, AAAA, CNAME, MX or TXT
+	Server     string // host or host:port; defaults to the system resolver
+	Expected   string // comma-separated values
+	Match      string // "contains" or "exact"
+	WarningMs  int
+	CriticalMs int
+	Timeout    time.Duration
+}
+
+// GetDescription returns the probe description.
+func GetDescription() probe.Description {
+	return probe.Description{
+		Name:        "dns",
+		Description: "Query a DNS resolver and check the answer",
+		Version:     "1.0.0",
+		Subcommand:  Name,
+		Arguments: probe.Arguments{
+			Required: map[string]probe.ArgumentSpec{
+				"name": {
+					Type:        "string",
+					Description: "Name to look up",
+				},
+			},
+			Optional: map[string]probe.ArgumentSpec{
+				"type": {
+					Type:        "string",
+					Description: "Record type",
+					Default:     "A",
+					Enum:        []string{"A", "AAAA", "CNAME", "MX", "TXT"},
+				},
+				"server": {
+					Type:        "string",
+					Description: "Resolver to query as host or host:port (defaults to the first nameserver in /etc/resolv.conf)",
+				},
+				"expected": {
+					Type:        "string",
+					Description: "Comma-separated values the answer must contain (MX as host or \"preference host\")",
+				},
+				"match": {
+					Type:        "string",
+					Description: "Whether the answer must contain the expected values or equal them",
+					Default:     "contains",
+					Enum:        []string{"contains", "exact"},
+				},
+				"warning_ms": {
+					Type:        "number",
+					Description: "Query time in milliseconds that triggers a warning (0 to disable)",
+					Default:     float64(0),
+				},
+				"critical_ms": {
+					Type:        "number",
+					Description: "Query time in milliseconds that is critical (0 to disable)",
+					Default:     float64(0),
+				},
+				"timeout_seconds": {
+					Type:        "number",
+					Description: "Query timeout in seconds",
+					Default:     float64(5),
+				},
+			},
+		},
+	}
+}
+
+// Run executes the probe with the given options.
+func Run(ctx context.Context, opts Options) *probe.Result {
+	if opts.Name == "" {
+		return &probe.Result{
+			Status:  probe.StatusUnknown,
+			Message: "name argument is required",
+		}
+	}
+
+	typeName := strings.ToUpper(opts.Type)
+	if typeName == "" {
+		typeName = "A"
+	}
+	qtype, ok := recordTypes[typeName]
+	if !ok {
+		return &probe.Result{
+			Status:  probe.StatusUnknown,
+			Message: fmt.Sprintf("unsupported record type %q", opts.Type),
+		}
+	}
+
+	match := opts.Match
+	if match == "" {
+		match = "contains"
+	}
+	if match != "contains" && match != "exact" {
+		return &probe.Result{
+			Status:  probe.StatusUnknown,
+			Message: fmt.Sprintf("invalid match %q", opts.Match),
+		}
+	}
+
+	server, err := resolverAddress(opts.Server)
+	if err != nil {
+		return &probe.Result{
+			Status:  probe.StatusUnknown,
+			Message: err.Error(),
+		}
+	}
+
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	name := strings.ToLower(strings.TrimSuffix(opts.Name, "."))
+	id := uint16(rand.N(1 << 16))
+	query, err := buildQuery(id, name, qtype)
+	if err != nil {
+		return &probe.Result{
+			Status:  probe.StatusUnknown,
+			Message: err.Error(),
+		}
+	}
+
+	start := time.Now()
+	resp, err := exchange(ctx, server, query, id)
+	queryTime := time.Since(start)
+
+	data := map[string]any{
+		"server": server,
+		"name":   name,
+		"type":   typeName,
+	}
+	if err != nil {
+		return &probe.Result{
+			Status:  probe.StatusCritical,
+			Message: fmt.Sprintf("query to %s failed: %v", server, err),
+			Data:    data,
+		}
+	}
+
+	var answers []string
+	var minTTL uint32
+	for _, rr := range resp.answers {
+		if rr.rtype != qtype {
+			// CNAMEs leading to the requested records
+			continue
+		}
+		answers = append(answers, rr.value)
+		if len(answers) == 1 || rr.ttl < minTTL {
+			minTTL = rr.ttl
+		}
+	}
+	data["rcode"] = rcodeName(resp.rcode)
+	data["answers"] = answers
+
+	metrics := map[string]any{
+		"query_ms":     msec(queryTime),
+		"answer_count": len(answers),
+	}
+	if len(answers) > 0 {
+		metrics["min_ttl"] = minTTL
+	}
+
+	status := probe.StatusOK
+	var failures []string
+
+	switch {
+	case resp.rcode != 0:
+		status = probe.StatusCritical
+		failures = append(failures, fmt.Sprintf("%s for %s %s", rcodeName(resp.rcode), name, typeName))
+	case len(answers) == 0:
+		status = probe.StatusCritical
+		failures = append(failures, fmt.Sprintf("no %s records for %s", typeName, name))
+	case opts.Expected != "":
+		expected := splitExpected(opts.Expected, qtype)
+		if missing := missingValues(expected, answers, qtype); len(missing) > 0 {
+			status = probe.StatusCritical
+			failures = append(failures, fmt.Sprintf("missing expected %s", strings.Join(missing, ", ")))
+		}
+		if match == "exact" {
+			if unexpected := unexpectedValues(expected, answers, qtype); len(unexpected) > 0 {
+				status = probe.StatusCritical
+				failures = append(failures, fmt.Sprintf("unexpected %s", strings.Join(unexpected, ", ")))
+			}
+		}
+	}
+
+	queryMs := msec(queryTime)
+	if opts.CriticalMs > 0 && queryMs >= float64(opts.CriticalMs) {
+		status = probe.StatusCritical
+		failures = append(failures, fmt.Sprintf("query took %.0f ms (critical %d ms)", queryMs, opts.CriticalMs))
+	} else if opts.WarningMs > 0 && queryMs >= float64(opts.WarningMs) {
+		if status == probe.StatusOK {
+			status = probe.StatusWarning
+		}
+		failures = append(failures, fmt.Sprintf("query took %.0f ms (warning %d ms)", queryMs, opts.WarningMs))
+	}
+
+	message := fmt.Sprintf("%s %s: %s (%.0f ms via %s)", name, typeName, strings.Join(answers, ", "), queryMs, server)
+	if len(failures) > 0 {
+		data["failures"] = failures
+		message = strings.Join(failures, "; ")
+	}
+
+	return &probe.Result{
+		Status:  status,
+		Message: message,
+		Metrics: metrics,
+		Data:    data,
+	}
+}
+
+// exchange sends query over UDP, retrying over TCP if the answer is
+// truncated.
+func exchange(ctx context.Context, server string, query []byte, id uint16) (*response, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "udp", server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if _, err := conn.Write(query); err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, 65535)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := parseResponse(buf[:n], id)
+		if err != nil {
+			// Ignore stray datagrams; a real answer may still arrive
+			continue
+		}
+		if resp.truncated {
+			return exchangeTCP(ctx, server, query, id)
+		}
+		return resp, nil
+	}
+}
+
+func exchangeTCP(ctx context.Context, server string, query []byte, id uint16) (*response, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	msg := binary.BigEndian.AppendUint16(nil, uint16(len(query)))
+	if _, err := conn.Write(append(msg, query...)); err != nil {
+		return nil, err
+	}
+
+	var length [2]byte
+	if _, err := io.ReadFull(conn, length[:]); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, binary.BigEndian.Uint16(length[:]))
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		return nil, err
+	}
+	return parseResponse(buf, id)
+}
+
+// resolverAddress returns server as host:port, defaulting to the first
+// nameserver in resolv.conf and port 53.
+func resolverAddress(server string) (string, error) {
+	if server == "" {
+		f, err := os.Open(resolvConf)
+		if err != nil {
+			return "", fmt.Errorf("no server given and %s unreadable: %v", resolvConf, err)
+		}
+		defer f.Close()
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			if len(fields) >= 2 && fields[0] == "nameserver" {
+				server = fields[1]
+				break
+			}
+		}
+		if server == "" {
+			return "", fmt.Errorf("no server given and no nameserver in %s", resolvConf)
+		}
+	}
+
+	if _, _, err := net.SplitHostPort(server); err == nil {
+		return server, nil
+	}
+	return net.JoinHostPort(strings.Trim(server, "[]"), "53"), nil
+}
+
+// splitExpected splits and normalizes the expected values.
+func splitExpected(s string, qtype uint16) []string {
+	var values []string
+	for _, v := range strings.Split(s, ",") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		values = append(values, normalize(v, qtype))
+	}
+	return values
+}
+
+func normalize(v string, qtype uint16) string {
+	switch qtype {
+	case typeA, typeAAAA:
+		if ip := net.ParseIP(v); ip != nil {
+			return ip.String()
+		}
+	case typeCNAME, typeMX:
+		return strings.ToLower(strings.TrimSuffix(v, "."))
+	}
+	return v
+}
+
+// matches reports whether answer satisfies expected. MX values match by
+// host alone or by "preference host".
+func matches(expected, answer string, qtype uint16) bool {
+	if expected == answer {
+		return true
+	}
+	if qtype == typeMX {
+		_, host, _ := strings.Cut(answer, " ")
+		return expected == host
+	}
+	return false
+}
+
+func missingValues(expected, answers []string, qtype uint16) []string {
+	var missing []string
+	for _, e := range expected {
+		if !slices.ContainsFunc(answers, func(a string) bool { return matches(e, a, qtype) }) {
+			missing = append(missing, e)
+		}
+	}
+	return missing
+}
+
+func unexpectedValues(expected, answers []string, qtype uint16) []string {
+	var unexpected []string
+	for _, a := range answers {
+		if !slices.ContainsFunc(expected, func(e string) bool { return matches(e, a, qtype) }) {
+			unexpected = append(unexpected, a)
+		}
+	}
+	return unexpected
+}
+
+func msec(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
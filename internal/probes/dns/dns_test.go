@@ -0,0 +1,293 @@
+package dns
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jandubois/monitor/internal/probe"
+)
+
+type testRecord struct {
+	rtype uint16
+	rdata []byte
+}
+
+// testServer answers queries for zone over UDP and TCP on the same port.
+// Names missing from the zone get NXDOMAIN. With truncate set, UDP answers
+// only carry the TC bit.
+type testServer struct {
+	zone     map[string][]testRecord
+	truncate bool
+	delay    time.Duration
+}
+
+func (s *testServer) start(t *testing.T) string {
+	t.Helper()
+	udp, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen udp: %v", err)
+	}
+	t.Cleanup(func() { udp.Close() })
+	tcp, err := net.Listen("tcp", udp.LocalAddr().String())
+	if err != nil {
+		t.Fatalf("listen tcp: %v", err)
+	}
+	t.Cleanup(func() { tcp.Close() })
+
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := udp.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			time.Sleep(s.delay)
+			udp.WriteTo(s.answer(buf[:n], s.truncate), addr)
+		}
+	}()
+	go func() {
+		for {
+			conn, err := tcp.Accept()
+			if err != nil {
+				return
+			}
+			var length [2]byte
+			io.ReadFull(conn, length[:])
+			query := make([]byte, binary.BigEndian.Uint16(length[:]))
+			io.ReadFull(conn, query)
+			reply := s.answer(query, false)
+			conn.Write(append(binary.BigEndian.AppendUint16(nil, uint16(len(reply))), reply...))
+			conn.Close()
+		}
+	}()
+
+	return udp.LocalAddr().String()
+}
+
+func (s *testServer) answer(query []byte, truncate bool) []byte {
+	name, end, _ := readName(query, headerSize)
+	qtype := binary.BigEndian.Uint16(query[end:])
+	question := query[headerSize : end+4]
+
+	records, exists := s.zone[name]
+	var answers []testRecord
+	for _, rr := range records {
+		if rr.rtype == qtype || rr.rtype == typeCNAME {
+			answers = append(answers, rr)
+		}
+	}
+
+	flags := uint16(0x8180) // QR, RD, RA
+	if !exists {
+		flags |= 3 // NXDOMAIN
+	}
+	if truncate {
+		flags |= 0x0200
+		answers = nil
+	}
+
+	msg := make([]byte, headerSize)
+	copy(msg, query[:2])
+	binary.BigEndian.PutUint16(msg[2:], flags)
+	binary.BigEndian.PutUint16(msg[4:], 1)
+	binary.BigEndian.PutUint16(msg[6:], uint16(len(answers)))
+	msg = append(msg, question...)
+	for _, rr := range answers {
+		msg = append(msg, 0xc0, headerSize) // Pointer to the question name
+		msg = binary.BigEndian.AppendUint16(msg, rr.rtype)
+		msg = binary.BigEndian.AppendUint16(msg, classIN)
+		msg = binary.BigEndian.AppendUint32(msg, 300)
+		msg = binary.BigEndian.AppendUint16(msg, uint16(len(rr.rdata)))
+		msg = append(msg, rr.rdata...)
+	}
+	return msg
+}
+
+func ipRecord(ip string) testRecord {
+	addr := net.ParseIP(ip)
+	if v4 := addr.To4(); v4 != nil {
+		return testRecord{typeA, v4}
+	}
+	return testRecord{typeAAAA, addr.To16()}
+}
+
+func nameRecord(rtype uint16, prefix []byte, name string) testRecord {
+	rdata, _ := appendName(prefix, name)
+	return testRecord{rtype, rdata}
+}
+
+func txtRecord(parts ...string) testRecord {
+	var rdata []byte
+	for _, p := range parts {
+		rdata = append(rdata, byte(len(p)))
+		rdata = append(rdata, p...)
+	}
+	return testRecord{typeTXT, rdata}
+}
+
+func testZone() map[string][]testRecord {
+	return map[string][]testRecord{
+		"example.test": {
+			ipRecord("192.0.2.1"),
+			ipRecord("192.0.2.2"),
+			ipRecord("2001:db8::1"),
+			nameRecord(typeMX, []byte{0, 10}, "mail.example.test"),
+			nameRecord(typeMX, []byte{0, 20}, "backup.example.test"),
+			txtRecord("v=spf1 ", "-all"),
+		},
+		"www.example.test": {
+			nameRecord(typeCNAME, nil, "example.test"),
+		},
+		"empty.example.test": {},
+	}
+}
+
+func TestRun(t *testing.T) {
+	server := (&testServer{zone: testZone()}).start(t)
+
+	tests := []struct {
+		name           string
+		opts           Options
+		expectedStatus probe.Status
+		messageContain string
+	}{
+		{
+			name:           "A",
+			opts:           Options{Name: "example.test", Expected: "192.0.2.1"},
+			expectedStatus: probe.StatusOK,
+			messageContain: "example.test A: 192.0.2.1, 192.0.2.2",
+		},
+		{
+			name:           "A exact",
+			opts:           Options{Name: "Example.Test.", Expected: "192.0.2.2, 192.0.2.1", Match: "exact"},
+			expectedStatus: probe.StatusOK,
+		},
+		{
+			name:           "A exact with extra record",
+			opts:           Options{Name: "example.test", Expected: "192.0.2.1", Match: "exact"},
+			expectedStatus: probe.StatusCritical,
+			messageContain: "unexpected 192.0.2.2",
+		},
+		{
+			name:           "A missing",
+			opts:           Options{Name: "example.test", Expected: "192.0.2.9"},
+			expectedStatus: probe.StatusCritical,
+			messageContain: "missing expected 192.0.2.9",
+		},
+		{
+			name:           "AAAA",
+			opts:           Options{Name: "example.test", Type: "aaaa", Expected: "2001:DB8:0::1"},
+			expectedStatus: probe.StatusOK,
+		},
+		{
+			name:           "CNAME",
+			opts:           Options{Name: "www.example.test", Type: "CNAME", Expected: "example.test."},
+			expectedStatus: probe.StatusOK,
+		},
+		{
+			name:           "MX by host and preference",
+			opts:           Options{Name: "example.test", Type: "MX", Expected: "mail.example.test,20 backup.example.test"},
+			expectedStatus: probe.StatusOK,
+		},
+		{
+			name:           "TXT",
+			opts:           Options{Name: "example.test", Type: "TXT", Expected: "v=spf1 -all"},
+			expectedStatus: probe.StatusOK,
+		},
+		{
+			name:           "NXDOMAIN",
+			opts:           Options{Name: "missing.example.test"},
+			expectedStatus: probe.StatusCritical,
+			messageContain: "NXDOMAIN for missing.example.test A",
+		},
+		{
+			name:           "no records",
+			opts:           Options{Name: "empty.example.test"},
+			expectedStatus: probe.StatusCritical,
+			messageContain: "no A records",
+		},
+		{
+			name:           "unsupported type",
+			opts:           Options{Name: "example.test", Type: "SRV"},
+			expectedStatus: probe.StatusUnknown,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.Server = server
+			result := Run(context.Background(), tt.opts)
+			if result.Status != tt.expectedStatus {
+				t.Errorf("expected status %q, got %q: %s", tt.expectedStatus, result.Status, result.Message)
+			}
+			if tt.messageContain != "" && !strings.Contains(result.Message, tt.messageContain) {
+				t.Errorf("expected message to contain %q, got %q", tt.messageContain, result.Message)
+			}
+		})
+	}
+}
+
+func TestRunMetrics(t *testing.T) {
+	server := (&testServer{zone: testZone()}).start(t)
+
+	result := Run(context.Background(), Options{Name: "example.test", Server: server})
+	if result.Status != probe.StatusOK {
+		t.Fatalf("expected ok, got %q: %s", result.Status, result.Message)
+	}
+	if _, ok := result.Metrics["query_ms"].(float64); !ok {
+		t.Errorf("expected query_ms metric, got %v", result.Metrics["query_ms"])
+	}
+	if result.Metrics["answer_count"] != 2 || result.Metrics["min_ttl"] != uint32(300) {
+		t.Errorf("unexpected metrics: %v", result.Metrics)
+	}
+	answers, _ := result.Data["answers"].([]string)
+	if len(answers) != 2 || result.Data["rcode"] != "NOERROR" {
+		t.Errorf("unexpected data: %v", result.Data)
+	}
+}
+
+func TestRunTruncatedFallsBackToTCP(t *testing.T) {
+	server := (&testServer{zone: testZone(), truncate: true}).start(t)
+
+	result := Run(context.Background(), Options{Name: "example.test", Type: "MX", Server: server, Expected: "mail.example.test"})
+	if result.Status != probe.StatusOK {
+		t.Errorf("expected ok, got %q: %s", result.Status, result.Message)
+	}
+}
+
+func TestRunLatency(t *testing.T) {
+	server := (&testServer{zone: testZone(), delay: 50 * time.Millisecond}).start(t)
+
+	result := Run(context.Background(), Options{Name: "example.test", Server: server, WarningMs: 20})
+	if result.Status != probe.StatusWarning {
+		t.Errorf("expected warning, got %q: %s", result.Status, result.Message)
+	}
+
+	result = Run(context.Background(), Options{Name: "example.test", Server: server, Timeout: 10 * time.Millisecond})
+	if result.Status != probe.StatusCritical || !strings.Contains(result.Message, "failed") {
+		t.Errorf("expected timeout to be critical, got %q: %s", result.Status, result.Message)
+	}
+}
+
+func TestResolverAddress(t *testing.T) {
+	tests := map[string]string{
+		"1.1.1.1":           "1.1.1.1:53",
+		"1.1.1.1:5353":      "1.1.1.1:5353",
+		"2606:4700::1111":   "[2606:4700::1111]:53",
+		"[2606:4700::1111]": "[2606:4700::1111]:53",
+		"dns.example.com":   "dns.example.com:53",
+	}
+	for input, expected := range tests {
+		got, err := resolverAddress(input)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", input, err)
+		} else if got != expected {
+			t.Errorf("%s: expected %s, got %s", input, expected, got)
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package dns
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Record types supported by the probe.
+const (
+	typeA     uint16 = 1
+	typeCNAME uint16 = 5
+	typeMX    uint16 = 15
+	typeTXT   uint16 = 16
+	typeAAAA  uint16 = 28
+)
+
+const classIN uint16 = 1
+
+const headerSize = 12
+
+var recordTypes = map[string]uint16{
+	"A":     typeA,
+	"AAAA":  typeAAAA,
+	"CNAME": typeCNAME,
+	"MX":    typeMX,
+	"TXT":   typeTXT,
+}
+
+var rcodeNames = map[int]string{
+	0: "NOERROR",
+	1: "FORMERR",
+	2: "SERVFAIL",
+	3: "NXDOMAIN",
+	4: "NOTIMP",
+	5: "REFUSED",
+}
+
+func rcodeName(rcode int) string {
+	if name, ok := rcodeNames[rcode]; ok {
+		return name
+	}
+	return fmt.Sprintf("RCODE%d", rcode)
+}
+
+// record is a parsed resource record. value is the presentation form:
+// an address, a name, "preference host" for MX, or the joined TXT strings.
+type record struct {
+	name  string
+	rtype uint16
+	ttl   uint32
+	value string
+}
+
+// response is the part of a DNS response the probe looks at.
+type response struct {
+	rcode     int
+	truncated bool
+	answers   []record
+}
+
+// buildQuery encodes a recursive query for name and qtype.
+func buildQuery(id uint16, name string, qtype uint16) ([]byte, error) {
+	msg := make([]byte, headerSize, 512)
+	binary.BigEndian.PutUint16(msg[0:], id)
+	binary.BigEndian.PutUint16(msg[2:], 0x0100) // RD
+	binary.BigEndian.PutUint16(msg[4:], 1)      // QDCOUNT
+
+	msg, err := appendName(msg, name)
+	if err != nil {
+		return nil, err
+	}
+	msg = binary.BigEndian.AppendUint16(msg, qtype)
+	msg = binary.BigEndian.AppendUint16(msg, classIN)
+	return msg, nil
+}
+
+// appendName appends name in uncompressed wire format.
+func appendName(msg []byte, name string) ([]byte, error) {
+	name = strings.TrimSuffix(name, ".")
+	if name != "" {
+		for _, label := range strings.Split(name, ".") {
+			if label == "" || len(label) > 63 {
+				return nil, fmt.Errorf("invalid name %q", name)
+			}
+			msg = append(msg, byte(len(label)))
+			msg = append(msg, label...)
+		}
+	}
+	return append(msg, 0), nil
+}
+
+// parseResponse parses a response to the query with the given id.
+func parseResponse(msg []byte, id uint16) (*response, error) {
+	if len(msg) < headerSize {
+		return nil, errors.New("response too short")
+	}
+	if binary.BigEndian.Uint16(msg[0:]) != id {
+		return nil, errors.New("response ID does not match query")
+	}
+	flags := binary.BigEndian.Uint16(msg[2:])
+	if flags&0x8000 == 0 {
+		return nil, errors.New("message is not a response")
+	}
+
+	resp := &response{
+		rcode:     int(flags & 0x000f),
+		truncated: flags&0x0200 != 0,
+	}
+	qdcount := int(binary.BigEndian.Uint16(msg[4:]))
+	ancount := int(binary.BigEndian.Uint16(msg[6:]))
+
+	off := headerSize
+	for range qdcount {
+		_, next, err := readName(msg, off)
+		if err != nil {
+			return nil, err
+		}
+		off = next + 4
+	}
+
+	for range ancount {
+		name, next, err := readName(msg, off)
+		if err != nil {
+			return nil, err
+		}
+		off = next
+		if off+10 > len(msg) {
+			return nil, errors.New("truncated resource record")
+		}
+		rtype := binary.BigEndian.Uint16(msg[off:])
+		ttl := binary.BigEndian.Uint32(msg[off+4:])
+		rdlength := int(binary.BigEndian.Uint16(msg[off+8:]))
+		off += 10
+		if off+rdlength > len(msg) {
+			return nil, errors.New("truncated resource data")
+		}
+
+		value, err := parseRData(msg, off, rdlength, rtype)
+		if err != nil {
+			return nil, err
+		}
+		off += rdlength
+
+		if value != "" {
+			resp.answers = append(resp.answers, record{name: name, rtype: rtype, ttl: ttl, value: value})
+		}
+	}
+
+	return resp, nil
+}
+
+// parseRData returns the presentation form of a record's data, or "" for
+// types the probe doesn't handle.
+func parseRData(msg []byte, off, length int, rtype uint16) (string, error) {
+	data := msg[off : off+length]
+	switch rtype {
+	case typeA:
+		if length != net.IPv4len {
+			return "", errors.New("invalid A record")
+		}
+		return net.IP(data).String(), nil
+	case typeAAAA:
+		if length != net.IPv6len {
+			return "", errors.New("invalid AAAA record")
+		}
+		return net.IP(data).String(), nil
+	case typeCNAME:
+		name, _, err := readName(msg, off)
+		return name, err
+	case typeMX:
+		if length < 3 {
+			return "", errors.New("invalid MX record")
+		}
+		host, _, err := readName(msg, off+2)
+		return fmt.Sprintf("%d %s", binary.BigEndian.Uint16(data), host), err
+	case typeTXT:
+		var sb strings.Builder
+		for i := 0; i < len(data); {
+			n := int(data[i])
+			if i+1+n > len(data) {
+				return "", errors.New("invalid TXT record")
+			}
+			sb.Write(data[i+1 : i+1+n])
+			i += 1 + n
+		}
+		return sb.String(), nil
+	}
+	return "", nil
+}
+
+// readName reads a possibly compressed name at off. It returns the name in
+// lower case without the trailing dot, and the offset after the name in
+// the original position.
+func readName(msg []byte, off int) (string, int, error) {
+	var labels []string
+	next := -1
+	for jumps := 0; ; {
+		if off >= len(msg) {
+			return "", 0, errors.New("truncated name")
+		}
+		n := int(msg[off])
+		switch {
+		case n == 0:
+			if next < 0 {
+				next = off + 1
+			}
+			return strings.ToLower(strings.Join(labels, ".")), next, nil
+		case n&0xc0 == 0xc0:
+			if off+1 >= len(msg) {
+				return "", 0, errors.New("truncated name pointer")
+			}
+			if jumps++; jumps > 64 {
+				return "", 0, errors.New("too many name pointers")
+			}
+			if next < 0 {
+				next = off + 2
+			}
+			off = int(binary.BigEndian.Uint16(msg[off:]) & 0x3fff)
+		default:
+			if off+1+n > len(msg) {
+				return "", 0, errors.New("truncated label")
+			}
+			labels = append(labels, string(msg[off+1:off+1+n]))
+			off += 1 + n
+		}
+	}
+}
@@ -0,0 +1,491 @@
+// Package http provides the HTTP(S) endpoint probe.
+package http
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jandubois/monitor/internal/probe"
+)
+
+// Name is the probe subcommand name.
+const Name = "http"
+
+// maxBodyBytes caps how much of the response body is read for matching.
+const maxBodyBytes = 10 * 1024 * 1024
+
+// bodyExcerptBytes is how much of the body is included in data when a
+// body check fails.
+const bodyExcerptBytes = 1024
+
+// Options are the probe arguments.
+type Options struct {
+	URL             string
+	Method          string
+	Headers         string // JSON object of header names to values
+	Body            string
+	ExpectedStatus  string // e.g. "200", "200-299,304", "2xx"
+	BodyContains    string
+	BodyRegex       string
+	JSONAssertions  string // JSON object of paths to expected values
+	WarningMs       int
+	CriticalMs      int
+	FollowRedirects bool
+	MaxRedirects    int
+	Insecure        bool
+	Timeout         time.Duration
+}
+
+// GetDescription returns the probe description.
+func GetDescription() probe.Description {
+	return probe.Description{
+		Name:        "http",
+		Description: "Check an HTTP(S) endpoint",
+		Version:     "1.0.0",
+		Subcommand:  Name,
+		Arguments: probe.Arguments{
+			Required: map[string]probe.ArgumentSpec{
+				"url": {
+					Type:        "string",
+					Description: "URL to request",
+				},
+			},
+			Optional: map[string]probe.ArgumentSpec{
+				"method": {
+					Type:        "string",
+					Description: "HTTP method",
+					Default:     "GET",
+					Enum:        []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+				},
+				"headers": {
+					Type:        "string",
+					Description: `Request headers as a JSON object, e.g. {"Accept": "application/json"}`,
+				},
+				"body": {
+					Type:        "string",
+					Description: "Request body",
+				},
+				"expected_status": {
+					Type:        "string",
+					Description: "Comma-separated status codes or ranges that count as success (e.g. 200,301-302,2xx)",
+					Default:     "200-299",
+				},
+				"body_contains": {
+					Type:        "string",
+					Description: "Substring the response body must contain",
+				},
+				"body_regex": {
+					Type:        "string",
+					Description: "Regular expression the response body must match",
+				},
+				"json_assertions": {
+					Type:        "string",
+					Description: `Expected values by JSON path, e.g. {"status": "ok", "checks.0.healthy": true}`,
+				},
+				"warning_ms": {
+					Type:        "number",
+					Description: "Response time in milliseconds that triggers a warning (0 to disable)",
+					Default:     float64(0),
+				},
+				"critical_ms": {
+					Type:        "number",
+					Description: "Response time in milliseconds that is critical (0 to disable)",
+					Default:     float64(0),
+				},
+				"follow_redirects": {
+					Type:        "boolean",
+					Description: "Follow redirects; otherwise the redirect response itself is checked",
+					Default:     true,
+				},
+				"max_redirects": {
+					Type:        "number",
+					Description: "Maximum number of redirects to follow",
+					Default:     float64(10),
+				},
+				"insecure": {
+					Type:        "boolean",
+					Description: "Skip TLS certificate verification",
+					Default:     false,
+				},
+				"timeout_seconds": {
+					Type:        "number",
+					Description: "Request timeout in seconds",
+					Default:     float64(30),
+				},
+			},
+		},
+	}
+}
+
+// timings records the phases of the most recent request. With redirects
+// this is the final hop.
+type timings struct {
+	start        time.Time
+	dnsStart     time.Time
+	dns          time.Duration
+	connectStart time.Time
+	connect      time.Duration
+	tlsStart     time.Time
+	tls          time.Duration
+	ttfb         time.Duration
+}
+
+func (t *timings) trace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		GetConn: func(string) {
+			*t = timings{start: time.Now()}
+		},
+		DNSStart: func(httptrace.DNSStartInfo) { t.dnsStart = time.Now() },
+		DNSDone:  func(httptrace.DNSDoneInfo) { t.dns = time.Since(t.dnsStart) },
+		ConnectStart: func(string, string) {
+			if t.connectStart.IsZero() {
+				t.connectStart = time.Now()
+			}
+		},
+		ConnectDone:       func(string, string, error) { t.connect = time.Since(t.connectStart) },
+		TLSHandshakeStart: func() { t.tlsStart = time.Now() },
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			t.tls = time.Since(t.tlsStart)
+		},
+		GotFirstResponseByte: func() { t.ttfb = time.Since(t.start) },
+	}
+}
+
+// Run executes the probe with the given options.
+func Run(ctx context.Context, opts Options) *probe.Result {
+	if opts.URL == "" {
+		return &probe.Result{
+			Status:  probe.StatusUnknown,
+			Message: "url argument is required",
+		}
+	}
+
+	method := strings.ToUpper(opts.Method)
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	headers := map[string]string{}
+	if opts.Headers != "" {
+		if err := json.Unmarshal([]byte(opts.Headers), &headers); err != nil {
+			return &probe.Result{
+				Status:  probe.StatusUnknown,
+				Message: fmt.Sprintf("invalid headers: %v", err),
+			}
+		}
+	}
+
+	expected := opts.ExpectedStatus
+	if expected == "" {
+		expected = "200-299"
+	}
+	statusOK, err := parseStatusCodes(expected)
+	if err != nil {
+		return &probe.Result{
+			Status:  probe.StatusUnknown,
+			Message: fmt.Sprintf("invalid expected_status: %v", err),
+		}
+	}
+
+	var bodyRegex *regexp.Regexp
+	if opts.BodyRegex != "" {
+		bodyRegex, err = regexp.Compile(opts.BodyRegex)
+		if err != nil {
+			return &probe.Result{
+				Status:  probe.StatusUnknown,
+				Message: fmt.Sprintf("invalid body_regex: %v", err),
+			}
+		}
+	}
+
+	var assertions map[string]any
+	if opts.JSONAssertions != "" {
+		if err := json.Unmarshal([]byte(opts.JSONAssertions), &assertions); err != nil {
+			return &probe.Result{
+				Status:  probe.StatusUnknown,
+				Message: fmt.Sprintf("invalid json_assertions: %v", err),
+			}
+		}
+	}
+
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var timing timings
+	req, err := http.NewRequestWithContext(httptrace.WithClientTrace(ctx, timing.trace()), method, opts.URL, strings.NewReader(opts.Body))
+	if err != nil {
+		return &probe.Result{
+			Status:  probe.StatusUnknown,
+			Message: fmt.Sprintf("invalid request: %v", err),
+		}
+	}
+	req.Header.Set("User-Agent", "monitor-probe")
+	for name, value := range headers {
+		if strings.EqualFold(name, "Host") {
+			req.Host = value
+			continue
+		}
+		req.Header.Set(name, value)
+	}
+
+	redirects := 0
+	client := &http.Client{
+		Transport: &http.Transport{
+			Proxy:             http.ProxyFromEnvironment,
+			DisableKeepAlives: true,
+			TLSClientConfig:   &tls.Config{InsecureSkipVerify: opts.Insecure},
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if !opts.FollowRedirects {
+				return http.ErrUseLastResponse
+			}
+			if len(via) > opts.MaxRedirects {
+				return fmt.Errorf("stopped after %d redirects", opts.MaxRedirects)
+			}
+			redirects = len(via)
+			return nil
+		},
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return &probe.Result{
+			Status:  probe.StatusCritical,
+			Message: fmt.Sprintf("request failed: %v", unwrapURLError(err)),
+			Metrics: map[string]any{
+				"total_ms": msec(time.Since(start)),
+			},
+			Data: map[string]any{
+				"url":    opts.URL,
+				"method": method,
+			},
+		}
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBodyBytes))
+	total := time.Since(start)
+	if err != nil {
+		return &probe.Result{
+			Status:  probe.StatusCritical,
+			Message: fmt.Sprintf("failed to read response body: %v", err),
+		}
+	}
+
+	metrics := map[string]any{
+		"dns_ms":      msec(timing.dns),
+		"connect_ms":  msec(timing.connect),
+		"tls_ms":      msec(timing.tls),
+		"ttfb_ms":     msec(timing.ttfb),
+		"total_ms":    msec(total),
+		"status_code": resp.StatusCode,
+		"body_bytes":  len(body),
+	}
+	data := map[string]any{
+		"url":          opts.URL,
+		"final_url":    resp.Request.URL.String(),
+		"method":       method,
+		"status_code":  resp.StatusCode,
+		"redirects":    redirects,
+		"content_type": resp.Header.Get("Content-Type"),
+	}
+
+	status := probe.StatusOK
+	var failures []string
+
+	if !statusOK(resp.StatusCode) {
+		status = probe.StatusCritical
+		failures = append(failures, fmt.Sprintf("unexpected status %d (expected %s)", resp.StatusCode, expected))
+	}
+
+	bodyFailed := false
+	if opts.BodyContains != "" && !strings.Contains(string(body), opts.BodyContains) {
+		bodyFailed = true
+		failures = append(failures, fmt.Sprintf("body does not contain %q", opts.BodyContains))
+	}
+	if bodyRegex != nil && !bodyRegex.Match(body) {
+		bodyFailed = true
+		failures = append(failures, fmt.Sprintf("body does not match /%s/", opts.BodyRegex))
+	}
+	if len(assertions) > 0 {
+		for _, failure := range checkJSONAssertions(body, assertions) {
+			bodyFailed = true
+			failures = append(failures, failure)
+		}
+	}
+	if bodyFailed {
+		status = probe.StatusCritical
+		excerpt := body
+		if len(excerpt) > bodyExcerptBytes {
+			excerpt = excerpt[:bodyExcerptBytes]
+		}
+		data["body_excerpt"] = string(excerpt)
+	}
+
+	totalMs := msec(total)
+	if opts.CriticalMs > 0 && totalMs >= float64(opts.CriticalMs) {
+		status = probe.StatusCritical
+		failures = append(failures, fmt.Sprintf("response took %.0f ms (critical %d ms)", totalMs, opts.CriticalMs))
+	} else if opts.WarningMs > 0 && totalMs >= float64(opts.WarningMs) {
+		if status == probe.StatusOK {
+			status = probe.StatusWarning
+		}
+		failures = append(failures, fmt.Sprintf("response took %.0f ms (warning %d ms)", totalMs, opts.WarningMs))
+	}
+
+	message := fmt.Sprintf("%s from %s in %.0f ms", resp.Status, opts.URL, totalMs)
+	if len(failures) > 0 {
+		data["failures"] = failures
+		message = strings.Join(failures, "; ")
+	}
+
+	return &probe.Result{
+		Status:  status,
+		Message: message,
+		Metrics: metrics,
+		Data:    data,
+	}
+}
+
+// parseStatusCodes parses a list like "200,301-302,2xx" into a matcher.
+func parseStatusCodes(s string) (func(int) bool, error) {
+	type codeRange struct{ lo, hi int }
+	var ranges []codeRange
+
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		if len(part) == 3 && strings.HasSuffix(strings.ToLower(part), "xx") {
+			class, err := strconv.Atoi(part[:1])
+			if err != nil || class < 1 || class > 5 {
+				return nil, fmt.Errorf("invalid status class %q", part)
+			}
+			ranges = append(ranges, codeRange{class * 100, class*100 + 99})
+			continue
+		}
+
+		lo, hi, isRange := strings.Cut(part, "-")
+		from, err := strconv.Atoi(strings.TrimSpace(lo))
+		if err != nil {
+			return nil, fmt.Errorf("invalid status code %q", part)
+		}
+		to := from
+		if isRange {
+			to, err = strconv.Atoi(strings.TrimSpace(hi))
+			if err != nil || to < from {
+				return nil, fmt.Errorf("invalid status range %q", part)
+			}
+		}
+		ranges = append(ranges, codeRange{from, to})
+	}
+
+	if len(ranges) == 0 {
+		return nil, errors.New("no status codes given")
+	}
+
+	return func(code int) bool {
+		for _, r := range ranges {
+			if code >= r.lo && code <= r.hi {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
+// checkJSONAssertions decodes body as JSON and compares the value at each
+// path with the expected value. It returns a description of each mismatch.
+func checkJSONAssertions(body []byte, assertions map[string]any) []string {
+	var doc any
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return []string{fmt.Sprintf("body is not valid JSON: %v", err)}
+	}
+
+	paths := make([]string, 0, len(assertions))
+	for path := range assertions {
+		paths = append(paths, path)
+	}
+	// Report failures in a stable order
+	sort.Strings(paths)
+
+	var failures []string
+	for _, path := range paths {
+		want := assertions[path]
+		got, ok := lookupJSONPath(doc, path)
+		if !ok {
+			failures = append(failures, fmt.Sprintf("JSON path %s not found", path))
+			continue
+		}
+		if !reflect.DeepEqual(got, want) {
+			gotJSON, _ := json.Marshal(got)
+			wantJSON, _ := json.Marshal(want)
+			failures = append(failures, fmt.Sprintf("JSON path %s is %s, expected %s", path, gotJSON, wantJSON))
+		}
+	}
+	return failures
+}
+
+// lookupJSONPath resolves a dotted path like "$.checks.0.status" in a
+// decoded JSON document. Numeric segments index into arrays.
+func lookupJSONPath(doc any, path string) (any, bool) {
+	path = strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	if path == "" {
+		return doc, true
+	}
+
+	current := doc
+	for _, segment := range strings.Split(path, ".") {
+		switch node := current.(type) {
+		case map[string]any:
+			value, ok := node[segment]
+			if !ok {
+				return nil, false
+			}
+			current = value
+		case []any:
+			index, err := strconv.Atoi(segment)
+			if err != nil || index < 0 || index >= len(node) {
+				return nil, false
+			}
+			current = node[index]
+		default:
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// unwrapURLError strips the "Get \"url\": " prefix that net/http adds,
+// since the URL is already in the result data.
+func unwrapURLError(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Err
+	}
+	return err
+}
+
+func msec(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
@@ -0,0 +1,294 @@
+package http
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jandubois/monitor/internal/probe"
+)
+
+func TestParseStatusCodes(t *testing.T) {
+	tests := []struct {
+		spec    string
+		match   []int
+		noMatch []int
+	}{
+		{"200", []int{200}, []int{201, 404}},
+		{"200-299", []int{200, 204, 299}, []int{199, 300}},
+		{"2xx,304", []int{200, 250, 304}, []int{301, 500}},
+		{" 301 , 302 ", []int{301, 302}, []int{200}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			matches, err := parseStatusCodes(tt.spec)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			for _, code := range tt.match {
+				if !matches(code) {
+					t.Errorf("expected %d to match", code)
+				}
+			}
+			for _, code := range tt.noMatch {
+				if matches(code) {
+					t.Errorf("expected %d not to match", code)
+				}
+			}
+		})
+	}
+
+	for _, spec := range []string{"", "abc", "300-200", "9xx"} {
+		if _, err := parseStatusCodes(spec); err == nil {
+			t.Errorf("expected error for %q", spec)
+		}
+	}
+}
+
+func TestLookupJSONPath(t *testing.T) {
+	doc := map[string]any{
+		"status": "ok",
+		"checks": []any{
+			map[string]any{"name": "db", "healthy": true},
+		},
+	}
+
+	tests := []struct {
+		path  string
+		want  any
+		found bool
+	}{
+		{"status", "ok", true},
+		{"$.status", "ok", true},
+		{"checks.0.name", "db", true},
+		{"checks.0.healthy", true, true},
+		{"checks.1.name", nil, false},
+		{"status.length", nil, false},
+		{"missing", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got, ok := lookupJSONPath(doc, tt.path)
+			if ok != tt.found {
+				t.Fatalf("expected found=%v, got %v", tt.found, ok)
+			}
+			if ok && got != tt.want {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestRunEmptyURL(t *testing.T) {
+	result := Run(context.Background(), Options{})
+	if result.Status != probe.StatusUnknown {
+		t.Errorf("expected status %q, got %q", probe.StatusUnknown, result.Status)
+	}
+}
+
+func TestRun(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"status": "ok", "version": "1.2.3", "checks": [{"name": "db", "healthy": true}]}`)
+	})
+	mux.HandleFunc("/echo", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "text/plain")
+		io.WriteString(w, r.Method+" "+r.Header.Get("X-Token")+" "+string(body))
+	})
+	mux.HandleFunc("/fail", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "broken", http.StatusInternalServerError)
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+	})
+	mux.HandleFunc("/moved", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/health", http.StatusFound)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	tests := []struct {
+		name           string
+		opts           Options
+		expectedStatus probe.Status
+		messageContain string
+	}{
+		{
+			name:           "ok",
+			opts:           Options{URL: server.URL + "/health"},
+			expectedStatus: probe.StatusOK,
+			messageContain: "200 OK",
+		},
+		{
+			name: "method, headers and body",
+			opts: Options{
+				URL:          server.URL + "/echo",
+				Method:       "post",
+				Headers:      `{"X-Token": "secret"}`,
+				Body:         "payload",
+				BodyContains: "POST secret payload",
+			},
+			expectedStatus: probe.StatusOK,
+		},
+		{
+			name:           "unexpected status",
+			opts:           Options{URL: server.URL + "/fail"},
+			expectedStatus: probe.StatusCritical,
+			messageContain: "unexpected status 500",
+		},
+		{
+			name:           "expected error status",
+			opts:           Options{URL: server.URL + "/fail", ExpectedStatus: "5xx"},
+			expectedStatus: probe.StatusOK,
+		},
+		{
+			name:           "body substring missing",
+			opts:           Options{URL: server.URL + "/health", BodyContains: "degraded"},
+			expectedStatus: probe.StatusCritical,
+			messageContain: `body does not contain "degraded"`,
+		},
+		{
+			name:           "body regex",
+			opts:           Options{URL: server.URL + "/health", BodyRegex: `"version": "1\.\d+\.\d+"`},
+			expectedStatus: probe.StatusOK,
+		},
+		{
+			name:           "body regex mismatch",
+			opts:           Options{URL: server.URL + "/health", BodyRegex: `"version": "2\.`},
+			expectedStatus: probe.StatusCritical,
+			messageContain: "body does not match",
+		},
+		{
+			name:           "json assertions",
+			opts:           Options{URL: server.URL + "/health", JSONAssertions: `{"status": "ok", "checks.0.healthy": true}`},
+			expectedStatus: probe.StatusOK,
+		},
+		{
+			name:           "json assertion mismatch",
+			opts:           Options{URL: server.URL + "/health", JSONAssertions: `{"checks.0.healthy": false}`},
+			expectedStatus: probe.StatusCritical,
+			messageContain: "JSON path checks.0.healthy is true, expected false",
+		},
+		{
+			name:           "latency warning",
+			opts:           Options{URL: server.URL + "/slow", WarningMs: 20},
+			expectedStatus: probe.StatusWarning,
+			messageContain: "warning 20 ms",
+		},
+		{
+			name:           "latency critical",
+			opts:           Options{URL: server.URL + "/slow", WarningMs: 10, CriticalMs: 20},
+			expectedStatus: probe.StatusCritical,
+			messageContain: "critical 20 ms",
+		},
+		{
+			name:           "follow redirects",
+			opts:           Options{URL: server.URL + "/moved", FollowRedirects: true, MaxRedirects: 10, BodyContains: "ok"},
+			expectedStatus: probe.StatusOK,
+		},
+		{
+			name:           "too many redirects",
+			opts:           Options{URL: server.URL + "/moved", FollowRedirects: true, MaxRedirects: 0},
+			expectedStatus: probe.StatusCritical,
+			messageContain: "stopped after 0 redirects",
+		},
+		{
+			name:           "redirect not followed",
+			opts:           Options{URL: server.URL + "/moved", ExpectedStatus: "302"},
+			expectedStatus: probe.StatusOK,
+		},
+		{
+			name:           "invalid regex",
+			opts:           Options{URL: server.URL + "/health", BodyRegex: "("},
+			expectedStatus: probe.StatusUnknown,
+			messageContain: "invalid body_regex",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := Run(context.Background(), tt.opts)
+			if result.Status != tt.expectedStatus {
+				t.Errorf("expected status %q, got %q: %s", tt.expectedStatus, result.Status, result.Message)
+			}
+			if tt.messageContain != "" && !strings.Contains(result.Message, tt.messageContain) {
+				t.Errorf("expected message to contain %q, got %q", tt.messageContain, result.Message)
+			}
+		})
+	}
+}
+
+func TestRunRedirectData(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/a", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/b", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/b", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/c", http.StatusFound)
+	})
+	mux.HandleFunc("/c", func(w http.ResponseWriter, r *http.Request) {})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	result := Run(context.Background(), Options{URL: server.URL + "/a", FollowRedirects: true, MaxRedirects: 5})
+	if result.Status != probe.StatusOK {
+		t.Fatalf("expected ok, got %q: %s", result.Status, result.Message)
+	}
+	if result.Data["redirects"] != 2 {
+		t.Errorf("expected 2 redirects, got %v", result.Data["redirects"])
+	}
+	if result.Data["final_url"] != server.URL+"/c" {
+		t.Errorf("unexpected final_url %v", result.Data["final_url"])
+	}
+}
+
+func TestRunTLSTimings(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "secure")
+	}))
+	defer server.Close()
+
+	// The test server's certificate is self-signed
+	result := Run(context.Background(), Options{URL: server.URL})
+	if result.Status != probe.StatusCritical {
+		t.Errorf("expected untrusted certificate to be critical, got %q", result.Status)
+	}
+
+	result = Run(context.Background(), Options{URL: server.URL, Insecure: true})
+	if result.Status != probe.StatusOK {
+		t.Fatalf("expected ok, got %q: %s", result.Status, result.Message)
+	}
+	for _, metric := range []string{"dns_ms", "connect_ms", "tls_ms", "ttfb_ms", "total_ms"} {
+		if _, ok := result.Metrics[metric].(float64); !ok {
+			t.Errorf("expected %s metric, got %v", metric, result.Metrics[metric])
+		}
+	}
+	if result.Metrics["tls_ms"].(float64) <= 0 {
+		t.Error("expected a TLS handshake time")
+	}
+	if result.Metrics["ttfb_ms"].(float64) > result.Metrics["total_ms"].(float64) {
+		t.Error("time to first byte should not exceed total time")
+	}
+}
+
+func TestRunConnectionRefused(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	url := server.URL
+	server.Close()
+
+	result := Run(context.Background(), Options{URL: url, Timeout: time.Second})
+	if result.Status != probe.StatusCritical {
+		t.Errorf("expected status %q, got %q", probe.StatusCritical, result.Status)
+	}
+	if !strings.HasPrefix(result.Message, "request failed: ") || strings.Contains(result.Message, url) {
+		t.Errorf("unexpected message: %s", result.Message)
+	}
+}
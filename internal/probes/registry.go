@@ -6,8 +6,12 @@ import (
 	"github.com/jandubois/monitor/internal/probes/command"
 	"github.com/jandubois/monitor/internal/probes/debug"
 	"github.com/jandubois/monitor/internal/probes/diskspace"
+	"github.com/jandubois/monitor/internal/probes/dns"
 	"github.com/jandubois/monitor/internal/probes/github"
 	"github.com/jandubois/monitor/internal/probes/gitstatus"
+	httpprobe "github.com/jandubois/monitor/internal/probes/http"
+	"github.com/jandubois/monitor/internal/probes/tcpport"
+	"github.com/jandubois/monitor/internal/probes/tlscert"
 )
 
 // GetAllDescriptions returns descriptions of all built-in probes.
@@ -16,7 +20,11 @@ func GetAllDescriptions() []probe.Description {
 		command.GetDescription(),
 		debug.GetDescription(),
 		diskspace.GetDescription(),
+		dns.GetDescription(),
 		github.GetDescription(),
 		gitstatus.GetDescription(),
+		httpprobe.GetDescription(),
+		tcpport.GetDescription(),
+		tlscert.GetDescription(),
 	}
 }
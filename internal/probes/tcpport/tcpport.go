@@ -0,0 +1,247 @@
+// Package tcpport provides the TCP/UDP port probe.
+package tcpport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jandubois/monitor/internal/probe"
+)
+
+// Name is the probe subcommand name.
+const Name = "tcp-port"
+
+// maxReadBytes caps how much is read while waiting for a banner or reply.
+const maxReadBytes = 64 * 1024
+
+// excerptBytes is how much of a banner or reply is included in data.
+const excerptBytes = 1024
+
+// Options are the probe arguments.
+type Options struct {
+	Host       string
+	Port       int
+	Protocol   string // "tcp" or "udp"
+	Banner     string // substring the server must send after connecting
+	Send       string // sent after the banner
+	Expect     string // substring the reply to Send must contain
+	WarningMs  int
+	CriticalMs int
+	Timeout    time.Duration
+}
+
+// GetDescription returns the probe description.
+func GetDescription() probe.Description {
+	return probe.Description{
+		Name:        "tcp-port",
+		Description: "Check that a TCP or UDP port answers",
+		Version:     "1.0.0",
+		Subcommand:  Name,
+		Arguments: probe.Arguments{
+			Required: map[string]probe.ArgumentSpec{
+				"host": {
+					Type:        "string",
+					Description: "Host to connect to",
+				},
+				"port": {
+					Type:        "number",
+					Description: "Port to connect to",
+				},
+			},
+			Optional: map[string]probe.ArgumentSpec{
+				"protocol": {
+					Type:        "string",
+					Description: "Transport protocol (udp requires send and expect)",
+					Default:     "tcp",
+					Enum:        []string{"tcp", "udp"},
+				},
+				"banner": {
+					Type:        "string",
+					Description: "Substring the server must send after connecting",
+				},
+				"send": {
+					Type:        "string",
+					Description: `Data to send (supports \r, \n, \t and \\ escapes)`,
+				},
+				"expect": {
+					Type:        "string",
+					Description: "Substring the reply to send must contain",
+				},
+				"warning_ms": {
+					Type:        "number",
+					Description: "Total time in milliseconds that triggers a warning (0 to disable)",
+					Default:     float64(0),
+				},
+				"critical_ms": {
+					Type:        "number",
+					Description: "Total time in milliseconds that is critical (0 to disable)",
+					Default:     float64(0),
+				},
+				"timeout_seconds": {
+					Type:        "number",
+					Description: "Timeout in seconds",
+					Default:     float64(10),
+				},
+			},
+		},
+	}
+}
+
+// Run executes the probe with the given options.
+func Run(ctx context.Context, opts Options) *probe.Result {
+	if opts.Host == "" || opts.Port == 0 {
+		return &probe.Result{
+			Status:  probe.StatusUnknown,
+			Message: "host and port arguments are required",
+		}
+	}
+
+	protocol := strings.ToLower(opts.Protocol)
+	if protocol == "" {
+		protocol = "tcp"
+	}
+	switch protocol {
+	case "tcp":
+	case "udp":
+		// UDP has no connection, so only a reply proves the port is open
+		if opts.Send == "" || opts.Expect == "" {
+			return &probe.Result{
+				Status:  probe.StatusUnknown,
+				Message: "udp requires send and expect arguments",
+			}
+		}
+	default:
+		return &probe.Result{
+			Status:  probe.StatusUnknown,
+			Message: fmt.Sprintf("unsupported protocol %q", opts.Protocol),
+		}
+	}
+
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	address := net.JoinHostPort(opts.Host, strconv.Itoa(opts.Port))
+	data := map[string]any{
+		"address":  address,
+		"protocol": protocol,
+	}
+	critical := func(format string, args ...any) *probe.Result {
+		return &probe.Result{
+			Status:  probe.StatusCritical,
+			Message: fmt.Sprintf(format, args...),
+			Data:    data,
+		}
+	}
+
+	start := time.Now()
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, protocol, address)
+	if err != nil {
+		return critical("failed to connect to %s: %v", address, err)
+	}
+	defer conn.Close()
+	connectTime := time.Since(start)
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	metrics := map[string]any{
+		"connect_ms": msec(connectTime),
+	}
+
+	if opts.Banner != "" {
+		bannerStart := time.Now()
+		banner, err := readUntil(conn, unescape(opts.Banner))
+		data["banner"] = excerpt(banner)
+		if err != nil {
+			return critical("banner from %s does not contain %q: %v", address, opts.Banner, err)
+		}
+		metrics["banner_ms"] = msec(time.Since(bannerStart))
+	}
+
+	if opts.Send != "" {
+		sendStart := time.Now()
+		if _, err := conn.Write([]byte(unescape(opts.Send))); err != nil {
+			return critical("failed to send to %s: %v", address, err)
+		}
+		if opts.Expect != "" {
+			reply, err := readUntil(conn, unescape(opts.Expect))
+			data["response"] = excerpt(reply)
+			if err != nil {
+				return critical("reply from %s does not contain %q: %v", address, opts.Expect, err)
+			}
+			metrics["response_ms"] = msec(time.Since(sendStart))
+		}
+	}
+
+	total := time.Since(start)
+	totalMs := msec(total)
+	metrics["total_ms"] = totalMs
+
+	status := probe.StatusOK
+	message := fmt.Sprintf("%s %s answered in %.0f ms", strings.ToUpper(protocol), address, totalMs)
+	if opts.CriticalMs > 0 && totalMs >= float64(opts.CriticalMs) {
+		status = probe.StatusCritical
+		message = fmt.Sprintf("%s took %.0f ms (critical %d ms)", address, totalMs, opts.CriticalMs)
+	} else if opts.WarningMs > 0 && totalMs >= float64(opts.WarningMs) {
+		status = probe.StatusWarning
+		message = fmt.Sprintf("%s took %.0f ms (warning %d ms)", address, totalMs, opts.WarningMs)
+	}
+
+	return &probe.Result{
+		Status:  status,
+		Message: message,
+		Metrics: metrics,
+		Data:    data,
+	}
+}
+
+// readUntil reads from conn until the data contains want, the connection
+// is closed, or the deadline passes. It returns everything read.
+func readUntil(conn net.Conn, want string) (string, error) {
+	var buf []byte
+	chunk := make([]byte, 4096)
+	for len(buf) < maxReadBytes {
+		n, err := conn.Read(chunk)
+		buf = append(buf, chunk[:n]...)
+		if strings.Contains(string(buf), want) {
+			return string(buf), nil
+		}
+		if err != nil {
+			if errors.Is(err, os.ErrDeadlineExceeded) {
+				err = errors.New("timed out")
+			}
+			return string(buf), err
+		}
+	}
+	return string(buf), fmt.Errorf("not found in first %d bytes", maxReadBytes)
+}
+
+var escapes = strings.NewReplacer(`\r`, "\r", `\n`, "\n", `\t`, "\t", `\\`, `\`)
+
+// unescape expands \r, \n, \t and \\ in banner, send and expect, since
+// probe arguments are passed as single-line flags.
+func unescape(s string) string {
+	return escapes.Replace(s)
+}
+
+func excerpt(s string) string {
+	if len(s) > excerptBytes {
+		return s[:excerptBytes]
+	}
+	return s
+}
+
+func msec(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
@@ -0,0 +1,193 @@
+package tcpport
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jandubois/monitor/internal/probe"
+)
+
+// serveTCP runs handle for each connection on a local port.
+func serveTCP(t *testing.T, handle func(net.Conn)) int {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				handle(conn)
+			}()
+		}
+	}()
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+// smtpLike greets, then answers each line with "250 <line>".
+func smtpLike(conn net.Conn) {
+	io.WriteString(conn, "220 mail.example.test ESMTP ready\r\n")
+	r := bufio.NewReader(conn)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		io.WriteString(conn, "250 "+line)
+	}
+}
+
+func TestRun(t *testing.T) {
+	port := serveTCP(t, smtpLike)
+	silent := serveTCP(t, func(conn net.Conn) { io.Copy(io.Discard, conn) })
+	slow := serveTCP(t, func(conn net.Conn) {
+		time.Sleep(50 * time.Millisecond)
+		io.WriteString(conn, "hello\n")
+	})
+
+	tests := []struct {
+		name           string
+		opts           Options
+		expectedStatus probe.Status
+		messageContain string
+	}{
+		{
+			name:           "connect",
+			opts:           Options{Port: port},
+			expectedStatus: probe.StatusOK,
+			messageContain: "TCP 127.0.0.1:",
+		},
+		{
+			name:           "banner",
+			opts:           Options{Port: port, Banner: "ESMTP"},
+			expectedStatus: probe.StatusOK,
+		},
+		{
+			name:           "banner mismatch",
+			opts:           Options{Port: port, Banner: "SSH-2.0", Timeout: 200 * time.Millisecond},
+			expectedStatus: probe.StatusCritical,
+			messageContain: `does not contain "SSH-2.0": timed out`,
+		},
+		{
+			name:           "send and expect",
+			opts:           Options{Port: port, Banner: "220 ", Send: `EHLO monitor\r\n`, Expect: `250 EHLO monitor\r\n`},
+			expectedStatus: probe.StatusOK,
+		},
+		{
+			name:           "no reply",
+			opts:           Options{Port: silent, Send: "PING\n", Expect: "PONG", Timeout: 200 * time.Millisecond},
+			expectedStatus: probe.StatusCritical,
+			messageContain: "reply from",
+		},
+		{
+			name:           "latency warning",
+			opts:           Options{Port: slow, Banner: "hello", WarningMs: 20},
+			expectedStatus: probe.StatusWarning,
+			messageContain: "(warning 20 ms)",
+		},
+		{
+			name:           "latency critical",
+			opts:           Options{Port: slow, Banner: "hello", WarningMs: 10, CriticalMs: 20},
+			expectedStatus: probe.StatusCritical,
+			messageContain: "(critical 20 ms)",
+		},
+		{
+			name:           "udp without exchange",
+			opts:           Options{Port: port, Protocol: "udp"},
+			expectedStatus: probe.StatusUnknown,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.Host = "127.0.0.1"
+			result := Run(context.Background(), tt.opts)
+			if result.Status != tt.expectedStatus {
+				t.Errorf("expected status %q, got %q: %s", tt.expectedStatus, result.Status, result.Message)
+			}
+			if tt.messageContain != "" && !strings.Contains(result.Message, tt.messageContain) {
+				t.Errorf("expected message to contain %q, got %q", tt.messageContain, result.Message)
+			}
+		})
+	}
+}
+
+func TestRunMetrics(t *testing.T) {
+	port := serveTCP(t, smtpLike)
+
+	result := Run(context.Background(), Options{Host: "127.0.0.1", Port: port, Banner: "220", Send: `QUIT\r\n`, Expect: "250 QUIT"})
+	if result.Status != probe.StatusOK {
+		t.Fatalf("expected ok, got %q: %s", result.Status, result.Message)
+	}
+	for _, metric := range []string{"connect_ms", "banner_ms", "response_ms", "total_ms"} {
+		if _, ok := result.Metrics[metric].(float64); !ok {
+			t.Errorf("expected %s metric, got %v", metric, result.Metrics[metric])
+		}
+	}
+	if result.Data["banner"] != "220 mail.example.test ESMTP ready\r\n" {
+		t.Errorf("unexpected banner: %q", result.Data["banner"])
+	}
+}
+
+func TestRunConnectionRefused(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	result := Run(context.Background(), Options{Host: "127.0.0.1", Port: port})
+	if result.Status != probe.StatusCritical || !strings.Contains(result.Message, "failed to connect") {
+		t.Errorf("unexpected result: %q %s", result.Status, result.Message)
+	}
+}
+
+func TestRunUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer conn.Close()
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			conn.WriteTo([]byte(strings.ToUpper(string(buf[:n]))), addr)
+		}
+	}()
+	port := conn.LocalAddr().(*net.UDPAddr).Port
+
+	result := Run(context.Background(), Options{Host: "127.0.0.1", Port: port, Protocol: "udp", Send: "ping", Expect: "PING"})
+	if result.Status != probe.StatusOK {
+		t.Errorf("expected ok, got %q: %s", result.Status, result.Message)
+	}
+
+	result = Run(context.Background(), Options{
+		Host: "127.0.0.1", Port: port, Protocol: "udp", Send: "ping", Expect: "pong", Timeout: 200 * time.Millisecond,
+	})
+	if result.Status != probe.StatusCritical {
+		t.Errorf("expected critical, got %q: %s", result.Status, result.Message)
+	}
+}
+
+func TestUnescape(t *testing.T) {
+	if got := unescape(`HELO x\r\n\\n`); got != "HELO x\r\n\\n" {
+		t.Errorf("unexpected unescape result %q", got)
+	}
+}
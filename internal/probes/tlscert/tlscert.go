@@ -0,0 +1,464 @@
+// Package tlscert provides the TLS certificate probe.
+package tlscert
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/textproto"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jandubois/monitor/internal/probe"
+)
+
+// Name is the probe subcommand name.
+const Name = "tls-cert"
+
+// Minimum key sizes below which a key is reported as weak.
+const (
+	minRSABits   = 2048
+	minECDSABits = 256
+)
+
+// Options are the probe arguments.
+type Options struct {
+	Host         string
+	Port         int
+	ServerName   string // SNI and the name the certificate must match; defaults to Host
+	StartTLS     string // "", "smtp", "imap" or "postgres"
+	WarningDays  float64
+	CriticalDays float64
+	CAFile       string // PEM bundle trusted in addition to the system roots
+	Timeout      time.Duration
+}
+
+// GetDescription returns the probe description.
+func GetDescription() probe.Description {
+	return probe.Description{
+		Name:        "tls-cert",
+		Description: "Check a TLS certificate for expiry and problems",
+		Version:     "1.0.0",
+		Subcommand:  Name,
+		Arguments: probe.Arguments{
+			Required: map[string]probe.ArgumentSpec{
+				"host": {
+					Type:        "string",
+					Description: "Host to connect to",
+				},
+			},
+			Optional: map[string]probe.ArgumentSpec{
+				"port": {
+					Type:        "number",
+					Description: "Port to connect to",
+					Default:     float64(443),
+				},
+				"server_name": {
+					Type:        "string",
+					Description: "Server name for SNI and hostname verification (defaults to host)",
+				},
+				"starttls": {
+					Type:        "string",
+					Description: "Protocol to negotiate TLS with before the handshake",
+					Default:     "",
+					Enum:        []string{"", "smtp", "imap", "postgres"},
+				},
+				"warning_days": {
+					Type:        "number",
+					Description: "Days before expiry that trigger a warning",
+					Default:     float64(30),
+				},
+				"critical_days": {
+					Type:        "number",
+					Description: "Days before expiry that are critical",
+					Default:     float64(7),
+				},
+				"ca_file": {
+					Type:        "string",
+					Description: "PEM file with additional trusted CA certificates",
+				},
+				"timeout_seconds": {
+					Type:        "number",
+					Description: "Connection timeout in seconds",
+					Default:     float64(10),
+				},
+			},
+		},
+	}
+}
+
+// Run executes the probe with the given options.
+func Run(ctx context.Context, opts Options) *probe.Result {
+	if opts.Host == "" {
+		return &probe.Result{
+			Status:  probe.StatusUnknown,
+			Message: "host argument is required",
+		}
+	}
+	if opts.Port == 0 {
+		opts.Port = 443
+	}
+	serverName := opts.ServerName
+	if serverName == "" {
+		serverName = opts.Host
+	}
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+
+	roots, err := loadRoots(opts.CAFile)
+	if err != nil {
+		return &probe.Result{
+			Status:  probe.StatusUnknown,
+			Message: err.Error(),
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	address := net.JoinHostPort(opts.Host, strconv.Itoa(opts.Port))
+	start := time.Now()
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return &probe.Result{
+			Status:  probe.StatusCritical,
+			Message: fmt.Sprintf("failed to connect to %s: %v", address, err),
+		}
+	}
+	defer conn.Close()
+	connectTime := time.Since(start)
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if opts.StartTLS != "" {
+		if err := startTLS(conn, opts.StartTLS); err != nil {
+			return &probe.Result{
+				Status:  probe.StatusCritical,
+				Message: fmt.Sprintf("STARTTLS (%s) failed: %v", opts.StartTLS, err),
+			}
+		}
+	}
+
+	// Verification is done below so every problem can be reported, not
+	// just the first one the handshake would fail on.
+	handshakeStart := time.Now()
+	tlsConn := tls.Client(conn, &tls.Config{
+		ServerName:         serverName,
+		InsecureSkipVerify: true,
+	})
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		return &probe.Result{
+			Status:  probe.StatusCritical,
+			Message: fmt.Sprintf("TLS handshake with %s failed: %v", address, err),
+		}
+	}
+	handshakeTime := time.Since(handshakeStart)
+
+	state := tlsConn.ConnectionState()
+	if len(state.PeerCertificates) == 0 {
+		return &probe.Result{
+			Status:  probe.StatusCritical,
+			Message: fmt.Sprintf("%s presented no certificate", address),
+		}
+	}
+
+	return evaluate(state, serverName, roots, time.Now(), opts, connectTime, handshakeTime)
+}
+
+// evaluate checks the presented chain and builds the result.
+func evaluate(state tls.ConnectionState, serverName string, roots *x509.CertPool, now time.Time, opts Options, connectTime, handshakeTime time.Duration) *probe.Result {
+	certs := state.PeerCertificates
+	leaf := certs[0]
+
+	status := probe.StatusOK
+	var problems []string
+	raise := func(s probe.Status, problem string) {
+		if severity(s) > severity(status) {
+			status = s
+		}
+		problems = append(problems, problem)
+	}
+
+	// The earliest expiry in the presented chain is what breaks first
+	expiring := leaf
+	for _, cert := range certs[1:] {
+		if cert.NotAfter.Before(expiring.NotAfter) {
+			expiring = cert
+		}
+	}
+	days := expiring.NotAfter.Sub(now).Hours() / 24
+	what := "certificate"
+	if expiring != leaf {
+		what = fmt.Sprintf("intermediate %q", expiring.Subject.CommonName)
+	}
+	switch {
+	case days < 0:
+		raise(probe.StatusCritical, fmt.Sprintf("%s expired %s", what, expiring.NotAfter.UTC().Format(time.DateOnly)))
+	case days < opts.CriticalDays:
+		raise(probe.StatusCritical, fmt.Sprintf("%s expires in %.0f days (critical %.0f)", what, math.Floor(days), opts.CriticalDays))
+	case days < opts.WarningDays:
+		raise(probe.StatusWarning, fmt.Sprintf("%s expires in %.0f days (warning %.0f)", what, math.Floor(days), opts.WarningDays))
+	}
+	if now.Before(leaf.NotBefore) {
+		raise(probe.StatusCritical, fmt.Sprintf("certificate is not valid before %s", leaf.NotBefore.UTC().Format(time.RFC3339)))
+	}
+
+	if err := leaf.VerifyHostname(serverName); err != nil {
+		raise(probe.StatusCritical, fmt.Sprintf("hostname mismatch: %v", err))
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, verifyErr := leaf.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   now,
+	})
+	var unknownAuthority x509.UnknownAuthorityError
+	switch {
+	case verifyErr == nil:
+	case errors.As(verifyErr, &unknownAuthority) && !isSelfSigned(certs[len(certs)-1]):
+		raise(probe.StatusCritical, fmt.Sprintf("incomplete chain: no trusted issuer for %q", certs[len(certs)-1].Issuer.String()))
+	default:
+		var invalid x509.CertificateInvalidError
+		if errors.As(verifyErr, &invalid) && invalid.Reason == x509.Expired {
+			// Already reported above
+			break
+		}
+		raise(probe.StatusCritical, fmt.Sprintf("untrusted chain: %v", verifyErr))
+	}
+
+	for _, cert := range certs {
+		if isSelfSigned(cert) && cert != leaf {
+			// A root's key and self-signature are not what clients rely on
+			continue
+		}
+		if key, weak := describeKey(cert.PublicKey); weak {
+			raise(probe.StatusWarning, fmt.Sprintf("weak key in %q: %s", cert.Subject.CommonName, key))
+		}
+		if weakSignature(cert.SignatureAlgorithm) {
+			raise(probe.StatusWarning, fmt.Sprintf("weak signature in %q: %s", cert.Subject.CommonName, cert.SignatureAlgorithm))
+		}
+	}
+
+	chain := make([]map[string]any, 0, len(certs))
+	for _, cert := range certs {
+		key, _ := describeKey(cert.PublicKey)
+		chain = append(chain, map[string]any{
+			"subject":             cert.Subject.String(),
+			"issuer":              cert.Issuer.String(),
+			"not_before":          cert.NotBefore.UTC().Format(time.RFC3339),
+			"not_after":           cert.NotAfter.UTC().Format(time.RFC3339),
+			"key":                 key,
+			"signature_algorithm": cert.SignatureAlgorithm.String(),
+			"self_signed":         isSelfSigned(cert),
+		})
+	}
+
+	leafKey, _ := describeKey(leaf.PublicKey)
+	data := map[string]any{
+		"host":                opts.Host,
+		"port":                opts.Port,
+		"server_name":         serverName,
+		"subject":             leaf.Subject.String(),
+		"issuer":              leaf.Issuer.String(),
+		"sans":                subjectAltNames(leaf),
+		"serial":              leaf.SerialNumber.String(),
+		"not_before":          leaf.NotBefore.UTC().Format(time.RFC3339),
+		"not_after":           leaf.NotAfter.UTC().Format(time.RFC3339),
+		"key":                 leafKey,
+		"signature_algorithm": leaf.SignatureAlgorithm.String(),
+		"tls_version":         tls.VersionName(state.Version),
+		"chain":               chain,
+	}
+	if len(problems) > 0 {
+		data["problems"] = problems
+	}
+
+	message := fmt.Sprintf("certificate for %s expires in %.0f days (%s)",
+		serverName, math.Floor(leaf.NotAfter.Sub(now).Hours()/24), leaf.NotAfter.UTC().Format(time.DateOnly))
+	if len(problems) > 0 {
+		message = strings.Join(problems, "; ")
+	}
+
+	return &probe.Result{
+		Status:  status,
+		Message: message,
+		Metrics: map[string]any{
+			"days_until_expiry": days,
+			"chain_length":      len(certs),
+			"connect_ms":        msec(connectTime),
+			"handshake_ms":      msec(handshakeTime),
+		},
+		Data: data,
+	}
+}
+
+// startTLS asks the server to switch the connection to TLS.
+func startTLS(conn net.Conn, protocol string) error {
+	switch strings.ToLower(protocol) {
+	case "smtp":
+		tp := textproto.NewConn(conn)
+		if _, _, err := tp.ReadResponse(220); err != nil {
+			return fmt.Errorf("greeting: %w", err)
+		}
+		if _, _, err := cmd(tp, 250, "EHLO monitor"); err != nil {
+			return fmt.Errorf("EHLO: %w", err)
+		}
+		if _, _, err := cmd(tp, 220, "STARTTLS"); err != nil {
+			return err
+		}
+		return nil
+
+	case "imap":
+		r := bufio.NewReader(conn)
+		greeting, err := r.ReadString('\n')
+		if err != nil {
+			return fmt.Errorf("greeting: %w", err)
+		}
+		if !strings.HasPrefix(greeting, "* OK") {
+			return fmt.Errorf("unexpected greeting %q", strings.TrimSpace(greeting))
+		}
+		if _, err := io.WriteString(conn, "a1 STARTTLS\r\n"); err != nil {
+			return err
+		}
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return err
+			}
+			if strings.HasPrefix(line, "a1 ") {
+				if !strings.HasPrefix(line, "a1 OK") {
+					return fmt.Errorf("server refused: %s", strings.TrimSpace(line))
+				}
+				return nil
+			}
+		}
+
+	case "postgres":
+		// SSLRequest: length 8 followed by the magic code 80877103
+		request := make([]byte, 8)
+		binary.BigEndian.PutUint32(request[0:4], 8)
+		binary.BigEndian.PutUint32(request[4:8], 80877103)
+		if _, err := conn.Write(request); err != nil {
+			return err
+		}
+		answer := make([]byte, 1)
+		if _, err := io.ReadFull(conn, answer); err != nil {
+			return err
+		}
+		if answer[0] != 'S' {
+			return errors.New("server does not support SSL")
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("unsupported protocol %q", protocol)
+	}
+}
+
+func cmd(tp *textproto.Conn, expectCode int, format string, args ...any) (int, string, error) {
+	id, err := tp.Cmd(format, args...)
+	if err != nil {
+		return 0, "", err
+	}
+	tp.StartResponse(id)
+	defer tp.EndResponse(id)
+	return tp.ReadResponse(expectCode)
+}
+
+// loadRoots returns the system roots plus the certificates in caFile.
+func loadRoots(caFile string) (*x509.CertPool, error) {
+	if caFile == "" {
+		return nil, nil // Verify uses the system roots
+	}
+	roots, err := x509.SystemCertPool()
+	if err != nil {
+		roots = x509.NewCertPool()
+	}
+	pem, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ca_file: %v", err)
+	}
+	if !roots.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in %s", caFile)
+	}
+	return roots, nil
+}
+
+// describeKey returns a description like "RSA 2048" and whether the key is
+// considered weak.
+func describeKey(key any) (string, bool) {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		bits := k.N.BitLen()
+		return fmt.Sprintf("RSA %d", bits), bits < minRSABits
+	case *ecdsa.PublicKey:
+		bits := k.Curve.Params().BitSize
+		return fmt.Sprintf("ECDSA %d", bits), bits < minECDSABits
+	case ed25519.PublicKey:
+		return "Ed25519", false
+	default:
+		return fmt.Sprintf("%T", key), false
+	}
+}
+
+func weakSignature(alg x509.SignatureAlgorithm) bool {
+	switch alg {
+	case x509.MD2WithRSA, x509.MD5WithRSA, x509.SHA1WithRSA, x509.DSAWithSHA1, x509.ECDSAWithSHA1:
+		return true
+	}
+	return false
+}
+
+func isSelfSigned(cert *x509.Certificate) bool {
+	return bytes.Equal(cert.RawSubject, cert.RawIssuer) &&
+		cert.CheckSignature(cert.SignatureAlgorithm, cert.RawTBSCertificate, cert.Signature) == nil
+}
+
+func subjectAltNames(cert *x509.Certificate) []string {
+	var names []string
+	names = append(names, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		names = append(names, ip.String())
+	}
+	names = append(names, cert.EmailAddresses...)
+	for _, uri := range cert.URIs {
+		names = append(names, uri.String())
+	}
+	return names
+}
+
+func severity(s probe.Status) int {
+	switch s {
+	case probe.StatusWarning:
+		return 1
+	case probe.StatusCritical:
+		return 2
+	}
+	return 0
+}
+
+func msec(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
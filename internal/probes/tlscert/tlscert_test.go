@@ -0,0 +1,346 @@
+package tlscert
+
+import (
+	"bufio"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jandubois/monitor/internal/probe"
+)
+
+type testCert struct {
+	cert *x509.Certificate
+	key  crypto.Signer
+}
+
+func newTestCert(t *testing.T, template *x509.Certificate, parent *testCert, key crypto.Signer) *testCert {
+	t.Helper()
+	if key == nil {
+		var err error
+		key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatalf("generate key: %v", err)
+		}
+	}
+	template.SerialNumber = big.NewInt(time.Now().UnixNano())
+	if template.NotBefore.IsZero() {
+		template.NotBefore = time.Now().Add(-time.Hour)
+	}
+	if template.NotAfter.IsZero() {
+		template.NotAfter = time.Now().Add(90 * 24 * time.Hour)
+	}
+
+	parentCert, parentKey := template, key
+	if parent != nil {
+		parentCert, parentKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parentCert, key.Public(), parentKey)
+	if err != nil {
+		t.Fatalf("create certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("parse certificate: %v", err)
+	}
+	return &testCert{cert: cert, key: key}
+}
+
+func newCA(t *testing.T, name string, parent *testCert) *testCert {
+	return newTestCert(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: name},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, parent, nil)
+}
+
+func newLeaf(t *testing.T, parent *testCert, notAfter time.Time, key crypto.Signer) *testCert {
+	return newTestCert(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "localhost"},
+		DNSNames:    []string{"localhost"},
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+		NotAfter:    notAfter,
+		KeyUsage:    x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, parent, key)
+}
+
+func writeCAFile(t *testing.T, ca *testCert) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.cert.Raw})
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatalf("write CA file: %v", err)
+	}
+	return path
+}
+
+// serveTLS accepts connections on a local port, runs preamble on the plain
+// connection, and then completes a TLS handshake presenting chain.
+func serveTLS(t *testing.T, chain []*testCert, preamble func(net.Conn) error) int {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	certificate := tls.Certificate{PrivateKey: chain[0].key, Leaf: chain[0].cert}
+	for _, c := range chain {
+		certificate.Certificate = append(certificate.Certificate, c.cert.Raw)
+	}
+	config := &tls.Config{Certificates: []tls.Certificate{certificate}}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				if preamble != nil {
+					if err := preamble(conn); err != nil {
+						return
+					}
+				}
+				tls.Server(conn, config).Handshake()
+			}()
+		}
+	}()
+
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+func TestRun(t *testing.T) {
+	root := newCA(t, "Test Root", nil)
+	intermediate := newCA(t, "Test Intermediate", root)
+	caFile := writeCAFile(t, root)
+
+	weakKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("generate RSA key: %v", err)
+	}
+
+	now := time.Now()
+	valid := newLeaf(t, intermediate, now.Add(90*24*time.Hour), nil)
+
+	tests := []struct {
+		name           string
+		chain          []*testCert
+		serverName     string
+		expectedStatus probe.Status
+		messageContain string
+	}{
+		{
+			name:           "valid",
+			chain:          []*testCert{valid, intermediate},
+			expectedStatus: probe.StatusOK,
+			messageContain: "certificate for localhost expires in 89 days",
+		},
+		{
+			name:           "expiring soon",
+			chain:          []*testCert{newLeaf(t, intermediate, now.Add(20*24*time.Hour), nil), intermediate},
+			expectedStatus: probe.StatusWarning,
+			messageContain: "certificate expires in 19 days (warning 30)",
+		},
+		{
+			name:           "about to expire",
+			chain:          []*testCert{newLeaf(t, intermediate, now.Add(3*24*time.Hour), nil), intermediate},
+			expectedStatus: probe.StatusCritical,
+			messageContain: "(critical 7)",
+		},
+		{
+			name:           "expired",
+			chain:          []*testCert{newLeaf(t, intermediate, now.Add(-time.Minute), nil), intermediate},
+			expectedStatus: probe.StatusCritical,
+			messageContain: "certificate expired",
+		},
+		{
+			name:           "hostname mismatch",
+			chain:          []*testCert{valid, intermediate},
+			serverName:     "www.example.com",
+			expectedStatus: probe.StatusCritical,
+			messageContain: "hostname mismatch",
+		},
+		{
+			name:           "incomplete chain",
+			chain:          []*testCert{valid},
+			expectedStatus: probe.StatusCritical,
+			messageContain: `incomplete chain: no trusted issuer for "CN=Test Intermediate"`,
+		},
+		{
+			name:           "weak key",
+			chain:          []*testCert{newLeaf(t, intermediate, now.Add(90*24*time.Hour), weakKey), intermediate},
+			expectedStatus: probe.StatusWarning,
+			messageContain: `weak key in "localhost": RSA 1024`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			port := serveTLS(t, tt.chain, nil)
+			serverName := tt.serverName
+			if serverName == "" {
+				serverName = "localhost"
+			}
+			result := Run(context.Background(), Options{
+				Host:         "127.0.0.1",
+				Port:         port,
+				ServerName:   serverName,
+				WarningDays:  30,
+				CriticalDays: 7,
+				CAFile:       caFile,
+				Timeout:      5 * time.Second,
+			})
+			if result.Status != tt.expectedStatus {
+				t.Errorf("expected status %q, got %q: %s", tt.expectedStatus, result.Status, result.Message)
+			}
+			if !strings.Contains(result.Message, tt.messageContain) {
+				t.Errorf("expected message to contain %q, got %q", tt.messageContain, result.Message)
+			}
+		})
+	}
+}
+
+func TestRunData(t *testing.T) {
+	root := newCA(t, "Test Root", nil)
+	leaf := newLeaf(t, root, time.Now().Add(90*24*time.Hour), nil)
+	port := serveTLS(t, []*testCert{leaf}, nil)
+
+	result := Run(context.Background(), Options{
+		Host:        "127.0.0.1",
+		Port:        port,
+		WarningDays: 30,
+		CAFile:      writeCAFile(t, root),
+	})
+	if result.Status != probe.StatusOK {
+		t.Fatalf("expected ok, got %q: %s", result.Status, result.Message)
+	}
+
+	if result.Data["subject"] != "CN=localhost" || result.Data["issuer"] != "CN=Test Root" {
+		t.Errorf("unexpected subject/issuer: %v / %v", result.Data["subject"], result.Data["issuer"])
+	}
+	sans, _ := result.Data["sans"].([]string)
+	if len(sans) != 2 || sans[0] != "localhost" || sans[1] != "127.0.0.1" {
+		t.Errorf("unexpected SANs: %v", result.Data["sans"])
+	}
+	if result.Data["key"] != "ECDSA 256" {
+		t.Errorf("unexpected key: %v", result.Data["key"])
+	}
+	chain, _ := result.Data["chain"].([]map[string]any)
+	if len(chain) != 1 || chain[0]["self_signed"] != false {
+		t.Errorf("unexpected chain: %v", result.Data["chain"])
+	}
+	days, _ := result.Metrics["days_until_expiry"].(float64)
+	if days < 89 || days > 90 {
+		t.Errorf("unexpected days_until_expiry: %v", days)
+	}
+}
+
+func TestRunUntrustedSelfSigned(t *testing.T) {
+	self := newTestCert(t, &x509.Certificate{
+		Subject:  pkix.Name{CommonName: "localhost"},
+		DNSNames: []string{"localhost"},
+	}, nil, nil)
+	port := serveTLS(t, []*testCert{self}, nil)
+
+	result := Run(context.Background(), Options{Host: "localhost", Port: port})
+	if result.Status != probe.StatusCritical {
+		t.Errorf("expected critical, got %q", result.Status)
+	}
+	if !strings.Contains(result.Message, "untrusted chain") {
+		t.Errorf("unexpected message: %s", result.Message)
+	}
+}
+
+func TestRunStartTLS(t *testing.T) {
+	root := newCA(t, "Test Root", nil)
+	leaf := newLeaf(t, root, time.Now().Add(90*24*time.Hour), nil)
+	caFile := writeCAFile(t, root)
+
+	preambles := map[string]func(net.Conn) error{
+		"smtp": func(conn net.Conn) error {
+			r := bufio.NewReader(conn)
+			io.WriteString(conn, "220 mail.example.com ESMTP\r\n")
+			if line, err := r.ReadString('\n'); err != nil || !strings.HasPrefix(line, "EHLO") {
+				return io.ErrUnexpectedEOF
+			}
+			io.WriteString(conn, "250-mail.example.com\r\n250 STARTTLS\r\n")
+			if line, err := r.ReadString('\n'); err != nil || line != "STARTTLS\r\n" {
+				return io.ErrUnexpectedEOF
+			}
+			_, err := io.WriteString(conn, "220 Ready to start TLS\r\n")
+			return err
+		},
+		"imap": func(conn net.Conn) error {
+			r := bufio.NewReader(conn)
+			io.WriteString(conn, "* OK IMAP4rev1 ready\r\n")
+			if line, err := r.ReadString('\n'); err != nil || line != "a1 STARTTLS\r\n" {
+				return io.ErrUnexpectedEOF
+			}
+			_, err := io.WriteString(conn, "a1 OK Begin TLS negotiation now\r\n")
+			return err
+		},
+		"postgres": func(conn net.Conn) error {
+			request := make([]byte, 8)
+			if _, err := io.ReadFull(conn, request); err != nil {
+				return err
+			}
+			_, err := conn.Write([]byte{'S'})
+			return err
+		},
+	}
+
+	for protocol, preamble := range preambles {
+		t.Run(protocol, func(t *testing.T) {
+			port := serveTLS(t, []*testCert{leaf}, preamble)
+			result := Run(context.Background(), Options{
+				Host:     "localhost",
+				Port:     port,
+				StartTLS: protocol,
+				CAFile:   caFile,
+				Timeout:  5 * time.Second,
+			})
+			if result.Status != probe.StatusOK {
+				t.Errorf("expected ok, got %q: %s", result.Status, result.Message)
+			}
+		})
+	}
+
+	t.Run("refused", func(t *testing.T) {
+		port := serveTLS(t, []*testCert{leaf}, func(conn net.Conn) error {
+			request := make([]byte, 8)
+			io.ReadFull(conn, request)
+			conn.Write([]byte{'N'})
+			return io.EOF
+		})
+		result := Run(context.Background(), Options{Host: "localhost", Port: port, StartTLS: "postgres", Timeout: 5 * time.Second})
+		if result.Status != probe.StatusCritical || !strings.Contains(result.Message, "does not support SSL") {
+			t.Errorf("unexpected result: %q %s", result.Status, result.Message)
+		}
+	})
+}
+
+func TestRunEmptyHost(t *testing.T) {
+	result := Run(context.Background(), Options{})
+	if result.Status != probe.StatusUnknown {
+		t.Errorf("expected status %q, got %q", probe.StatusUnknown, result.Status)
+	}
+}
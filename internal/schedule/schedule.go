@@ -0,0 +1,216 @@
+// Package schedule computes probe run times from a config's schedule.
+// The watcher and the web service both use it, so they agree on when a
+// probe is due.
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// maxWindowSkips bounds the search for a cron time inside an active window,
+// so a schedule whose runs never fall into a window can't loop forever.
+const maxWindowSkips = 1000
+
+// Schedule is either a fixed interval or a cron expression, evaluated in a
+// timezone and optionally restricted to daily active windows.
+type Schedule struct {
+	interval time.Duration
+	cron     cron.Schedule
+	location *time.Location
+	windows  []Window
+}
+
+// Window is a daily time range in which probes may run. End before Start
+// means the window wraps past midnight (e.g. 22:00-06:00).
+type Window struct {
+	Start int // Minutes since midnight
+	End   int
+}
+
+// Parse parses a schedule. spec is an interval ("5m", "1h", "1d", or a Go
+// duration) or a standard 5-field cron expression ("0 6 * * 1-5", "@daily").
+// timezone is an IANA name and defaults to UTC. windows are "HH:MM-HH:MM"
+// ranges; if any are given, runs only happen inside one of them.
+func Parse(spec, timezone string, windows []string) (*Schedule, error) {
+	location := time.UTC
+	if timezone != "" {
+		var err error
+		location, err = time.LoadLocation(timezone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timezone %q: %w", timezone, err)
+		}
+	}
+
+	s := &Schedule{location: location}
+
+	if IsCron(spec) {
+		sched, err := cron.ParseStandard(spec)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cron expression %q: %w", spec, err)
+		}
+		s.cron = sched
+	} else {
+		interval, err := ParseInterval(spec)
+		if err != nil {
+			return nil, fmt.Errorf("invalid interval %q: %w", spec, err)
+		}
+		if interval < 0 {
+			return nil, fmt.Errorf("invalid interval %q: must not be negative", spec)
+		}
+		s.interval = interval
+	}
+
+	for _, w := range windows {
+		window, err := ParseWindow(w)
+		if err != nil {
+			return nil, err
+		}
+		s.windows = append(s.windows, window)
+	}
+
+	return s, nil
+}
+
+// IsCron reports whether spec is a cron expression rather than an interval.
+func IsCron(spec string) bool {
+	spec = strings.TrimSpace(spec)
+	return strings.HasPrefix(spec, "@") || strings.ContainsAny(spec, " \t")
+}
+
+// ParseInterval parses interval strings like "5m", "1h", "1d".
+func ParseInterval(s string) (time.Duration, error) {
+	if len(s) < 2 {
+		return 0, nil
+	}
+
+	value, err := strconv.Atoi(s[:len(s)-1])
+	if err != nil {
+		return time.ParseDuration(s)
+	}
+
+	switch s[len(s)-1] {
+	case 'm':
+		return time.Duration(value) * time.Minute, nil
+	case 'h':
+		return time.Duration(value) * time.Hour, nil
+	case 'd':
+		return time.Duration(value) * 24 * time.Hour, nil
+	default:
+		return time.ParseDuration(s)
+	}
+}
+
+// ParseWindow parses a daily window like "08:00-22:00".
+func ParseWindow(s string) (Window, error) {
+	start, end, ok := strings.Cut(strings.ReplaceAll(s, "–", "-"), "-")
+	if !ok {
+		return Window{}, fmt.Errorf("invalid window %q: expected HH:MM-HH:MM", s)
+	}
+
+	var w Window
+	var err error
+	if w.Start, err = parseClock(start); err != nil {
+		return Window{}, fmt.Errorf("invalid window %q: %w", s, err)
+	}
+	if w.End, err = parseClock(end); err != nil {
+		return Window{}, fmt.Errorf("invalid window %q: %w", s, err)
+	}
+	if w.Start == w.End {
+		return Window{}, fmt.Errorf("invalid window %q: start and end are equal", s)
+	}
+	return w, nil
+}
+
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		// Allow 24:00 as the end of the day
+		if strings.TrimSpace(s) == "24:00" {
+			return 24 * 60, nil
+		}
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// IsCron reports whether the schedule is a cron expression.
+func (s *Schedule) IsCron() bool {
+	return s.cron != nil
+}
+
+// Interval returns the fixed interval, or 0 for cron schedules.
+func (s *Schedule) Interval() time.Duration {
+	return s.interval
+}
+
+// Next returns the first run time after t. It returns the zero time if the
+// schedule never runs (an empty interval, or no cron time inside a window).
+func (s *Schedule) Next(t time.Time) time.Time {
+	if s.cron == nil {
+		if s.interval <= 0 {
+			return time.Time{}
+		}
+		next := t.Add(s.interval)
+		if !s.Active(next) {
+			next = s.nextWindowStart(next)
+		}
+		return next
+	}
+
+	next := s.cron.Next(t.In(s.location))
+	for i := 0; i < maxWindowSkips && !next.IsZero(); i++ {
+		if s.Active(next) {
+			return next
+		}
+		// cron.Next is exclusive, so step back to include the window start
+		next = s.cron.Next(s.nextWindowStart(next).Add(-time.Second))
+	}
+	return time.Time{}
+}
+
+// Active reports whether t falls inside an active window. Schedules without
+// windows are always active.
+func (s *Schedule) Active(t time.Time) bool {
+	if len(s.windows) == 0 {
+		return true
+	}
+
+	local := t.In(s.location)
+	minute := local.Hour()*60 + local.Minute()
+	for _, w := range s.windows {
+		if w.Start < w.End {
+			if minute >= w.Start && minute < w.End {
+				return true
+			}
+		} else if minute >= w.Start || minute < w.End {
+			return true
+		}
+	}
+	return false
+}
+
+// nextWindowStart returns the earliest window start at or after t.
+func (s *Schedule) nextWindowStart(t time.Time) time.Time {
+	local := t.In(s.location)
+	var earliest time.Time
+	for day := 0; day <= 1; day++ {
+		for _, w := range s.windows {
+			start := time.Date(local.Year(), local.Month(), local.Day()+day, 0, w.Start, 0, 0, s.location)
+			if start.Before(local) {
+				continue
+			}
+			if earliest.IsZero() || start.Before(earliest) {
+				earliest = start
+			}
+		}
+	}
+	if earliest.IsZero() {
+		return t
+	}
+	return earliest
+}
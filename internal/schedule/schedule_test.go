@@ -0,0 +1,152 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseInterval(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected time.Duration
+	}{
+		{"5m", 5 * time.Minute},
+		{"2h", 2 * time.Hour},
+		{"1d", 24 * time.Hour},
+		{"90s", 90 * time.Second},
+		{"1h30m", 90 * time.Minute},
+		{"", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseInterval(tt.input)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		spec     string
+		timezone string
+		windows  []string
+	}{
+		{"bad interval", "5x", "", nil},
+		{"bad cron", "61 * * * *", "", nil},
+		{"bad timezone", "5m", "Mars/Olympus", nil},
+		{"bad window", "5m", "", []string{"08:00"}},
+		{"bad window time", "5m", "", []string{"08:00-25:00"}},
+		{"empty window", "5m", "", []string{"08:00-08:00"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Parse(tt.spec, tt.timezone, tt.windows); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+func TestNext(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("timezone data unavailable: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		spec     string
+		timezone string
+		windows  []string
+		after    time.Time
+		expected time.Time
+	}{
+		{
+			name:     "interval",
+			spec:     "15m",
+			after:    time.Date(2025, 3, 3, 10, 0, 0, 0, time.UTC),
+			expected: time.Date(2025, 3, 3, 10, 15, 0, 0, time.UTC),
+		},
+		{
+			name:     "interval inside window",
+			spec:     "1h",
+			windows:  []string{"08:00-22:00"},
+			after:    time.Date(2025, 3, 3, 12, 0, 0, 0, time.UTC),
+			expected: time.Date(2025, 3, 3, 13, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "interval deferred to window start",
+			spec:     "1h",
+			windows:  []string{"08:00-22:00"},
+			after:    time.Date(2025, 3, 3, 21, 30, 0, 0, time.UTC),
+			expected: time.Date(2025, 3, 4, 8, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "window wrapping midnight",
+			spec:     "1h",
+			windows:  []string{"22:00-06:00"},
+			after:    time.Date(2025, 3, 3, 5, 30, 0, 0, time.UTC),
+			expected: time.Date(2025, 3, 3, 22, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "cron weekdays",
+			spec:     "0 6 * * 1-5",
+			after:    time.Date(2025, 3, 7, 7, 0, 0, 0, time.UTC), // Friday
+			expected: time.Date(2025, 3, 10, 6, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "cron in timezone",
+			spec:     "0 6 * * *",
+			timezone: "Europe/Berlin",
+			after:    time.Date(2025, 3, 3, 12, 0, 0, 0, time.UTC),
+			expected: time.Date(2025, 3, 4, 6, 0, 0, 0, berlin),
+		},
+		{
+			name:     "cron skips to window",
+			spec:     "*/30 * * * *",
+			windows:  []string{"08:00-22:00"},
+			after:    time.Date(2025, 3, 3, 22, 10, 0, 0, time.UTC),
+			expected: time.Date(2025, 3, 4, 8, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "window in timezone",
+			spec:     "1h",
+			timezone: "Europe/Berlin",
+			windows:  []string{"08:00-22:00"},
+			after:    time.Date(2025, 3, 3, 21, 30, 0, 0, time.UTC), // 22:30 in Berlin
+			expected: time.Date(2025, 3, 4, 8, 0, 0, 0, berlin),
+		},
+		{
+			name:    "cron never inside window",
+			spec:    "0 3 * * *",
+			windows: []string{"08:00-22:00"},
+			after:   time.Date(2025, 3, 3, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:  "empty interval",
+			spec:  "",
+			after: time.Date(2025, 3, 3, 0, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Parse(tt.spec, tt.timezone, tt.windows)
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+			got := s.Next(tt.after)
+			if !got.Equal(tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
@@ -1,23 +1,37 @@
 package watcher
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"mime"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/jandubois/monitor/internal/probe"
 )
 
+// configChannelIdleTimeout is how long the config channel may stay silent
+// before the connection is presumed dead. The web service sends a
+// keep-alive every 30 seconds.
+const configChannelIdleTimeout = 90 * time.Second
+
+// errNoConfigChannel is returned by StreamEvents when the web service is too
+// old to have a config channel.
+var errNoConfigChannel = errors.New("web service has no config channel")
+
 // Client communicates with the web service via HTTP.
 type Client struct {
-	baseURL    string
-	authToken  string
-	httpClient *http.Client
+	baseURL      string
+	authToken    string
+	httpClient   *http.Client
+	streamClient *http.Client // No timeout; streams stay open
 }
 
 // NewClient creates a new HTTP client for the web service.
@@ -28,6 +42,7 @@ func NewClient(baseURL, authToken string) *Client {
 		httpClient: &http.Client{
 			Timeout: 30 * time.Second,
 		},
+		streamClient: &http.Client{},
 	}
 }
 
@@ -48,6 +63,8 @@ type RegisterProbeType struct {
 	Arguments      map[string]any `json:"arguments"`
 	ExecutablePath string         `json:"executable_path"`
 	Subcommand     string         `json:"subcommand,omitempty"`
+
+	schema probe.Arguments // For validating local configs
 }
 
 // RegisterResponse is returned from registration.
@@ -59,8 +76,19 @@ type RegisterResponse struct {
 
 // HeartbeatRequest is sent periodically.
 type HeartbeatRequest struct {
-	Name    string `json:"name"`
-	Version string `json:"version"`
+	Name       string `json:"name"`
+	Version    string `json:"version"`
+	QueueDepth int    `json:"queue_depth"` // Results waiting to be delivered
+}
+
+// StatusError is returned when the web service responds with an error status.
+type StatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("request failed with status %d: %s", e.StatusCode, e.Body)
 }
 
 // ResultRequest is sent when a probe completes.
@@ -86,11 +114,20 @@ type ProbeConfigResponse struct {
 	Subcommand     string         `json:"subcommand,omitempty"`
 	Name           string         `json:"name"`
 	Arguments      map[string]any `json:"arguments"`
-	Interval       string         `json:"interval"`
+	Interval       string         `json:"interval"` // Interval or cron expression
+	Timezone       string         `json:"timezone,omitempty"`
+	ActiveWindows  []string       `json:"active_windows,omitempty"`
 	TimeoutSeconds int            `json:"timeout_seconds"`
 	NextRunAt      *time.Time     `json:"next_run_at"`
 }
 
+// ChannelEvent is a message on the watcher's config channel: a change to
+// one of its configs, or a request to run one now.
+type ChannelEvent struct {
+	Type          string // config_change or trigger
+	ProbeConfigID int
+}
+
 // Register registers the watcher and its probe types with the web service.
 // Registration uses the token in the request body rather than Authorization header.
 func (c *Client) Register(ctx context.Context, req *RegisterRequest) (*RegisterResponse, error) {
@@ -120,6 +157,69 @@ func (c *Client) GetConfigs(ctx context.Context, watcherName string) ([]ProbeCon
 	return configs, nil
 }
 
+// StreamEvents holds the watcher's config channel open and calls onEvent
+// for each event on it. onConnect is called once the channel is open. It
+// returns when the web service closes the channel, or with an error when
+// the connection fails or stays silent for too long.
+func (c *Client) StreamEvents(ctx context.Context, onConnect func(), onEvent func(ChannelEvent)) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/api/push/events", nil)
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+c.authToken)
+	req.Header.Set("Accept", "text/event-stream")
+
+	resp, err := c.streamClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Older web services serve the frontend for unknown paths
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if resp.StatusCode == http.StatusNotFound || (resp.StatusCode < 400 && mediaType != "text/event-stream") {
+		return errNoConfigChannel
+	}
+	if resp.StatusCode >= 400 {
+		body, _ := io.ReadAll(resp.Body)
+		return &StatusError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+	onConnect()
+
+	idle := time.AfterFunc(configChannelIdleTimeout, cancel)
+	defer idle.Stop()
+
+	var event ChannelEvent
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		idle.Reset(configChannelIdleTimeout)
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if event.Type != "" {
+				onEvent(event)
+			}
+			event = ChannelEvent{}
+		case strings.HasPrefix(line, "event:"):
+			event.Type = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			var data struct {
+				ProbeConfigID int `json:"probe_config_id"`
+			}
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data:")), &data); err == nil {
+				event.ProbeConfigID = data.ProbeConfigID
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("read stream: %w", err)
+	}
+	return nil
+}
+
 // postWithRetry sends a POST request with exponential backoff retry.
 // Retries up to 5 times over ~30 seconds for transient network failures.
 func (c *Client) postWithRetry(ctx context.Context, path string, body any, response any) error {
@@ -144,6 +244,9 @@ func (c *Client) postWithRetry(ctx context.Context, path string, body any, respo
 		if ctx.Err() != nil {
 			return ctx.Err()
 		}
+		if isPermanent(lastErr) {
+			return lastErr
+		}
 
 		slog.Warn("request failed, retrying", "path", path, "attempt", attempt+1, "error", lastErr)
 	}
@@ -182,7 +285,7 @@ func (c *Client) doPost(ctx context.Context, path string, body any, response any
 
 	if resp.StatusCode >= 400 {
 		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("request failed with status %d: %s", resp.StatusCode, string(body))
+		return &StatusError{StatusCode: resp.StatusCode, Body: string(body)}
 	}
 
 	if response != nil {
@@ -209,7 +312,7 @@ func (c *Client) get(ctx context.Context, path string, response any) error {
 
 	if resp.StatusCode >= 400 {
 		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("request failed with status %d: %s", resp.StatusCode, string(body))
+		return &StatusError{StatusCode: resp.StatusCode, Body: string(body)}
 	}
 
 	if response != nil {
@@ -222,20 +325,26 @@ func (c *Client) get(ctx context.Context, path string, response any) error {
 }
 
 // HTTPResultWriter sends probe results via HTTP to the web service.
+// Results that can't be delivered are kept in a durable queue and replayed
+// in order once the web service is reachable again.
 type HTTPResultWriter struct {
 	client      *Client
 	watcherName string
+	queue       *ResultQueue // Optional
 }
 
-// NewHTTPResultWriter creates a new HTTP-based result writer.
-func NewHTTPResultWriter(client *Client, watcherName string) *HTTPResultWriter {
+// NewHTTPResultWriter creates a new HTTP-based result writer. queue may be
+// nil, in which case undeliverable results are dropped.
+func NewHTTPResultWriter(client *Client, watcherName string, queue *ResultQueue) *HTTPResultWriter {
 	return &HTTPResultWriter{
 		client:      client,
 		watcherName: watcherName,
+		queue:       queue,
 	}
 }
 
-// WriteResult sends a probe result to the web service.
+// WriteResult sends a probe result to the web service, queueing it if the
+// web service is unreachable.
 func (w *HTTPResultWriter) WriteResult(ctx context.Context, cfg *ProbeConfig, result *probe.Result, scheduledAt, executedAt time.Time, durationMs int) error {
 	req := &ResultRequest{
 		Watcher:       w.watcherName,
@@ -250,5 +359,37 @@ func (w *HTTPResultWriter) WriteResult(ctx context.Context, cfg *ProbeConfig, re
 		ExecutedAt:    executedAt,
 	}
 
-	return w.client.SendResult(ctx, req)
+	if w.queue == nil {
+		return w.client.SendResult(ctx, req)
+	}
+
+	// Once anything is queued, new results queue behind it to keep the order
+	if w.queue.Len() == 0 {
+		err := w.client.SendResult(ctx, req)
+		if err == nil || isPermanent(err) {
+			return err
+		}
+		slog.Warn("web service unreachable, queueing result", "probe", cfg.Name, "error", err)
+	}
+
+	return w.queue.Push(req)
+}
+
+// QueueDepth returns the number of results waiting to be delivered.
+func (w *HTTPResultWriter) QueueDepth() int {
+	if w.queue == nil {
+		return 0
+	}
+	return w.queue.Len()
+}
+
+// Flush replays queued results to the web service. It returns the number of
+// results delivered or dropped.
+func (w *HTTPResultWriter) Flush(ctx context.Context) (int, error) {
+	if w.queue == nil {
+		return 0, nil
+	}
+	return w.queue.Replay(ctx, func(ctx context.Context, req *ResultRequest) error {
+		return w.client.post(ctx, "/api/push/result", req, nil)
+	})
 }
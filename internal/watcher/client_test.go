@@ -0,0 +1,58 @@
+package watcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestStreamEvents(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/push/events" || r.Header.Get("Authorization") != "Bearer secret" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, ": keep-alive\n\n")
+		fmt.Fprint(w, "id: 1\nevent: config_change\ndata: {\"probe_config_id\":3,\"action\":\"updated\"}\n\n")
+		fmt.Fprint(w, "id: 2\nevent: trigger\ndata: {\"probe_config_id\":4}\n\n")
+	}))
+	defer server.Close()
+
+	connected := false
+	var events []ChannelEvent
+	err := NewClient(server.URL, "secret").StreamEvents(context.Background(), func() {
+		connected = true
+	}, func(e ChannelEvent) {
+		events = append(events, e)
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !connected {
+		t.Error("expected onConnect to be called")
+	}
+	expected := []ChannelEvent{{Type: "config_change", ProbeConfigID: 3}, {Type: "trigger", ProbeConfigID: 4}}
+	if fmt.Sprint(events) != fmt.Sprint(expected) {
+		t.Errorf("expected %v, got %v", expected, events)
+	}
+}
+
+func TestStreamEventsUnsupported(t *testing.T) {
+	// Older web services answer unknown paths with the frontend
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, "<html></html>")
+	}))
+	defer server.Close()
+
+	err := NewClient(server.URL, "secret").StreamEvents(context.Background(), func() {
+		t.Error("unexpected onConnect")
+	}, func(ChannelEvent) {})
+	if !errors.Is(err, errNoConfigChannel) {
+		t.Errorf("expected errNoConfigChannel, got %v", err)
+	}
+}
@@ -88,6 +88,7 @@ func (d *Discovery) DiscoverAll(ctx context.Context) ([]RegisterProbeType, error
 				Arguments:      argsMap,
 				ExecutablePath: absPath,
 				Subcommand:     desc.Subcommand,
+				schema:         desc.Arguments,
 			})
 
 			slog.Info("discovered probe", "name", desc.Name, "version", version, "subcommand", desc.Subcommand)
@@ -124,6 +125,7 @@ func (d *Discovery) discoverBuiltIn() ([]RegisterProbeType, error) {
 			Arguments:      argsMap,
 			ExecutablePath: absPath,
 			Subcommand:     desc.Subcommand,
+			schema:         desc.Arguments,
 		})
 
 		slog.Info("discovered built-in probe", "name", desc.Name, "version", version, "subcommand", desc.Subcommand)
@@ -0,0 +1,164 @@
+package watcher
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/jandubois/monitor/internal/manifest"
+	"github.com/jandubois/monitor/internal/notify"
+	"github.com/jandubois/monitor/internal/schedule"
+)
+
+// LocalConfigs reads probe configs and notification channels from a YAML
+// file on the watcher, in the format of `monitor config apply`. Probes for
+// other watchers are skipped, so several watchers can share a file.
+//
+// Local configs have negative IDs, so they never collide with the web
+// service's. IDs are kept by name across reloads.
+type LocalConfigs struct {
+	path        string
+	watcherName string
+	dispatcher  *notify.Dispatcher
+
+	mu         sync.Mutex
+	probeTypes []RegisterProbeType
+	probeIDs   map[string]int
+	channelIDs map[string]int
+}
+
+// NewLocalConfigs creates a reader for the file at path. The notification
+// channels in the file are loaded into dispatcher.
+func NewLocalConfigs(path, watcherName string, dispatcher *notify.Dispatcher) *LocalConfigs {
+	return &LocalConfigs{
+		path:        path,
+		watcherName: watcherName,
+		dispatcher:  dispatcher,
+		probeIDs:    make(map[string]int),
+		channelIDs:  make(map[string]int),
+	}
+}
+
+// SetProbeTypes sets the probe types that local configs can use.
+func (l *LocalConfigs) SetProbeTypes(probeTypes []RegisterProbeType) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.probeTypes = probeTypes
+}
+
+// Load reads the file and returns the enabled probe configs for this
+// watcher. Nothing changes if the file has errors.
+func (l *LocalConfigs) Load() ([]*ProbeConfig, error) {
+	f, err := manifest.Load(l.path)
+	if err != nil {
+		return nil, err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var errs []error
+	channels := make(map[int]notify.Channel)
+	channelIDs := make(map[string]int)
+	for _, c := range f.NotificationChannels {
+		id, ok := l.channelIDs[c.Name]
+		if !ok {
+			id = len(l.channelIDs) + 1
+			l.channelIDs[c.Name] = id
+		}
+		channelIDs[c.Name] = id
+		if c.Enabled != nil && !*c.Enabled {
+			continue
+		}
+
+		config, err := json.Marshal(c.Config)
+		if err == nil {
+			channels[id], err = notify.NewChannel(c.Type, config)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("notification channel %q: %w", c.Name, err))
+		}
+	}
+
+	var configs []*ProbeConfig
+	for _, p := range f.Probes {
+		if p.Watcher != "" && p.Watcher != l.watcherName {
+			continue
+		}
+		cfg, err := l.probeConfig(&p, channelIDs)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("probe %q: %w", p.Name, err))
+			continue
+		}
+		if p.Enabled == nil || *p.Enabled {
+			configs = append(configs, cfg)
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, fmt.Errorf("%s: %w", l.path, err)
+	}
+
+	l.dispatcher.SetChannels(channels)
+	return configs, nil
+}
+
+func (l *LocalConfigs) probeConfig(p *manifest.Probe, channelIDs map[string]int) (*ProbeConfig, error) {
+	switch {
+	case p.Composite != nil || len(p.DependsOn) > 0 || p.EscalationPolicy != "":
+		return nil, errors.New("composites, dependencies and escalation policies need the web service")
+	case p.FlapWindow != 0 || p.FlapThreshold != 0 || p.AnomalyThreshold != 0:
+		return nil, errors.New("flap detection and anomaly thresholds need the web service")
+	}
+
+	probeType := l.probeType(p.Type)
+	if probeType == nil {
+		return nil, fmt.Errorf("watcher %s has no probe type %q", l.watcherName, p.Type)
+	}
+	if err := manifest.ValidateArguments(p.Arguments, probeType.schema); err != nil {
+		return nil, err
+	}
+	sched, err := schedule.Parse(p.Interval, p.Timezone, p.ActiveWindows)
+	if err != nil {
+		return nil, fmt.Errorf("parse schedule: %w", err)
+	}
+
+	id, ok := l.probeIDs[p.Name]
+	if !ok {
+		id = -(len(l.probeIDs) + 1)
+		l.probeIDs[p.Name] = id
+	}
+	cfg := &ProbeConfig{
+		ID:                 id,
+		Name:               p.Name,
+		ExecutablePath:     probeType.ExecutablePath,
+		Subcommand:         probeType.Subcommand,
+		Arguments:          p.Arguments,
+		Interval:           p.Interval,
+		Timezone:           p.Timezone,
+		ActiveWindows:      p.ActiveWindows,
+		Schedule:           sched,
+		TimeoutSeconds:     p.TimeoutSeconds,
+		ConsecutiveResults: max(p.ConsecutiveResults, 1),
+	}
+	for _, name := range p.NotificationChannels {
+		channelID, ok := channelIDs[name]
+		if !ok {
+			return nil, fmt.Errorf("notification channel %q is not in the file", name)
+		}
+		cfg.NotificationChannels = append(cfg.NotificationChannels, channelID)
+	}
+	return cfg, nil
+}
+
+// probeType returns the newest version of the named probe type.
+func (l *LocalConfigs) probeType(name string) *RegisterProbeType {
+	var best *RegisterProbeType
+	for i := range l.probeTypes {
+		t := &l.probeTypes[i]
+		if t.Name == name && (best == nil || manifest.CompareVersions(t.Version, best.Version) > 0) {
+			best = t
+		}
+	}
+	return best
+}
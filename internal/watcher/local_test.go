@@ -0,0 +1,123 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jandubois/monitor/internal/notify"
+	"github.com/jandubois/monitor/internal/probe"
+)
+
+var testProbeTypes = []RegisterProbeType{
+	{Name: "http", Version: "1.0.0", ExecutablePath: "/old/http"},
+	{Name: "http", Version: "1.10.0", ExecutablePath: "/new/http", schema: probe.Arguments{
+		Required: map[string]probe.ArgumentSpec{"url": {Type: "string"}},
+	}},
+	{Name: "disk-space", Version: "1.0.0", ExecutablePath: "/bin/monitor", Subcommand: "disk-space"},
+}
+
+func writeLocalConfigs(t *testing.T, path, data string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+}
+
+func TestLocalConfigsLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "probes.yaml")
+	writeLocalConfigs(t, path, `
+notification_channels:
+  - name: hook
+    type: webhook
+    config:
+      url: http://localhost:9/hook
+probes:
+  - name: site
+    type: http
+    interval: 1m
+    arguments:
+      url: https://example.com
+    notification_channels: [hook]
+    consecutive_results: 2
+  - name: root-disk
+    type: disk-space
+    watcher: nas
+    interval: 5m
+  - name: other
+    type: http
+    watcher: elsewhere
+    interval: 1m
+  - name: paused
+    type: disk-space
+    enabled: false
+    interval: 5m
+`)
+
+	local := NewLocalConfigs(path, "nas", notify.NewDispatcher(nil))
+	local.SetProbeTypes(testProbeTypes)
+	configs, err := local.Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(configs) != 2 {
+		t.Fatalf("expected 2 configs, got %d", len(configs))
+	}
+	site, disk := configs[0], configs[1]
+	if site.ID >= 0 || disk.ID >= 0 || site.ID == disk.ID {
+		t.Errorf("expected distinct negative IDs, got %d and %d", site.ID, disk.ID)
+	}
+	if site.ExecutablePath != "/new/http" || site.ConsecutiveResults != 2 || len(site.NotificationChannels) != 1 {
+		t.Errorf("unexpected config %+v", site)
+	}
+	if disk.Subcommand != "disk-space" || disk.ConsecutiveResults != 1 || disk.Schedule == nil {
+		t.Errorf("unexpected config %+v", disk)
+	}
+
+	// IDs stay with the names when the file changes
+	writeLocalConfigs(t, path, `
+probes:
+  - name: new
+    type: disk-space
+    interval: 5m
+  - name: site
+    type: http
+    interval: 1m
+    arguments:
+      url: https://example.com
+`)
+	reloaded, err := local.Load()
+	if err != nil {
+		t.Fatalf("reload failed: %v", err)
+	}
+	if reloaded[1].ID != site.ID || reloaded[0].ID == disk.ID {
+		t.Errorf("expected site to keep ID %d, got %d and %d", site.ID, reloaded[1].ID, reloaded[0].ID)
+	}
+}
+
+func TestLocalConfigsLoadErrors(t *testing.T) {
+	tests := map[string]string{
+		"unknown type":     "probes:\n  - {name: a, type: ping, interval: 1m}\n",
+		"arguments":        "probes:\n  - {name: a, type: http, interval: 1m}\n",
+		"schedule":         "probes:\n  - {name: a, type: disk-space, interval: often}\n",
+		"unknown channel":  "probes:\n  - {name: a, type: disk-space, interval: 1m, notification_channels: [ops]}\n",
+		"channel type":     "notification_channels:\n  - {name: ops, type: pager}\n",
+		"composite":        "probes:\n  - {name: a, type: disk-space, interval: 1m}\n  - {name: b, composite: {mode: all, members: [a]}}\n",
+		"escalation":       "probes:\n  - {name: a, type: disk-space, interval: 1m, escalation_policy: on-call}\n",
+		"flap detection":   "probes:\n  - {name: a, type: disk-space, interval: 1m, flap_window: 5, flap_threshold: 3}\n",
+		"invalid manifest": "probes:\n  - {name: a, interval: 1m}\n",
+	}
+	path := filepath.Join(t.TempDir(), "probes.yaml")
+	for name, data := range tests {
+		writeLocalConfigs(t, path, data)
+		local := NewLocalConfigs(path, "nas", notify.NewDispatcher(nil))
+		local.SetProbeTypes(testProbeTypes)
+		_, err := local.Load()
+		if err == nil {
+			t.Errorf("%s: expected error", name)
+		} else if !strings.Contains(err.Error(), path) {
+			t.Errorf("%s: expected the path in %v", name, err)
+		}
+	}
+}
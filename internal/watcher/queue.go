@@ -0,0 +1,200 @@
+package watcher
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// queueCompactEvery is how many replayed results may accumulate before the
+// queue file is rewritten. A crash in between replays at most this many
+// results twice.
+const queueCompactEvery = 100
+
+// ResultQueue is a durable FIFO of results that could not be delivered to the
+// web service. It is stored as JSON lines in an append-only file; replayed
+// entries are removed by rewriting the file.
+type ResultQueue struct {
+	path string
+
+	mu      sync.Mutex
+	pending []*ResultRequest
+
+	replaying sync.Mutex
+}
+
+// OpenResultQueue opens or creates the queue file at path and loads any
+// results left over from a previous run.
+func OpenResultQueue(path string) (*ResultQueue, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("create queue directory: %w", err)
+	}
+
+	q := &ResultQueue{path: path}
+
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("read queue: %w", err)
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var req ResultRequest
+		if err := json.Unmarshal(line, &req); err != nil {
+			// Most likely a partial write from a crash; skip it
+			slog.Warn("skipping corrupt queue entry", "path", path, "error", err)
+			continue
+		}
+		q.pending = append(q.pending, &req)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read queue: %w", err)
+	}
+
+	if len(q.pending) > 0 {
+		slog.Info("loaded queued results", "count", len(q.pending))
+	}
+	return q, nil
+}
+
+// Len returns the number of queued results.
+func (q *ResultQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.pending)
+}
+
+// Push appends a result to the queue and syncs it to disk.
+func (q *ResultQueue) Push(req *ResultRequest) error {
+	line, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("marshal result: %w", err)
+	}
+	line = append(line, '\n')
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	f, err := os.OpenFile(q.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("open queue: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(line); err != nil {
+		return fmt.Errorf("write queue: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		return fmt.Errorf("sync queue: %w", err)
+	}
+
+	q.pending = append(q.pending, req)
+	return nil
+}
+
+// Replay sends queued results in order until the queue is empty or send
+// fails. Results rejected as invalid (see isPermanent) are dropped so they
+// can't block the queue. Only one replay runs at a time; concurrent calls
+// return immediately. It returns the number of results removed.
+func (q *ResultQueue) Replay(ctx context.Context, send func(context.Context, *ResultRequest) error) (int, error) {
+	if !q.replaying.TryLock() {
+		return 0, nil
+	}
+	defer q.replaying.Unlock()
+
+	removed := 0
+	defer func() {
+		if removed > 0 {
+			if err := q.rewrite(); err != nil {
+				slog.Error("failed to rewrite result queue", "error", err)
+			}
+		}
+	}()
+
+	for {
+		q.mu.Lock()
+		if len(q.pending) == 0 {
+			q.mu.Unlock()
+			return removed, nil
+		}
+		req := q.pending[0]
+		q.mu.Unlock()
+
+		if err := send(ctx, req); err != nil {
+			if !isPermanent(err) {
+				return removed, err
+			}
+			slog.Error("dropping queued result rejected by web service",
+				"probe_config_id", req.ProbeConfigID, "executed_at", req.ExecutedAt, "error", err)
+		}
+
+		q.mu.Lock()
+		q.pending = q.pending[1:]
+		q.mu.Unlock()
+		removed++
+
+		if removed%queueCompactEvery == 0 {
+			if err := q.rewrite(); err != nil {
+				return removed, err
+			}
+		}
+	}
+}
+
+// rewrite replaces the queue file with the current pending results.
+func (q *ResultQueue) rewrite() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var buf bytes.Buffer
+	for _, req := range q.pending {
+		line, err := json.Marshal(req)
+		if err != nil {
+			return fmt.Errorf("marshal result: %w", err)
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+
+	tmp := q.path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("create queue: %w", err)
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		return fmt.Errorf("write queue: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("sync queue: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("close queue: %w", err)
+	}
+	return os.Rename(tmp, q.path)
+}
+
+// isPermanent reports whether err is a rejection by the web service that
+// retrying won't fix.
+func isPermanent(err error) bool {
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) {
+		return false
+	}
+	// Auth failures are usually temporary (e.g. watcher awaiting approval)
+	return statusErr.StatusCode >= 400 && statusErr.StatusCode < 500 &&
+		statusErr.StatusCode != 401 && statusErr.StatusCode != 403
+}
@@ -0,0 +1,151 @@
+package watcher
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jandubois/monitor/internal/probe"
+)
+
+func TestResultQueuePersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.queue")
+
+	q, err := OpenResultQueue(path)
+	if err != nil {
+		t.Fatalf("open queue: %v", err)
+	}
+	executedAt := time.Date(2025, 3, 3, 10, 0, 0, 0, time.UTC)
+	for i := 1; i <= 3; i++ {
+		if err := q.Push(&ResultRequest{ProbeConfigID: i, ExecutedAt: executedAt.Add(time.Duration(i) * time.Minute)}); err != nil {
+			t.Fatalf("push: %v", err)
+		}
+	}
+
+	// Simulate a crash halfway through an append
+	f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0600)
+	f.WriteString(`{"probe_config_id": 4, "sta`)
+	f.Close()
+
+	q, err = OpenResultQueue(path)
+	if err != nil {
+		t.Fatalf("reopen queue: %v", err)
+	}
+	if q.Len() != 3 {
+		t.Fatalf("expected 3 queued results after reopen, got %d", q.Len())
+	}
+
+	// First send fails: nothing is removed
+	sendErr := errors.New("connection refused")
+	n, err := q.Replay(context.Background(), func(context.Context, *ResultRequest) error { return sendErr })
+	if !errors.Is(err, sendErr) || n != 0 || q.Len() != 3 {
+		t.Fatalf("expected failed replay to keep queue, got n=%d len=%d err=%v", n, q.Len(), err)
+	}
+
+	// Second result is rejected permanently and dropped; the rest are sent in order
+	var sent []*ResultRequest
+	n, err = q.Replay(context.Background(), func(_ context.Context, req *ResultRequest) error {
+		if req.ProbeConfigID == 2 {
+			return &StatusError{StatusCode: http.StatusBadRequest}
+		}
+		sent = append(sent, req)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	if n != 3 || q.Len() != 0 {
+		t.Errorf("expected 3 removed and empty queue, got n=%d len=%d", n, q.Len())
+	}
+	if len(sent) != 2 || sent[0].ProbeConfigID != 1 || sent[1].ProbeConfigID != 3 {
+		t.Fatalf("unexpected replay order: %+v", sent)
+	}
+	if !sent[0].ExecutedAt.Equal(executedAt.Add(time.Minute)) {
+		t.Errorf("expected original executed_at, got %v", sent[0].ExecutedAt)
+	}
+
+	q, err = OpenResultQueue(path)
+	if err != nil {
+		t.Fatalf("reopen queue: %v", err)
+	}
+	if q.Len() != 0 {
+		t.Errorf("expected empty queue on disk after replay, got %d", q.Len())
+	}
+}
+
+func TestHTTPResultWriterQueuesWhileOffline(t *testing.T) {
+	var mu sync.Mutex
+	online := false
+	var received []ResultRequest
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if !online {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		var req ResultRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		received = append(received, req)
+	}))
+	defer server.Close()
+
+	queue, err := OpenResultQueue(filepath.Join(t.TempDir(), "test.queue"))
+	if err != nil {
+		t.Fatalf("open queue: %v", err)
+	}
+	writer := NewHTTPResultWriter(NewClient(server.URL, "token"), "laptop", queue)
+
+	// A cancelled context skips postWithRetry's backoff
+	offlineCtx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	first := time.Date(2025, 3, 3, 10, 0, 0, 0, time.UTC)
+	cfg := &ProbeConfig{ID: 7, Name: "disk"}
+	result := &probe.Result{Status: probe.StatusOK, Message: "fine"}
+	if err := writer.WriteResult(offlineCtx, cfg, result, first, first, 10); err != nil {
+		t.Fatalf("write while offline: %v", err)
+	}
+	if writer.QueueDepth() != 1 {
+		t.Fatalf("expected 1 queued result, got %d", writer.QueueDepth())
+	}
+
+	mu.Lock()
+	online = true
+	mu.Unlock()
+
+	// Results written while the queue is non-empty go behind it
+	second := first.Add(time.Minute)
+	if err := writer.WriteResult(context.Background(), cfg, result, second, second, 10); err != nil {
+		t.Fatalf("write behind queue: %v", err)
+	}
+	if writer.QueueDepth() != 2 {
+		t.Fatalf("expected 2 queued results, got %d", writer.QueueDepth())
+	}
+
+	n, err := writer.Flush(context.Background())
+	if err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+	if n != 2 || writer.QueueDepth() != 0 {
+		t.Errorf("expected 2 delivered and empty queue, got n=%d depth=%d", n, writer.QueueDepth())
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(received) != 2 {
+		t.Fatalf("expected 2 results delivered, got %d", len(received))
+	}
+	if !received[0].ExecutedAt.Equal(first) || !received[1].ExecutedAt.Equal(second) {
+		t.Errorf("results replayed out of order or with wrong executed_at: %v, %v",
+			received[0].ExecutedAt, received[1].ExecutedAt)
+	}
+}
@@ -2,11 +2,29 @@ package watcher
 
 import (
 	"context"
+	"errors"
 	"log/slog"
 	"reflect"
+	"slices"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/jandubois/monitor/internal/schedule"
+)
+
+const (
+	// configPollInterval is how often configs are reloaded while the
+	// config channel is closed.
+	configPollInterval = 5 * time.Second
+
+	// configChannelPollInterval is how often configs are reloaded while
+	// the config channel is open, in case it missed something.
+	configChannelPollInterval = time.Minute
+
+	configChannelMinRetry = 5 * time.Second
+	configChannelMaxRetry = time.Minute
 )
 
 // ProbeConfig represents a configured probe instance.
@@ -16,24 +34,32 @@ type ProbeConfig struct {
 	ExecutablePath       string
 	Subcommand           string // If set, execute as: binary <subcommand> --args
 	Arguments            map[string]any
-	Interval             time.Duration
+	Interval             string   // Interval or cron expression
+	Timezone             string   // For cron schedules and active windows
+	ActiveWindows        []string // Daily windows, e.g. "08:00-22:00"
+	Schedule             *schedule.Schedule
 	TimeoutSeconds       int
 	NextRunAt            *time.Time
-	NotificationChannels []int // Kept for compatibility with ResultWriter interface
+	NotificationChannels []int // Local configs only; the web service notifies for its own
+	ConsecutiveResults   int   // Local configs only
 }
 
 // Scheduler manages probe execution timing.
 type Scheduler struct {
-	client      *Client
+	client      *Client // Nil in standalone mode
+	local       *LocalConfigs
 	executor    *Executor
 	watcherName string
 
 	mu      sync.RWMutex
 	configs map[int]*ProbeConfig
 	timers  map[int]*time.Timer
+
+	channelOpen atomic.Bool
 }
 
-// NewScheduler creates a new Scheduler.
+// NewScheduler creates a new Scheduler. client is nil in standalone mode,
+// which runs only local configs.
 func NewScheduler(client *Client, executor *Executor, watcherName string) *Scheduler {
 	return &Scheduler{
 		client:      client,
@@ -44,6 +70,11 @@ func NewScheduler(client *Client, executor *Executor, watcherName string) *Sched
 	}
 }
 
+// SetLocalConfigs adds probe configs from a file on the watcher.
+func (s *Scheduler) SetLocalConfigs(local *LocalConfigs) {
+	s.local = local
+}
+
 // Run starts the scheduler loop.
 func (s *Scheduler) Run(ctx context.Context) {
 	// Initial load
@@ -51,85 +82,184 @@ func (s *Scheduler) Run(ctx context.Context) {
 		slog.Error("initial config load failed", "error", err)
 	}
 
-	// Periodic config refresh to pick up new configs or trigger requests
-	ticker := time.NewTicker(5 * time.Second)
+	// Local configs change only on reload requests
+	if s.client == nil {
+		<-ctx.Done()
+		s.stopAllTimers()
+		return
+	}
+
+	// Changes and triggers arrive through the config channel; the
+	// periodic refresh is the fallback while it is closed
+	go s.listen(ctx)
+
+	ticker := time.NewTicker(configPollInterval)
 	defer ticker.Stop()
 
+	lastReload := time.Now()
 	for {
 		select {
 		case <-ctx.Done():
 			s.stopAllTimers()
 			return
 		case <-ticker.C:
-			if err := s.Reload(ctx); err != nil {
+			if s.channelOpen.Load() && time.Since(lastReload) < configChannelPollInterval {
+				continue
+			}
+			if err := s.reloadRemote(ctx); err != nil {
+				slog.Error("config reload failed", "error", err)
+			}
+			lastReload = time.Now()
+		}
+	}
+}
+
+// listen holds the config channel open, reconnecting with a growing delay
+// when it fails. It gives up on web services without one.
+func (s *Scheduler) listen(ctx context.Context) {
+	delay := configChannelMinRetry
+	for {
+		err := s.client.StreamEvents(ctx, func() {
+			s.channelOpen.Store(true)
+			delay = configChannelMinRetry
+			slog.Debug("config channel open")
+			// Changes made while it was closed are not replayed
+			if err := s.reloadRemote(ctx); err != nil {
 				slog.Error("config reload failed", "error", err)
 			}
+		}, func(e ChannelEvent) {
+			s.handleChannelEvent(ctx, e)
+		})
+		s.channelOpen.Store(false)
+
+		if ctx.Err() != nil {
+			return
+		}
+		if errors.Is(err, errNoConfigChannel) {
+			slog.Info("web service has no config channel, polling for changes")
+			return
+		}
+		if err != nil {
+			slog.Warn("config channel failed", "error", err, "retry", delay)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
 		}
+		delay = min(delay*2, configChannelMaxRetry)
 	}
 }
 
-// Reload reloads probe configurations from the web service.
+func (s *Scheduler) handleChannelEvent(ctx context.Context, e ChannelEvent) {
+	switch e.Type {
+	case "config_change":
+		if err := s.reloadRemote(ctx); err != nil {
+			slog.Error("config reload failed", "error", err)
+		}
+	case "trigger":
+		if err := s.TriggerImmediate(ctx, strconv.Itoa(e.ProbeConfigID)); err != nil {
+			slog.Error("failed to trigger probe", "config_id", e.ProbeConfigID, "error", err)
+		}
+	}
+}
+
+// Reload reloads probe configurations from the local file and the web
+// service.
 func (s *Scheduler) Reload(ctx context.Context) error {
+	var errs []error
+	if s.local != nil {
+		configs, err := s.local.Load()
+		if err != nil {
+			errs = append(errs, err)
+		} else {
+			s.update(ctx, configs, true)
+			slog.Debug("loaded local probe configs", "count", len(configs))
+		}
+	}
+	if s.client != nil {
+		errs = append(errs, s.reloadRemote(ctx))
+	}
+	return errors.Join(errs...)
+}
+
+// reloadRemote reloads probe configurations from the web service.
+func (s *Scheduler) reloadRemote(ctx context.Context) error {
 	// Fetch configs from web service
-	configs, err := s.client.GetConfigs(ctx, s.watcherName)
+	responses, err := s.client.GetConfigs(ctx, s.watcherName)
 	if err != nil {
 		return err
 	}
 
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	// Track which configs we've seen
-	seen := make(map[int]bool)
-
-	for _, cfg := range configs {
-		seen[cfg.ID] = true
-
-		interval, err := parseInterval(cfg.Interval)
+	var configs []*ProbeConfig
+	for _, cfg := range responses {
+		sched, err := schedule.Parse(cfg.Interval, cfg.Timezone, cfg.ActiveWindows)
 		if err != nil {
-			slog.Error("parse interval failed", "config", cfg.Name, "interval", cfg.Interval, "error", err)
+			slog.Error("parse schedule failed", "config", cfg.Name, "interval", cfg.Interval, "error", err)
 			continue
 		}
 
-		probeConfig := &ProbeConfig{
+		configs = append(configs, &ProbeConfig{
 			ID:             cfg.ID,
 			Name:           cfg.Name,
 			ExecutablePath: cfg.ExecutablePath,
 			Subcommand:     cfg.Subcommand,
 			Arguments:      cfg.Arguments,
-			Interval:       interval,
+			Interval:       cfg.Interval,
+			Timezone:       cfg.Timezone,
+			ActiveWindows:  cfg.ActiveWindows,
+			Schedule:       sched,
 			TimeoutSeconds: cfg.TimeoutSeconds,
 			NextRunAt:      cfg.NextRunAt,
-		}
+		})
+	}
+	s.update(ctx, configs, false)
+
+	slog.Debug("loaded probe configs", "count", len(configs))
+	return nil
+}
+
+// update schedules new and changed configs and stops the ones that are
+// gone. Local configs, which have negative IDs, and the web service's are
+// updated separately.
+func (s *Scheduler) update(ctx context.Context, configs []*ProbeConfig, local bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Track which configs we've seen
+	seen := make(map[int]bool)
+
+	for _, probeConfig := range configs {
+		seen[probeConfig.ID] = true
 
 		// Check if config changed or is new
-		existing, exists := s.configs[cfg.ID]
+		existing, exists := s.configs[probeConfig.ID]
 		if exists && !s.configChanged(existing, probeConfig) {
 			continue
 		}
 
 		// Stop existing timer if any
-		if timer, ok := s.timers[cfg.ID]; ok {
+		if timer, ok := s.timers[probeConfig.ID]; ok {
 			timer.Stop()
-			delete(s.timers, cfg.ID)
+			delete(s.timers, probeConfig.ID)
 		}
 
-		s.configs[cfg.ID] = probeConfig
+		s.configs[probeConfig.ID] = probeConfig
 		s.scheduleProbe(ctx, probeConfig)
 	}
 
 	// Remove configs that are no longer assigned to us
-	for id, timer := range s.timers {
-		if !seen[id] {
-			timer.Stop()
-			delete(s.timers, id)
+	for id := range s.configs {
+		if (id < 0) == local && !seen[id] {
+			if timer, ok := s.timers[id]; ok {
+				timer.Stop()
+				delete(s.timers, id)
+			}
 			delete(s.configs, id)
 			slog.Debug("removed config", "id", id)
 		}
 	}
-
-	slog.Debug("loaded probe configs", "count", len(configs))
-	return nil
 }
 
 // TriggerImmediate runs a probe immediately with fresh config.
@@ -164,16 +294,20 @@ func (s *Scheduler) TriggerImmediate(ctx context.Context, configIDStr string) er
 }
 
 func (s *Scheduler) scheduleProbe(ctx context.Context, cfg *ProbeConfig) {
-	delay := s.calculateNextRun(cfg)
+	delay, ok := s.calculateNextRun(cfg, time.Now())
+	if !ok {
+		slog.Warn("probe has no upcoming run", "name", cfg.Name, "interval", cfg.Interval)
+		return
+	}
 	slog.Debug("scheduling probe", "name", cfg.Name, "delay", delay)
 
 	timer := time.AfterFunc(delay, func() {
 		if err := s.executor.Execute(ctx, cfg); err != nil {
 			slog.Error("probe execution failed", "name", cfg.Name, "error", err)
 		}
-		// Reschedule with interval (next_run_at will be updated via web service)
+		// Reschedule (next_run_at will be updated via web service)
 		s.mu.Lock()
-		cfg.NextRunAt = nil // Clear next_run_at, will be recalculated based on interval
+		cfg.NextRunAt = nil // Clear next_run_at, will be recalculated from the schedule
 		s.scheduleProbe(ctx, cfg)
 		s.mu.Unlock()
 	})
@@ -181,19 +315,25 @@ func (s *Scheduler) scheduleProbe(ctx context.Context, cfg *ProbeConfig) {
 	s.timers[cfg.ID] = timer
 }
 
-func (s *Scheduler) calculateNextRun(cfg *ProbeConfig) time.Duration {
+// calculateNextRun returns the delay until the next run as of now, and false
+// if the schedule has no upcoming run.
+func (s *Scheduler) calculateNextRun(cfg *ProbeConfig, now time.Time) (time.Duration, bool) {
 	// If next_run_at is set (either from web service or probe result), use it
 	if cfg.NextRunAt != nil {
-		delay := time.Until(*cfg.NextRunAt)
+		delay := cfg.NextRunAt.Sub(now)
 		if delay < 0 {
 			// Overdue, run immediately
-			return 0
+			return 0, true
 		}
-		return delay
+		return delay, true
 	}
 
-	// Use the configured interval
-	return cfg.Interval
+	// Same computation the web service uses for next_run_at
+	next := cfg.Schedule.Next(now)
+	if next.IsZero() {
+		return 0, false
+	}
+	return next.Sub(now), true
 }
 
 func (s *Scheduler) configChanged(old, new *ProbeConfig) bool {
@@ -203,7 +343,10 @@ func (s *Scheduler) configChanged(old, new *ProbeConfig) bool {
 	if old.Subcommand != new.Subcommand {
 		return true
 	}
-	if old.Interval != new.Interval {
+	if old.Interval != new.Interval || old.Timezone != new.Timezone {
+		return true
+	}
+	if !slices.Equal(old.ActiveWindows, new.ActiveWindows) {
 		return true
 	}
 	if old.TimeoutSeconds != new.TimeoutSeconds {
@@ -212,6 +355,9 @@ func (s *Scheduler) configChanged(old, new *ProbeConfig) bool {
 	if !reflect.DeepEqual(old.Arguments, new.Arguments) {
 		return true
 	}
+	if !slices.Equal(old.NotificationChannels, new.NotificationChannels) || old.ConsecutiveResults != new.ConsecutiveResults {
+		return true
+	}
 	// Check if next_run_at changed and is in the past (immediate run requested)
 	if new.NextRunAt != nil && (old.NextRunAt == nil || !new.NextRunAt.Equal(*old.NextRunAt)) {
 		if time.Until(*new.NextRunAt) <= 0 {
@@ -228,26 +374,3 @@ func (s *Scheduler) stopAllTimers() {
 		timer.Stop()
 	}
 }
-
-// parseInterval parses interval strings like "5m", "1h", "1d".
-func parseInterval(s string) (time.Duration, error) {
-	if len(s) < 2 {
-		return 0, nil
-	}
-
-	value, err := strconv.Atoi(s[:len(s)-1])
-	if err != nil {
-		return 0, err
-	}
-
-	switch s[len(s)-1] {
-	case 'm':
-		return time.Duration(value) * time.Minute, nil
-	case 'h':
-		return time.Duration(value) * time.Hour, nil
-	case 'd':
-		return time.Duration(value) * 24 * time.Hour, nil
-	default:
-		return time.ParseDuration(s)
-	}
-}
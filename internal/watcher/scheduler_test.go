@@ -0,0 +1,50 @@
+package watcher
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jandubois/monitor/internal/schedule"
+)
+
+func TestCalculateNextRun(t *testing.T) {
+	now := time.Date(2025, 3, 3, 21, 30, 0, 0, time.UTC)
+	soon := now.Add(2 * time.Minute)
+	past := now.Add(-time.Minute)
+
+	mustParse := func(spec string, windows ...string) *schedule.Schedule {
+		t.Helper()
+		sched, err := schedule.Parse(spec, "", windows)
+		if err != nil {
+			t.Fatalf("parse schedule: %v", err)
+		}
+		return sched
+	}
+
+	tests := []struct {
+		name      string
+		cfg       ProbeConfig
+		wantDelay time.Duration
+		wantOK    bool
+	}{
+		{"interval", ProbeConfig{Schedule: mustParse("15m")}, 15 * time.Minute, true},
+		{"next_run_at wins", ProbeConfig{Schedule: mustParse("15m"), NextRunAt: &soon}, 2 * time.Minute, true},
+		{"overdue", ProbeConfig{Schedule: mustParse("15m"), NextRunAt: &past}, 0, true},
+		{"cron", ProbeConfig{Schedule: mustParse("0 22 * * *")}, 30 * time.Minute, true},
+		{"outside window", ProbeConfig{Schedule: mustParse("1h", "08:00-22:00")}, 10*time.Hour + 30*time.Minute, true},
+		{"no schedule", ProbeConfig{Schedule: mustParse("")}, 0, false},
+	}
+
+	s := &Scheduler{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			delay, ok := s.calculateNextRun(&tt.cfg, now)
+			if ok != tt.wantOK {
+				t.Fatalf("expected ok=%v, got %v", tt.wantOK, ok)
+			}
+			if delay != tt.wantDelay {
+				t.Errorf("expected delay %v, got %v", tt.wantDelay, delay)
+			}
+		})
+	}
+}
@@ -0,0 +1,221 @@
+package watcher
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/jandubois/monitor/internal/db"
+	"github.com/jandubois/monitor/internal/notify"
+	"github.com/jandubois/monitor/internal/probe"
+)
+
+// LocalResult is a result of a local probe config.
+type LocalResult struct {
+	Watcher     string         `json:"watcher"`
+	Probe       string         `json:"probe"`
+	Status      probe.Status   `json:"status"`
+	Message     string         `json:"message"`
+	Metrics     map[string]any `json:"metrics,omitempty"`
+	Data        map[string]any `json:"data,omitempty"`
+	DurationMs  int            `json:"duration_ms"`
+	ScheduledAt time.Time      `json:"scheduled_at"`
+	ExecutedAt  time.Time      `json:"executed_at"`
+}
+
+// ResultSink stores the results of local probe configs.
+type ResultSink interface {
+	Write(ctx context.Context, result *LocalResult) error
+	Close() error
+}
+
+// OpenResultSink opens the sink at path. "-" writes JSON lines to standard
+// output, a path ending in .db, .sqlite or .sqlite3 stores results in a
+// SQLite database, and any other path appends JSON lines to the file.
+func OpenResultSink(ctx context.Context, path string) (ResultSink, error) {
+	switch {
+	case path == "-":
+		return &jsonLinesSink{w: os.Stdout}, nil
+	case slices.Contains([]string{".db", ".sqlite", ".sqlite3"}, filepath.Ext(path)):
+		return openSQLiteSink(ctx, path)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("open result file: %w", err)
+	}
+	return &jsonLinesSink{w: f, closer: f}, nil
+}
+
+// jsonLinesSink writes one JSON object per result.
+type jsonLinesSink struct {
+	mu     sync.Mutex
+	w      io.Writer
+	closer io.Closer // Nil for standard output
+}
+
+func (s *jsonLinesSink) Write(ctx context.Context, result *LocalResult) error {
+	line, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.w.Write(append(line, '\n'))
+	return err
+}
+
+func (s *jsonLinesSink) Close() error {
+	if s.closer == nil {
+		return nil
+	}
+	return s.closer.Close()
+}
+
+// sqliteSink stores results in a table of their own, not in the web
+// service's schema.
+type sqliteSink struct {
+	db *db.DB
+}
+
+func openSQLiteSink(ctx context.Context, path string) (*sqliteSink, error) {
+	database, err := db.Connect(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	_, err = database.DB().ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS results (
+			id INTEGER PRIMARY KEY,
+			watcher TEXT NOT NULL,
+			probe TEXT NOT NULL,
+			status TEXT NOT NULL,
+			message TEXT NOT NULL,
+			metrics TEXT,
+			data TEXT,
+			duration_ms INTEGER NOT NULL,
+			scheduled_at TEXT NOT NULL,
+			executed_at TEXT NOT NULL
+		);
+		CREATE INDEX IF NOT EXISTS idx_results_probe ON results(probe, executed_at);
+	`)
+	if err != nil {
+		database.Close()
+		return nil, fmt.Errorf("create results table: %w", err)
+	}
+	return &sqliteSink{db: database}, nil
+}
+
+func (s *sqliteSink) Write(ctx context.Context, result *LocalResult) error {
+	metrics, err := json.Marshal(result.Metrics)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(result.Data)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.DB().ExecContext(ctx, `
+		INSERT INTO results (watcher, probe, status, message, metrics, data, duration_ms, scheduled_at, executed_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, result.Watcher, result.Probe, result.Status, result.Message, string(metrics), string(data), result.DurationMs,
+		result.ScheduledAt.UTC().Format(db.SQLiteTimeFormat), result.ExecutedAt.UTC().Format(db.SQLiteTimeFormat))
+	return err
+}
+
+func (s *sqliteSink) Close() error {
+	s.db.Close()
+	return nil
+}
+
+// LocalResultWriter stores the results of local probe configs in a sink,
+// and notifies their notification channels of status changes. Results of
+// the web service's configs go to the remote writer.
+type LocalResultWriter struct {
+	sink        ResultSink
+	dispatcher  *notify.Dispatcher
+	watcherName string
+	remote      ResultWriter // Nil in standalone mode
+
+	mu     sync.Mutex
+	states map[int]*localStatus
+}
+
+// localStatus is the notification state of a local config.
+type localStatus struct {
+	confirmed probe.Status   // Status that notifications are based on
+	recent    []probe.Status // Newest first
+}
+
+// NewLocalResultWriter creates a result writer for local configs. remote
+// may be nil when there are no other configs.
+func NewLocalResultWriter(sink ResultSink, dispatcher *notify.Dispatcher, watcherName string, remote ResultWriter) *LocalResultWriter {
+	return &LocalResultWriter{
+		sink:        sink,
+		dispatcher:  dispatcher,
+		watcherName: watcherName,
+		remote:      remote,
+		states:      make(map[int]*localStatus),
+	}
+}
+
+// WriteResult stores a result and notifies a confirmed status change.
+func (w *LocalResultWriter) WriteResult(ctx context.Context, cfg *ProbeConfig, result *probe.Result, scheduledAt, executedAt time.Time, durationMs int) error {
+	if cfg.ID > 0 {
+		if w.remote == nil {
+			return nil
+		}
+		return w.remote.WriteResult(ctx, cfg, result, scheduledAt, executedAt, durationMs)
+	}
+
+	w.checkStatusChange(ctx, cfg, result)
+	return w.sink.Write(ctx, &LocalResult{
+		Watcher:     w.watcherName,
+		Probe:       cfg.Name,
+		Status:      result.Status,
+		Message:     result.Message,
+		Metrics:     result.Metrics,
+		Data:        result.Data,
+		DurationMs:  durationMs,
+		ScheduledAt: scheduledAt,
+		ExecutedAt:  executedAt,
+	})
+}
+
+// checkStatusChange notifies a status change once the config's latest
+// results agree on it. The first status is only notified if it is not ok,
+// so that restarting the watcher doesn't notify every probe.
+func (w *LocalResultWriter) checkStatusChange(ctx context.Context, cfg *ProbeConfig, result *probe.Result) {
+	w.mu.Lock()
+	state, ok := w.states[cfg.ID]
+	if !ok {
+		state = &localStatus{}
+		w.states[cfg.ID] = state
+	}
+	n := max(cfg.ConsecutiveResults, 1)
+	state.recent = append([]probe.Status{result.Status}, state.recent...)
+	if len(state.recent) > n {
+		state.recent = state.recent[:n]
+	}
+	old := state.confirmed
+	changed := len(state.recent) == n && result.Status != old &&
+		!slices.ContainsFunc(state.recent, func(s probe.Status) bool { return s != result.Status })
+	if changed {
+		state.confirmed = result.Status
+	}
+	w.mu.Unlock()
+
+	if !changed || (old == "" && result.Status == probe.StatusOK) {
+		return
+	}
+	w.dispatcher.NotifyStatusChange(ctx, cfg.NotificationChannels, &notify.StatusChange{
+		ProbeName: cfg.Name,
+		OldStatus: old,
+		NewStatus: result.Status,
+		Message:   result.Message,
+	})
+}
@@ -0,0 +1,133 @@
+package watcher
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jandubois/monitor/internal/db"
+	"github.com/jandubois/monitor/internal/notify"
+	"github.com/jandubois/monitor/internal/probe"
+)
+
+type recordingWriter struct {
+	configIDs []int
+}
+
+func (w *recordingWriter) WriteResult(ctx context.Context, cfg *ProbeConfig, result *probe.Result, scheduledAt, executedAt time.Time, durationMs int) error {
+	w.configIDs = append(w.configIDs, cfg.ID)
+	return nil
+}
+
+func TestLocalResultWriter(t *testing.T) {
+	notified := make(chan string, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload notify.WebhookPayload
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &payload)
+		notified <- payload.OldStatus + "->" + payload.NewStatus
+	}))
+	defer server.Close()
+
+	channel, err := notify.NewWebhookChannel(notify.WebhookConfig{URL: server.URL})
+	if err != nil {
+		t.Fatalf("create channel: %v", err)
+	}
+	dispatcher := notify.NewDispatcher(nil)
+	dispatcher.SetChannels(map[int]notify.Channel{1: channel})
+
+	var out bytes.Buffer
+	remote := &recordingWriter{}
+	w := NewLocalResultWriter(&jsonLinesSink{w: &out}, dispatcher, "nas", remote)
+	ctx := context.Background()
+	now := time.Now()
+
+	cfg := &ProbeConfig{ID: -1, Name: "site", NotificationChannels: []int{1}, ConsecutiveResults: 2}
+	statuses := []probe.Status{probe.StatusOK, probe.StatusOK, probe.StatusCritical, probe.StatusOK, probe.StatusCritical, probe.StatusCritical}
+	for _, status := range statuses {
+		result := &probe.Result{Status: status, Message: "checked"}
+		if err := w.WriteResult(ctx, cfg, result, now, now, 5); err != nil {
+			t.Fatalf("WriteResult failed: %v", err)
+		}
+	}
+
+	// The first ok is not notified, and one critical result is not enough
+	select {
+	case change := <-notified:
+		if change != "ok->critical" {
+			t.Errorf("expected ok->critical, got %s", change)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected a notification")
+	}
+	select {
+	case change := <-notified:
+		t.Errorf("unexpected notification %s", change)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != len(statuses) {
+		t.Fatalf("expected %d lines, got %d", len(statuses), len(lines))
+	}
+	var last LocalResult
+	if err := json.Unmarshal([]byte(lines[len(lines)-1]), &last); err != nil {
+		t.Fatalf("decode line: %v", err)
+	}
+	if last.Watcher != "nas" || last.Probe != "site" || last.Status != probe.StatusCritical || last.DurationMs != 5 {
+		t.Errorf("unexpected result %+v", last)
+	}
+
+	// Results of the web service's configs pass through
+	w.WriteResult(ctx, &ProbeConfig{ID: 7}, &probe.Result{Status: probe.StatusOK}, now, now, 1)
+	if len(remote.configIDs) != 1 || remote.configIDs[0] != 7 || strings.Count(out.String(), "\n") != len(statuses) {
+		t.Errorf("expected the result of config 7 to go to the web service only, got %v", remote.configIDs)
+	}
+}
+
+func TestSQLiteSink(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "results.db")
+	sink, err := OpenResultSink(ctx, path)
+	if err != nil {
+		t.Skipf("failed to open SQLite database: %v", err)
+	}
+	executedAt := time.Date(2025, 3, 3, 10, 0, 0, 0, time.UTC)
+	err = sink.Write(ctx, &LocalResult{
+		Watcher:     "nas",
+		Probe:       "site",
+		Status:      probe.StatusWarning,
+		Message:     "slow",
+		Metrics:     map[string]any{"latency_ms": 900},
+		ScheduledAt: executedAt,
+		ExecutedAt:  executedAt,
+	})
+	if err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	sink.Close()
+
+	// Reopening keeps the results
+	sink, err = OpenResultSink(ctx, path)
+	if err != nil {
+		t.Fatalf("reopen sink: %v", err)
+	}
+	defer sink.Close()
+
+	var probeName, status, metrics, at string
+	err = sink.(*sqliteSink).db.DB().QueryRowContext(ctx,
+		`SELECT probe, status, metrics, executed_at FROM results`).Scan(&probeName, &status, &metrics, &at)
+	if err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	if probeName != "site" || status != "warning" || metrics != `{"latency_ms":900}` || at != executedAt.Format(db.SQLiteTimeFormat) {
+		t.Errorf("unexpected row %s %s %s %s", probeName, status, metrics, at)
+	}
+}
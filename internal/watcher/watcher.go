@@ -2,13 +2,16 @@ package watcher
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
+	"path/filepath"
 	"sync"
 	"time"
 
 	"github.com/jandubois/monitor/internal/config"
+	"github.com/jandubois/monitor/internal/notify"
 )
 
 const Version = "1.0.0"
@@ -16,8 +19,11 @@ const Version = "1.0.0"
 // Watcher schedules and executes probes.
 type Watcher struct {
 	config    *config.WatcherConfig
-	client    *Client
+	client    *Client // Nil in standalone mode
 	discovery *Discovery
+	results   *HTTPResultWriter
+	local     *LocalConfigs
+	sink      ResultSink
 
 	scheduler *Scheduler
 	executor  *Executor
@@ -28,19 +34,53 @@ type Watcher struct {
 
 // New creates a new Watcher instance.
 func New(cfg *config.WatcherConfig) (*Watcher, error) {
-	client := NewClient(cfg.PushURL, cfg.AuthToken)
-	executor := NewExecutor(cfg.MaxConcurrent, cfg.ProbesDir)
-	executor.SetResultWriter(NewHTTPResultWriter(client, cfg.Name))
-	scheduler := NewScheduler(client, executor, cfg.Name)
-	discovery := NewDiscovery(cfg.ProbesDir)
+	if cfg.Standalone && cfg.ConfigPath == "" {
+		return nil, errors.New("standalone mode needs a file of local probe configs")
+	}
 
-	return &Watcher{
+	w := &Watcher{
 		config:    cfg,
-		client:    client,
-		discovery: discovery,
-		scheduler: scheduler,
-		executor:  executor,
-	}, nil
+		discovery: NewDiscovery(cfg.ProbesDir),
+		executor:  NewExecutor(cfg.MaxConcurrent, cfg.ProbesDir),
+	}
+	var writer ResultWriter
+	if !cfg.Standalone {
+		queuePath := cfg.QueuePath
+		if queuePath == "" {
+			configDir, err := getConfigDir()
+			if err != nil {
+				return nil, fmt.Errorf("get config directory: %w", err)
+			}
+			queuePath = filepath.Join(configDir, cfg.Name+".queue")
+		}
+		queue, err := OpenResultQueue(queuePath)
+		if err != nil {
+			return nil, fmt.Errorf("open result queue: %w", err)
+		}
+		w.client = NewClient(cfg.PushURL, cfg.AuthToken)
+		w.results = NewHTTPResultWriter(w.client, cfg.Name, queue)
+		writer = w.results
+	}
+	w.scheduler = NewScheduler(w.client, w.executor, cfg.Name)
+
+	if cfg.ConfigPath != "" {
+		resultsPath := cfg.ResultsPath
+		if resultsPath == "" {
+			resultsPath = "-"
+		}
+		sink, err := OpenResultSink(context.Background(), resultsPath)
+		if err != nil {
+			return nil, fmt.Errorf("open result sink: %w", err)
+		}
+		dispatcher := notify.NewDispatcher(nil)
+		w.sink = sink
+		w.local = NewLocalConfigs(cfg.ConfigPath, cfg.Name, dispatcher)
+		w.scheduler.SetLocalConfigs(w.local)
+		writer = NewLocalResultWriter(sink, dispatcher, cfg.Name, writer)
+	}
+	w.executor.SetResultWriter(writer)
+
+	return w, nil
 }
 
 // Run starts the watcher service.
@@ -53,8 +93,52 @@ func (w *Watcher) Run(ctx context.Context) error {
 		slog.Info("probe discovery complete", "count", len(probeTypes))
 	}
 
-	// Register with web service (retry for up to 2 minutes to allow for
-	// macOS Local Network Privacy prompt)
+	// Errors in the local configs are fatal at startup, and only logged on
+	// later reloads
+	if w.local != nil {
+		defer w.sink.Close()
+		w.local.SetProbeTypes(probeTypes)
+		if _, err := w.local.Load(); err != nil {
+			return err
+		}
+	}
+
+	if !w.config.Standalone {
+		if err := w.register(ctx, probeTypes); err != nil {
+			return err
+		}
+		// Start heartbeat
+		go w.heartbeatLoop(ctx)
+	}
+
+	// Start scheduler
+	go w.scheduler.Run(ctx)
+
+	// Start API server (minimal, for debugging)
+	server := w.createAPIServer()
+	serverErr := make(chan error, 1)
+	go func() {
+		addr := fmt.Sprintf(":%d", w.config.APIPort)
+		slog.Info("watcher API listening", "addr", addr)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			serverErr <- err
+		}
+	}()
+
+	select {
+	case <-ctx.Done():
+		slog.Info("shutting down watcher")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		return server.Shutdown(shutdownCtx)
+	case err := <-serverErr:
+		return err
+	}
+}
+
+// register registers the watcher with the web service, retrying for up to
+// 2 minutes to allow for the macOS Local Network Privacy prompt.
+func (w *Watcher) register(ctx context.Context, probeTypes []RegisterProbeType) error {
 	regReq := &RegisterRequest{
 		Name:        w.config.Name,
 		Version:     Version,
@@ -63,6 +147,7 @@ func (w *Watcher) Run(ctx context.Context) error {
 		ProbeTypes:  probeTypes,
 	}
 	var resp *RegisterResponse
+	var err error
 	for attempt := 1; attempt <= 12; attempt++ {
 		resp, err = w.client.Register(ctx, regReq)
 		if err == nil {
@@ -83,33 +168,7 @@ func (w *Watcher) Run(ctx context.Context) error {
 	} else {
 		slog.Warn("registered with web service (pending approval)", "watcher_id", resp.WatcherID, "probe_types", resp.RegisteredProbes)
 	}
-
-	// Start heartbeat
-	go w.heartbeatLoop(ctx)
-
-	// Start scheduler
-	go w.scheduler.Run(ctx)
-
-	// Start API server (minimal, for debugging)
-	server := w.createAPIServer()
-	serverErr := make(chan error, 1)
-	go func() {
-		addr := fmt.Sprintf(":%d", w.config.APIPort)
-		slog.Info("watcher API listening", "addr", addr)
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			serverErr <- err
-		}
-	}()
-
-	select {
-	case <-ctx.Done():
-		slog.Info("shutting down watcher")
-		shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-		defer cancel()
-		return server.Shutdown(shutdownCtx)
-	case err := <-serverErr:
-		return err
-	}
+	return nil
 }
 
 func (w *Watcher) heartbeatLoop(ctx context.Context) {
@@ -130,12 +189,30 @@ func (w *Watcher) heartbeatLoop(ctx context.Context) {
 }
 
 func (w *Watcher) sendHeartbeat(ctx context.Context) {
+	queueDepth := w.results.QueueDepth()
 	err := w.client.Heartbeat(ctx, &HeartbeatRequest{
-		Name:    w.config.Name,
-		Version: Version,
+		Name:       w.config.Name,
+		Version:    Version,
+		QueueDepth: queueDepth,
 	})
 	if err != nil {
 		slog.Error("failed to send heartbeat", "error", err)
+		return
+	}
+
+	// The web service is reachable again; deliver anything queued meanwhile
+	if queueDepth > 0 {
+		go w.flushResults(ctx)
+	}
+}
+
+func (w *Watcher) flushResults(ctx context.Context) {
+	n, err := w.results.Flush(ctx)
+	if n > 0 {
+		slog.Info("replayed queued results", "count", n, "remaining", w.results.QueueDepth())
+	}
+	if err != nil {
+		slog.Warn("result replay interrupted", "error", err)
 	}
 }
 
@@ -174,6 +251,14 @@ func (w *Watcher) createAPIServer() *http.Server {
 			http.Error(rw, err.Error(), http.StatusInternalServerError)
 			return
 		}
+		if w.local != nil {
+			w.local.SetProbeTypes(probeTypes)
+		}
+		if w.client == nil {
+			rw.WriteHeader(http.StatusOK)
+			fmt.Fprintf(rw, `{"status":"discovered","count":%d}`, len(probeTypes))
+			return
+		}
 
 		// Re-register with web service
 		regReq := &RegisterRequest{
@@ -0,0 +1,176 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jandubois/monitor/internal/db"
+	"github.com/jandubois/monitor/internal/probe"
+)
+
+const (
+	anomalyAlpha        = 0.05 // Weight of each new value in the baseline
+	anomalyWarmup       = 20   // Values a baseline needs before it flags anything
+	anomalyMinDeviation = 0.1  // Smallest deviation flagged, relative to the mean
+	durationMetric      = "duration_ms"
+)
+
+// baseline is the exponentially weighted moving average and variance of
+// one metric of a config.
+type baseline struct {
+	Mean     float64
+	Variance float64
+	Samples  int
+}
+
+// anomaly is a value that deviates from its baseline.
+type anomaly struct {
+	Metric   string  `json:"metric"`
+	Value    float64 `json:"value"`
+	Baseline float64 `json:"baseline"`
+	StdDev   float64 `json:"stddev"`
+	Score    float64 `json:"score"` // Deviation in standard deviations
+}
+
+func (a anomaly) String() string {
+	return fmt.Sprintf("%s %g anomalous (baseline %.4g ± %.2g)", a.Metric, a.Value, a.Baseline, a.StdDev)
+}
+
+// check compares value against the baseline. A deviation must exceed
+// threshold standard deviations and a tenth of the mean, so a metric that
+// barely moves doesn't flag every small change.
+func (b *baseline) check(value, threshold float64) (anomaly, bool) {
+	a := anomaly{Value: value, Baseline: b.Mean, StdDev: math.Sqrt(b.Variance)}
+	if b.Samples < anomalyWarmup {
+		return a, false
+	}
+	deviation := math.Abs(value - b.Mean)
+	a.Score = deviation / max(a.StdDev, 1e-9)
+	return a, a.Score >= threshold && deviation >= anomalyMinDeviation*math.Abs(b.Mean)
+}
+
+// update adds value to the baseline.
+func (b *baseline) update(value float64) {
+	if b.Samples == 0 {
+		b.Mean, b.Variance = value, 0
+	} else {
+		diff := value - b.Mean
+		incr := anomalyAlpha * diff
+		b.Mean += incr
+		b.Variance = (1 - anomalyAlpha) * (b.Variance + diff*incr)
+	}
+	b.Samples++
+}
+
+// anomalyValues returns the numeric metrics of a result plus its duration.
+func anomalyValues(durationMs int, metrics map[string]any) map[string]float64 {
+	values := map[string]float64{durationMetric: float64(durationMs)}
+	for name, v := range metrics {
+		if f, ok := v.(float64); ok {
+			values[name] = f
+		}
+	}
+	return values
+}
+
+// detectAnomalies checks values against their baselines and then adds them
+// to the baselines, creating missing ones. Anomalies are sorted by metric.
+func detectAnomalies(baselines map[string]*baseline, values map[string]float64, threshold float64) []anomaly {
+	var anomalies []anomaly
+	for name, value := range values {
+		b := baselines[name]
+		if b == nil {
+			b = &baseline{}
+			baselines[name] = b
+		}
+		if a, ok := b.check(value, threshold); ok {
+			a.Metric = name
+			anomalies = append(anomalies, a)
+		}
+		b.update(value)
+	}
+	sort.Slice(anomalies, func(i, j int) bool { return anomalies[i].Metric < anomalies[j].Metric })
+	return anomalies
+}
+
+// annotateAnomalies raises an ok result to warning and prepends the
+// anomalies to its message.
+func annotateAnomalies(status probe.Status, message string, anomalies []anomaly) (probe.Status, string) {
+	if len(anomalies) == 0 {
+		return status, message
+	}
+	// An unknown result says nothing about the service's health
+	if status == probe.StatusOK {
+		status = probe.StatusWarning
+	}
+	descriptions := make([]string, 0, len(anomalies)+1)
+	for _, a := range anomalies {
+		descriptions = append(descriptions, a.String())
+	}
+	if message != "" {
+		descriptions = append(descriptions, message)
+	}
+	return status, strings.Join(descriptions, "; ")
+}
+
+// checkAnomalies checks a new result against the baselines of its config,
+// if anomaly detection is enabled, and updates them.
+func (s *Server) checkAnomalies(ctx context.Context, configID int, durationMs int, metrics map[string]any) ([]anomaly, error) {
+	var threshold float64
+	err := s.db.DB().QueryRowContext(ctx, `
+		SELECT anomaly_threshold FROM probe_configs WHERE id = ?
+	`, configID).Scan(&threshold)
+	if err != nil || threshold <= 0 {
+		return nil, err
+	}
+
+	rows, err := s.db.DB().QueryContext(ctx, `
+		SELECT metric, mean, variance, samples FROM metric_baselines WHERE probe_config_id = ?
+	`, configID)
+	if err != nil {
+		return nil, err
+	}
+	baselines := make(map[string]*baseline)
+	for rows.Next() {
+		var name string
+		var b baseline
+		if err := rows.Scan(&name, &b.Mean, &b.Variance, &b.Samples); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		baselines[name] = &b
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	values := anomalyValues(durationMs, metrics)
+	anomalies := detectAnomalies(baselines, values, threshold)
+
+	tx, err := s.db.DB().BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC().Format(db.SQLiteTimeFormat)
+	for name := range values {
+		b := baselines[name]
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO metric_baselines (probe_config_id, metric, mean, variance, samples, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?)
+			ON CONFLICT (probe_config_id, metric) DO UPDATE SET
+				mean = excluded.mean, variance = excluded.variance,
+				samples = excluded.samples, updated_at = excluded.updated_at
+		`, configID, name, b.Mean, b.Variance, b.Samples, now)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return anomalies, tx.Commit()
+}
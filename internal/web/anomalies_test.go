@@ -0,0 +1,72 @@
+package web
+
+import (
+	"math"
+	"testing"
+
+	"github.com/jandubois/monitor/internal/probe"
+)
+
+func TestBaselineUpdate(t *testing.T) {
+	var b baseline
+	for i := 0; i < 200; i++ {
+		// Alternates between 90 and 110
+		b.update(100 + 10*float64(1-2*(i%2)))
+	}
+	if math.Abs(b.Mean-100) > 1 {
+		t.Errorf("expected a mean near 100, got %g", b.Mean)
+	}
+	if stddev := math.Sqrt(b.Variance); math.Abs(stddev-10) > 1 {
+		t.Errorf("expected a standard deviation near 10, got %g", stddev)
+	}
+	if b.Samples != 200 {
+		t.Errorf("expected 200 samples, got %d", b.Samples)
+	}
+}
+
+func TestDetectAnomalies(t *testing.T) {
+	baselines := make(map[string]*baseline)
+	for i := 0; i < anomalyWarmup-1; i++ {
+		detectAnomalies(baselines, map[string]float64{durationMetric: 100 + float64(i%3)}, 3)
+	}
+
+	// Not enough history yet
+	if anomalies := detectAnomalies(baselines, map[string]float64{durationMetric: 1000}, 3); len(anomalies) != 0 {
+		t.Errorf("expected no anomalies during warm-up, got %+v", anomalies)
+	}
+	for i := 0; i < 50; i++ {
+		detectAnomalies(baselines, map[string]float64{durationMetric: 100 + float64(i%3)}, 3)
+	}
+
+	anomalies := detectAnomalies(baselines, map[string]float64{durationMetric: 500, "free_percent": 50}, 3)
+	if len(anomalies) != 1 || anomalies[0].Metric != durationMetric || anomalies[0].Score < 3 {
+		t.Fatalf("expected a duration anomaly, got %+v", anomalies)
+	}
+	if baselines["free_percent"] == nil || baselines["free_percent"].Samples != 1 {
+		t.Errorf("expected a new baseline for free_percent, got %+v", baselines["free_percent"])
+	}
+
+	// Many standard deviations, but less than a tenth of the mean
+	steady := &baseline{Mean: 1000, Variance: 1, Samples: anomalyWarmup}
+	if _, ok := steady.check(1050, 3); ok {
+		t.Error("expected a small relative deviation not to be flagged")
+	}
+	if _, ok := steady.check(1200, 3); !ok {
+		t.Error("expected a large deviation to be flagged")
+	}
+}
+
+func TestAnnotateAnomalies(t *testing.T) {
+	anomalies := []anomaly{{Metric: durationMetric, Value: 850, Baseline: 120, StdDev: 15}}
+
+	status, message := annotateAnomalies(probe.StatusOK, "HTTP 200", anomalies)
+	if status != probe.StatusWarning || message != "duration_ms 850 anomalous (baseline 120 ± 15); HTTP 200" {
+		t.Errorf("unexpected annotation: %q, %q", status, message)
+	}
+	if status, _ := annotateAnomalies(probe.StatusCritical, "", anomalies); status != probe.StatusCritical {
+		t.Errorf("expected critical to be kept, got %q", status)
+	}
+	if status, message := annotateAnomalies(probe.StatusOK, "HTTP 200", nil); status != probe.StatusOK || message != "HTTP 200" {
+		t.Errorf("expected no change without anomalies, got %q, %q", status, message)
+	}
+}
@@ -0,0 +1,316 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"maps"
+	"net/http"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/jandubois/monitor/internal/db"
+	"github.com/jandubois/monitor/internal/notify"
+)
+
+// Target types in the audit log
+const (
+	auditProbeConfig         = "probe_config"
+	auditWatcher             = "watcher"
+	auditNotificationChannel = "notification_channel"
+	auditEscalationPolicy    = "escalation_policy"
+	auditMetricRule          = "metric_rule"
+	auditSilence             = "silence"
+	auditUser                = "user"
+)
+
+// auditQueries select the recorded fields of each target type. Secrets
+// such as watcher tokens and password hashes are left out, and audit
+// redacts the credentials in notification channel configs. The name column
+// labels entries.
+var auditQueries = map[string]string{
+	auditProbeConfig: `
+		SELECT name, probe_type_id, watcher_id, enabled, arguments, interval, timezone, active_windows,
+		       timeout_seconds, notification_channels, escalation_policy_id,
+		       consecutive_results, flap_window, flap_threshold, anomaly_threshold, group_path, keywords,
+		       composite_mode, composite_quorum,
+		       (SELECT json_group_array(depends_on_id) FROM (
+		            SELECT depends_on_id FROM probe_dependencies WHERE probe_config_id = probe_configs.id ORDER BY depends_on_id
+		       )) AS depends_on,
+		       (SELECT json_group_array(member_id) FROM (
+		            SELECT member_id FROM composite_members WHERE composite_id = probe_configs.id ORDER BY member_id
+		       )) AS members
+		FROM probe_configs WHERE id = ?`,
+	auditWatcher: `
+		SELECT name, paused, approved, notification_channels FROM watchers WHERE id = ?`,
+	auditNotificationChannel: `
+		SELECT name, type, config, enabled FROM notification_channels WHERE id = ?`,
+	auditEscalationPolicy: `
+		SELECT name, steps, repeat_minutes FROM escalation_policies WHERE id = ?`,
+	auditMetricRule: `
+		SELECT metric AS name, probe_config_id, probe_type, operator, threshold, for_seconds, status, mode
+		FROM metric_rules WHERE id = ?`,
+	auditSilence: `
+		SELECT comment AS name, starts_at, ends_at, probe_config_id, group_path, keyword, watcher_id, probe_type
+		FROM silences WHERE id = ?`,
+	auditUser: `
+		SELECT username AS name, role FROM users WHERE id = ?`,
+}
+
+// auditJSONColumns hold JSON and are recorded decoded.
+var auditJSONColumns = map[string]bool{
+	"arguments": true, "active_windows": true, "notification_channels": true, "keywords": true,
+	"depends_on": true, "members": true, "config": true, "steps": true,
+}
+
+// auditBoolColumns are stored as 0 or 1 and recorded as booleans.
+var auditBoolColumns = map[string]bool{"enabled": true, "paused": true, "approved": true}
+
+// auditState returns the recorded fields of a target, or nil if it doesn't
+// exist.
+func (s *Server) auditState(ctx context.Context, targetType string, id int) map[string]any {
+	rows, err := s.db.DB().QueryContext(ctx, auditQueries[targetType], id)
+	if err != nil {
+		slog.Error("failed to read audit state", "target_type", targetType, "id", id, "error", err)
+		return nil
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return nil
+	}
+
+	columns, _ := rows.Columns()
+	values := make([]any, len(columns))
+	ptrs := make([]any, len(columns))
+	for i := range values {
+		ptrs[i] = &values[i]
+	}
+	if err := rows.Scan(ptrs...); err != nil {
+		slog.Error("failed to read audit state", "target_type", targetType, "id", id, "error", err)
+		return nil
+	}
+
+	state := make(map[string]any, len(columns))
+	for i, column := range columns {
+		value := values[i]
+		if b, ok := value.([]byte); ok {
+			value = string(b)
+		}
+		switch {
+		case auditJSONColumns[column]:
+			if str, ok := value.(string); ok && json.Valid([]byte(str)) {
+				value = json.RawMessage(str)
+			}
+		case auditBoolColumns[column]:
+			if n, ok := value.(int64); ok {
+				value = n != 0
+			}
+		}
+		state[column] = value
+	}
+	return state
+}
+
+// auditDiff returns the fields that differ between two states. A missing
+// state contributes all fields of the other one.
+func auditDiff(before, after map[string]any) (map[string]any, map[string]any) {
+	if before == nil || after == nil {
+		return before, after
+	}
+	changedBefore := map[string]any{}
+	changedAfter := map[string]any{}
+	for key, value := range after {
+		if !auditEqual(before[key], value) {
+			changedBefore[key] = before[key]
+			changedAfter[key] = value
+		}
+	}
+	return changedBefore, changedAfter
+}
+
+// auditRedact returns a copy of a notification channel state with the
+// credentials in its config redacted.
+func auditRedact(state map[string]any) map[string]any {
+	raw, ok := state["config"].(json.RawMessage)
+	if !ok {
+		return state
+	}
+	var config map[string]any
+	if err := json.Unmarshal(raw, &config); err != nil {
+		return state
+	}
+	redacted := maps.Clone(state)
+	redacted["config"] = notify.RedactConfig(config)
+	return redacted
+}
+
+// auditEqual compares state values, including JSON that is formatted
+// differently.
+func auditEqual(a, b any) bool {
+	ja, errA := json.Marshal(a)
+	jb, errB := json.Marshal(b)
+	if errA != nil || errB != nil {
+		return reflect.DeepEqual(a, b)
+	}
+	var va, vb any
+	json.Unmarshal(ja, &va)
+	json.Unmarshal(jb, &vb)
+	return reflect.DeepEqual(va, vb)
+}
+
+// audit records a change by the caller. before and after come from
+// auditState; nil means the target didn't or doesn't exist, and both are
+// nil for actions that change no fields, such as running a probe. Updates
+// that change nothing are not recorded.
+func (s *Server) audit(ctx context.Context, action, targetType string, targetID int, before, after map[string]any) {
+	changedBefore, changedAfter := auditDiff(before, after)
+	if before != nil && after != nil && len(changedAfter) == 0 {
+		return
+	}
+	if targetType == auditNotificationChannel {
+		// Compared in full, so a changed credential still shows as a changed config
+		changedBefore, changedAfter = auditRedact(changedBefore), auditRedact(changedAfter)
+	}
+
+	actor, role := "unknown", ""
+	if id := identityFromContext(ctx); id != nil {
+		actor, role = id.username, id.role
+	}
+	var name any
+	states := []map[string]any{after, before}
+	if before == nil && after == nil && targetID != 0 {
+		// Actions without a diff still label the entry with the target's name
+		states = []map[string]any{s.auditState(ctx, targetType, targetID)}
+	}
+	for _, state := range states {
+		if n, ok := state["name"]; ok && n != nil {
+			name = n
+			break
+		}
+	}
+	var id any
+	if targetID != 0 {
+		id = targetID
+	}
+	var beforeJSON, afterJSON any
+	if changedBefore != nil {
+		b, _ := json.Marshal(changedBefore)
+		beforeJSON = string(b)
+	}
+	if changedAfter != nil {
+		b, _ := json.Marshal(changedAfter)
+		afterJSON = string(b)
+	}
+
+	// The request may already be finished, but the entry must still be written
+	if _, err := s.db.DB().ExecContext(context.WithoutCancel(ctx), `
+		INSERT INTO audit_log (actor, actor_role, action, target_type, target_id, target_name, before, after)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, actor, role, action, targetType, id, name, beforeJSON, afterJSON); err != nil {
+		slog.Error("failed to write audit log", "action", action, "target_type", targetType, "target_id", targetID, "error", err)
+	}
+}
+
+func (s *Server) handleListAudit(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	query := r.URL.Query()
+
+	sqlQuery := `
+		SELECT id, created_at, actor, actor_role, action, target_type, target_id, target_name, before, after
+		FROM audit_log
+		WHERE 1=1
+	`
+	var args []any
+	for _, param := range []string{"actor", "action", "target_type"} {
+		if v := query.Get(param); v != "" {
+			sqlQuery += " AND " + param + " = ?"
+			args = append(args, v)
+		}
+	}
+	if v := query.Get("target_id"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			http.Error(w, "invalid target_id", http.StatusBadRequest)
+			return
+		}
+		sqlQuery += " AND target_id = ?"
+		args = append(args, id)
+	}
+	for param, op := range map[string]string{"since": ">=", "until": "<"} {
+		if v := query.Get(param); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				http.Error(w, "invalid "+param+": "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			sqlQuery += " AND created_at " + op + " ?"
+			args = append(args, t.UTC().Format(db.SQLiteTimeFormat))
+		}
+	}
+
+	limit := 100
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > 1000 {
+			http.Error(w, "limit must be between 1 and 1000", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	offset, _ := strconv.Atoi(query.Get("offset"))
+	sqlQuery += " ORDER BY id DESC LIMIT ? OFFSET ?"
+	args = append(args, limit, offset)
+
+	rows, err := s.db.DB().QueryContext(ctx, sqlQuery, args...)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	entries := []map[string]any{}
+	for rows.Next() {
+		var id int
+		var createdAt db.NullTime
+		var actor, action, targetType string
+		var actorRole, targetName, before, after *string
+		var targetID *int
+		if err := rows.Scan(&id, &createdAt, &actor, &actorRole, &action, &targetType, &targetID, &targetName, &before, &after); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		entry := map[string]any{
+			"id":          id,
+			"actor":       actor,
+			"action":      action,
+			"target_type": targetType,
+		}
+		if createdAt.Valid {
+			entry["created_at"] = createdAt.Time
+		}
+		if actorRole != nil && *actorRole != "" {
+			entry["actor_role"] = *actorRole
+		}
+		if targetID != nil {
+			entry["target_id"] = *targetID
+		}
+		if targetName != nil {
+			entry["target_name"] = *targetName
+		}
+		if before != nil {
+			entry["before"] = json.RawMessage(*before)
+		}
+		if after != nil {
+			entry["after"] = json.RawMessage(*after)
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
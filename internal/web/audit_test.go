@@ -0,0 +1,133 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestAuditDiff(t *testing.T) {
+	before := map[string]any{
+		"name":      "disk",
+		"interval":  "5m",
+		"enabled":   true,
+		"arguments": json.RawMessage(`{"path": "/", "min_free": 10}`),
+	}
+	after := map[string]any{
+		"name":      "disk",
+		"interval":  "1m",
+		"enabled":   true,
+		"arguments": json.RawMessage(`{"min_free":10,"path":"/"}`),
+	}
+
+	changedBefore, changedAfter := auditDiff(before, after)
+	if len(changedAfter) != 1 || changedBefore["interval"] != "5m" || changedAfter["interval"] != "1m" {
+		t.Errorf("expected only the interval to change, got %v -> %v", changedBefore, changedAfter)
+	}
+
+	// Creating and deleting record the whole state
+	if _, changedAfter := auditDiff(nil, after); len(changedAfter) != len(after) {
+		t.Errorf("expected the full state for a create, got %v", changedAfter)
+	}
+	if changedBefore, changedAfter := auditDiff(before, nil); len(changedBefore) != len(before) || changedAfter != nil {
+		t.Errorf("expected the full state for a delete, got %v -> %v", changedBefore, changedAfter)
+	}
+}
+
+func TestAuditLog(t *testing.T) {
+	server, cleanup := testServer(t)
+	if server == nil {
+		return
+	}
+	defer cleanup()
+
+	ctx := context.Background()
+	// The audit log survives the cleanup of other tests
+	var baseline int
+	server.db.DB().QueryRowContext(ctx, `SELECT COALESCE(MAX(id), 0) FROM audit_log`).Scan(&baseline)
+
+	handler := server.routes()
+	do := func(method, path string, body any) *httptest.ResponseRecorder {
+		t.Helper()
+		b, _ := json.Marshal(body)
+		req := httptest.NewRequest(method, path, bytes.NewReader(b))
+		req.Header.Set("Authorization", "Bearer test-token")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	channel := map[string]any{"name": "ops", "type": "ntfy", "config": map[string]any{"topic": "ops", "token": "tk_first"}, "enabled": true}
+	w := do("POST", "/api/notification-channels", channel)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create failed: %d %s", w.Code, w.Body.String())
+	}
+	var created struct {
+		ID int `json:"id"`
+	}
+	json.NewDecoder(w.Body).Decode(&created)
+	path := "/api/notification-channels/" + strconv.Itoa(created.ID)
+
+	// An update that changes nothing is not recorded
+	do("PUT", path, channel)
+	channel["enabled"] = false
+	do("PUT", path, channel)
+	// Changing only a credential is recorded without it
+	channel["config"] = map[string]any{"topic": "ops", "token": "tk_second"}
+	do("PUT", path, channel)
+	do("DELETE", path, nil)
+
+	w = do("GET", "/api/audit?target_type=notification_channel&target_id="+strconv.Itoa(created.ID), nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("list failed: %d %s", w.Code, w.Body.String())
+	}
+	var entries []struct {
+		ID         int                        `json:"id"`
+		Actor      string                     `json:"actor"`
+		Action     string                     `json:"action"`
+		TargetName string                     `json:"target_name"`
+		Before     map[string]json.RawMessage `json:"before"`
+		After      map[string]json.RawMessage `json:"after"`
+	}
+	if body := w.Body.String(); strings.Contains(body, "tk_first") || strings.Contains(body, "tk_second") {
+		t.Errorf("expected no credentials in the audit log, got %s", body)
+	}
+	json.NewDecoder(w.Body).Decode(&entries)
+
+	var actions []string
+	for _, entry := range entries {
+		if entry.ID <= baseline {
+			continue
+		}
+		actions = append(actions, entry.Action)
+		if entry.Actor != "auth-token" || entry.TargetName != "ops" {
+			t.Errorf("unexpected actor %q or name %q", entry.Actor, entry.TargetName)
+		}
+	}
+	if len(actions) != 4 || actions[0] != "delete" || actions[1] != "update" || actions[2] != "update" || actions[3] != "create" {
+		t.Fatalf("expected delete, two updates and create, newest first, got %v", actions)
+	}
+	if update := entries[2]; len(update.After) != 1 || string(update.Before["enabled"]) != "true" || string(update.After["enabled"]) != "false" {
+		t.Errorf("expected only enabled in the diff, got %v -> %v", update.Before, update.After)
+	}
+	if update := entries[1]; len(update.After) != 1 || string(update.After["config"]) != `{"token":"REDACTED","topic":"ops"}` {
+		t.Errorf("expected only the redacted config in the diff, got %v -> %v", update.Before, update.After)
+	}
+
+	if w := do("GET", "/api/audit?since=yesterday", nil); w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for a bad since, got %d", w.Code)
+	}
+
+	// Entries can't be changed or removed
+	if _, err := server.db.DB().ExecContext(ctx, `UPDATE audit_log SET actor = 'someone' WHERE id > ?`, baseline); err == nil {
+		t.Error("expected updating the audit log to fail")
+	}
+	if _, err := server.db.DB().ExecContext(ctx, `DELETE FROM audit_log WHERE id > ?`, baseline); err == nil {
+		t.Error("expected deleting from the audit log to fail")
+	}
+}
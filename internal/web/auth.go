@@ -0,0 +1,294 @@
+package web
+
+import (
+	"context"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jandubois/monitor/internal/db"
+)
+
+// Roles, from least to most privileged. Viewers can read everything,
+// operators can also change configs, silences, rules and incidents, and
+// admins can also manage watchers, notification channels, escalation
+// policies and users.
+const (
+	roleViewer   = "viewer"
+	roleOperator = "operator"
+	roleAdmin    = "admin"
+)
+
+var roleRank = map[string]int{roleViewer: 0, roleOperator: 1, roleAdmin: 2}
+
+func validRole(role string) bool {
+	_, ok := roleRank[role]
+	return ok
+}
+
+// roleAtLeast reports whether role grants everything min does.
+func roleAtLeast(role, min string) bool {
+	rank, ok := roleRank[role]
+	return ok && rank >= roleRank[min]
+}
+
+const (
+	// passwordIterations is the PBKDF2-SHA256 work factor recommended by
+	// OWASP.
+	passwordIterations = 600_000
+	passwordSaltSize   = 16
+	passwordKeySize    = 32
+	minPasswordLength  = 8
+
+	// apiTokenPrefix marks personal API tokens, so other bearer tokens are
+	// rejected without a database lookup.
+	apiTokenPrefix = "mon_"
+
+	sessionCookie   = "monitor_session"
+	sessionDuration = 30 * 24 * time.Hour
+)
+
+var errShortPassword = fmt.Errorf("password must be at least %d characters", minPasswordLength)
+
+// hashPassword returns a PBKDF2 hash of password in the form
+// pbkdf2-sha256$<iterations>$<salt>$<key>.
+func hashPassword(password string) (string, error) {
+	salt := make([]byte, passwordSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key, err := pbkdf2.Key(sha256.New, password, salt, passwordIterations, passwordKeySize)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("pbkdf2-sha256$%d$%s$%s", passwordIterations,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// checkPassword reports whether password matches a hash from hashPassword.
+func checkPassword(hash, password string) bool {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 4 || parts[0] != "pbkdf2-sha256" {
+		return false
+	}
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations <= 0 {
+		return false
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+	want, err := base64.RawStdEncoding.DecodeString(parts[3])
+	if err != nil {
+		return false
+	}
+	key, err := pbkdf2.Key(sha256.New, password, salt, iterations, len(want))
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare(key, want) == 1
+}
+
+// dummyPasswordHash is checked against when a username doesn't exist, so a
+// failed login takes as long either way.
+var dummyPasswordHash = sync.OnceValue(func() string {
+	hash, _ := hashPassword("not a password")
+	return hash
+})
+
+// newSecret returns a random URL-safe string for session cookies and API
+// tokens.
+func newSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashSecret returns the form in which sessions and API tokens are stored.
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateUser adds a user account with a hashed password.
+func CreateUser(ctx context.Context, database *db.DB, username, password, role string) (int, error) {
+	if err := validateUser(username, role); err != nil {
+		return 0, err
+	}
+	if len(password) < minPasswordLength {
+		return 0, errShortPassword
+	}
+	hash, err := hashPassword(password)
+	if err != nil {
+		return 0, err
+	}
+	result, err := database.DB().ExecContext(ctx, `
+		INSERT INTO users (username, password_hash, role) VALUES (?, ?, ?)
+	`, username, hash, role)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE") {
+			return 0, fmt.Errorf("user %q already exists", username)
+		}
+		return 0, err
+	}
+	id, _ := result.LastInsertId()
+	return int(id), nil
+}
+
+// SetPassword replaces a user's password and ends their sessions.
+func SetPassword(ctx context.Context, database *db.DB, username, password string) error {
+	if len(password) < minPasswordLength {
+		return errShortPassword
+	}
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+	var id int
+	err = database.DB().QueryRowContext(ctx, `SELECT id FROM users WHERE username = ?`, username).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("user %q not found", username)
+	}
+	if err != nil {
+		return err
+	}
+	return setPassword(ctx, database.DB(), id, hash, "")
+}
+
+// setPassword stores a password hash and ends the user's sessions, except
+// the one with keepSession as its hash.
+func setPassword(ctx context.Context, sqlDB *sql.DB, userID int, hash, keepSession string) error {
+	tx, err := sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := setPasswordTx(ctx, tx, userID, hash, keepSession); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// setPasswordTx is setPassword within a transaction.
+func setPasswordTx(ctx context.Context, tx *sql.Tx, userID int, hash, keepSession string) error {
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE users SET password_hash = ?, updated_at = datetime('now') WHERE id = ?
+	`, hash, userID); err != nil {
+		return err
+	}
+	_, err := tx.ExecContext(ctx, `
+		DELETE FROM sessions WHERE user_id = ? AND token_hash <> ?
+	`, userID, keepSession)
+	return err
+}
+
+func validateUser(username, role string) error {
+	if username == "" || strings.TrimSpace(username) != username {
+		return errors.New("username must not be empty or start or end with spaces")
+	}
+	if !validRole(role) {
+		return fmt.Errorf("role must be %s, %s or %s", roleViewer, roleOperator, roleAdmin)
+	}
+	return nil
+}
+
+// identity is the authenticated caller of the user API.
+type identity struct {
+	userID   int // 0 for the shared token
+	username string
+	role     string
+	method   string // session, token or shared-token
+
+	sessionHash string // Set for session logins
+	tokenID     int    // Set for API tokens
+}
+
+// authenticate returns the caller of a request with a bearer token or a
+// session cookie, or nil.
+func (s *Server) authenticate(ctx context.Context, authHeader string, cookie string) (*identity, error) {
+	now := time.Now().UTC().Format(db.SQLiteTimeFormat)
+
+	if authHeader != "" {
+		token := strings.TrimPrefix(authHeader, "Bearer ")
+		// The shared token from the command line has full access
+		if s.config.AuthToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(s.config.AuthToken)) == 1 {
+			return &identity{username: "auth-token", role: roleAdmin, method: "shared-token"}, nil
+		}
+		if !strings.HasPrefix(token, apiTokenPrefix) {
+			return nil, nil
+		}
+
+		id := identity{method: "token"}
+		var tokenRole *string
+		err := s.db.DB().QueryRowContext(ctx, `
+			SELECT t.id, t.role, u.id, u.username, u.role
+			FROM api_tokens t
+			JOIN users u ON u.id = t.user_id
+			WHERE t.token_hash = ? AND (t.expires_at IS NULL OR t.expires_at > ?)
+		`, hashSecret(token), now).Scan(&id.tokenID, &tokenRole, &id.userID, &id.username, &id.role)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		// A token never has more rights than its user
+		if tokenRole != nil && !roleAtLeast(*tokenRole, id.role) {
+			id.role = *tokenRole
+		}
+		// Recording every request would be a write per API call
+		s.db.DB().ExecContext(ctx, `
+			UPDATE api_tokens SET last_used_at = ?
+			WHERE id = ? AND (last_used_at IS NULL OR last_used_at < datetime(?, '-1 minute'))
+		`, now, id.tokenID, now)
+		return &id, nil
+	}
+
+	if cookie == "" {
+		return nil, nil
+	}
+	id := identity{method: "session", sessionHash: hashSecret(cookie)}
+	err := s.db.DB().QueryRowContext(ctx, `
+		SELECT u.id, u.username, u.role
+		FROM sessions s
+		JOIN users u ON u.id = s.user_id
+		WHERE s.token_hash = ? AND s.expires_at > ?
+	`, id.sessionHash, now).Scan(&id.userID, &id.username, &id.role)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &id, nil
+}
+
+// identityFromContext returns the caller set by requireAuth.
+func identityFromContext(ctx context.Context) *identity {
+	id, _ := ctx.Value(identityKey).(*identity)
+	return id
+}
+
+// callerName returns the username of the caller for recording who did
+// something, or "" for the shared token.
+func callerName(ctx context.Context) string {
+	id := identityFromContext(ctx)
+	if id == nil || id.userID == 0 {
+		return ""
+	}
+	return id.username
+}
@@ -0,0 +1,182 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/jandubois/monitor/internal/config"
+)
+
+func TestPasswordHash(t *testing.T) {
+	hash, err := hashPassword("correct horse")
+	if err != nil {
+		t.Fatalf("hashPassword failed: %v", err)
+	}
+	if !checkPassword(hash, "correct horse") {
+		t.Error("expected the password to match")
+	}
+	if checkPassword(hash, "wrong horse") {
+		t.Error("expected a wrong password not to match")
+	}
+	if checkPassword("plain text", "plain text") {
+		t.Error("expected a malformed hash not to match")
+	}
+
+	other, _ := hashPassword("correct horse")
+	if other == hash {
+		t.Error("expected different salts")
+	}
+}
+
+func TestRoleAtLeast(t *testing.T) {
+	tests := []struct {
+		role, min string
+		expected  bool
+	}{
+		{roleAdmin, roleOperator, true},
+		{roleOperator, roleOperator, true},
+		{roleViewer, roleOperator, false},
+		{"root", roleViewer, false},
+	}
+	for _, tt := range tests {
+		if got := roleAtLeast(tt.role, tt.min); got != tt.expected {
+			t.Errorf("roleAtLeast(%q, %q): expected %v, got %v", tt.role, tt.min, tt.expected, got)
+		}
+	}
+}
+
+func TestRequireRoleSharedToken(t *testing.T) {
+	s := &Server{config: &config.WebConfig{AuthToken: "secret-token"}}
+	handler := s.requireRole(roleAdmin, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if callerName(r.Context()) != "" {
+			t.Error("expected no caller name for the shared token")
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest("DELETE", "/test", nil)
+	req.Header.Set("Authorization", "Bearer secret-token")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("expected the shared token to have admin access, got %d", w.Code)
+	}
+
+	// Without a shared token, an empty bearer token must not match it
+	s.config.AuthToken = ""
+	req = httptest.NewRequest("DELETE", "/test", nil)
+	req.Header.Set("Authorization", "Bearer ")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected status 401, got %d", w.Code)
+	}
+}
+
+func TestUserAuth(t *testing.T) {
+	server, cleanup := testServer(t)
+	if server == nil {
+		return
+	}
+	defer cleanup()
+
+	ctx := context.Background()
+	if _, err := CreateUser(ctx, server.db, "alice", "short", roleViewer); err == nil {
+		t.Error("expected a short password to be rejected")
+	}
+	aliceID, err := CreateUser(ctx, server.db, "alice", "alice-password", roleOperator)
+	if err != nil {
+		t.Fatalf("CreateUser failed: %v", err)
+	}
+
+	handler := server.routes()
+	do := func(method, path string, body any, setup func(*http.Request)) *httptest.ResponseRecorder {
+		t.Helper()
+		b, _ := json.Marshal(body)
+		req := httptest.NewRequest(method, path, bytes.NewReader(b))
+		if setup != nil {
+			setup(req)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := do("POST", "/api/login", map[string]string{"username": "alice", "password": "wrong"}, nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected status 401 for a wrong password, got %d", w.Code)
+	}
+	w := do("POST", "/api/login", map[string]string{"username": "alice", "password": "alice-password"}, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("login failed: %d %s", w.Code, w.Body.String())
+	}
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != sessionCookie || !cookies[0].HttpOnly {
+		t.Fatalf("expected an HttpOnly session cookie, got %v", cookies)
+	}
+	withSession := func(req *http.Request) { req.AddCookie(cookies[0]) }
+
+	if w := do("GET", "/api/me", nil, withSession); w.Code != http.StatusOK {
+		t.Errorf("expected the session to work, got %d", w.Code)
+	}
+	// Operators can't manage users
+	if w := do("GET", "/api/users", nil, withSession); w.Code != http.StatusForbidden {
+		t.Errorf("expected status 403, got %d", w.Code)
+	}
+
+	// A viewer token of an operator can only read
+	w = do("POST", "/api/tokens", map[string]string{"name": "grafana", "role": roleViewer}, withSession)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("token creation failed: %d %s", w.Code, w.Body.String())
+	}
+	var created struct {
+		ID    int    `json:"id"`
+		Token string `json:"token"`
+	}
+	json.NewDecoder(w.Body).Decode(&created)
+	withToken := func(req *http.Request) { req.Header.Set("Authorization", "Bearer "+created.Token) }
+
+	if w := do("GET", "/api/probe-configs", nil, withToken); w.Code != http.StatusOK {
+		t.Errorf("expected the token to read, got %d", w.Code)
+	}
+	if w := do("POST", "/api/silences", map[string]string{"keyword": "x", "duration": "1h"}, withToken); w.Code != http.StatusForbidden {
+		t.Errorf("expected status 403 for a viewer token, got %d", w.Code)
+	}
+
+	if w := do("DELETE", "/api/tokens/"+strconv.Itoa(created.ID), nil, withSession); w.Code != http.StatusNoContent {
+		t.Errorf("token revocation failed: %d", w.Code)
+	}
+	if w := do("GET", "/api/probe-configs", nil, withToken); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected a revoked token to fail, got %d", w.Code)
+	}
+
+	if w := do("POST", "/api/logout", nil, withSession); w.Code != http.StatusNoContent {
+		t.Errorf("logout failed: %d", w.Code)
+	}
+	if w := do("GET", "/api/me", nil, withSession); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected the session to end, got %d", w.Code)
+	}
+
+	// An invalid password leaves the role alone too
+	withAdmin := func(req *http.Request) { req.Header.Set("Authorization", "Bearer test-token") }
+	path := "/api/users/" + strconv.Itoa(aliceID)
+	if w := do("PUT", path, map[string]string{"role": roleAdmin, "password": "short"}, withAdmin); w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for a short password, got %d", w.Code)
+	}
+	var role string
+	server.db.DB().QueryRowContext(ctx, `SELECT role FROM users WHERE id = ?`, aliceID).Scan(&role)
+	if role != roleOperator {
+		t.Errorf("expected role %s after the failed update, got %s", roleOperator, role)
+	}
+	if w := do("PUT", path, map[string]string{"role": roleAdmin, "password": "new-alice-password"}, withAdmin); w.Code != http.StatusNoContent {
+		t.Errorf("update failed: %d %s", w.Code, w.Body.String())
+	}
+	server.db.DB().QueryRowContext(ctx, `SELECT role FROM users WHERE id = ?`, aliceID).Scan(&role)
+	if role != roleAdmin {
+		t.Errorf("expected role %s, got %s", roleAdmin, role)
+	}
+}
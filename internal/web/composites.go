@@ -0,0 +1,422 @@
+package web
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jandubois/monitor/internal/db"
+	"github.com/jandubois/monitor/internal/probe"
+)
+
+const (
+	compositeAll    = "all"    // ok only if all members are ok
+	compositeAny    = "any"    // ok if any member is ok
+	compositeQuorum = "quorum" // ok if at least quorum members are ok
+
+	// maxCompositeDepth bounds how far a result propagates through nested
+	// composites. Cycles are rejected, so it only guards against mistakes.
+	maxCompositeDepth = 16
+)
+
+// compositeRequest creates or updates a composite config.
+type compositeRequest struct {
+	Name                 string   `json:"name"`
+	Enabled              bool     `json:"enabled"`
+	Mode                 string   `json:"mode"`
+	Quorum               int      `json:"quorum"` // Members that must be ok in quorum mode
+	Members              []int    `json:"members"`
+	NotificationChannels []int    `json:"notification_channels"`
+	EscalationPolicyID   *int     `json:"escalation_policy_id"`
+	ConsecutiveResults   int      `json:"consecutive_results"` // Defaults to 1
+	GroupPath            *string  `json:"group_path"`
+	Keywords             []string `json:"keywords"`
+}
+
+func (c *compositeRequest) validate() error {
+	if c.Name == "" {
+		return errors.New("name is required")
+	}
+	if len(c.Members) == 0 {
+		return errors.New("members are required")
+	}
+	switch c.Mode {
+	case compositeAll, compositeAny:
+		c.Quorum = 0
+	case compositeQuorum:
+		if c.Quorum < 1 || c.Quorum > len(c.Members) {
+			return fmt.Errorf("quorum must be between 1 and %d", len(c.Members))
+		}
+	default:
+		return fmt.Errorf("mode must be %s, %s or %s", compositeAll, compositeAny, compositeQuorum)
+	}
+	if c.ConsecutiveResults == 0 {
+		c.ConsecutiveResults = 1
+	}
+	filter := statusFilter{consecutive: c.ConsecutiveResults}
+	return filter.validate()
+}
+
+// memberStatus is the latest status of a member of a composite; empty if
+// it has no results yet.
+type memberStatus struct {
+	name   string
+	status probe.Status
+}
+
+// compositeStatus computes the status of a composite from its members:
+// with members sorted from best to worst status, it is the status of the
+// last one that must be ok, so "all" reports the worst member and "any"
+// the best.
+func compositeStatus(mode string, quorum int, members []memberStatus) (probe.Status, string, map[string]any) {
+	if len(members) == 0 {
+		return probe.StatusUnknown, "no enabled members", nil
+	}
+
+	var ok int
+	var failing []string
+	statuses := make([]probe.Status, len(members))
+	for i, m := range members {
+		status := m.status
+		if status == "" {
+			status = probe.StatusUnknown
+		}
+		statuses[i] = status
+		if status == probe.StatusOK {
+			ok++
+		} else {
+			failing = append(failing, m.name+" "+string(status))
+		}
+	}
+	sort.SliceStable(statuses, func(i, j int) bool {
+		return statusRank[string(statuses[i])] < statusRank[string(statuses[j])]
+	})
+
+	required := len(members)
+	switch mode {
+	case compositeAny:
+		required = 1
+	case compositeQuorum:
+		required = min(quorum, len(members))
+	}
+
+	message := fmt.Sprintf("%d of %d members ok", ok, len(members))
+	if mode == compositeQuorum {
+		message += fmt.Sprintf(" (quorum %d)", quorum)
+	}
+	if len(failing) > 0 {
+		message += "; " + strings.Join(failing, ", ")
+	}
+	metrics := map[string]any{"members": len(members), "members_ok": ok}
+	return statuses[required-1], message, metrics
+}
+
+// evaluateComposites updates the composites that configID is a member of
+// after it recorded a result.
+func (s *Server) evaluateComposites(ctx context.Context, configID int, depth int) {
+	if depth >= maxCompositeDepth {
+		slog.Error("composite configs nested too deeply", "config_id", configID)
+		return
+	}
+	rows, err := s.db.DB().QueryContext(ctx, `
+		SELECT composite_id FROM composite_members WHERE member_id = ?
+	`, configID)
+	if err != nil {
+		slog.Error("failed to find composite configs", "config_id", configID, "error", err)
+		return
+	}
+	var composites []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err == nil {
+			composites = append(composites, id)
+		}
+	}
+	rows.Close()
+
+	for _, id := range composites {
+		if err := s.evaluateComposite(ctx, id, depth); err != nil {
+			slog.Error("failed to evaluate composite config", "config_id", id, "error", err)
+		}
+	}
+}
+
+// evaluateComposite computes the status of an enabled composite config and
+// records it as a result if it changed since the last one.
+func (s *Server) evaluateComposite(ctx context.Context, id int, depth int) error {
+	var mode string
+	var quorum int
+	err := s.db.DB().QueryRowContext(ctx, `
+		SELECT composite_mode, composite_quorum FROM probe_configs
+		WHERE id = ? AND enabled = 1 AND composite_mode IS NOT NULL
+	`, id).Scan(&mode, &quorum)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	rows, err := s.db.DB().QueryContext(ctx, `
+		SELECT pc.name,
+		       COALESCE((SELECT status FROM probe_results WHERE probe_config_id = pc.id ORDER BY executed_at DESC LIMIT 1), '')
+		FROM composite_members m
+		JOIN probe_configs pc ON pc.id = m.member_id
+		WHERE m.composite_id = ? AND pc.enabled = 1
+		ORDER BY pc.name
+	`, id)
+	if err != nil {
+		return err
+	}
+	var members []memberStatus
+	for rows.Next() {
+		var m memberStatus
+		if err := rows.Scan(&m.name, &m.status); err != nil {
+			rows.Close()
+			return err
+		}
+		members = append(members, m)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	status, message, metrics := compositeStatus(mode, quorum, members)
+
+	// Only changes are recorded, or every member result would add one
+	var lastStatus, lastMessage string
+	err = s.db.DB().QueryRowContext(ctx, `
+		SELECT status, COALESCE(message, '') FROM probe_results WHERE probe_config_id = ? ORDER BY executed_at DESC LIMIT 1
+	`, id).Scan(&lastStatus, &lastMessage)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	if err == nil && lastStatus == string(status) && lastMessage == message {
+		return nil
+	}
+
+	var suppressedBy *int
+	if status != probe.StatusOK {
+		if suppressedBy, err = s.failingDependency(ctx, id); err != nil {
+			return err
+		}
+	}
+
+	executedAt := time.Now()
+	now := executedAt.UTC().Format(db.SQLiteTimeFormat)
+	metricsJSON, _ := json.Marshal(metrics)
+	result, err := s.db.DB().ExecContext(ctx, `
+		INSERT INTO probe_results (probe_config_id, status, message, metrics, duration_ms, suppressed_by, scheduled_at, executed_at)
+		VALUES (?, ?, ?, ?, 0, ?, ?, ?)
+	`, id, status, message, string(metricsJSON), suppressedBy, now, now)
+	if err != nil {
+		return err
+	}
+	resultID, _ := result.LastInsertId()
+	s.publishResult(ctx, resultID, id, string(status), message, executedAt)
+
+	s.checkStatusChangeAndNotify(ctx, id, status, message)
+	s.evaluateComposites(ctx, id, depth+1)
+	return nil
+}
+
+// compositeProbeTypeID returns the ID of the built-in probe type of
+// composite configs, creating it if needed.
+func (s *Server) compositeProbeTypeID(ctx context.Context) (int, error) {
+	var id int
+	err := s.db.DB().QueryRowContext(ctx, `
+		SELECT id FROM probe_types WHERE name = ? AND version = ?
+	`, "composite", "1.0.0").Scan(&id)
+	if err == nil || !errors.Is(err, sql.ErrNoRows) {
+		return id, err
+	}
+	result, err := s.db.DB().ExecContext(ctx, `
+		INSERT INTO probe_types (name, version, description, arguments, registered_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, "composite", "1.0.0", "Status computed from other probe configs", "{}", time.Now().UTC().Format(db.SQLiteTimeFormat))
+	if err != nil {
+		return 0, err
+	}
+	newID, _ := result.LastInsertId()
+	return int(newID), nil
+}
+
+// setCompositeMembers replaces the members of a composite.
+func setCompositeMembers(ctx context.Context, tx *sql.Tx, id int, members []int) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM composite_members WHERE composite_id = ?`, id); err != nil {
+		return err
+	}
+	for _, member := range members {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT OR IGNORE INTO composite_members (composite_id, member_id) VALUES (?, ?)
+		`, id, member); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Server) handleCreateComposite(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req compositeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := req.validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := s.checkEscalationPolicyID(ctx, req.EscalationPolicyID); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// A new config can't be part of a cycle yet
+	if err := s.checkGraphTargets(ctx, 0, req.Members); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	probeTypeID, err := s.compositeProbeTypeID(ctx)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	enabledInt := 0
+	if req.Enabled {
+		enabledInt = 1
+	}
+	notificationChannelsJSON, _ := json.Marshal(req.NotificationChannels)
+	keywordsJSON, _ := json.Marshal(req.Keywords)
+
+	tx, err := s.db.DB().BeginTx(ctx, nil)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	// Composites are never scheduled, so they have no interval or next run
+	result, err := tx.ExecContext(ctx, `
+		INSERT INTO probe_configs (probe_type_id, name, enabled, arguments, interval, timeout_seconds,
+		                           notification_channels, escalation_policy_id, consecutive_results,
+		                           group_path, keywords, composite_mode, composite_quorum)
+		VALUES (?, ?, ?, '{}', '0', 0, ?, ?, ?, ?, ?, ?, ?)
+	`, probeTypeID, req.Name, enabledInt, string(notificationChannelsJSON), req.EscalationPolicyID, req.ConsecutiveResults,
+		req.GroupPath, string(keywordsJSON), req.Mode, req.Quorum)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	id, _ := result.LastInsertId()
+	if err := setCompositeMembers(ctx, tx, int(id), req.Members); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	s.audit(ctx, "create", auditProbeConfig, int(id), nil, s.auditState(ctx, auditProbeConfig, int(id)))
+	s.publishConfigChange(int(id), "created", req.Name, req.GroupPath, nil)
+
+	if err := s.evaluateComposite(ctx, int(id), 0); err != nil {
+		slog.Error("failed to evaluate composite config", "config_id", id, "error", err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]any{"id": id})
+}
+
+func (s *Server) handleUpdateComposite(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id, _ := strconv.Atoi(r.PathValue("id"))
+
+	var req compositeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var isComposite bool
+	err := s.db.DB().QueryRowContext(ctx, `
+		SELECT EXISTS (SELECT 1 FROM probe_configs WHERE id = ? AND composite_mode IS NOT NULL)
+	`, id).Scan(&isComposite)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !isComposite {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	if err := req.validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := s.checkEscalationPolicyID(ctx, req.EscalationPolicyID); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := s.checkGraphTargets(ctx, id, req.Members); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	enabledInt := 0
+	if req.Enabled {
+		enabledInt = 1
+	}
+	notificationChannelsJSON, _ := json.Marshal(req.NotificationChannels)
+	keywordsJSON, _ := json.Marshal(req.Keywords)
+	before := s.auditState(ctx, auditProbeConfig, id)
+
+	tx, err := s.db.DB().BeginTx(ctx, nil)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE probe_configs
+		SET name = ?, enabled = ?, notification_channels = ?, escalation_policy_id = ?, consecutive_results = ?,
+		    group_path = ?, keywords = ?, composite_mode = ?, composite_quorum = ?, updated_at = datetime('now')
+		WHERE id = ?
+	`, req.Name, enabledInt, string(notificationChannelsJSON), req.EscalationPolicyID, req.ConsecutiveResults,
+		req.GroupPath, string(keywordsJSON), req.Mode, req.Quorum, id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := setCompositeMembers(ctx, tx, id, req.Members); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	s.audit(ctx, "update", auditProbeConfig, id, before, s.auditState(ctx, auditProbeConfig, id))
+	s.publishConfigChange(id, "updated", req.Name, req.GroupPath, nil)
+
+	if err := s.evaluateComposite(ctx, id, 0); err != nil {
+		slog.Error("failed to evaluate composite config", "config_id", id, "error", err)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,68 @@
+package web
+
+import (
+	"testing"
+
+	"github.com/jandubois/monitor/internal/probe"
+)
+
+func TestCompositeRequestValidate(t *testing.T) {
+	valid := compositeRequest{Name: "nas", Mode: compositeQuorum, Quorum: 2, Members: []int{1, 2, 3}}
+	if err := valid.validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if valid.ConsecutiveResults != 1 {
+		t.Errorf("expected consecutive_results to default to 1, got %d", valid.ConsecutiveResults)
+	}
+
+	tests := map[string]func(c *compositeRequest){
+		"no name":      func(c *compositeRequest) { c.Name = "" },
+		"no members":   func(c *compositeRequest) { c.Members = nil },
+		"bad mode":     func(c *compositeRequest) { c.Mode = "most" },
+		"no quorum":    func(c *compositeRequest) { c.Quorum = 0 },
+		"large quorum": func(c *compositeRequest) { c.Quorum = 4 },
+	}
+	for name, mutate := range tests {
+		c := compositeRequest{Name: "nas", Mode: compositeQuorum, Quorum: 2, Members: []int{1, 2, 3}}
+		mutate(&c)
+		if err := c.validate(); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestCompositeStatus(t *testing.T) {
+	members := []memberStatus{
+		{name: "db", status: probe.StatusCritical},
+		{name: "disk", status: probe.StatusOK},
+		{name: "web", status: probe.StatusWarning},
+	}
+
+	tests := []struct {
+		mode     string
+		quorum   int
+		members  []memberStatus
+		expected probe.Status
+	}{
+		{mode: compositeAll, members: members, expected: probe.StatusCritical},
+		{mode: compositeAny, members: members, expected: probe.StatusOK},
+		{mode: compositeQuorum, quorum: 2, members: members, expected: probe.StatusWarning},
+		{mode: compositeAll, members: members[1:2], expected: probe.StatusOK},
+		{mode: compositeAll, members: []memberStatus{{name: "new"}}, expected: probe.StatusUnknown},
+		{mode: compositeAny, expected: probe.StatusUnknown},
+	}
+	for _, tt := range tests {
+		status, _, _ := compositeStatus(tt.mode, tt.quorum, tt.members)
+		if status != tt.expected {
+			t.Errorf("%s %d of %d: expected %q, got %q", tt.mode, tt.quorum, len(tt.members), tt.expected, status)
+		}
+	}
+
+	_, message, metrics := compositeStatus(compositeQuorum, 2, members)
+	if message != "1 of 3 members ok (quorum 2); db critical, web warning" {
+		t.Errorf("unexpected message %q", message)
+	}
+	if metrics["members_ok"] != 1 || metrics["members"] != 3 {
+		t.Errorf("unexpected metrics %v", metrics)
+	}
+}
@@ -0,0 +1,278 @@
+package web
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// suppressedBySQL is the config that the latest result of the probe config
+// aliased pc depends on and that was failing when it was recorded, or NULL.
+const suppressedBySQL = `(SELECT suppressed_by FROM probe_results WHERE probe_config_id = pc.id ORDER BY executed_at DESC LIMIT 1)`
+
+// graphEdgesSQL lists the edges of the dependency graph, from the config that
+// relies on another one to that one: dependencies and composite members.
+const graphEdgesSQL = `
+	SELECT probe_config_id AS src, depends_on_id AS dst, 'depends_on' AS kind FROM probe_dependencies
+	UNION ALL
+	SELECT composite_id, member_id, 'member' FROM composite_members`
+
+// failingDependency returns an enabled config that configID depends on,
+// directly or through other dependencies, whose latest result is critical.
+func (s *Server) failingDependency(ctx context.Context, configID int) (*int, error) {
+	var id int
+	err := s.db.DB().QueryRowContext(ctx, `
+		WITH RECURSIVE ancestors(id) AS (
+			SELECT depends_on_id FROM probe_dependencies WHERE probe_config_id = ?
+			UNION
+			SELECT d.depends_on_id FROM probe_dependencies d JOIN ancestors a ON d.probe_config_id = a.id
+		)
+		SELECT pc.id FROM ancestors a
+		JOIN probe_configs pc ON pc.id = a.id
+		WHERE pc.enabled = 1
+		  AND (SELECT status FROM probe_results WHERE probe_config_id = pc.id ORDER BY executed_at DESC LIMIT 1) = 'critical'
+		LIMIT 1
+	`, configID).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &id, nil
+}
+
+// checkGraphTargets checks that configID can depend on, or contain, all of
+// targets: they must exist, and none may rely on configID already, which
+// would create a cycle.
+func (s *Server) checkGraphTargets(ctx context.Context, configID int, targets []int) error {
+	for _, target := range targets {
+		if target == configID {
+			return errors.New("a config cannot depend on itself")
+		}
+		exists, err := s.configExists(ctx, target)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return fmt.Errorf("probe config %d not found", target)
+		}
+	}
+
+	targetsJSON, _ := json.Marshal(targets)
+	var cycle bool
+	err := s.db.DB().QueryRowContext(ctx, `
+		WITH RECURSIVE edges(src, dst, kind) AS (`+graphEdgesSQL+`),
+		reach(id) AS (
+			SELECT value FROM json_each(?)
+			UNION
+			SELECT e.dst FROM edges e JOIN reach r ON e.src = r.id
+		)
+		SELECT EXISTS (SELECT 1 FROM reach WHERE id = ?)
+	`, string(targetsJSON), configID).Scan(&cycle)
+	if err != nil {
+		return err
+	}
+	if cycle {
+		return errors.New("dependency cycle")
+	}
+	return nil
+}
+
+// configExists reports whether a probe config exists.
+func (s *Server) configExists(ctx context.Context, id int) (bool, error) {
+	var exists bool
+	err := s.db.DB().QueryRowContext(ctx, `
+		SELECT EXISTS (SELECT 1 FROM probe_configs WHERE id = ?)
+	`, id).Scan(&exists)
+	return exists, err
+}
+
+// configIDs returns the config IDs selected by query.
+func (s *Server) configIDs(ctx context.Context, query string, args ...any) ([]int, error) {
+	rows, err := s.db.DB().QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func (s *Server) handleSetDependencies(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id, _ := strconv.Atoi(r.PathValue("id"))
+
+	var req struct {
+		DependsOn []int `json:"depends_on"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	exists, err := s.configExists(ctx, id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !exists {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	if err := s.checkGraphTargets(ctx, id, req.DependsOn); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	before := s.auditState(ctx, auditProbeConfig, id)
+
+	tx, err := s.db.DB().BeginTx(ctx, nil)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM probe_dependencies WHERE probe_config_id = ?`, id); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for _, parent := range req.DependsOn {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT OR IGNORE INTO probe_dependencies (probe_config_id, depends_on_id) VALUES (?, ?)
+		`, id, parent); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	s.audit(ctx, "update", auditProbeConfig, id, before, s.auditState(ctx, auditProbeConfig, id))
+	s.publishConfigChanged(ctx, id, "updated")
+
+	if req.DependsOn == nil {
+		req.DependsOn = []int{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"depends_on": req.DependsOn})
+}
+
+// graphNode is a probe config in the dependency graph.
+type graphNode struct {
+	ID            int     `json:"id"`
+	Name          string  `json:"name"`
+	Status        *string `json:"status,omitempty"`
+	CompositeMode *string `json:"composite_mode,omitempty"`
+	SuppressedBy  *int    `json:"suppressed_by,omitempty"`
+}
+
+// graphEdge points from a config to one it depends on or, for a
+// composite, to one of its members.
+type graphEdge struct {
+	From int    `json:"from"`
+	To   int    `json:"to"`
+	Kind string `json:"kind"` // depends_on or member
+}
+
+// handleDependencyGraph returns the configs that have dependencies or
+// composite members, or are one, and the edges between them. With
+// ?config_id= it returns only the part of the graph connected to that config.
+func (s *Server) handleDependencyGraph(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	rows, err := s.db.DB().QueryContext(ctx, graphEdgesSQL+` ORDER BY src, dst`)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	edges := []graphEdge{}
+	for rows.Next() {
+		var e graphEdge
+		if err := rows.Scan(&e.From, &e.To, &e.Kind); err != nil {
+			rows.Close()
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		edges = append(edges, e)
+	}
+	rows.Close()
+
+	nodeIDs := make(map[int]bool)
+	if configID := r.URL.Query().Get("config_id"); configID != "" {
+		id, err := strconv.Atoi(configID)
+		if err != nil {
+			http.Error(w, "invalid config_id", http.StatusBadRequest)
+			return
+		}
+		// Walk the edges in both directions from the config
+		nodeIDs[id] = true
+		for changed := true; changed; {
+			changed = false
+			for _, e := range edges {
+				if nodeIDs[e.From] != nodeIDs[e.To] {
+					nodeIDs[e.From], nodeIDs[e.To] = true, true
+					changed = true
+				}
+			}
+		}
+		connected := edges[:0]
+		for _, e := range edges {
+			if nodeIDs[e.From] {
+				connected = append(connected, e)
+			}
+		}
+		edges = connected
+	} else {
+		for _, e := range edges {
+			nodeIDs[e.From], nodeIDs[e.To] = true, true
+		}
+	}
+
+	ids := make([]int, 0, len(nodeIDs))
+	for id := range nodeIDs {
+		ids = append(ids, id)
+	}
+	idsJSON, _ := json.Marshal(ids)
+	rows, err = s.db.DB().QueryContext(ctx, `
+		SELECT pc.id, pc.name,
+		       (SELECT status FROM probe_results WHERE probe_config_id = pc.id ORDER BY executed_at DESC LIMIT 1),
+		       pc.composite_mode, `+suppressedBySQL+`
+		FROM probe_configs pc
+		WHERE pc.id IN (SELECT value FROM json_each(?)) OR pc.composite_mode IS NOT NULL AND ? = ''
+		ORDER BY pc.name
+	`, string(idsJSON), r.URL.Query().Get("config_id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	nodes := []graphNode{}
+	for rows.Next() {
+		var n graphNode
+		if err := rows.Scan(&n.ID, &n.Name, &n.Status, &n.CompositeMode, &n.SuppressedBy); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		nodes = append(nodes, n)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"nodes": nodes, "edges": edges})
+}
@@ -0,0 +1,68 @@
+package web
+
+import (
+	"context"
+	"testing"
+)
+
+func TestDependencies(t *testing.T) {
+	server, cleanup := testServer(t)
+	if server == nil {
+		return
+	}
+	defer cleanup()
+
+	ctx := context.Background()
+	sqlDB := server.db.DB()
+
+	if _, err := sqlDB.ExecContext(ctx, `INSERT INTO probe_types (name, version, arguments) VALUES ('dep-type', '1.0.0', '{}')`); err != nil {
+		t.Fatalf("failed to create probe type: %v", err)
+	}
+	addConfig := func(name string) int {
+		t.Helper()
+		result, err := sqlDB.ExecContext(ctx, `
+			INSERT INTO probe_configs (probe_type_id, name, interval)
+			SELECT id, ?, '1m' FROM probe_types WHERE name = 'dep-type'
+		`, name)
+		if err != nil {
+			t.Fatalf("failed to create config: %v", err)
+		}
+		id, _ := result.LastInsertId()
+		return int(id)
+	}
+	network := addConfig("network")
+	share := addConfig("share")
+	backup := addConfig("backup")
+
+	// backup depends on share, which depends on network
+	if _, err := sqlDB.ExecContext(ctx, `
+		INSERT INTO probe_dependencies (probe_config_id, depends_on_id) VALUES (?, ?), (?, ?)
+	`, share, network, backup, share); err != nil {
+		t.Fatalf("failed to add dependencies: %v", err)
+	}
+
+	if err := server.checkGraphTargets(ctx, network, []int{backup}); err == nil {
+		t.Error("expected a cycle to be rejected")
+	}
+	if err := server.checkGraphTargets(ctx, backup, []int{network}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := server.checkGraphTargets(ctx, backup, []int{backup + 100}); err == nil {
+		t.Error("expected a missing config to be rejected")
+	}
+
+	parent, err := server.failingDependency(ctx, backup)
+	if err != nil || parent != nil {
+		t.Fatalf("expected no failing dependency, got %v, %v", parent, err)
+	}
+
+	if _, err := sqlDB.ExecContext(ctx, `
+		INSERT INTO probe_results (probe_config_id, status, executed_at) VALUES (?, 'critical', datetime('now'))
+	`, network); err != nil {
+		t.Fatalf("failed to insert result: %v", err)
+	}
+	parent, err = server.failingDependency(ctx, backup)
+	if err != nil || parent == nil || *parent != network {
+		t.Errorf("expected network to be failing, got %v, %v", parent, err)
+	}
+}
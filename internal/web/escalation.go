@@ -0,0 +1,541 @@
+package web
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/jandubois/monitor/internal/db"
+	"github.com/jandubois/monitor/internal/notify"
+	"github.com/jandubois/monitor/internal/probe"
+)
+
+const escalationCheckInterval = 30 * time.Second
+
+// escalationStep notifies channels once a probe has been critical for
+// DelayMinutes.
+type escalationStep struct {
+	DelayMinutes int   `json:"delay_minutes"`
+	ChannelIDs   []int `json:"channel_ids"`
+}
+
+// escalationPolicy routes notifications for the probe configs it is
+// attached to, in place of their notification_channels.
+type escalationPolicy struct {
+	ID            int              `json:"id"`
+	Name          string           `json:"name"`
+	Steps         []escalationStep `json:"steps"`
+	RepeatMinutes int              `json:"repeat_minutes"`
+}
+
+func (p *escalationPolicy) validate() error {
+	if p.Name == "" {
+		return errors.New("name is required")
+	}
+	if len(p.Steps) == 0 {
+		return errors.New("at least one step is required")
+	}
+	for i, step := range p.Steps {
+		if step.DelayMinutes < 0 {
+			return fmt.Errorf("step %d: delay_minutes must not be negative", i+1)
+		}
+		if i > 0 && step.DelayMinutes < p.Steps[i-1].DelayMinutes {
+			return fmt.Errorf("step %d: delay_minutes must not be less than the previous step", i+1)
+		}
+		if len(step.ChannelIDs) == 0 {
+			return fmt.Errorf("step %d: channel_ids is required", i+1)
+		}
+	}
+	if p.RepeatMinutes < 0 {
+		return errors.New("repeat_minutes must not be negative")
+	}
+	return nil
+}
+
+// reached returns how many steps are due after being critical for elapsed.
+func (p *escalationPolicy) reached(elapsed time.Duration) int {
+	n := 0
+	for _, step := range p.Steps {
+		if elapsed < time.Duration(step.DelayMinutes)*time.Minute {
+			break
+		}
+		n++
+	}
+	return n
+}
+
+// channels returns the distinct channels of the first n steps.
+func (p *escalationPolicy) channels(n int) []int {
+	var ids []int
+	for _, step := range p.Steps[:min(n, len(p.Steps))] {
+		for _, id := range step.ChannelIDs {
+			if !slices.Contains(ids, id) {
+				ids = append(ids, id)
+			}
+		}
+	}
+	return ids
+}
+
+// escalation is the state of a config that is currently escalating.
+type escalation struct {
+	configID      int
+	configName    string
+	startedAt     time.Time
+	stepsNotified int
+	lastNotified  db.NullTime
+	policy        escalationPolicy
+	lastStatus    *string // Confirmed status, see statusFilter
+	lastMessage   *string
+	ackToken      *string // Of the open incident
+}
+
+// due returns how many steps should have been notified as of now, and
+// whether the channels notified so far are due for a repeat.
+func (e *escalation) due(now time.Time) (int, bool) {
+	reached := e.policy.reached(now.Sub(e.startedAt))
+	if reached > e.stepsNotified {
+		return reached, false
+	}
+	repeat := e.policy.RepeatMinutes > 0 && e.stepsNotified > 0 && e.lastNotified.Valid &&
+		now.Sub(e.lastNotified.Time) >= time.Duration(e.policy.RepeatMinutes)*time.Minute
+	return e.stepsNotified, repeat
+}
+
+func (s *Server) escalationLoop(ctx context.Context) {
+	ticker := time.NewTicker(escalationCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.checkEscalations(ctx, time.Now().UTC()); err != nil {
+				slog.Error("escalation check failed", "error", err)
+			}
+		}
+	}
+}
+
+// checkEscalations notifies the next steps of escalations whose delay has
+// passed, and repeats notifications that are due. Acknowledged escalations,
+// disabled configs, paused watchers, silenced configs and suppressed
+// failures are skipped. It returns the number of notifications sent.
+func (s *Server) checkEscalations(ctx context.Context, now time.Time) (int, error) {
+	// A detached policy ends the escalation
+	if _, err := s.db.DB().ExecContext(ctx, `
+		DELETE FROM escalations
+		WHERE probe_config_id IN (SELECT id FROM probe_configs WHERE escalation_policy_id IS NULL)
+	`); err != nil {
+		return 0, err
+	}
+
+	rows, err := s.db.DB().QueryContext(ctx, `
+		SELECT e.probe_config_id, pc.name, e.started_at, e.steps_notified, e.last_notified_at,
+		       ep.id, ep.name, ep.steps, ep.repeat_minutes,
+		       COALESCE(pc.confirmed_status,
+		                (SELECT status FROM probe_results WHERE probe_config_id = pc.id ORDER BY executed_at DESC LIMIT 1)),
+		       (SELECT message FROM probe_results WHERE probe_config_id = pc.id ORDER BY executed_at DESC LIMIT 1),
+		       (SELECT ack_token FROM incidents WHERE probe_config_id = pc.id AND resolved_at IS NULL)
+		FROM escalations e
+		JOIN probe_configs pc ON pc.id = e.probe_config_id
+		JOIN escalation_policies ep ON ep.id = pc.escalation_policy_id
+		LEFT JOIN watchers w ON w.id = pc.watcher_id
+		WHERE e.acknowledged_at IS NULL AND pc.enabled = 1 AND COALESCE(w.paused, 0) = 0
+		  AND NOT `+silencedSQL+` AND `+suppressedBySQL+` IS NULL
+	`, silenceArgs(now)...)
+	if err != nil {
+		return 0, err
+	}
+
+	var escalations []escalation
+	for rows.Next() {
+		var e escalation
+		var startedAt db.NullTime
+		var steps string
+		if err := rows.Scan(&e.configID, &e.configName, &startedAt, &e.stepsNotified, &e.lastNotified,
+			&e.policy.ID, &e.policy.Name, &steps, &e.policy.RepeatMinutes,
+			&e.lastStatus, &e.lastMessage, &e.ackToken); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if err := json.Unmarshal([]byte(steps), &e.policy.Steps); err != nil {
+			slog.Error("invalid escalation policy steps", "policy", e.policy.Name, "error", err)
+			continue
+		}
+		e.startedAt = startedAt.Time
+		escalations = append(escalations, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, e := range escalations {
+		// The change that ended the escalation may not have been notified,
+		// e.g. while the watcher was paused
+		if e.lastStatus == nil || probe.Status(*e.lastStatus) != probe.StatusCritical {
+			if _, err := s.db.DB().ExecContext(ctx, `DELETE FROM escalations WHERE probe_config_id = ?`, e.configID); err != nil {
+				return sent, err
+			}
+			continue
+		}
+
+		reached, repeat := e.due(now)
+		if reached == e.stepsNotified && !repeat {
+			continue
+		}
+
+		// Guard against a concurrent recovery or acknowledgement
+		result, err := s.db.DB().ExecContext(ctx, `
+			UPDATE escalations SET steps_notified = ?, last_notified_at = ?
+			WHERE probe_config_id = ? AND steps_notified = ? AND acknowledged_at IS NULL
+		`, reached, now.Format(db.SQLiteTimeFormat), e.configID, e.stepsNotified)
+		if err != nil {
+			return sent, err
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			continue
+		}
+
+		channels := e.policy.channels(reached)
+		if !repeat {
+			// Channels of earlier steps already know
+			notified := e.policy.channels(e.stepsNotified)
+			channels = slices.DeleteFunc(channels, func(id int) bool { return slices.Contains(notified, id) })
+		}
+		if len(channels) == 0 {
+			continue
+		}
+
+		var message string
+		if e.lastMessage != nil {
+			message = *e.lastMessage
+		}
+		var incident *openIncident
+		if e.ackToken != nil {
+			incident = &openIncident{ackToken: *e.ackToken}
+		}
+		slog.Info("escalating probe", "config_id", e.configID, "policy", e.policy.Name, "steps", reached, "repeat", repeat)
+		s.dispatcher.NotifyEscalation(ctx, channels, &notify.Escalation{
+			ProbeName: e.configName,
+			Status:    probe.StatusCritical,
+			Message:   message,
+			Duration:  now.Sub(e.startedAt),
+			Repeat:    repeat,
+			AckURL:    s.ackURL(incident),
+		})
+		sent++
+	}
+
+	return sent, nil
+}
+
+// escalateStatusChange notifies a status change for a config with an
+// escalation policy. Going critical starts an escalation and notifies the
+// steps without delay; leaving critical ends it and tells every channel
+// that was notified. Other changes go to the first step.
+func (s *Server) escalateStatusChange(ctx context.Context, configID, policyID int, change *notify.StatusChange) {
+	policy, err := s.loadEscalationPolicy(ctx, policyID)
+	if err != nil {
+		slog.Error("failed to load escalation policy", "config_id", configID, "policy_id", policyID, "error", err)
+		return
+	}
+
+	now := time.Now().UTC()
+	if change.NewStatus == probe.StatusCritical {
+		reached := policy.reached(0)
+		var lastNotified *string
+		if reached > 0 {
+			ts := now.Format(db.SQLiteTimeFormat)
+			lastNotified = &ts
+		}
+		_, err := s.db.DB().ExecContext(ctx, `
+			INSERT INTO escalations (probe_config_id, started_at, steps_notified, last_notified_at)
+			VALUES (?, ?, ?, ?)
+			ON CONFLICT (probe_config_id) DO UPDATE
+			SET started_at = excluded.started_at, steps_notified = excluded.steps_notified,
+			    last_notified_at = excluded.last_notified_at, acknowledged_at = NULL
+		`, configID, now.Format(db.SQLiteTimeFormat), reached, lastNotified)
+		if err != nil {
+			slog.Error("failed to start escalation", "config_id", configID, "error", err)
+			return
+		}
+		if channels := policy.channels(reached); len(channels) > 0 {
+			s.dispatcher.NotifyStatusChange(ctx, channels, change)
+		}
+		return
+	}
+
+	reached := 1
+	var stepsNotified int
+	err = s.db.DB().QueryRowContext(ctx, `
+		DELETE FROM escalations WHERE probe_config_id = ? RETURNING steps_notified
+	`, configID).Scan(&stepsNotified)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+	case err != nil:
+		slog.Error("failed to end escalation", "config_id", configID, "error", err)
+		return
+	default:
+		// Nobody hears about the end of an escalation that paged nobody
+		reached = stepsNotified
+	}
+	if channels := policy.channels(reached); len(channels) > 0 {
+		s.dispatcher.NotifyStatusChange(ctx, channels, change)
+	}
+}
+
+func (s *Server) loadEscalationPolicy(ctx context.Context, id int) (*escalationPolicy, error) {
+	policy := &escalationPolicy{ID: id}
+	var steps string
+	err := s.db.DB().QueryRowContext(ctx, `
+		SELECT name, steps, repeat_minutes FROM escalation_policies WHERE id = ?
+	`, id).Scan(&policy.Name, &steps, &policy.RepeatMinutes)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(steps), &policy.Steps); err != nil {
+		return nil, fmt.Errorf("invalid steps: %w", err)
+	}
+	return policy, nil
+}
+
+// checkEscalationPolicyID returns an error if id is set but names no policy.
+func (s *Server) checkEscalationPolicyID(ctx context.Context, id *int) error {
+	if id == nil {
+		return nil
+	}
+	var exists bool
+	err := s.db.DB().QueryRowContext(ctx, `
+		SELECT EXISTS (SELECT 1 FROM escalation_policies WHERE id = ?)
+	`, *id).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("escalation policy %d not found", *id)
+	}
+	return nil
+}
+
+func (s *Server) handleListEscalationPolicies(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	rows, err := s.db.DB().QueryContext(ctx, `
+		SELECT id, name, steps, repeat_minutes FROM escalation_policies ORDER BY name
+	`)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	policies := []escalationPolicy{}
+	for rows.Next() {
+		var policy escalationPolicy
+		var steps string
+		if err := rows.Scan(&policy.ID, &policy.Name, &steps, &policy.RepeatMinutes); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		json.Unmarshal([]byte(steps), &policy.Steps)
+		policies = append(policies, policy)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(policies)
+}
+
+func (s *Server) handleGetEscalationPolicy(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(r.PathValue("id"))
+
+	policy, err := s.loadEscalationPolicy(r.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(policy)
+}
+
+func (s *Server) handleCreateEscalationPolicy(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req escalationPolicy
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := req.validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	stepsJSON, _ := json.Marshal(req.Steps)
+	result, err := s.db.DB().ExecContext(ctx, `
+		INSERT INTO escalation_policies (name, steps, repeat_minutes) VALUES (?, ?, ?)
+	`, req.Name, string(stepsJSON), req.RepeatMinutes)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	id, _ := result.LastInsertId()
+	s.audit(ctx, "create", auditEscalationPolicy, int(id), nil, s.auditState(ctx, auditEscalationPolicy, int(id)))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]any{"id": id})
+}
+
+func (s *Server) handleUpdateEscalationPolicy(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id, _ := strconv.Atoi(r.PathValue("id"))
+
+	var req escalationPolicy
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := req.validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	stepsJSON, _ := json.Marshal(req.Steps)
+	before := s.auditState(ctx, auditEscalationPolicy, id)
+	result, err := s.db.DB().ExecContext(ctx, `
+		UPDATE escalation_policies SET name = ?, steps = ?, repeat_minutes = ?, updated_at = datetime('now')
+		WHERE id = ?
+	`, req.Name, string(stepsJSON), req.RepeatMinutes, id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	s.audit(ctx, "update", auditEscalationPolicy, id, before, s.auditState(ctx, auditEscalationPolicy, id))
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleDeleteEscalationPolicy(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id, _ := strconv.Atoi(r.PathValue("id"))
+	before := s.auditState(ctx, auditEscalationPolicy, id)
+
+	tx, err := s.db.DB().BeginTx(ctx, nil)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	// Configs fall back to their notification channels
+	for _, stmt := range []string{
+		`DELETE FROM escalations WHERE probe_config_id IN (SELECT id FROM probe_configs WHERE escalation_policy_id = ?)`,
+		`UPDATE probe_configs SET escalation_policy_id = NULL WHERE escalation_policy_id = ?`,
+		`DELETE FROM escalation_policies WHERE id = ?`,
+	} {
+		if _, err := tx.ExecContext(ctx, stmt, id); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if before != nil {
+		s.audit(ctx, "delete", auditEscalationPolicy, id, before, nil)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleListEscalations(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	rows, err := s.db.DB().QueryContext(ctx, `
+		SELECT e.probe_config_id, pc.name, ep.id, ep.name, e.started_at, e.steps_notified,
+		       e.last_notified_at, e.acknowledged_at
+		FROM escalations e
+		JOIN probe_configs pc ON pc.id = e.probe_config_id
+		JOIN escalation_policies ep ON ep.id = pc.escalation_policy_id
+		ORDER BY e.started_at
+	`)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	escalations := []map[string]any{}
+	for rows.Next() {
+		var configID, policyID, stepsNotified int
+		var configName, policyName string
+		var startedAt, lastNotifiedAt, acknowledgedAt db.NullTime
+		if err := rows.Scan(&configID, &configName, &policyID, &policyName, &startedAt, &stepsNotified,
+			&lastNotifiedAt, &acknowledgedAt); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		e := map[string]any{
+			"probe_config_id":   configID,
+			"probe_config_name": configName,
+			"policy_id":         policyID,
+			"policy_name":       policyName,
+			"started_at":        startedAt.Time,
+			"steps_notified":    stepsNotified,
+		}
+		if lastNotifiedAt.Valid {
+			e["last_notified_at"] = lastNotifiedAt.Time
+		}
+		if acknowledgedAt.Valid {
+			e["acknowledged_at"] = acknowledgedAt.Time
+		}
+		escalations = append(escalations, e)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(escalations)
+}
+
+// handleAcknowledgeConfig acknowledges a config's open incident and
+// escalation, which stops further steps and repeats. The recovery is still
+// notified.
+func (s *Server) handleAcknowledgeConfig(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id, _ := strconv.Atoi(r.PathValue("id"))
+
+	acknowledged, err := s.acknowledgeConfig(ctx, id, callerName(ctx))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !acknowledged {
+		http.Error(w, "nothing to acknowledge", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,198 @@
+package web
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/jandubois/monitor/internal/db"
+	"github.com/jandubois/monitor/internal/notify"
+	"github.com/jandubois/monitor/internal/probe"
+)
+
+func testEscalationPolicy() escalationPolicy {
+	return escalationPolicy{
+		Name: "on-call",
+		Steps: []escalationStep{
+			{DelayMinutes: 0, ChannelIDs: []int{1}},
+			{DelayMinutes: 15, ChannelIDs: []int{1, 2}},
+			{DelayMinutes: 60, ChannelIDs: []int{3}},
+		},
+		RepeatMinutes: 10,
+	}
+}
+
+func TestEscalationPolicyValidate(t *testing.T) {
+	valid := testEscalationPolicy()
+	if err := valid.validate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	tests := map[string]func(p *escalationPolicy){
+		"no name":          func(p *escalationPolicy) { p.Name = "" },
+		"no steps":         func(p *escalationPolicy) { p.Steps = nil },
+		"negative delay":   func(p *escalationPolicy) { p.Steps[0].DelayMinutes = -1 },
+		"decreasing delay": func(p *escalationPolicy) { p.Steps[2].DelayMinutes = 5 },
+		"no channels":      func(p *escalationPolicy) { p.Steps[1].ChannelIDs = nil },
+		"negative repeat":  func(p *escalationPolicy) { p.RepeatMinutes = -1 },
+	}
+	for name, mutate := range tests {
+		p := testEscalationPolicy()
+		mutate(&p)
+		if err := p.validate(); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestEscalationPolicyChannels(t *testing.T) {
+	p := testEscalationPolicy()
+	if got := p.channels(0); len(got) != 0 {
+		t.Errorf("expected no channels, got %v", got)
+	}
+	if got := p.channels(2); !slices.Equal(got, []int{1, 2}) {
+		t.Errorf("expected [1 2], got %v", got)
+	}
+	if got := p.channels(10); !slices.Equal(got, []int{1, 2, 3}) {
+		t.Errorf("expected [1 2 3], got %v", got)
+	}
+}
+
+func TestEscalationDue(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	notifiedAt := func(minutes int) db.NullTime {
+		return db.NullTime{Time: start.Add(time.Duration(minutes) * time.Minute), Valid: true}
+	}
+
+	tests := []struct {
+		name          string
+		stepsNotified int
+		lastNotified  db.NullTime
+		firstDelay    int
+		minutes       int
+		repeatMinutes int
+		reached       int
+		repeat        bool
+	}{
+		{name: "first step already sent", stepsNotified: 1, lastNotified: notifiedAt(0), minutes: 5, repeatMinutes: 10, reached: 1},
+		{name: "repeat first step", stepsNotified: 1, lastNotified: notifiedAt(0), minutes: 10, repeatMinutes: 10, reached: 1, repeat: true},
+		{name: "second step", stepsNotified: 1, lastNotified: notifiedAt(10), minutes: 15, repeatMinutes: 10, reached: 2},
+		{name: "skipped steps", stepsNotified: 1, lastNotified: notifiedAt(0), minutes: 90, repeatMinutes: 10, reached: 3},
+		{name: "no repeats", stepsNotified: 3, lastNotified: notifiedAt(60), minutes: 600, reached: 3},
+		{name: "delayed first step", stepsNotified: 0, firstDelay: 5, minutes: 5, repeatMinutes: 1, reached: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := escalation{
+				startedAt:     start,
+				stepsNotified: tt.stepsNotified,
+				lastNotified:  tt.lastNotified,
+				policy:        testEscalationPolicy(),
+			}
+			e.policy.Steps[0].DelayMinutes = tt.firstDelay
+			e.policy.RepeatMinutes = tt.repeatMinutes
+			reached, repeat := e.due(start.Add(time.Duration(tt.minutes) * time.Minute))
+			if reached != tt.reached || repeat != tt.repeat {
+				t.Errorf("expected (%d, %v), got (%d, %v)", tt.reached, tt.repeat, reached, repeat)
+			}
+		})
+	}
+}
+
+func TestEscalationLifecycle(t *testing.T) {
+	server, cleanup := testServer(t)
+	if server == nil {
+		return
+	}
+	defer cleanup()
+
+	ctx := context.Background()
+	sqlDB := server.db.DB()
+
+	result, err := sqlDB.ExecContext(ctx, `
+		INSERT INTO escalation_policies (name, steps, repeat_minutes)
+		VALUES ('lifecycle', '[{"delay_minutes": 0, "channel_ids": [1]}, {"delay_minutes": 15, "channel_ids": [2]}]', 10)
+	`)
+	if err != nil {
+		t.Fatalf("failed to create policy: %v", err)
+	}
+	policyID, _ := result.LastInsertId()
+
+	if _, err := sqlDB.ExecContext(ctx, `INSERT INTO probe_types (name, version, arguments) VALUES ('esc-type', '1.0.0', '{}')`); err != nil {
+		t.Fatalf("failed to create probe type: %v", err)
+	}
+	result, err = sqlDB.ExecContext(ctx, `
+		INSERT INTO probe_configs (probe_type_id, name, interval, escalation_policy_id)
+		SELECT id, 'escalating', '1m', ? FROM probe_types WHERE name = 'esc-type'
+	`, policyID)
+	if err != nil {
+		t.Fatalf("failed to create config: %v", err)
+	}
+	configID, _ := result.LastInsertId()
+
+	addResult := func(status probe.Status, at time.Time) {
+		t.Helper()
+		if _, err := sqlDB.ExecContext(ctx, `
+			INSERT INTO probe_results (probe_config_id, status, message, executed_at) VALUES (?, ?, 'disk full', ?)
+		`, configID, status, at.Format(db.SQLiteTimeFormat)); err != nil {
+			t.Fatalf("failed to insert result: %v", err)
+		}
+	}
+	stepsNotified := func() int {
+		t.Helper()
+		var n int
+		if err := sqlDB.QueryRowContext(ctx, `SELECT steps_notified FROM escalations WHERE probe_config_id = ?`, configID).Scan(&n); err != nil {
+			t.Fatalf("failed to read escalation: %v", err)
+		}
+		return n
+	}
+
+	now := time.Now().UTC()
+	addResult(probe.StatusCritical, now)
+	server.escalateStatusChange(ctx, int(configID), int(policyID), &notify.StatusChange{
+		ProbeName: "escalating",
+		OldStatus: probe.StatusOK,
+		NewStatus: probe.StatusCritical,
+	})
+	if n := stepsNotified(); n != 1 {
+		t.Fatalf("expected the first step to be notified immediately, got %d", n)
+	}
+
+	check := func(at time.Time, expected int) {
+		t.Helper()
+		sent, err := server.checkEscalations(ctx, at)
+		if err != nil {
+			t.Fatalf("checkEscalations failed: %v", err)
+		}
+		if sent != expected {
+			t.Errorf("expected %d notifications, got %d", expected, sent)
+		}
+	}
+
+	check(now.Add(5*time.Minute), 0)
+	check(now.Add(10*time.Minute), 1) // Repeat
+	check(now.Add(15*time.Minute), 1) // Second step
+	if n := stepsNotified(); n != 2 {
+		t.Errorf("expected 2 steps notified, got %d", n)
+	}
+
+	if _, err := sqlDB.ExecContext(ctx, `UPDATE escalations SET acknowledged_at = ? WHERE probe_config_id = ?`,
+		now.Format(db.SQLiteTimeFormat), configID); err != nil {
+		t.Fatalf("failed to acknowledge: %v", err)
+	}
+	check(now.Add(time.Hour), 0)
+
+	addResult(probe.StatusOK, now.Add(time.Hour))
+	server.escalateStatusChange(ctx, int(configID), int(policyID), &notify.StatusChange{
+		ProbeName: "escalating",
+		OldStatus: probe.StatusCritical,
+		NewStatus: probe.StatusOK,
+	})
+	var remaining int
+	sqlDB.QueryRowContext(ctx, `SELECT COUNT(*) FROM escalations`).Scan(&remaining)
+	if remaining != 0 {
+		t.Errorf("expected recovery to end the escalation, %d remaining", remaining)
+	}
+}
@@ -0,0 +1,326 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// eventBufferSize is how many recent events are kept for clients that
+	// reconnect with Last-Event-ID.
+	eventBufferSize = 1000
+
+	// eventSubscriberQueue is how many events may be waiting for a
+	// subscriber before it is disconnected as too slow.
+	eventSubscriberQueue = 64
+
+	eventKeepAliveInterval = 30 * time.Second
+)
+
+// Event types sent on /api/events
+const (
+	eventResult         = "result"
+	eventStatusChange   = "status_change"
+	eventHeartbeat      = "heartbeat"
+	eventWatcherOnline  = "watcher_online"
+	eventWatcherOffline = "watcher_offline"
+	eventConfigChange   = "config_change"
+	eventTrigger        = "trigger"
+
+	// eventReset tells a resuming client that events were lost, so it must
+	// reload its state instead of applying the ones that follow.
+	eventReset = "reset"
+)
+
+// event is a message for /api/events subscribers. The watcher and group are
+// only used for filtering; the client sees the type and data.
+type event struct {
+	id        int64
+	kind      string
+	data      []byte
+	watcherID *int
+	groupPath *string
+}
+
+// eventHub fans events out to the subscribers of /api/events and keeps the
+// latest ones so reconnecting clients can catch up.
+type eventHub struct {
+	mu          sync.Mutex
+	nextID      int64
+	buffer      []event
+	subscribers map[chan event]*eventFilter // Nil for all events
+	closed      bool
+}
+
+// newEventHub creates an event hub. IDs start at the current time in
+// microseconds, so they keep increasing across restarts and an ID from
+// before a restart is never mistaken for a recent one.
+func newEventHub() *eventHub {
+	return &eventHub{
+		nextID:      time.Now().UnixMicro(),
+		subscribers: make(map[chan event]*eventFilter),
+	}
+}
+
+// publish sends an event to the subscribers whose filter it passes. A
+// subscriber that has fallen too far behind is disconnected; it can resume
+// from the buffer. Filtering here, rather than when writing, keeps other
+// subscribers' traffic out of a slow subscriber's queue.
+func (h *eventHub) publish(kind string, watcherID *int, groupPath *string, data any) {
+	b, err := json.Marshal(data)
+	if err != nil {
+		slog.Error("failed to encode event", "type", kind, "error", err)
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return
+	}
+
+	e := event{id: h.nextID, kind: kind, data: b, watcherID: watcherID, groupPath: groupPath}
+	h.nextID++
+	h.buffer = append(h.buffer, e)
+	if len(h.buffer) > eventBufferSize {
+		h.buffer = h.buffer[len(h.buffer)-eventBufferSize:]
+	}
+
+	for ch, filter := range h.subscribers {
+		if filter != nil && !filter.matches(&e) {
+			continue
+		}
+		select {
+		case ch <- e:
+		default:
+			delete(h.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// subscribe returns a channel of new events that pass the filter, and the
+// buffered ones after lastID if it is not 0. complete is false if some of
+// the events after lastID are no longer buffered. The channel is closed
+// when the subscriber falls behind or the hub is closed.
+func (h *eventHub) subscribe(lastID int64, filter *eventFilter) (ch chan event, missed []event, complete bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	ch = make(chan event, eventSubscriberQueue)
+	if h.closed {
+		close(ch)
+		return ch, nil, true
+	}
+	h.subscribers[ch] = filter
+
+	if lastID == 0 {
+		return ch, nil, true
+	}
+	// Completeness depends on all events, not just the ones that pass
+	firstID := int64(-1)
+	for i := range h.buffer {
+		e := &h.buffer[i]
+		if e.id <= lastID {
+			continue
+		}
+		if firstID < 0 {
+			firstID = e.id
+		}
+		if filter == nil || filter.matches(e) {
+			missed = append(missed, *e)
+		}
+	}
+	switch {
+	case lastID >= h.nextID:
+		// From the future, or from a server with a faster clock
+		complete = false
+	case lastID == h.nextID-1:
+		complete = true
+	default:
+		complete = firstID == lastID+1
+	}
+	return ch, missed, complete
+}
+
+func (h *eventHub) unsubscribe(ch chan event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.subscribers[ch]; ok {
+		delete(h.subscribers, ch)
+		close(ch)
+	}
+}
+
+// close disconnects all subscribers, so open streams don't hold up the
+// server shutdown.
+func (h *eventHub) close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for ch := range h.subscribers {
+		delete(h.subscribers, ch)
+		close(ch)
+	}
+}
+
+// eventFilter selects the events a subscriber wants.
+type eventFilter struct {
+	group     string
+	watcherID int
+	types     map[string]bool
+}
+
+// matches reports whether e passes the filter. The group applies to events
+// about configs only; watcher events have no group.
+func (f *eventFilter) matches(e *event) bool {
+	if len(f.types) > 0 && !f.types[e.kind] {
+		return false
+	}
+	if f.watcherID != 0 && (e.watcherID == nil || *e.watcherID != f.watcherID) {
+		return false
+	}
+	if f.group == "" || e.kind == eventHeartbeat || e.kind == eventWatcherOnline || e.kind == eventWatcherOffline {
+		return true
+	}
+	return e.groupPath != nil && (*e.groupPath == f.group || strings.HasPrefix(*e.groupPath, f.group+"/"))
+}
+
+// configEventInfo returns the name, group and watcher of a config for the
+// events about it.
+func (s *Server) configEventInfo(ctx context.Context, configID int) (name string, groupPath *string, watcherID *int) {
+	err := s.db.DB().QueryRowContext(ctx, `
+		SELECT name, group_path, watcher_id FROM probe_configs WHERE id = ?
+	`, configID).Scan(&name, &groupPath, &watcherID)
+	if err != nil {
+		slog.Error("failed to get config for event", "config_id", configID, "error", err)
+	}
+	return name, groupPath, watcherID
+}
+
+// publishResult announces a newly recorded result.
+func (s *Server) publishResult(ctx context.Context, resultID int64, configID int, status, message string, executedAt time.Time) {
+	name, groupPath, watcherID := s.configEventInfo(ctx, configID)
+	s.events.publish(eventResult, watcherID, groupPath, map[string]any{
+		"id":              resultID,
+		"probe_config_id": configID,
+		"name":            name,
+		"group_path":      groupPath,
+		"watcher_id":      watcherID,
+		"status":          status,
+		"message":         message,
+		"executed_at":     executedAt.UTC(),
+	})
+}
+
+// publishConfigChange announces that a config was created, updated,
+// enabled, disabled or deleted. Deleted configs must be looked up before
+// they are gone, so the caller passes the details.
+func (s *Server) publishConfigChange(configID int, action, name string, groupPath *string, watcherID *int) {
+	s.events.publish(eventConfigChange, watcherID, groupPath, map[string]any{
+		"probe_config_id": configID,
+		"action":          action,
+		"name":            name,
+		"group_path":      groupPath,
+		"watcher_id":      watcherID,
+	})
+}
+
+// publishConfigChanged announces a change to an existing config.
+func (s *Server) publishConfigChanged(ctx context.Context, configID int, action string) {
+	name, groupPath, watcherID := s.configEventInfo(ctx, configID)
+	s.publishConfigChange(configID, action, name, groupPath, watcherID)
+}
+
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := eventFilter{group: query.Get("group")}
+	if v := query.Get("watcher"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil || id <= 0 {
+			http.Error(w, "invalid watcher", http.StatusBadRequest)
+			return
+		}
+		filter.watcherID = id
+	}
+	if v := query.Get("types"); v != "" {
+		filter.types = make(map[string]bool)
+		for _, t := range strings.Split(v, ",") {
+			filter.types[strings.TrimSpace(t)] = true
+		}
+	}
+
+	// Browsers send Last-Event-ID when they reconnect; scripts may find the
+	// query parameter easier
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = query.Get("last_event_id")
+	}
+	var lastID int64
+	if lastEventID != "" {
+		id, err := strconv.ParseInt(lastEventID, 10, 64)
+		if err != nil {
+			http.Error(w, "invalid Last-Event-ID", http.StatusBadRequest)
+			return
+		}
+		lastID = id
+	}
+
+	s.streamEvents(w, r, &filter, lastID)
+}
+
+// streamEvents sends the events that pass the filter until the client
+// disconnects, starting with the buffered ones after lastID.
+func (s *Server) streamEvents(w http.ResponseWriter, r *http.Request, filter *eventFilter, lastID int64) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	ch, missed, complete := s.events.subscribe(lastID, filter)
+	defer s.events.unsubscribe(ch)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	if !complete {
+		fmt.Fprintf(w, "event: %s\ndata: {}\n\n", eventReset)
+	}
+	for i := range missed {
+		writeEvent(w, &missed[i])
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(eventKeepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e, ok := <-ch:
+			if !ok {
+				return
+			}
+			writeEvent(w, &e)
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+		}
+		flusher.Flush()
+	}
+}
+
+// writeEvent writes e in the text/event-stream format.
+func writeEvent(w http.ResponseWriter, e *event) {
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.id, e.kind, e.data)
+}
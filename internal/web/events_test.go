@@ -0,0 +1,114 @@
+package web
+
+import (
+	"testing"
+)
+
+func TestEventHubResume(t *testing.T) {
+	h := newEventHub()
+	first, _, _ := h.subscribe(0, nil)
+
+	for i := 0; i < 3; i++ {
+		h.publish(eventResult, nil, nil, map[string]any{"n": i})
+	}
+	var ids []int64
+	for i := 0; i < 3; i++ {
+		ids = append(ids, (<-first).id)
+	}
+
+	ch, missed, complete := h.subscribe(ids[0], nil)
+	if !complete || len(missed) != 2 || missed[0].id != ids[1] {
+		t.Errorf("expected events after %d, got %d, complete %v", ids[0], len(missed), complete)
+	}
+	h.unsubscribe(ch)
+
+	ch, missed, complete = h.subscribe(ids[2], nil)
+	if !complete || len(missed) != 0 {
+		t.Errorf("expected no missed events, got %d, complete %v", len(missed), complete)
+	}
+	h.unsubscribe(ch)
+
+	// Events before the buffer are lost
+	for i := 0; i < eventBufferSize; i++ {
+		h.publish(eventResult, nil, nil, nil)
+	}
+	ch, missed, complete = h.subscribe(ids[0], nil)
+	if complete {
+		t.Error("expected a gap after the buffer overflowed")
+	}
+	if len(missed) != eventBufferSize {
+		t.Errorf("expected %d buffered events, got %d", eventBufferSize, len(missed))
+	}
+	h.unsubscribe(ch)
+
+	// The first subscriber fell behind and was disconnected
+	for range first {
+	}
+
+	h.close()
+	if _, ok := <-ch; ok {
+		t.Error("expected the channel to be closed")
+	}
+}
+
+func TestEventHubFilter(t *testing.T) {
+	h := newEventHub()
+	watcher1, watcher2 := 1, 2
+	mine, _, _ := h.subscribe(0, &eventFilter{watcherID: watcher1, types: map[string]bool{eventTrigger: true}})
+
+	// Other watchers' traffic doesn't fill the queue
+	for i := 0; i < 2*eventSubscriberQueue; i++ {
+		h.publish(eventResult, &watcher2, nil, nil)
+		h.publish(eventTrigger, &watcher2, nil, nil)
+	}
+	h.publish(eventTrigger, &watcher1, nil, map[string]any{"probe_config_id": 7})
+
+	e, ok := <-mine
+	if !ok || e.kind != eventTrigger || *e.watcherID != watcher1 {
+		t.Fatalf("expected the trigger for watcher 1, got %+v (open %v)", e, ok)
+	}
+	select {
+	case e := <-mine:
+		t.Errorf("unexpected event %+v", e)
+	default:
+	}
+
+	// Resuming returns only matching events, but still detects gaps in all
+	last := e.id
+	h.publish(eventResult, &watcher2, nil, nil)
+	h.publish(eventTrigger, &watcher1, nil, nil)
+	ch, missed, complete := h.subscribe(last, &eventFilter{watcherID: watcher1})
+	if !complete || len(missed) != 1 || missed[0].kind != eventTrigger {
+		t.Errorf("expected the one trigger after %d, got %d, complete %v", last, len(missed), complete)
+	}
+	h.unsubscribe(ch)
+	h.unsubscribe(mine)
+}
+
+func TestEventFilter(t *testing.T) {
+	watcher1, watcher2 := 1, 2
+	infra, infraDB, web := "infra", "infra/db", "infrastructure"
+
+	tests := []struct {
+		name     string
+		filter   eventFilter
+		event    event
+		expected bool
+	}{
+		{"no filter", eventFilter{}, event{kind: eventResult}, true},
+		{"type", eventFilter{types: map[string]bool{eventStatusChange: true}}, event{kind: eventResult}, false},
+		{"watcher", eventFilter{watcherID: 1}, event{kind: eventResult, watcherID: &watcher1}, true},
+		{"other watcher", eventFilter{watcherID: 1}, event{kind: eventResult, watcherID: &watcher2}, false},
+		{"no watcher", eventFilter{watcherID: 1}, event{kind: eventResult}, false},
+		{"group", eventFilter{group: "infra"}, event{kind: eventResult, groupPath: &infra}, true},
+		{"subgroup", eventFilter{group: "infra"}, event{kind: eventResult, groupPath: &infraDB}, true},
+		{"prefix only", eventFilter{group: "infra"}, event{kind: eventResult, groupPath: &web}, false},
+		{"no group", eventFilter{group: "infra"}, event{kind: eventConfigChange}, false},
+		{"heartbeat", eventFilter{group: "infra"}, event{kind: eventHeartbeat, watcherID: &watcher1}, true},
+	}
+	for _, tt := range tests {
+		if got := tt.filter.matches(&tt.event); got != tt.expected {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.expected, got)
+		}
+	}
+}
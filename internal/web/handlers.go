@@ -1,6 +1,7 @@
 package web
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log/slog"
@@ -9,6 +10,7 @@ import (
 	"time"
 
 	"github.com/jandubois/monitor/internal/db"
+	"github.com/jandubois/monitor/internal/notify"
 )
 
 func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
@@ -38,7 +40,7 @@ func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
 		if err := rows.Scan(&name, &lastSeen, &version); err != nil {
 			continue
 		}
-		healthy := lastSeen.Valid && time.Since(lastSeen.Time) < 30*time.Second
+		healthy := lastSeen.Valid && time.Since(lastSeen.Time) < watcherHealthyTimeout
 		if !healthy {
 			allHealthy = false
 		}
@@ -179,6 +181,7 @@ func (s *Server) handleListWatchers(w http.ResponseWriter, r *http.Request) {
 
 	rows, err := s.db.DB().QueryContext(ctx, `
 		SELECT w.id, w.name, w.last_seen_at, w.version, w.registered_at, w.paused, w.approved,
+		       w.down_since, w.notification_channels, w.queue_depth,
 		       (SELECT COUNT(*) FROM watcher_probe_types WHERE watcher_id = w.id) as probe_type_count,
 		       (SELECT COUNT(*) FROM probe_configs WHERE watcher_id = w.id) as config_count
 		FROM watchers w
@@ -198,23 +201,31 @@ func (s *Server) handleListWatchers(w http.ResponseWriter, r *http.Request) {
 		var version *string
 		var registeredAt db.NullTime
 		var paused, approved int
-		var probeTypeCount, configCount int
+		var downSince db.NullTime
+		var notificationChannels db.JSONIntArray
+		var queueDepth, probeTypeCount, configCount int
 
-		if err := rows.Scan(&id, &name, &lastSeen, &version, &registeredAt, &paused, &approved, &probeTypeCount, &configCount); err != nil {
+		if err := rows.Scan(&id, &name, &lastSeen, &version, &registeredAt, &paused, &approved,
+			&downSince, &notificationChannels, &queueDepth, &probeTypeCount, &configCount); err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
-		healthy := lastSeen.Valid && time.Since(lastSeen.Time) < 30*time.Second
+		healthy := lastSeen.Valid && time.Since(lastSeen.Time) < watcherHealthyTimeout
 
 		watcher := map[string]any{
-			"id":               id,
-			"name":             name,
-			"healthy":          healthy,
-			"paused":           paused != 0,
-			"approved":         approved != 0,
-			"probe_type_count": probeTypeCount,
-			"config_count":     configCount,
+			"id":                    id,
+			"name":                  name,
+			"healthy":               healthy,
+			"paused":                paused != 0,
+			"approved":              approved != 0,
+			"probe_type_count":      probeTypeCount,
+			"config_count":          configCount,
+			"notification_channels": notificationChannels,
+			"queue_depth":           queueDepth,
+		}
+		if downSince.Valid {
+			watcher["down_since"] = downSince.Time
 		}
 		if registeredAt.Valid {
 			watcher["registered_at"] = registeredAt.Time
@@ -242,11 +253,14 @@ func (s *Server) handleGetWatcher(w http.ResponseWriter, r *http.Request) {
 	var version *string
 	var registeredAt db.NullTime
 	var paused, approved int
+	var downSince db.NullTime
+	var notificationChannels db.JSONIntArray
+	var queueDepth int
 
 	err := s.db.DB().QueryRowContext(ctx, `
-		SELECT id, name, last_seen_at, version, registered_at, paused, approved
+		SELECT id, name, last_seen_at, version, registered_at, paused, approved, down_since, notification_channels, queue_depth
 		FROM watchers WHERE id = ?
-	`, id).Scan(&id, &name, &lastSeen, &version, &registeredAt, &paused, &approved)
+	`, id).Scan(&id, &name, &lastSeen, &version, &registeredAt, &paused, &approved, &downSince, &notificationChannels, &queueDepth)
 	if err != nil {
 		http.Error(w, "not found", http.StatusNotFound)
 		return
@@ -286,15 +300,20 @@ func (s *Server) handleGetWatcher(w http.ResponseWriter, r *http.Request) {
 		probeTypes = append(probeTypes, pt)
 	}
 
-	healthy := lastSeen.Valid && time.Since(lastSeen.Time) < 30*time.Second
+	healthy := lastSeen.Valid && time.Since(lastSeen.Time) < watcherHealthyTimeout
 
 	watcher := map[string]any{
-		"id":          id,
-		"name":        name,
-		"healthy":     healthy,
-		"paused":      paused != 0,
-		"approved":    approved != 0,
-		"probe_types": probeTypes,
+		"id":                    id,
+		"name":                  name,
+		"healthy":               healthy,
+		"paused":                paused != 0,
+		"approved":              approved != 0,
+		"probe_types":           probeTypes,
+		"notification_channels": notificationChannels,
+		"queue_depth":           queueDepth,
+	}
+	if downSince.Valid {
+		watcher["down_since"] = downSince.Time
 	}
 	if registeredAt.Valid {
 		watcher["registered_at"] = registeredAt.Time
@@ -313,6 +332,7 @@ func (s *Server) handleGetWatcher(w http.ResponseWriter, r *http.Request) {
 func (s *Server) handleDeleteWatcher(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	id, _ := strconv.Atoi(r.PathValue("id"))
+	before := s.auditState(ctx, auditWatcher, id)
 
 	result, err := s.db.DB().ExecContext(ctx, `DELETE FROM watchers WHERE id = ?`, id)
 	if err != nil {
@@ -326,6 +346,7 @@ func (s *Server) handleDeleteWatcher(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	s.audit(ctx, "delete", auditWatcher, id, before, nil)
 	slog.Info("watcher deleted", "id", id)
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -347,6 +368,8 @@ func (s *Server) handleSetWatcherPaused(w http.ResponseWriter, r *http.Request)
 		pausedInt = 1
 	}
 
+	before := s.auditState(ctx, auditWatcher, id)
+
 	// When unpausing, also approve the watcher
 	var result interface{ RowsAffected() (int64, error) }
 	var err error
@@ -367,6 +390,14 @@ func (s *Server) handleSetWatcherPaused(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	action := "resume"
+	if req.Paused {
+		action = "pause"
+	} else if before["approved"] == false {
+		action = "approve"
+	}
+	s.audit(ctx, action, auditWatcher, id, before, s.auditState(ctx, auditWatcher, id))
+
 	if req.Paused {
 		slog.Info("watcher paused", "id", id)
 	} else {
@@ -385,7 +416,10 @@ func (s *Server) handleListProbeConfigs(w http.ResponseWriter, r *http.Request)
 		SELECT pc.id, pc.probe_type_id, pt.name as probe_type_name, pc.name, pc.enabled,
 		       pc.arguments, pc.interval, pc.timeout_seconds, pc.notification_channels,
 		       pc.watcher_id, w.name as watcher_name, pc.next_run_at, pc.group_path, pc.keywords,
-		       pc.created_at, pc.updated_at,
+		       pc.created_at, pc.updated_at, pc.timezone, pc.active_windows, pc.escalation_policy_id,
+		       pc.consecutive_results, pc.flap_window, pc.flap_threshold, pc.flapping_since, pc.anomaly_threshold,
+		       pc.composite_mode, pc.composite_quorum,
+		       (SELECT suppressed_by FROM probe_results WHERE probe_config_id = pc.id ORDER BY executed_at DESC LIMIT 1) as suppressed_by,
 		       (SELECT status FROM probe_results WHERE probe_config_id = pc.id ORDER BY executed_at DESC LIMIT 1) as last_status,
 		       (SELECT message FROM probe_results WHERE probe_config_id = pc.id ORDER BY executed_at DESC LIMIT 1) as last_message,
 		       (SELECT executed_at FROM probe_results WHERE probe_config_id = pc.id ORDER BY executed_at DESC LIMIT 1) as last_executed_at
@@ -433,7 +467,15 @@ func (s *Server) handleListProbeConfigs(w http.ResponseWriter, r *http.Request)
 		var notificationChannels db.JSONIntArray
 		var watcherID *int
 		var watcherName, groupPath *string
-		var keywords db.JSONStringArray
+		var keywords, activeWindows db.JSONStringArray
+		var timezone *string
+		var escalationPolicyID *int
+		var consecutiveResults, flapWindow, flapThreshold int
+		var flappingSince db.NullTime
+		var anomalyThreshold float64
+		var compositeMode *string
+		var compositeQuorum int
+		var suppressedBy *int
 		var nextRunAt db.NullTime
 		var createdAt db.NullTime
 		var updatedAt, lastExecutedAt db.NullTime
@@ -443,7 +485,9 @@ func (s *Server) handleListProbeConfigs(w http.ResponseWriter, r *http.Request)
 			&id, &probeTypeID, &probeTypeName, &name, &enabled,
 			&arguments, &interval, &timeoutSeconds, &notificationChannels,
 			&watcherID, &watcherName, &nextRunAt, &groupPath, &keywords,
-			&createdAt, &updatedAt,
+			&createdAt, &updatedAt, &timezone, &activeWindows, &escalationPolicyID,
+			&consecutiveResults, &flapWindow, &flapThreshold, &flappingSince, &anomalyThreshold,
+			&compositeMode, &compositeQuorum, &suppressedBy,
 			&lastStatus, &lastMessage, &lastExecutedAt,
 		); err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -461,6 +505,27 @@ func (s *Server) handleListProbeConfigs(w http.ResponseWriter, r *http.Request)
 			"timeout_seconds":       timeoutSeconds,
 			"notification_channels": notificationChannels,
 			"keywords":              keywords,
+			"active_windows":        activeWindows,
+			"consecutive_results":   consecutiveResults,
+			"flap_window":           flapWindow,
+			"flap_threshold":        flapThreshold,
+			"anomaly_threshold":     anomalyThreshold,
+		}
+		if timezone != nil {
+			config["timezone"] = *timezone
+		}
+		if escalationPolicyID != nil {
+			config["escalation_policy_id"] = *escalationPolicyID
+		}
+		if compositeMode != nil {
+			config["composite_mode"] = *compositeMode
+			config["composite_quorum"] = compositeQuorum
+		}
+		if suppressedBy != nil {
+			config["suppressed_by"] = *suppressedBy
+		}
+		if flappingSince.Valid {
+			config["flapping_since"] = flappingSince.Time
 		}
 		if createdAt.Valid {
 			config["created_at"] = createdAt.Time
@@ -506,9 +571,16 @@ func (s *Server) handleCreateProbeConfig(w http.ResponseWriter, r *http.Request)
 		Name                 string         `json:"name"`
 		Enabled              bool           `json:"enabled"`
 		Arguments            map[string]any `json:"arguments"`
-		Interval             string         `json:"interval"`
+		Interval             string         `json:"interval"` // Interval or cron expression
+		Timezone             *string        `json:"timezone"`
+		ActiveWindows        []string       `json:"active_windows"`
 		TimeoutSeconds       int            `json:"timeout_seconds"`
 		NotificationChannels []int          `json:"notification_channels"`
+		EscalationPolicyID   *int           `json:"escalation_policy_id"`
+		ConsecutiveResults   int            `json:"consecutive_results"` // Defaults to 1
+		FlapWindow           int            `json:"flap_window"`
+		FlapThreshold        int            `json:"flap_threshold"`
+		AnomalyThreshold     float64        `json:"anomaly_threshold"` // Standard deviations; 0 disables it
 		GroupPath            *string        `json:"group_path"`
 		Keywords             []string       `json:"keywords"`
 	}
@@ -521,6 +593,28 @@ func (s *Server) handleCreateProbeConfig(w http.ResponseWriter, r *http.Request)
 		req.TimeoutSeconds = 60
 	}
 
+	sched, err := parseSchedule(req.Interval, req.Timezone, req.ActiveWindows)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := s.checkEscalationPolicyID(ctx, req.EscalationPolicyID); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.ConsecutiveResults == 0 {
+		req.ConsecutiveResults = 1
+	}
+	filter := statusFilter{consecutive: req.ConsecutiveResults, flapWindow: req.FlapWindow, flapThreshold: req.FlapThreshold}
+	if err := filter.validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.AnomalyThreshold < 0 {
+		http.Error(w, "anomaly_threshold must not be negative", http.StatusBadRequest)
+		return
+	}
+
 	enabledInt := 0
 	if req.Enabled {
 		enabledInt = 1
@@ -529,17 +623,24 @@ func (s *Server) handleCreateProbeConfig(w http.ResponseWriter, r *http.Request)
 	argumentsJSON, _ := json.Marshal(req.Arguments)
 	notificationChannelsJSON, _ := json.Marshal(req.NotificationChannels)
 	keywordsJSON, _ := json.Marshal(req.Keywords)
+	activeWindowsJSON, _ := json.Marshal(req.ActiveWindows)
 
 	result, err := s.db.DB().ExecContext(ctx, `
-		INSERT INTO probe_configs (probe_type_id, watcher_id, name, enabled, arguments, interval, timeout_seconds, notification_channels, group_path, keywords)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-	`, req.ProbeTypeID, req.WatcherID, req.Name, enabledInt, string(argumentsJSON), req.Interval, req.TimeoutSeconds, string(notificationChannelsJSON), req.GroupPath, string(keywordsJSON))
+		INSERT INTO probe_configs (probe_type_id, watcher_id, name, enabled, arguments, interval, timezone, active_windows,
+		                           timeout_seconds, notification_channels, escalation_policy_id,
+		                           consecutive_results, flap_window, flap_threshold, anomaly_threshold, group_path, keywords, next_run_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, req.ProbeTypeID, req.WatcherID, req.Name, enabledInt, string(argumentsJSON), req.Interval, req.Timezone, string(activeWindowsJSON),
+		req.TimeoutSeconds, string(notificationChannelsJSON), req.EscalationPolicyID,
+		req.ConsecutiveResults, req.FlapWindow, req.FlapThreshold, req.AnomalyThreshold, req.GroupPath, string(keywordsJSON), formatNextRun(sched, time.Now()))
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	id, _ := result.LastInsertId()
+	s.audit(ctx, "create", auditProbeConfig, int(id), nil, s.auditState(ctx, auditProbeConfig, int(id)))
+	s.publishConfigChange(int(id), "created", req.Name, req.GroupPath, req.WatcherID)
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
@@ -557,7 +658,15 @@ func (s *Server) handleGetProbeConfig(w http.ResponseWriter, r *http.Request) {
 	var notificationChannels db.JSONIntArray
 	var watcherID *int
 	var watcherName, groupPath *string
-	var keywords db.JSONStringArray
+	var keywords, activeWindows db.JSONStringArray
+	var timezone *string
+	var escalationPolicyID *int
+	var consecutiveResults, flapWindow, flapThreshold int
+	var flappingSince db.NullTime
+	var anomalyThreshold float64
+	var compositeMode *string
+	var compositeQuorum int
+	var suppressedBy *int
 	var nextRunAt db.NullTime
 	var createdAt db.NullTime
 	var updatedAt db.NullTime
@@ -566,7 +675,9 @@ func (s *Server) handleGetProbeConfig(w http.ResponseWriter, r *http.Request) {
 		SELECT pc.id, pc.probe_type_id, pt.name, pc.name, pc.enabled, pc.arguments,
 		       pc.interval, pc.timeout_seconds, pc.notification_channels,
 		       pc.watcher_id, w.name, pc.next_run_at, pc.group_path, pc.keywords,
-		       pc.created_at, pc.updated_at
+		       pc.created_at, pc.updated_at, pc.timezone, pc.active_windows, pc.escalation_policy_id,
+		       pc.consecutive_results, pc.flap_window, pc.flap_threshold, pc.flapping_since, pc.anomaly_threshold,
+		       pc.composite_mode, pc.composite_quorum, `+suppressedBySQL+`
 		FROM probe_configs pc
 		JOIN probe_types pt ON pt.id = pc.probe_type_id
 		LEFT JOIN watchers w ON w.id = pc.watcher_id
@@ -574,7 +685,9 @@ func (s *Server) handleGetProbeConfig(w http.ResponseWriter, r *http.Request) {
 	`, id).Scan(&id, &probeTypeID, &probeTypeName, &name, &enabled, &arguments,
 		&interval, &timeoutSeconds, &notificationChannels,
 		&watcherID, &watcherName, &nextRunAt, &groupPath, &keywords,
-		&createdAt, &updatedAt)
+		&createdAt, &updatedAt, &timezone, &activeWindows, &escalationPolicyID,
+		&consecutiveResults, &flapWindow, &flapThreshold, &flappingSince, &anomalyThreshold,
+		&compositeMode, &compositeQuorum, &suppressedBy)
 	if err != nil {
 		http.Error(w, "not found", http.StatusNotFound)
 		return
@@ -591,6 +704,27 @@ func (s *Server) handleGetProbeConfig(w http.ResponseWriter, r *http.Request) {
 		"timeout_seconds":       timeoutSeconds,
 		"notification_channels": notificationChannels,
 		"keywords":              keywords,
+		"active_windows":        activeWindows,
+		"consecutive_results":   consecutiveResults,
+		"flap_window":           flapWindow,
+		"flap_threshold":        flapThreshold,
+		"anomaly_threshold":     anomalyThreshold,
+	}
+	if timezone != nil {
+		config["timezone"] = *timezone
+	}
+	if escalationPolicyID != nil {
+		config["escalation_policy_id"] = *escalationPolicyID
+	}
+	if flappingSince.Valid {
+		config["flapping_since"] = flappingSince.Time
+	}
+	if compositeMode != nil {
+		config["composite_mode"] = *compositeMode
+		config["composite_quorum"] = compositeQuorum
+	}
+	if suppressedBy != nil {
+		config["suppressed_by"] = *suppressedBy
 	}
 	if createdAt.Valid {
 		config["created_at"] = createdAt.Time
@@ -611,6 +745,25 @@ func (s *Server) handleGetProbeConfig(w http.ResponseWriter, r *http.Request) {
 		config["group_path"] = *groupPath
 	}
 
+	dependsOn, err := s.configIDs(ctx, `
+		SELECT depends_on_id FROM probe_dependencies WHERE probe_config_id = ? ORDER BY depends_on_id
+	`, id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	config["depends_on"] = dependsOn
+	if compositeMode != nil {
+		members, err := s.configIDs(ctx, `
+			SELECT member_id FROM composite_members WHERE composite_id = ? ORDER BY member_id
+		`, id)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		config["members"] = members
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(config)
 }
@@ -624,9 +777,16 @@ func (s *Server) handleUpdateProbeConfig(w http.ResponseWriter, r *http.Request)
 		Name                 string         `json:"name"`
 		Enabled              bool           `json:"enabled"`
 		Arguments            map[string]any `json:"arguments"`
-		Interval             string         `json:"interval"`
+		Interval             string         `json:"interval"` // Interval or cron expression
+		Timezone             *string        `json:"timezone"`
+		ActiveWindows        []string       `json:"active_windows"`
 		TimeoutSeconds       int            `json:"timeout_seconds"`
 		NotificationChannels []int          `json:"notification_channels"`
+		EscalationPolicyID   *int           `json:"escalation_policy_id"`
+		ConsecutiveResults   int            `json:"consecutive_results"` // Defaults to 1
+		FlapWindow           int            `json:"flap_window"`
+		FlapThreshold        int            `json:"flap_threshold"`
+		AnomalyThreshold     float64        `json:"anomaly_threshold"` // Standard deviations; 0 disables it
 		GroupPath            *string        `json:"group_path"`
 		Keywords             []string       `json:"keywords"`
 	}
@@ -635,6 +795,28 @@ func (s *Server) handleUpdateProbeConfig(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	sched, err := parseSchedule(req.Interval, req.Timezone, req.ActiveWindows)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := s.checkEscalationPolicyID(ctx, req.EscalationPolicyID); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.ConsecutiveResults == 0 {
+		req.ConsecutiveResults = 1
+	}
+	filter := statusFilter{consecutive: req.ConsecutiveResults, flapWindow: req.FlapWindow, flapThreshold: req.FlapThreshold}
+	if err := filter.validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.AnomalyThreshold < 0 {
+		http.Error(w, "anomaly_threshold must not be negative", http.StatusBadRequest)
+		return
+	}
+
 	enabledInt := 0
 	if req.Enabled {
 		enabledInt = 1
@@ -643,17 +825,36 @@ func (s *Server) handleUpdateProbeConfig(w http.ResponseWriter, r *http.Request)
 	argumentsJSON, _ := json.Marshal(req.Arguments)
 	notificationChannelsJSON, _ := json.Marshal(req.NotificationChannels)
 	keywordsJSON, _ := json.Marshal(req.Keywords)
+	activeWindowsJSON, _ := json.Marshal(req.ActiveWindows)
 
-	_, err := s.db.DB().ExecContext(ctx, `
+	// A watcher that loses the config must hear about it too
+	_, _, oldWatcherID := s.configEventInfo(ctx, id)
+	before := s.auditState(ctx, auditProbeConfig, id)
+
+	// A changed schedule invalidates next_run_at; SET expressions see the old row
+	_, err = s.db.DB().ExecContext(ctx, `
 		UPDATE probe_configs
-		SET watcher_id = ?, name = ?, enabled = ?, arguments = ?, interval = ?,
-		    timeout_seconds = ?, notification_channels = ?, group_path = ?, keywords = ?, updated_at = datetime('now')
+		SET next_run_at = CASE WHEN interval IS NOT ? OR timezone IS NOT ? OR active_windows IS NOT ?
+		                       THEN ? ELSE next_run_at END,
+		    watcher_id = ?, name = ?, enabled = ?, arguments = ?, interval = ?, timezone = ?, active_windows = ?,
+		    timeout_seconds = ?, notification_channels = ?, escalation_policy_id = ?,
+		    consecutive_results = ?, flap_window = ?, flap_threshold = ?, anomaly_threshold = ?, group_path = ?, keywords = ?, updated_at = datetime('now')
 		WHERE id = ?
-	`, req.WatcherID, req.Name, enabledInt, string(argumentsJSON), req.Interval, req.TimeoutSeconds, string(notificationChannelsJSON), req.GroupPath, string(keywordsJSON), id)
+	`, req.Interval, req.Timezone, string(activeWindowsJSON), formatNextRun(sched, time.Now()),
+		req.WatcherID, req.Name, enabledInt, string(argumentsJSON), req.Interval, req.Timezone, string(activeWindowsJSON),
+		req.TimeoutSeconds, string(notificationChannelsJSON), req.EscalationPolicyID,
+		req.ConsecutiveResults, req.FlapWindow, req.FlapThreshold, req.AnomalyThreshold, req.GroupPath, string(keywordsJSON), id)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	if before != nil {
+		s.audit(ctx, "update", auditProbeConfig, id, before, s.auditState(ctx, auditProbeConfig, id))
+	}
+	s.publishConfigChange(id, "updated", req.Name, req.GroupPath, req.WatcherID)
+	if oldWatcherID != nil && (req.WatcherID == nil || *req.WatcherID != *oldWatcherID) {
+		s.publishConfigChange(id, "updated", req.Name, req.GroupPath, oldWatcherID)
+	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -662,11 +863,19 @@ func (s *Server) handleDeleteProbeConfig(w http.ResponseWriter, r *http.Request)
 	ctx := r.Context()
 	id, _ := strconv.Atoi(r.PathValue("id"))
 
+	// Subscribers filter on the config's group and watcher
+	name, groupPath, watcherID := s.configEventInfo(ctx, id)
+	before := s.auditState(ctx, auditProbeConfig, id)
+
 	_, err := s.db.DB().ExecContext(ctx, `DELETE FROM probe_configs WHERE id = ?`, id)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	if before != nil {
+		s.audit(ctx, "delete", auditProbeConfig, id, before, nil)
+	}
+	s.publishConfigChange(id, "deleted", name, groupPath, watcherID)
 
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -676,17 +885,26 @@ func (s *Server) handleRunProbeConfig(w http.ResponseWriter, r *http.Request) {
 	id, _ := strconv.Atoi(r.PathValue("id"))
 
 	// Get watcher callback URL for this probe config
+	var watcherID *int
 	var callbackURL *string
 	err := s.db.DB().QueryRowContext(ctx, `
-		SELECT w.callback_url
+		SELECT pc.watcher_id, w.callback_url
 		FROM probe_configs pc
 		JOIN watchers w ON w.id = pc.watcher_id
 		WHERE pc.id = ? AND pc.enabled = 1
-	`, id).Scan(&callbackURL)
+	`, id).Scan(&watcherID, &callbackURL)
 	if err != nil {
 		http.Error(w, "probe config not found or disabled", http.StatusNotFound)
 		return
 	}
+	s.audit(ctx, "run", auditProbeConfig, id, nil, nil)
+
+	// A watcher holding a config channel gets the trigger through it
+	if s.triggerOverChannel(watcherID, id) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"status": "triggered"})
+		return
+	}
 
 	// If watcher has callback URL, trigger directly
 	if callbackURL != nil && *callbackURL != "" {
@@ -742,6 +960,8 @@ func (s *Server) handleSetProbeEnabled(w http.ResponseWriter, r *http.Request) {
 		enabledInt = 1
 	}
 
+	before := s.auditState(ctx, auditProbeConfig, id)
+
 	// Update enabled state
 	_, err := s.db.DB().ExecContext(ctx, `
 		UPDATE probe_configs SET enabled = ?, updated_at = datetime('now') WHERE id = ?
@@ -750,26 +970,42 @@ func (s *Server) handleSetProbeEnabled(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	if before != nil {
+		action := "disable"
+		if req.Enabled {
+			action = "enable"
+		}
+		s.audit(ctx, action, auditProbeConfig, id, before, s.auditState(ctx, auditProbeConfig, id))
+	}
+	if req.Enabled {
+		s.publishConfigChanged(ctx, id, "enabled")
+	} else {
+		s.publishConfigChanged(ctx, id, "disabled")
+	}
 
 	// If enabling (resuming), trigger immediate run
 	if req.Enabled {
 		// Get watcher callback URL
+		var watcherID *int
 		var callbackURL *string
 		s.db.DB().QueryRowContext(ctx, `
-			SELECT w.callback_url
+			SELECT pc.watcher_id, w.callback_url
 			FROM probe_configs pc
 			JOIN watchers w ON w.id = pc.watcher_id
 			WHERE pc.id = ?
-		`, id).Scan(&callbackURL)
+		`, id).Scan(&watcherID, &callbackURL)
 
-		if callbackURL != nil && *callbackURL != "" {
+		switch {
+		case s.triggerOverChannel(watcherID, id):
+			// Sent through the watcher's config channel
+		case callbackURL != nil && *callbackURL != "":
 			triggerURL := fmt.Sprintf("%s/trigger/%d", *callbackURL, id)
 			triggerReq, _ := http.NewRequestWithContext(ctx, "POST", triggerURL, nil)
 			triggerReq.Header.Set("Authorization", "Bearer "+s.config.AuthToken)
 			if resp, err := http.DefaultClient.Do(triggerReq); err == nil {
 				resp.Body.Close()
 			}
-		} else {
+		default:
 			// Fall back to poll-based trigger
 			s.db.DB().ExecContext(ctx, `UPDATE probe_configs SET next_run_at = datetime('now') WHERE id = ?`, id)
 		}
@@ -790,8 +1026,9 @@ func (s *Server) handleQueryResults(w http.ResponseWriter, r *http.Request) {
 
 	query := `
 		SELECT pr.id, pr.probe_config_id, pc.name as config_name, pr.status, pr.message,
-		       pr.metrics, pr.data, pr.duration_ms, pr.scheduled_at, pr.executed_at, pr.recorded_at
-		FROM probe_results pr
+		       pr.metrics, pr.data, pr.duration_ms, pr.scheduled_at, pr.executed_at, pr.recorded_at, pr.rollup,
+		       pr.anomalies, pr.suppressed_by
+		FROM probe_result_history pr
 		JOIN probe_configs pc ON pc.id = pr.probe_config_id
 		WHERE 1=1
 	`
@@ -837,12 +1074,13 @@ func (s *Server) handleQueryResults(w http.ResponseWriter, r *http.Request) {
 	for rows.Next() {
 		var id, probeConfigID, durationMs int
 		var configName, statusVal string
-		var message *string
-		var metrics, data db.JSONMap
+		var message, anomalies *string
+		var suppressedBy *int
+		var metrics, data, rollup db.JSONMap
 		var scheduledAt, executedAt, recordedAt db.NullTime
 
 		if err := rows.Scan(&id, &probeConfigID, &configName, &statusVal, &message,
-			&metrics, &data, &durationMs, &scheduledAt, &executedAt, &recordedAt); err != nil {
+			&metrics, &data, &durationMs, &scheduledAt, &executedAt, &recordedAt, &rollup, &anomalies, &suppressedBy); err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
@@ -870,6 +1108,16 @@ func (s *Server) handleQueryResults(w http.ResponseWriter, r *http.Request) {
 		if recordedAt.Valid {
 			result["recorded_at"] = recordedAt.Time
 		}
+		if rollup != nil {
+			// Hourly aggregate of results older than the retention period
+			result["rollup"] = rollup
+		}
+		if anomalies != nil {
+			result["anomalies"] = json.RawMessage(*anomalies)
+		}
+		if suppressedBy != nil {
+			result["suppressed_by"] = *suppressedBy
+		}
 
 		results = append(results, result)
 	}
@@ -884,8 +1132,8 @@ func (s *Server) handleGetResults(w http.ResponseWriter, r *http.Request) {
 
 	rows, err := s.db.DB().QueryContext(ctx, `
 		SELECT id, probe_config_id, status, message, metrics, data,
-		       duration_ms, scheduled_at, executed_at, recorded_at
-		FROM probe_results
+		       duration_ms, scheduled_at, executed_at, recorded_at, rollup, anomalies, suppressed_by
+		FROM probe_result_history
 		WHERE probe_config_id = ?
 		ORDER BY executed_at DESC
 		LIMIT 100
@@ -900,12 +1148,13 @@ func (s *Server) handleGetResults(w http.ResponseWriter, r *http.Request) {
 	for rows.Next() {
 		var id, probeConfigID, durationMs int
 		var statusVal string
-		var message *string
-		var metrics, data db.JSONMap
+		var message, anomalies *string
+		var suppressedBy *int
+		var metrics, data, rollup db.JSONMap
 		var scheduledAt, executedAt, recordedAt db.NullTime
 
 		if err := rows.Scan(&id, &probeConfigID, &statusVal, &message, &metrics, &data,
-			&durationMs, &scheduledAt, &executedAt, &recordedAt); err != nil {
+			&durationMs, &scheduledAt, &executedAt, &recordedAt, &rollup, &anomalies, &suppressedBy); err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
@@ -932,6 +1181,16 @@ func (s *Server) handleGetResults(w http.ResponseWriter, r *http.Request) {
 		if recordedAt.Valid {
 			result["recorded_at"] = recordedAt.Time
 		}
+		if rollup != nil {
+			// Hourly aggregate of results older than the retention period
+			result["rollup"] = rollup
+		}
+		if anomalies != nil {
+			result["anomalies"] = json.RawMessage(*anomalies)
+		}
+		if suppressedBy != nil {
+			result["suppressed_by"] = *suppressedBy
+		}
 
 		results = append(results, result)
 	}
@@ -978,6 +1237,9 @@ func (s *Server) handleResultStats(w http.ResponseWriter, r *http.Request) {
 
 func (s *Server) handleListNotificationChannels(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
+	// Only admins, who can edit the channels, see their credentials
+	caller := identityFromContext(ctx)
+	showSecrets := caller != nil && roleAtLeast(caller.role, roleAdmin)
 
 	rows, err := s.db.DB().QueryContext(ctx, `
 		SELECT id, name, type, config, enabled FROM notification_channels ORDER BY name
@@ -999,6 +1261,9 @@ func (s *Server) handleListNotificationChannels(w http.ResponseWriter, r *http.R
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
+		if !showSecrets {
+			config = notify.RedactConfig(config)
+		}
 
 		channels = append(channels, map[string]any{
 			"id":      id,
@@ -1044,6 +1309,8 @@ func (s *Server) handleCreateNotificationChannel(w http.ResponseWriter, r *http.
 	}
 
 	id, _ := result.LastInsertId()
+	s.audit(ctx, "create", auditNotificationChannel, int(id), nil, s.auditState(ctx, auditNotificationChannel, int(id)))
+	s.reloadNotificationChannels(ctx)
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
@@ -1071,6 +1338,7 @@ func (s *Server) handleUpdateNotificationChannel(w http.ResponseWriter, r *http.
 	}
 
 	configJSON, _ := json.Marshal(req.Config)
+	before := s.auditState(ctx, auditNotificationChannel, id)
 
 	_, err := s.db.DB().ExecContext(ctx, `
 		UPDATE notification_channels
@@ -1081,6 +1349,10 @@ func (s *Server) handleUpdateNotificationChannel(w http.ResponseWriter, r *http.
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	if before != nil {
+		s.audit(ctx, "update", auditNotificationChannel, id, before, s.auditState(ctx, auditNotificationChannel, id))
+	}
+	s.reloadNotificationChannels(ctx)
 
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -1089,17 +1361,39 @@ func (s *Server) handleDeleteNotificationChannel(w http.ResponseWriter, r *http.
 	ctx := r.Context()
 	id, _ := strconv.Atoi(r.PathValue("id"))
 
+	before := s.auditState(ctx, auditNotificationChannel, id)
+
 	_, err := s.db.DB().ExecContext(ctx, `DELETE FROM notification_channels WHERE id = ?`, id)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	if before != nil {
+		s.audit(ctx, "delete", auditNotificationChannel, id, before, nil)
+	}
+	s.reloadNotificationChannels(ctx)
 
 	w.WriteHeader(http.StatusNoContent)
 }
 
 func (s *Server) handleTestNotificationChannel(w http.ResponseWriter, r *http.Request) {
-	// This would send a test notification
-	// For now, return not implemented
-	http.Error(w, "not implemented", http.StatusNotImplemented)
+	ctx := r.Context()
+	id, _ := strconv.Atoi(r.PathValue("id"))
+
+	if err := s.dispatcher.SendTest(ctx, id); err != nil {
+		slog.Warn("test notification failed", "channel_id", id, "error", err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "sent"})
+}
+
+// reloadNotificationChannels refreshes the dispatcher after channel edits so
+// changes take effect without restarting the server.
+func (s *Server) reloadNotificationChannels(ctx context.Context) {
+	if err := s.dispatcher.LoadChannels(ctx); err != nil {
+		slog.Error("failed to reload notification channels", "error", err)
+	}
 }
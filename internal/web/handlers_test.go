@@ -52,12 +52,20 @@ func testServer(t *testing.T) (*Server, func()) {
 
 	cleanup := func() {
 		// Clean up test data
+		database.DB().ExecContext(ctx, "DELETE FROM missed_runs")
+		database.DB().ExecContext(ctx, "DELETE FROM probe_result_rollups")
 		database.DB().ExecContext(ctx, "DELETE FROM probe_results")
+		database.DB().ExecContext(ctx, "DELETE FROM silences")
+		database.DB().ExecContext(ctx, "DELETE FROM metric_rules")
+		database.DB().ExecContext(ctx, "DELETE FROM probe_dependencies")
+		database.DB().ExecContext(ctx, "DELETE FROM composite_members")
 		database.DB().ExecContext(ctx, "DELETE FROM probe_configs")
 		database.DB().ExecContext(ctx, "DELETE FROM watcher_probe_types")
 		database.DB().ExecContext(ctx, "DELETE FROM probe_types")
 		database.DB().ExecContext(ctx, "DELETE FROM watchers")
 		database.DB().ExecContext(ctx, "DELETE FROM notification_channels")
+		database.DB().ExecContext(ctx, "DELETE FROM escalation_policies")
+		database.DB().ExecContext(ctx, "DELETE FROM users")
 		database.Close()
 	}
 
@@ -365,13 +373,43 @@ func TestHandleListNotificationChannels(t *testing.T) {
 	}
 	defer cleanup()
 
-	req := httptest.NewRequest("GET", "/api/notification-channels", nil)
-	w := httptest.NewRecorder()
+	_, err := server.db.DB().Exec(`
+		INSERT INTO notification_channels (name, type, config, enabled)
+		VALUES ('hook', 'webhook', '{"url":"https://example.com","secret":"s3cret","headers":{"Authorization":"Bearer abc"}}', 1)
+	`)
+	if err != nil {
+		t.Fatalf("insert channel: %v", err)
+	}
 
-	server.handleListNotificationChannels(w, req)
+	list := func(role string) map[string]any {
+		t.Helper()
+		req := httptest.NewRequest("GET", "/api/notification-channels", nil)
+		req = req.WithContext(context.WithValue(req.Context(), identityKey, &identity{userID: 1, username: "alice", role: role}))
+		w := httptest.NewRecorder()
+		server.handleListNotificationChannels(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", w.Code)
+		}
+		var channels []struct {
+			Config map[string]any `json:"config"`
+		}
+		json.NewDecoder(w.Body).Decode(&channels)
+		if len(channels) != 1 {
+			t.Fatalf("expected 1 channel, got %d", len(channels))
+		}
+		return channels[0].Config
+	}
 
-	if w.Code != http.StatusOK {
-		t.Errorf("expected status 200, got %d", w.Code)
+	// Viewers don't see credentials
+	config := list(roleViewer)
+	headers, _ := config["headers"].(map[string]any)
+	if config["url"] != "https://example.com" || config["secret"] != "REDACTED" || headers["Authorization"] != "REDACTED" {
+		t.Errorf("expected redacted credentials, got %v", config)
+	}
+	config = list(roleAdmin)
+	headers, _ = config["headers"].(map[string]any)
+	if config["secret"] != "s3cret" || headers["Authorization"] != "Bearer abc" {
+		t.Errorf("expected credentials for an admin, got %v", config)
 	}
 }
 
@@ -0,0 +1,440 @@
+package web
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jandubois/monitor/internal/db"
+	"github.com/jandubois/monitor/internal/probe"
+)
+
+// openIncident is the part of an open incident needed for notifications.
+type openIncident struct {
+	id           int
+	ackToken     string
+	acknowledged bool
+}
+
+// trackIncident opens an incident for a non-ok status or continues the open
+// one, and resolves the open incident on ok. It returns the open incident,
+// or nil after recovery.
+func (s *Server) trackIncident(ctx context.Context, configID int, status probe.Status, message string) (*openIncident, error) {
+	now := time.Now().UTC().Format(db.SQLiteTimeFormat)
+
+	if status == probe.StatusOK {
+		_, err := s.db.DB().ExecContext(ctx, `
+			UPDATE incidents SET resolved_at = ? WHERE probe_config_id = ? AND resolved_at IS NULL
+		`, now, configID)
+		return nil, err
+	}
+
+	token, err := newAckToken()
+	if err != nil {
+		return nil, err
+	}
+
+	var incident openIncident
+	var acknowledgedAt db.NullTime
+	err = s.db.DB().QueryRowContext(ctx, `
+		INSERT INTO incidents (probe_config_id, status, message, opened_at, ack_token)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (probe_config_id) WHERE resolved_at IS NULL DO UPDATE
+		SET status = CASE WHEN status = 'critical' THEN status ELSE excluded.status END
+		RETURNING id, ack_token, acknowledged_at
+	`, configID, status, message, now, token).Scan(&incident.id, &incident.ackToken, &acknowledgedAt)
+	if err != nil {
+		return nil, err
+	}
+	incident.acknowledged = acknowledgedAt.Valid
+	return &incident, nil
+}
+
+func newAckToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate ack token: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// ackURL returns the acknowledgement link for an incident, or "" without a
+// public URL or once the incident is acknowledged.
+func (s *Server) ackURL(incident *openIncident) string {
+	if incident == nil || incident.acknowledged || s.config.PublicURL == "" {
+		return ""
+	}
+	return strings.TrimSuffix(s.config.PublicURL, "/") + "/api/ack/" + incident.ackToken
+}
+
+// acknowledgeConfig acknowledges the open incident and the escalation of a
+// config, which stops repeat notifications until it recovers. It reports
+// whether there was anything to acknowledge.
+func (s *Server) acknowledgeConfig(ctx context.Context, configID int, by string) (bool, error) {
+	now := time.Now().UTC().Format(db.SQLiteTimeFormat)
+
+	result, err := s.db.DB().ExecContext(ctx, `
+		UPDATE incidents SET acknowledged_at = ?, acknowledged_by = NULLIF(?, '')
+		WHERE probe_config_id = ? AND resolved_at IS NULL AND acknowledged_at IS NULL
+	`, now, by, configID)
+	if err != nil {
+		return false, err
+	}
+	incidents, _ := result.RowsAffected()
+
+	result, err = s.db.DB().ExecContext(ctx, `
+		UPDATE escalations SET acknowledged_at = ? WHERE probe_config_id = ? AND acknowledged_at IS NULL
+	`, now, configID)
+	if err != nil {
+		return false, err
+	}
+	escalations, _ := result.RowsAffected()
+
+	if incidents+escalations > 0 {
+		slog.Info("acknowledged", "config_id", configID, "by", by)
+	}
+	return incidents+escalations > 0, nil
+}
+
+// acknowledgeIncident acknowledges an open incident. It returns
+// sql.ErrNoRows if the incident doesn't exist or is resolved.
+func (s *Server) acknowledgeIncident(ctx context.Context, id int, by, note string) error {
+	var configID int
+	err := s.db.DB().QueryRowContext(ctx, `
+		SELECT probe_config_id FROM incidents WHERE id = ? AND resolved_at IS NULL
+	`, id).Scan(&configID)
+	if err != nil {
+		return err
+	}
+	if _, err := s.acknowledgeConfig(ctx, configID, by); err != nil {
+		return err
+	}
+	if note != "" {
+		_, err = s.addIncidentNote(ctx, id, by, note)
+	}
+	return err
+}
+
+func (s *Server) addIncidentNote(ctx context.Context, id int, author, note string) (int64, error) {
+	result, err := s.db.DB().ExecContext(ctx, `
+		INSERT INTO incident_notes (incident_id, author, note) VALUES (?, NULLIF(?, ''), ?)
+	`, id, author, note)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+func (s *Server) handleListIncidents(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	query := `
+		SELECT i.id, i.probe_config_id, pc.name, i.status, i.message, i.opened_at,
+		       i.acknowledged_at, i.acknowledged_by, i.resolved_at
+		FROM incidents i
+		JOIN probe_configs pc ON pc.id = i.probe_config_id
+		WHERE 1=1
+	`
+	args := []any{}
+
+	switch r.URL.Query().Get("state") {
+	case "":
+	case "open":
+		query += " AND i.resolved_at IS NULL"
+	case "resolved":
+		query += " AND i.resolved_at IS NOT NULL"
+	default:
+		http.Error(w, "state must be open or resolved", http.StatusBadRequest)
+		return
+	}
+	if configID := r.URL.Query().Get("config_id"); configID != "" {
+		query += " AND i.probe_config_id = ?"
+		args = append(args, configID)
+	}
+	if since := r.URL.Query().Get("since"); since != "" {
+		t, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			http.Error(w, "invalid since: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		// Incidents still open at since count too
+		query += " AND (i.resolved_at IS NULL OR i.resolved_at >= ?)"
+		args = append(args, t.UTC().Format(db.SQLiteTimeFormat))
+	}
+
+	limit := 100
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 && l <= 1000 {
+		limit = l
+	}
+	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+
+	// Open incidents first, then the most recent
+	query += " ORDER BY i.resolved_at IS NOT NULL, i.opened_at DESC LIMIT ? OFFSET ?"
+	args = append(args, limit, max(offset, 0))
+
+	incidents, err := s.queryIncidents(ctx, query, args...)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(incidents)
+}
+
+func (s *Server) handleGetIncident(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(r.PathValue("id"))
+
+	incidents, err := s.queryIncidents(r.Context(), `
+		SELECT i.id, i.probe_config_id, pc.name, i.status, i.message, i.opened_at,
+		       i.acknowledged_at, i.acknowledged_by, i.resolved_at
+		FROM incidents i
+		JOIN probe_configs pc ON pc.id = i.probe_config_id
+		WHERE i.id = ?
+	`, id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if len(incidents) == 0 {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(incidents[0])
+}
+
+// queryIncidents runs an incident query and attaches durations and notes.
+func (s *Server) queryIncidents(ctx context.Context, query string, args ...any) ([]map[string]any, error) {
+	rows, err := s.db.DB().QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	incidents := []map[string]any{}
+	byID := map[int]map[string]any{}
+	var ids []any
+	for rows.Next() {
+		var id, configID int
+		var configName, status string
+		var message, acknowledgedBy *string
+		var openedAt, acknowledgedAt, resolvedAt db.NullTime
+		if err := rows.Scan(&id, &configID, &configName, &status, &message, &openedAt,
+			&acknowledgedAt, &acknowledgedBy, &resolvedAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+
+		end := now
+		if resolvedAt.Valid {
+			end = resolvedAt.Time
+		}
+		incident := map[string]any{
+			"id":                id,
+			"probe_config_id":   configID,
+			"probe_config_name": configName,
+			"status":            status,
+			"opened_at":         openedAt.Time,
+			"duration_seconds":  int(end.Sub(openedAt.Time).Seconds()),
+			"notes":             []map[string]any{},
+		}
+		if message != nil {
+			incident["message"] = *message
+		}
+		if acknowledgedAt.Valid {
+			incident["acknowledged_at"] = acknowledgedAt.Time
+		}
+		if acknowledgedBy != nil {
+			incident["acknowledged_by"] = *acknowledgedBy
+		}
+		if resolvedAt.Valid {
+			incident["resolved_at"] = resolvedAt.Time
+		}
+		incidents = append(incidents, incident)
+		byID[id] = incident
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return incidents, nil
+	}
+
+	rows, err = s.db.DB().QueryContext(ctx, `
+		SELECT id, incident_id, author, note, created_at FROM incident_notes
+		WHERE incident_id IN (?`+strings.Repeat(", ?", len(ids)-1)+`)
+		ORDER BY created_at, id
+	`, ids...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id, incidentID int
+		var author *string
+		var note string
+		var createdAt db.NullTime
+		if err := rows.Scan(&id, &incidentID, &author, &note, &createdAt); err != nil {
+			return nil, err
+		}
+		entry := map[string]any{
+			"id":         id,
+			"note":       note,
+			"created_at": createdAt.Time,
+		}
+		if author != nil {
+			entry["author"] = *author
+		}
+		incident := byID[incidentID]
+		incident["notes"] = append(incident["notes"].([]map[string]any), entry)
+	}
+	return incidents, rows.Err()
+}
+
+func (s *Server) handleAcknowledgeIncident(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id, _ := strconv.Atoi(r.PathValue("id"))
+
+	var req struct {
+		By   string `json:"by"` // Only used with the shared token
+		Note string `json:"note"`
+	}
+	// The body is optional
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Users can't acknowledge in someone else's name
+	if name := callerName(ctx); name != "" {
+		req.By = name
+	}
+
+	err := s.acknowledgeIncident(ctx, id, req.By, req.Note)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "no open incident", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleAddIncidentNote(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id, _ := strconv.Atoi(r.PathValue("id"))
+
+	var req struct {
+		Author string `json:"author"` // Only used with the shared token
+		Note   string `json:"note"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(req.Note) == "" {
+		http.Error(w, "note is required", http.StatusBadRequest)
+		return
+	}
+
+	if name := callerName(ctx); name != "" {
+		req.Author = name
+	}
+
+	var exists bool
+	if err := s.db.DB().QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM incidents WHERE id = ?)`, id).Scan(&exists); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !exists {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	noteID, err := s.addIncidentNote(ctx, id, req.Author, req.Note)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]any{"id": noteID})
+}
+
+var ackPage = template.Must(template.New("ack").Parse(`<!DOCTYPE html>
+<html><head><meta name="viewport" content="width=device-width, initial-scale=1"><title>{{.Title}}</title></head>
+<body style="font-family: sans-serif; margin: 2em">
+<h1>{{.Title}}</h1>
+{{if .Message}}<p>{{.Message}}</p>{{end}}
+{{if .Form}}<form method="post"><button type="submit" style="font-size: 1.2em; padding: 0.5em 1em">Acknowledge</button></form>{{end}}
+</body></html>
+`))
+
+// handleAckLink serves the acknowledgement link sent in notifications. The
+// token authorizes it, since it is opened from a phone without the API token.
+// GET shows a confirmation form, so link previews don't acknowledge; POST
+// acknowledges, and is what the ntfy action sends.
+func (s *Server) handleAckLink(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var id int
+	var configName string
+	var acknowledgedAt, resolvedAt db.NullTime
+	err := s.db.DB().QueryRowContext(ctx, `
+		SELECT i.id, pc.name, i.acknowledged_at, i.resolved_at
+		FROM incidents i
+		JOIN probe_configs pc ON pc.id = i.probe_config_id
+		WHERE i.ack_token = ?
+	`, r.PathValue("token")).Scan(&id, &configName, &acknowledgedAt, &resolvedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	page := struct {
+		Title   string
+		Message string
+		Form    bool
+	}{Title: configName}
+	switch {
+	case resolvedAt.Valid:
+		page.Message = "The incident is resolved."
+	case acknowledgedAt.Valid:
+		page.Message = "The incident is already acknowledged."
+	case r.Method == http.MethodPost:
+		if err := s.acknowledgeIncident(ctx, id, "link", ""); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		page.Message = "Acknowledged. Repeat notifications are stopped until it recovers."
+	default:
+		page.Form = true
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	ackPage.Execute(w, page)
+}
@@ -0,0 +1,277 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jandubois/monitor/internal/config"
+	"github.com/jandubois/monitor/internal/db"
+	"github.com/jandubois/monitor/internal/probe"
+)
+
+func TestAckURL(t *testing.T) {
+	s := &Server{config: &config.WebConfig{}}
+	incident := &openIncident{id: 1, ackToken: "abc"}
+	if got := s.ackURL(incident); got != "" {
+		t.Errorf("expected no link without a public URL, got %q", got)
+	}
+
+	s.config.PublicURL = "https://monitor.example.com/"
+	if got := s.ackURL(incident); got != "https://monitor.example.com/api/ack/abc" {
+		t.Errorf("unexpected link %q", got)
+	}
+	if got := s.ackURL(nil); got != "" {
+		t.Errorf("expected no link without an incident, got %q", got)
+	}
+	incident.acknowledged = true
+	if got := s.ackURL(incident); got != "" {
+		t.Errorf("expected no link for an acknowledged incident, got %q", got)
+	}
+}
+
+func TestIncidentLifecycle(t *testing.T) {
+	server, cleanup := testServer(t)
+	if server == nil {
+		return
+	}
+	defer cleanup()
+
+	ctx := context.Background()
+	sqlDB := server.db.DB()
+
+	if _, err := sqlDB.ExecContext(ctx, `INSERT INTO probe_types (name, version, arguments) VALUES ('incident-type', '1.0.0', '{}')`); err != nil {
+		t.Fatalf("failed to create probe type: %v", err)
+	}
+	result, err := sqlDB.ExecContext(ctx, `
+		INSERT INTO probe_configs (probe_type_id, name, interval)
+		SELECT id, 'incident-config', '1m' FROM probe_types WHERE name = 'incident-type'
+	`)
+	if err != nil {
+		t.Fatalf("failed to create config: %v", err)
+	}
+	id, _ := result.LastInsertId()
+	configID := int(id)
+
+	opened, err := server.trackIncident(ctx, configID, probe.StatusWarning, "disk at 85%")
+	if err != nil {
+		t.Fatalf("trackIncident failed: %v", err)
+	}
+	again, err := server.trackIncident(ctx, configID, probe.StatusCritical, "disk at 95%")
+	if err != nil {
+		t.Fatalf("trackIncident failed: %v", err)
+	}
+	if again.id != opened.id || again.ackToken != opened.ackToken {
+		t.Errorf("expected the open incident to continue, got %+v and %+v", opened, again)
+	}
+	if _, err := server.trackIncident(ctx, configID, probe.StatusWarning, "disk at 85%"); err != nil {
+		t.Fatalf("trackIncident failed: %v", err)
+	}
+
+	// The ack link shows a form on GET and acknowledges on POST
+	req := httptest.NewRequest("GET", "/api/ack/"+opened.ackToken, nil)
+	req.SetPathValue("token", opened.ackToken)
+	w := httptest.NewRecorder()
+	server.handleAckLink(w, req)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "<form") {
+		t.Fatalf("expected confirmation form, got %d: %s", w.Code, w.Body.String())
+	}
+
+	req = httptest.NewRequest("POST", "/api/ack/"+opened.ackToken, nil)
+	req.SetPathValue("token", opened.ackToken)
+	w = httptest.NewRecorder()
+	server.handleAckLink(w, req)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Acknowledged") {
+		t.Fatalf("expected acknowledgement, got %d: %s", w.Code, w.Body.String())
+	}
+
+	incident, err := server.trackIncident(ctx, configID, probe.StatusCritical, "disk at 99%")
+	if err != nil {
+		t.Fatalf("trackIncident failed: %v", err)
+	}
+	if !incident.acknowledged {
+		t.Error("expected the incident to be acknowledged")
+	}
+
+	idStr := strconv.Itoa(opened.id)
+	body, _ := json.Marshal(map[string]string{"author": "oncall", "note": "cleaned up old logs"})
+	req = httptest.NewRequest("POST", "/api/incidents/"+idStr+"/notes", bytes.NewReader(body))
+	req.SetPathValue("id", idStr)
+	w = httptest.NewRecorder()
+	server.handleAddIncidentNote(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+
+	if _, err := server.trackIncident(ctx, configID, probe.StatusOK, "disk at 40%"); err != nil {
+		t.Fatalf("trackIncident failed: %v", err)
+	}
+
+	req = httptest.NewRequest("GET", "/api/incidents?state=resolved", nil)
+	w = httptest.NewRecorder()
+	server.handleListIncidents(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var incidents []map[string]any
+	if err := json.NewDecoder(w.Body).Decode(&incidents); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(incidents) != 1 {
+		t.Fatalf("expected 1 resolved incident, got %d", len(incidents))
+	}
+	got := incidents[0]
+	if got["status"] != "critical" || got["message"] != "disk at 85%" || got["acknowledged_by"] != "link" {
+		t.Errorf("unexpected incident: %v", got)
+	}
+	if _, ok := got["resolved_at"]; !ok {
+		t.Error("expected resolved_at")
+	}
+	notes, _ := got["notes"].([]any)
+	if len(notes) != 1 || notes[0].(map[string]any)["note"] != "cleaned up old logs" {
+		t.Errorf("unexpected notes: %v", got["notes"])
+	}
+
+	// A new non-ok result opens a new incident
+	next, err := server.trackIncident(ctx, configID, probe.StatusUnknown, "probe failed")
+	if err != nil {
+		t.Fatalf("trackIncident failed: %v", err)
+	}
+	if next.id == opened.id || next.acknowledged {
+		t.Errorf("expected a new unacknowledged incident, got %+v", next)
+	}
+}
+
+func TestIncidentCallerName(t *testing.T) {
+	server, cleanup := testServer(t)
+	if server == nil {
+		return
+	}
+	defer cleanup()
+
+	ctx := context.Background()
+	sqlDB := server.db.DB()
+
+	if _, err := sqlDB.ExecContext(ctx, `INSERT INTO probe_types (name, version, arguments) VALUES ('caller-type', '1.0.0', '{}')`); err != nil {
+		t.Fatalf("failed to create probe type: %v", err)
+	}
+	result, err := sqlDB.ExecContext(ctx, `
+		INSERT INTO probe_configs (probe_type_id, name, interval)
+		SELECT id, 'caller-config', '1m' FROM probe_types WHERE name = 'caller-type'
+	`)
+	if err != nil {
+		t.Fatalf("failed to create config: %v", err)
+	}
+	configID, _ := result.LastInsertId()
+	opened, err := server.trackIncident(ctx, int(configID), probe.StatusCritical, "down")
+	if err != nil {
+		t.Fatalf("trackIncident failed: %v", err)
+	}
+
+	// Users are recorded by name, whatever the request says
+	idStr := strconv.Itoa(opened.id)
+	post := func(path string, body map[string]string, handler http.HandlerFunc) int {
+		t.Helper()
+		data, _ := json.Marshal(body)
+		req := httptest.NewRequest("POST", path, bytes.NewReader(data))
+		req.SetPathValue("id", idStr)
+		req = req.WithContext(context.WithValue(req.Context(), identityKey, &identity{userID: 1, username: "alice", role: roleOperator}))
+		w := httptest.NewRecorder()
+		handler(w, req)
+		return w.Code
+	}
+	if code := post("/api/incidents/"+idStr+"/acknowledge", map[string]string{"by": "bob", "note": "on it"}, server.handleAcknowledgeIncident); code != http.StatusNoContent {
+		t.Fatalf("acknowledge failed: %d", code)
+	}
+	if code := post("/api/incidents/"+idStr+"/notes", map[string]string{"author": "bob", "note": "fixed"}, server.handleAddIncidentNote); code != http.StatusCreated {
+		t.Fatalf("add note failed: %d", code)
+	}
+
+	var acknowledgedBy string
+	sqlDB.QueryRowContext(ctx, `SELECT acknowledged_by FROM incidents WHERE id = ?`, opened.id).Scan(&acknowledgedBy)
+	if acknowledgedBy != "alice" {
+		t.Errorf("expected the incident acknowledged by alice, got %q", acknowledgedBy)
+	}
+	var others int
+	sqlDB.QueryRowContext(ctx, `SELECT COUNT(*) FROM incident_notes WHERE incident_id = ? AND author IS NOT 'alice'`, opened.id).Scan(&others)
+	if others != 0 {
+		t.Errorf("expected all notes by alice, got %d by others", others)
+	}
+}
+
+func TestIncidentFollowsConfirmedStatus(t *testing.T) {
+	server, cleanup := testServer(t)
+	if server == nil {
+		return
+	}
+	defer cleanup()
+
+	ctx := context.Background()
+	sqlDB := server.db.DB()
+
+	if _, err := sqlDB.ExecContext(ctx, `INSERT INTO probe_types (name, version, arguments) VALUES ('blip-type', '1.0.0', '{}')`); err != nil {
+		t.Fatalf("failed to create probe type: %v", err)
+	}
+	result, err := sqlDB.ExecContext(ctx, `
+		INSERT INTO probe_configs (probe_type_id, name, interval, consecutive_results)
+		SELECT id, 'blip-config', '1m', 3 FROM probe_types WHERE name = 'blip-type'
+	`)
+	if err != nil {
+		t.Fatalf("failed to create config: %v", err)
+	}
+	id, _ := result.LastInsertId()
+	configID := int(id)
+
+	start := time.Now().UTC().Add(-time.Hour)
+	report := func(i int, status probe.Status) {
+		t.Helper()
+		_, err := sqlDB.ExecContext(ctx, `
+			INSERT INTO probe_results (probe_config_id, status, message, executed_at) VALUES (?, ?, 'checked', ?)
+		`, configID, status, start.Add(time.Duration(i)*time.Minute).Format(db.SQLiteTimeFormat))
+		if err != nil {
+			t.Fatalf("failed to insert result: %v", err)
+		}
+		server.checkStatusChangeAndNotify(ctx, configID, status, "checked")
+	}
+	openIncidents := func() int {
+		t.Helper()
+		var n int
+		if err := sqlDB.QueryRowContext(ctx, `
+			SELECT COUNT(*) FROM incidents WHERE probe_config_id = ? AND resolved_at IS NULL
+		`, configID).Scan(&n); err != nil {
+			t.Fatalf("failed to count incidents: %v", err)
+		}
+		return n
+	}
+
+	statuses := []probe.Status{probe.StatusOK, probe.StatusOK, probe.StatusOK, probe.StatusCritical}
+	for i, status := range statuses {
+		report(i, status)
+	}
+	if n := openIncidents(); n != 0 {
+		t.Fatalf("expected a single critical result not to open an incident, got %d", n)
+	}
+
+	report(4, probe.StatusCritical)
+	report(5, probe.StatusCritical)
+	if n := openIncidents(); n != 1 {
+		t.Fatalf("expected 3 critical results to open an incident, got %d", n)
+	}
+
+	report(6, probe.StatusOK)
+	if n := openIncidents(); n != 1 {
+		t.Fatalf("expected a single ok result not to resolve the incident, got %d", n)
+	}
+	report(7, probe.StatusOK)
+	report(8, probe.StatusOK)
+	if n := openIncidents(); n != 0 {
+		t.Fatalf("expected 3 ok results to resolve the incident, got %d", n)
+	}
+}
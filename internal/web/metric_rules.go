@@ -0,0 +1,387 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jandubois/monitor/internal/db"
+	"github.com/jandubois/monitor/internal/probe"
+)
+
+const (
+	ruleModeDerive   = "derive"   // Raise the probe's own status
+	ruleModeOverride = "override" // Replace the probe's own status
+)
+
+// metricRule sets the status of a result from one of its numeric metrics,
+// e.g. free_percent < 15 for 10m → warning.
+type metricRule struct {
+	ID            int          `json:"id"`
+	ProbeConfigID *int         `json:"probe_config_id,omitempty"`
+	ProbeType     *string      `json:"probe_type,omitempty"` // Applies to all configs of the type
+	Metric        string       `json:"metric"`
+	Operator      string       `json:"operator"`
+	Threshold     float64      `json:"threshold"`
+	For           string       `json:"for,omitempty"` // How long the condition must hold
+	Status        probe.Status `json:"status"`
+	Mode          string       `json:"mode"`
+
+	forDuration time.Duration
+}
+
+func (r *metricRule) validate() error {
+	if (r.ProbeConfigID == nil) == (r.ProbeType == nil) {
+		return errors.New("exactly one of probe_config_id and probe_type is required")
+	}
+	if r.Metric == "" {
+		return errors.New("metric is required")
+	}
+	switch r.Operator {
+	case "<", "<=", ">", ">=", "==", "!=":
+	default:
+		return fmt.Errorf("invalid operator %q", r.Operator)
+	}
+	if r.Status != probe.StatusWarning && r.Status != probe.StatusCritical {
+		return errors.New("status must be warning or critical")
+	}
+	switch r.Mode {
+	case "":
+		r.Mode = ruleModeDerive
+	case ruleModeDerive, ruleModeOverride:
+	default:
+		return fmt.Errorf("mode must be %s or %s", ruleModeDerive, ruleModeOverride)
+	}
+	r.forDuration = 0
+	if r.For != "" {
+		d, err := time.ParseDuration(r.For)
+		if err != nil {
+			return fmt.Errorf("invalid for: %w", err)
+		}
+		if d < 0 {
+			return errors.New("for must not be negative")
+		}
+		r.forDuration = d
+	}
+	return nil
+}
+
+// matches reports whether a metric value meets the rule's condition.
+func (r *metricRule) matches(value float64) bool {
+	switch r.Operator {
+	case "<":
+		return value < r.Threshold
+	case "<=":
+		return value <= r.Threshold
+	case ">":
+		return value > r.Threshold
+	case ">=":
+		return value >= r.Threshold
+	case "==":
+		return value == r.Threshold
+	case "!=":
+		return value != r.Threshold
+	}
+	return false
+}
+
+// matchesMetrics reports whether the rule's metric is present, numeric,
+// and meets the condition.
+func (r *metricRule) matchesMetrics(metrics map[string]any) (float64, bool) {
+	value, ok := metrics[r.Metric].(float64)
+	return value, ok && r.matches(value)
+}
+
+// firedRule is a rule whose condition held, with the value that met it.
+type firedRule struct {
+	rule  *metricRule
+	value float64
+}
+
+func (f firedRule) String() string {
+	s := fmt.Sprintf("%s %g %s %g", f.rule.Metric, f.value, f.rule.Operator, f.rule.Threshold)
+	if f.rule.forDuration > 0 {
+		s += " for " + f.rule.forDuration.String()
+	}
+	return s
+}
+
+// deriveStatus combines a probe's own status with the rules that apply to
+// the config and the ones that fired. Override rules replace any status
+// but unknown, which means the probe could not measure anything, and only
+// if the result has their metric: without it, the probe's status is all
+// there is to go by, e.g. for a refused connection.
+func deriveStatus(status probe.Status, message string, metrics map[string]any, rules []metricRule, fired []firedRule) (probe.Status, string) {
+	for _, rule := range rules {
+		if _, measured := metrics[rule.Metric].(float64); rule.Mode == ruleModeOverride && measured && status != probe.StatusUnknown {
+			status = probe.StatusOK
+			break
+		}
+	}
+	if len(fired) == 0 {
+		return status, message
+	}
+
+	descriptions := make([]string, 0, len(fired)+1)
+	for _, f := range fired {
+		if statusRank[string(f.rule.Status)] > statusRank[string(status)] {
+			status = f.rule.Status
+		}
+		descriptions = append(descriptions, f.String())
+	}
+	if message != "" {
+		descriptions = append(descriptions, message)
+	}
+	return status, strings.Join(descriptions, "; ")
+}
+
+// applyMetricRules evaluates the metric rules of a config against a new
+// result and returns the status and message to store for it.
+func (s *Server) applyMetricRules(ctx context.Context, configID int, executedAt time.Time, status probe.Status, message string, metrics map[string]any) (probe.Status, string, error) {
+	rules, err := s.loadMetricRules(ctx, `
+		WHERE probe_config_id = ?
+		   OR probe_type = (SELECT pt.name FROM probe_configs pc JOIN probe_types pt ON pt.id = pc.probe_type_id WHERE pc.id = ?)
+	`, configID, configID)
+	if err != nil || len(rules) == 0 {
+		return status, message, err
+	}
+
+	var fired []firedRule
+	for i := range rules {
+		rule := &rules[i]
+		value, ok := rule.matchesMetrics(metrics)
+		if !ok {
+			continue
+		}
+		if rule.forDuration > 0 {
+			held, err := s.metricRuleHeld(ctx, configID, rule, executedAt)
+			if err != nil {
+				return status, message, err
+			}
+			if !held {
+				continue
+			}
+		}
+		fired = append(fired, firedRule{rule: rule, value: value})
+	}
+
+	status, message = deriveStatus(status, message, metrics, rules, fired)
+	return status, message, nil
+}
+
+// metricRuleHeld reports whether the rule's condition held in every
+// earlier result within its for duration before executedAt, including the
+// last result at or before the start of that period. Without such a result
+// the condition cannot have held long enough.
+func (s *Server) metricRuleHeld(ctx context.Context, configID int, rule *metricRule, executedAt time.Time) (bool, error) {
+	start := executedAt.Add(-rule.forDuration).UTC().Format(db.SQLiteTimeFormat)
+	rows, err := s.db.DB().QueryContext(ctx, `
+		SELECT metrics FROM probe_results
+		WHERE probe_config_id = ? AND executed_at < ?
+		  AND executed_at >= (SELECT MAX(executed_at) FROM probe_results WHERE probe_config_id = ? AND executed_at <= ?)
+	`, configID, executedAt.UTC().Format(db.SQLiteTimeFormat), configID, start)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	held := false
+	for rows.Next() {
+		var metrics db.JSONMap
+		if err := rows.Scan(&metrics); err != nil {
+			return false, err
+		}
+		if _, ok := rule.matchesMetrics(metrics); !ok {
+			return false, nil
+		}
+		held = true
+	}
+	return held, rows.Err()
+}
+
+// loadMetricRules returns the rules selected by a WHERE clause.
+func (s *Server) loadMetricRules(ctx context.Context, where string, args ...any) ([]metricRule, error) {
+	rows, err := s.db.DB().QueryContext(ctx, `
+		SELECT id, probe_config_id, probe_type, metric, operator, threshold, for_seconds, status, mode
+		FROM metric_rules
+	`+where+` ORDER BY id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := []metricRule{}
+	for rows.Next() {
+		var rule metricRule
+		var forSeconds int
+		var status string
+		if err := rows.Scan(&rule.ID, &rule.ProbeConfigID, &rule.ProbeType, &rule.Metric, &rule.Operator,
+			&rule.Threshold, &forSeconds, &status, &rule.Mode); err != nil {
+			return nil, err
+		}
+		rule.Status = probe.Status(status)
+		if forSeconds > 0 {
+			rule.forDuration = time.Duration(forSeconds) * time.Second
+			rule.For = rule.forDuration.String()
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
+
+// checkMetricRuleTarget returns an error if the rule's config does not exist.
+func (s *Server) checkMetricRuleTarget(ctx context.Context, rule *metricRule) error {
+	if rule.ProbeConfigID == nil {
+		return nil
+	}
+	var exists bool
+	if err := s.db.DB().QueryRowContext(ctx, `
+		SELECT EXISTS (SELECT 1 FROM probe_configs WHERE id = ?)
+	`, *rule.ProbeConfigID).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("probe config %d not found", *rule.ProbeConfigID)
+	}
+	return nil
+}
+
+// handleListMetricRules lists all rules, or with ?config_id= the rules
+// that apply to a config, including those for its probe type.
+func (s *Server) handleListMetricRules(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	where := ""
+	var args []any
+	if configID := r.URL.Query().Get("config_id"); configID != "" {
+		where = `
+			WHERE probe_config_id = ?
+			   OR probe_type = (SELECT pt.name FROM probe_configs pc JOIN probe_types pt ON pt.id = pc.probe_type_id WHERE pc.id = ?)
+		`
+		args = append(args, configID, configID)
+	}
+
+	rules, err := s.loadMetricRules(ctx, where, args...)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rules)
+}
+
+func (s *Server) handleGetMetricRule(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(r.PathValue("id"))
+
+	rules, err := s.loadMetricRules(r.Context(), "WHERE id = ?", id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if len(rules) == 0 {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rules[0])
+}
+
+// decodeMetricRule reads and validates a rule from a request body.
+func (s *Server) decodeMetricRule(w http.ResponseWriter, r *http.Request) (*metricRule, bool) {
+	var rule metricRule
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+	if err := rule.validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+	if err := s.checkMetricRuleTarget(r.Context(), &rule); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+	return &rule, true
+}
+
+func (s *Server) handleCreateMetricRule(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	rule, ok := s.decodeMetricRule(w, r)
+	if !ok {
+		return
+	}
+
+	result, err := s.db.DB().ExecContext(ctx, `
+		INSERT INTO metric_rules (probe_config_id, probe_type, metric, operator, threshold, for_seconds, status, mode)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, rule.ProbeConfigID, rule.ProbeType, rule.Metric, rule.Operator, rule.Threshold,
+		int(rule.forDuration.Seconds()), rule.Status, rule.Mode)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	id, _ := result.LastInsertId()
+	s.audit(ctx, "create", auditMetricRule, int(id), nil, s.auditState(ctx, auditMetricRule, int(id)))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]any{"id": id})
+}
+
+func (s *Server) handleUpdateMetricRule(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id, _ := strconv.Atoi(r.PathValue("id"))
+
+	rule, ok := s.decodeMetricRule(w, r)
+	if !ok {
+		return
+	}
+
+	before := s.auditState(ctx, auditMetricRule, id)
+	result, err := s.db.DB().ExecContext(ctx, `
+		UPDATE metric_rules
+		SET probe_config_id = ?, probe_type = ?, metric = ?, operator = ?, threshold = ?, for_seconds = ?,
+		    status = ?, mode = ?, updated_at = datetime('now')
+		WHERE id = ?
+	`, rule.ProbeConfigID, rule.ProbeType, rule.Metric, rule.Operator, rule.Threshold,
+		int(rule.forDuration.Seconds()), rule.Status, rule.Mode, id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	s.audit(ctx, "update", auditMetricRule, id, before, s.auditState(ctx, auditMetricRule, id))
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleDeleteMetricRule(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id, _ := strconv.Atoi(r.PathValue("id"))
+
+	before := s.auditState(ctx, auditMetricRule, id)
+	result, err := s.db.DB().ExecContext(ctx, `DELETE FROM metric_rules WHERE id = ?`, id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	s.audit(ctx, "delete", auditMetricRule, id, before, nil)
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,202 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/jandubois/monitor/internal/db"
+	"github.com/jandubois/monitor/internal/probe"
+)
+
+func testMetricRule() metricRule {
+	configID := 1
+	return metricRule{
+		ProbeConfigID: &configID,
+		Metric:        "free_percent",
+		Operator:      "<",
+		Threshold:     15,
+		For:           "10m",
+		Status:        probe.StatusWarning,
+	}
+}
+
+func TestMetricRuleValidate(t *testing.T) {
+	valid := testMetricRule()
+	if err := valid.validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if valid.Mode != ruleModeDerive || valid.forDuration != 10*time.Minute {
+		t.Errorf("unexpected defaults: mode %q, for %v", valid.Mode, valid.forDuration)
+	}
+
+	diskspace := "diskspace"
+	tests := map[string]func(r *metricRule){
+		"no target":      func(r *metricRule) { r.ProbeConfigID = nil },
+		"two targets":    func(r *metricRule) { r.ProbeType = &diskspace },
+		"no metric":      func(r *metricRule) { r.Metric = "" },
+		"bad operator":   func(r *metricRule) { r.Operator = "=<" },
+		"ok status":      func(r *metricRule) { r.Status = probe.StatusOK },
+		"bad mode":       func(r *metricRule) { r.Mode = "replace" },
+		"bad duration":   func(r *metricRule) { r.For = "ten minutes" },
+		"negative delay": func(r *metricRule) { r.For = "-1m" },
+	}
+	for name, mutate := range tests {
+		r := testMetricRule()
+		mutate(&r)
+		if err := r.validate(); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestMetricRuleMatchesMetrics(t *testing.T) {
+	r := testMetricRule()
+	if _, ok := r.matchesMetrics(map[string]any{"free_percent": 12.5}); !ok {
+		t.Error("expected 12.5 < 15 to match")
+	}
+	if _, ok := r.matchesMetrics(map[string]any{"free_percent": 15.0}); ok {
+		t.Error("expected 15 < 15 not to match")
+	}
+	if _, ok := r.matchesMetrics(map[string]any{"free_gb": 1.0}); ok {
+		t.Error("expected a missing metric not to match")
+	}
+	if _, ok := r.matchesMetrics(map[string]any{"free_percent": "low"}); ok {
+		t.Error("expected a non-numeric metric not to match")
+	}
+}
+
+func TestDeriveStatus(t *testing.T) {
+	warning := testMetricRule()
+	warning.validate()
+	critical := testMetricRule()
+	critical.Threshold, critical.For, critical.Status = 5, "", probe.StatusCritical
+	critical.validate()
+	override := critical
+	override.Mode = ruleModeOverride
+
+	tests := []struct {
+		name     string
+		status   probe.Status
+		metrics  map[string]any // Defaults to free_percent 12
+		rules    []metricRule
+		fired    []firedRule
+		expected probe.Status
+		message  string
+	}{
+		{
+			name: "nothing fired", status: probe.StatusOK,
+			rules: []metricRule{warning}, expected: probe.StatusOK, message: "12% free",
+		},
+		{
+			name: "raised", status: probe.StatusOK,
+			rules: []metricRule{warning}, fired: []firedRule{{rule: &warning, value: 12}},
+			expected: probe.StatusWarning, message: "free_percent 12 < 15 for 10m0s; 12% free",
+		},
+		{
+			name: "not lowered", status: probe.StatusCritical,
+			rules: []metricRule{warning}, fired: []firedRule{{rule: &warning, value: 12}},
+			expected: probe.StatusCritical, message: "free_percent 12 < 15 for 10m0s; 12% free",
+		},
+		{
+			name: "worst fired", status: probe.StatusOK,
+			rules: []metricRule{warning, critical}, fired: []firedRule{{rule: &warning, value: 4}, {rule: &critical, value: 4}},
+			expected: probe.StatusCritical, message: "free_percent 4 < 15 for 10m0s; free_percent 4 < 5; 12% free",
+		},
+		{
+			name: "overridden", status: probe.StatusCritical,
+			rules: []metricRule{override}, expected: probe.StatusOK, message: "12% free",
+		},
+		{
+			name: "override keeps unknown", status: probe.StatusUnknown,
+			rules: []metricRule{override}, expected: probe.StatusUnknown, message: "12% free",
+		},
+		{
+			name: "override needs its metric", status: probe.StatusCritical, metrics: map[string]any{"total_ms": 3.0},
+			rules: []metricRule{override}, expected: probe.StatusCritical, message: "12% free",
+		},
+	}
+	for _, tt := range tests {
+		metrics := tt.metrics
+		if metrics == nil {
+			metrics = map[string]any{"free_percent": 12.0}
+		}
+		status, message := deriveStatus(tt.status, "12% free", metrics, tt.rules, tt.fired)
+		if status != tt.expected || message != tt.message {
+			t.Errorf("%s: expected (%q, %q), got (%q, %q)", tt.name, tt.expected, tt.message, status, message)
+		}
+	}
+}
+
+func TestApplyMetricRules(t *testing.T) {
+	server, cleanup := testServer(t)
+	if server == nil {
+		return
+	}
+	defer cleanup()
+
+	ctx := context.Background()
+	sqlDB := server.db.DB()
+
+	if _, err := sqlDB.ExecContext(ctx, `INSERT INTO probe_types (name, version, arguments) VALUES ('rule-type', '1.0.0', '{}')`); err != nil {
+		t.Fatalf("failed to create probe type: %v", err)
+	}
+	result, err := sqlDB.ExecContext(ctx, `
+		INSERT INTO probe_configs (probe_type_id, name, interval)
+		SELECT id, 'rule-config', '1m' FROM probe_types WHERE name = 'rule-type'
+	`)
+	if err != nil {
+		t.Fatalf("failed to create config: %v", err)
+	}
+	id, _ := result.LastInsertId()
+	configID := int(id)
+
+	if _, err := sqlDB.ExecContext(ctx, `
+		INSERT INTO metric_rules (probe_type, metric, operator, threshold, for_seconds, status)
+		VALUES ('rule-type', 'free_percent', '<', 15, 600, 'warning')
+	`); err != nil {
+		t.Fatalf("failed to create rule: %v", err)
+	}
+
+	start := time.Now().UTC().Truncate(time.Minute).Add(-time.Hour)
+	addResult := func(minutes int, freePercent float64) {
+		t.Helper()
+		metrics, _ := json.Marshal(map[string]any{"free_percent": freePercent})
+		if _, err := sqlDB.ExecContext(ctx, `
+			INSERT INTO probe_results (probe_config_id, status, metrics, executed_at) VALUES (?, 'ok', ?, ?)
+		`, configID, string(metrics), start.Add(time.Duration(minutes)*time.Minute).Format(db.SQLiteTimeFormat)); err != nil {
+			t.Fatalf("failed to insert result: %v", err)
+		}
+	}
+	apply := func(minutes int, freePercent float64) probe.Status {
+		t.Helper()
+		status, _, err := server.applyMetricRules(ctx, configID, start.Add(time.Duration(minutes)*time.Minute),
+			probe.StatusOK, "", map[string]any{"free_percent": freePercent})
+		if err != nil {
+			t.Fatalf("applyMetricRules failed: %v", err)
+		}
+		return status
+	}
+
+	// Not long enough without results before the period
+	addResult(0, 12)
+	addResult(5, 12)
+	if status := apply(5, 12); status != probe.StatusOK {
+		t.Errorf("expected ok after 5 minutes, got %s", status)
+	}
+	addResult(10, 12)
+	if status := apply(10, 12); status != probe.StatusWarning {
+		t.Errorf("expected warning after 10 minutes, got %s", status)
+	}
+
+	// A recovery within the period resets it
+	addResult(15, 20)
+	addResult(20, 12)
+	if status := apply(25, 12); status != probe.StatusOK {
+		t.Errorf("expected ok after a recent recovery, got %s", status)
+	}
+	if status := apply(25, 50); status != probe.StatusOK {
+		t.Errorf("expected ok for a value that doesn't match, got %s", status)
+	}
+}
@@ -0,0 +1,299 @@
+package web
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jandubois/monitor/internal/db"
+)
+
+// metricsContentType is the Prometheus text exposition format.
+const metricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// statusValues maps probe statuses to the value of monitor_probe_status.
+var statusValues = map[string]float64{
+	"ok":       0,
+	"warning":  1,
+	"critical": 2,
+	"unknown":  3,
+}
+
+// resultCounter counts results ingested since the server started, by
+// watcher and status. Prometheus treats the reset on restart as a counter
+// reset, so rate() and increase() still work.
+type resultCounter struct {
+	mu     sync.Mutex
+	counts map[resultCounterKey]uint64
+}
+
+type resultCounterKey struct {
+	watcher string
+	status  string
+}
+
+func (c *resultCounter) inc(watcher, status string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.counts == nil {
+		c.counts = make(map[resultCounterKey]uint64)
+	}
+	c.counts[resultCounterKey{watcher, status}]++
+}
+
+func (c *resultCounter) snapshot() map[resultCounterKey]uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	counts := make(map[resultCounterKey]uint64, len(c.counts))
+	for k, v := range c.counts {
+		counts[k] = v
+	}
+	return counts
+}
+
+// metricFamily is a named group of samples sharing HELP and TYPE lines.
+type metricFamily struct {
+	name    string
+	help    string
+	typ     string
+	samples []metricSample
+}
+
+type metricSample struct {
+	labels []metricLabel
+	value  float64
+}
+
+type metricLabel struct {
+	name  string
+	value string
+}
+
+func (f *metricFamily) add(value float64, labels ...metricLabel) {
+	f.samples = append(f.samples, metricSample{labels: labels, value: value})
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// writeMetricFamilies writes families in the Prometheus text format,
+// skipping families without samples.
+func writeMetricFamilies(w io.Writer, families []*metricFamily) error {
+	bw := bufio.NewWriter(w)
+	for _, f := range families {
+		if len(f.samples) == 0 {
+			continue
+		}
+		fmt.Fprintf(bw, "# HELP %s %s\n", f.name, f.help)
+		fmt.Fprintf(bw, "# TYPE %s %s\n", f.name, f.typ)
+		for _, sample := range f.samples {
+			bw.WriteString(f.name)
+			if len(sample.labels) > 0 {
+				bw.WriteByte('{')
+				for i, l := range sample.labels {
+					if i > 0 {
+						bw.WriteByte(',')
+					}
+					fmt.Fprintf(bw, `%s="%s"`, l.name, labelValueEscaper.Replace(l.value))
+				}
+				bw.WriteByte('}')
+			}
+			bw.WriteByte(' ')
+			bw.WriteString(formatMetricValue(sample.value))
+			bw.WriteByte('\n')
+		}
+	}
+	return bw.Flush()
+}
+
+func formatMetricValue(v float64) string {
+	switch {
+	case math.IsNaN(v):
+		return "NaN"
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	families, err := s.collectMetrics(r.Context(), time.Now().UTC())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", metricsContentType)
+	if err := writeMetricFamilies(w, families); err != nil {
+		slog.Error("failed to write metrics", "error", err)
+	}
+}
+
+// collectMetrics gathers the latest result of every enabled probe config,
+// watcher heartbeat state, and the ingestion counters.
+func (s *Server) collectMetrics(ctx context.Context, now time.Time) ([]*metricFamily, error) {
+	probeStatus := &metricFamily{
+		name: "monitor_probe_status",
+		help: "Status of the latest probe result (0=ok, 1=warning, 2=critical, 3=unknown).",
+		typ:  "gauge",
+	}
+	probeDuration := &metricFamily{
+		name: "monitor_probe_duration_seconds",
+		help: "Duration of the latest probe run.",
+		typ:  "gauge",
+	}
+	probeLastRun := &metricFamily{
+		name: "monitor_probe_last_run_timestamp_seconds",
+		help: "Unix time the latest probe result was executed.",
+		typ:  "gauge",
+	}
+	probeMetric := &metricFamily{
+		name: "monitor_probe_metric",
+		help: "Numeric metrics reported by the latest probe result.",
+		typ:  "gauge",
+	}
+	watcherLastSeen := &metricFamily{
+		name: "monitor_watcher_last_seen_age_seconds",
+		help: "Seconds since the watcher's last heartbeat.",
+		typ:  "gauge",
+	}
+	watcherQueueDepth := &metricFamily{
+		name: "monitor_watcher_queue_depth",
+		help: "Results queued on the watcher awaiting delivery, as of its last heartbeat.",
+		typ:  "gauge",
+	}
+	resultsIngested := &metricFamily{
+		name: "monitor_results_ingested_total",
+		help: "Probe results received from watchers since the server started.",
+		typ:  "counter",
+	}
+
+	// The watcher is the one that produced the result; configs that run on
+	// any watcher have no watcher_id of their own.
+	rows, err := s.db.DB().QueryContext(ctx, `
+		SELECT pc.id, pc.name, COALESCE(pc.group_path, ''), pt.name, COALESCE(w.name, ''),
+		       pr.status, pr.metrics, COALESCE(pr.duration_ms, 0), pr.executed_at
+		FROM probe_configs pc
+		JOIN probe_types pt ON pt.id = pc.probe_type_id
+		JOIN probe_results pr ON pr.id = (
+			SELECT id FROM probe_results
+			WHERE probe_config_id = pc.id
+			ORDER BY executed_at DESC, id DESC
+			LIMIT 1
+		)
+		LEFT JOIN watchers w ON w.id = COALESCE(pr.watcher_id, pc.watcher_id)
+		WHERE pc.enabled = 1
+		ORDER BY pc.name, pc.id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var (
+			configID                        int
+			name, group, probeType, watcher string
+			status                          string
+			metrics                         db.JSONMap
+			durationMs                      int64
+			executedAt                      db.NullTime
+		)
+		if err := rows.Scan(&configID, &name, &group, &probeType, &watcher, &status, &metrics, &durationMs, &executedAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+
+		labels := []metricLabel{
+			{"config_id", strconv.Itoa(configID)},
+			{"config", name},
+			{"group_path", group},
+			{"watcher", watcher},
+		}
+
+		statusValue, ok := statusValues[status]
+		if !ok {
+			statusValue = statusValues["unknown"]
+		}
+		probeStatus.add(statusValue, append(labels, metricLabel{"probe_type", probeType})...)
+		probeDuration.add(float64(durationMs)/1000, labels...)
+		if executedAt.Valid {
+			probeLastRun.add(float64(executedAt.Time.Unix()), labels...)
+		}
+
+		keys := make([]string, 0, len(metrics))
+		for k := range metrics {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			v, ok := metrics[k].(float64)
+			if !ok {
+				continue
+			}
+			probeMetric.add(v, append(labels, metricLabel{"metric", k})...)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = s.db.DB().QueryContext(ctx, `
+		SELECT name, last_seen_at, queue_depth FROM watchers WHERE approved = 1 ORDER BY name
+	`)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var name string
+		var lastSeen db.NullTime
+		var queueDepth int
+		if err := rows.Scan(&name, &lastSeen, &queueDepth); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		labels := []metricLabel{{"watcher", name}}
+		if lastSeen.Valid {
+			watcherLastSeen.add(now.Sub(lastSeen.Time).Seconds(), labels...)
+		}
+		watcherQueueDepth.add(float64(queueDepth), labels...)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	counts := s.resultsIngested.snapshot()
+	keys := make([]resultCounterKey, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].watcher != keys[j].watcher {
+			return keys[i].watcher < keys[j].watcher
+		}
+		return keys[i].status < keys[j].status
+	})
+	for _, k := range keys {
+		resultsIngested.add(float64(counts[k]), metricLabel{"watcher", k.watcher}, metricLabel{"status", k.status})
+	}
+
+	return []*metricFamily{
+		probeStatus,
+		probeDuration,
+		probeLastRun,
+		probeMetric,
+		watcherLastSeen,
+		watcherQueueDepth,
+		resultsIngested,
+	}, nil
+}
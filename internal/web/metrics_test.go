@@ -0,0 +1,118 @@
+package web
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jandubois/monitor/internal/db"
+)
+
+func TestWriteMetricFamilies(t *testing.T) {
+	status := &metricFamily{name: "monitor_probe_status", help: "Status.", typ: "gauge"}
+	status.add(2, metricLabel{"config", `disk "/" \ root` + "\nnext"}, metricLabel{"watcher", "nas"})
+	status.add(math.Inf(1))
+	empty := &metricFamily{name: "monitor_empty", help: "Never written.", typ: "gauge"}
+
+	var buf strings.Builder
+	if err := writeMetricFamilies(&buf, []*metricFamily{status, empty}); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	expected := `# HELP monitor_probe_status Status.
+# TYPE monitor_probe_status gauge
+monitor_probe_status{config="disk \"/\" \\ root\nnext",watcher="nas"} 2
+monitor_probe_status +Inf
+`
+	if buf.String() != expected {
+		t.Errorf("unexpected output:\n%s\nexpected:\n%s", buf.String(), expected)
+	}
+}
+
+func TestHandleMetrics(t *testing.T) {
+	server, cleanup := testServer(t)
+	if server == nil {
+		return
+	}
+	defer cleanup()
+
+	ctx := context.Background()
+	now := time.Now().UTC()
+	sqlDB := server.db.DB()
+
+	result, err := sqlDB.ExecContext(ctx, `
+		INSERT INTO watchers (name, token, approved, paused, last_seen_at, queue_depth)
+		VALUES ('metrics-watcher', 'token', 1, 0, ?, 3)
+	`, now.Add(-time.Minute).Format(db.SQLiteTimeFormat))
+	if err != nil {
+		t.Fatalf("failed to create watcher: %v", err)
+	}
+	watcherID, _ := result.LastInsertId()
+
+	result, err = sqlDB.ExecContext(ctx, `
+		INSERT INTO probe_types (name, version, arguments) VALUES ('metrics-test', '1.0.0', '{}')
+	`)
+	if err != nil {
+		t.Fatalf("failed to create probe type: %v", err)
+	}
+	probeTypeID, _ := result.LastInsertId()
+
+	result, err = sqlDB.ExecContext(ctx, `
+		INSERT INTO probe_configs (probe_type_id, watcher_id, name, group_path, enabled, arguments, interval)
+		VALUES (?, ?, 'disk-root', 'nas/storage', 1, '{}', '5m')
+	`, probeTypeID, watcherID)
+	if err != nil {
+		t.Fatalf("failed to create config: %v", err)
+	}
+	configID, _ := result.LastInsertId()
+
+	for i, status := range []string{"ok", "warning"} {
+		_, err := sqlDB.ExecContext(ctx, `
+			INSERT INTO probe_results (probe_config_id, watcher_id, status, message, metrics, duration_ms, executed_at)
+			VALUES (?, ?, ?, '', ?, 1500, ?)
+		`, configID, watcherID, status, `{"free_gb": 42.5, "mount": "/"}`,
+			now.Add(time.Duration(i-2)*time.Minute).Format(db.SQLiteTimeFormat))
+		if err != nil {
+			t.Fatalf("failed to insert result: %v", err)
+		}
+	}
+
+	server.resultsIngested.inc("metrics-watcher", "ok")
+	server.resultsIngested.inc("metrics-watcher", "ok")
+
+	req := httptest.NewRequest("GET", "/metrics", nil)
+	w := httptest.NewRecorder()
+	server.handleMetrics(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); ct != metricsContentType {
+		t.Errorf("unexpected content type %q", ct)
+	}
+
+	body := w.Body.String()
+	labels := `config_id="` + strconv.FormatInt(configID, 10) + `",config="disk-root",group_path="nas/storage",watcher="metrics-watcher"`
+	for _, line := range []string{
+		`monitor_probe_status{` + labels + `,probe_type="metrics-test"} 1`,
+		`monitor_probe_duration_seconds{` + labels + `} 1.5`,
+		`monitor_probe_metric{` + labels + `,metric="free_gb"} 42.5`,
+		`monitor_watcher_queue_depth{watcher="metrics-watcher"} 3`,
+		`monitor_results_ingested_total{watcher="metrics-watcher",status="ok"} 2`,
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("expected line %q in output:\n%s", line, body)
+		}
+	}
+	if strings.Contains(body, `metric="mount"`) {
+		t.Error("non-numeric metric should not be exported")
+	}
+	if !strings.Contains(body, `monitor_watcher_last_seen_age_seconds{watcher="metrics-watcher"} `) {
+		t.Error("expected watcher last-seen age")
+	}
+}
@@ -14,6 +14,8 @@ const (
 	watcherIDKey contextKey = "watcherID"
 	// watcherNameKey is the context key for the authenticated watcher's name.
 	watcherNameKey contextKey = "watcherName"
+	// identityKey is the context key for the authenticated user API caller.
+	identityKey contextKey = "identity"
 )
 
 // WatcherIDFromContext returns the watcher ID from the request context.
@@ -28,21 +30,36 @@ func WatcherNameFromContext(ctx context.Context) (string, bool) {
 	return name, ok
 }
 
+// requireAuth lets through requests with the shared token, a personal API
+// token or a session cookie, with any role.
 func (s *Server) requireAuth(next http.Handler) http.Handler {
+	return s.requireRole(roleViewer, next)
+}
+
+// requireRole lets through authenticated requests whose role grants at
+// least role.
+func (s *Server) requireRole(role string, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		auth := r.Header.Get("Authorization")
-		if auth == "" {
-			http.Error(w, "unauthorized", http.StatusUnauthorized)
-			return
+		var cookie string
+		if c, err := r.Cookie(sessionCookie); err == nil {
+			cookie = c.Value
 		}
 
-		token := strings.TrimPrefix(auth, "Bearer ")
-		if token != s.config.AuthToken {
+		id, err := s.authenticate(r.Context(), r.Header.Get("Authorization"), cookie)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if id == nil {
 			http.Error(w, "unauthorized", http.StatusUnauthorized)
 			return
 		}
+		if !roleAtLeast(id.role, role) {
+			http.Error(w, "forbidden: requires the "+role+" role", http.StatusForbidden)
+			return
+		}
 
-		next.ServeHTTP(w, r)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), identityKey, id)))
 	})
 }
 
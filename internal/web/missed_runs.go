@@ -0,0 +1,283 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/jandubois/monitor/internal/db"
+	"github.com/jandubois/monitor/internal/notify"
+	"github.com/jandubois/monitor/internal/schedule"
+)
+
+// Reasons recorded for missed runs.
+const (
+	missedReasonWatcherPaused  = "watcher paused"
+	missedReasonWatcherOffline = "watcher offline"
+	missedReasonNoExecutable   = "no executable on watcher"
+	missedReasonNoResult       = "no result received"
+)
+
+const (
+	// defaultMissedRunGrace is how long past next_run_at a config may go
+	// without a result before the run counts as missed.
+	defaultMissedRunGrace = 5 * time.Minute
+
+	missedRunCheckInterval = time.Minute
+
+	// maxMissedSlotsPerCheck caps how many interval slots are backfilled
+	// for a single config in one pass (e.g. after a long outage). Later
+	// passes continue after the last recorded slot.
+	maxMissedSlotsPerCheck = 100
+)
+
+// overdueConfig is an enabled probe config whose next run is past due.
+type overdueConfig struct {
+	id            int
+	name          string
+	schedule      *schedule.Schedule
+	nextRunAt     time.Time
+	channels      db.JSONIntArray
+	watcherPaused bool
+	silenced      bool
+	lastSeen      db.NullTime
+	hasExecutable bool
+}
+
+func (s *Server) missedRunLoop(ctx context.Context) {
+	ticker := time.NewTicker(missedRunCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.detectMissedRuns(ctx, time.Now().UTC()); err != nil {
+				slog.Error("missed run detection failed", "error", err)
+			}
+		}
+	}
+}
+
+// detectMissedRuns records missed runs for all configs that are overdue by
+// more than the grace period as of now, and returns the number of new records.
+// The first missed slot of an outage triggers a notification; later slots
+// are only recorded.
+func (s *Server) detectMissedRuns(ctx context.Context, now time.Time) (int, error) {
+	grace := s.config.MissedRunGrace
+	if grace <= 0 {
+		grace = defaultMissedRunGrace
+	}
+	// next_run_at is stored to the second; sub-seconds in the cutoff would
+	// count a slot exactly at the grace period as missed
+	now = now.Truncate(time.Second)
+	cutoff := now.Add(-grace)
+
+	// Configs with an escalation policy notify its first step instead of
+	// their notification channels
+	rows, err := s.db.DB().QueryContext(ctx, `
+		SELECT pc.id, pc.name, pc.interval, pc.timezone, pc.active_windows, pc.next_run_at,
+		       COALESCE((SELECT json_extract(ep.steps, '$[0].channel_ids') FROM escalation_policies ep
+		                 WHERE ep.id = pc.escalation_policy_id), pc.notification_channels),
+		       w.paused, `+silencedSQL+`, w.last_seen_at,
+		       EXISTS (SELECT 1 FROM watcher_probe_types wpt
+		               WHERE wpt.watcher_id = w.id AND wpt.probe_type_id = pc.probe_type_id)
+		FROM probe_configs pc
+		JOIN watchers w ON w.id = pc.watcher_id
+		WHERE pc.enabled = 1 AND pc.next_run_at IS NOT NULL AND pc.next_run_at < ?
+		  AND NOT EXISTS (SELECT 1 FROM probe_results pr
+		                  WHERE pr.probe_config_id = pc.id AND pr.executed_at >= pc.next_run_at)
+	`, append(silenceArgs(now), cutoff.Format(db.SQLiteTimeFormat))...)
+	if err != nil {
+		return 0, err
+	}
+
+	var overdue []overdueConfig
+	for rows.Next() {
+		var cfg overdueConfig
+		var interval string
+		var timezone *string
+		var activeWindows db.JSONStringArray
+		var nextRunAt db.NullTime
+		var paused int
+		if err := rows.Scan(&cfg.id, &cfg.name, &interval, &timezone, &activeWindows, &nextRunAt, &cfg.channels,
+			&paused, &cfg.silenced, &cfg.lastSeen, &cfg.hasExecutable); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if sched, err := parseSchedule(interval, timezone, activeWindows); err == nil {
+			cfg.schedule = sched
+		}
+		cfg.nextRunAt = nextRunAt.Time
+		cfg.watcherPaused = paused != 0
+		overdue = append(overdue, cfg)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	recorded := 0
+	detectedAt := now.Format(db.SQLiteTimeFormat)
+	for _, cfg := range overdue {
+		reason := missedRunReason(&cfg, now)
+
+		// Continue after the slots of this outage that earlier passes recorded
+		var latest db.NullTime
+		if err := s.db.DB().QueryRowContext(ctx, `
+			SELECT MAX(scheduled_at) FROM missed_runs WHERE probe_config_id = ? AND scheduled_at >= ?
+		`, cfg.id, cfg.nextRunAt.UTC().Format(db.SQLiteTimeFormat)).Scan(&latest); err != nil {
+			return recorded, err
+		}
+		scheduledAt := cfg.nextRunAt
+		if latest.Valid {
+			if cfg.schedule == nil {
+				continue
+			}
+			scheduledAt = cfg.schedule.Next(latest.Time)
+		}
+
+		for slots := 0; slots < maxMissedSlotsPerCheck && !scheduledAt.IsZero() && scheduledAt.Before(cutoff); {
+			result, err := s.db.DB().ExecContext(ctx, `
+				INSERT OR IGNORE INTO missed_runs (probe_config_id, scheduled_at, reason, detected_at)
+				VALUES (?, ?, ?, ?)
+			`, cfg.id, scheduledAt.UTC().Format(db.SQLiteTimeFormat), reason, detectedAt)
+			if err != nil {
+				return recorded, err
+			}
+			if inserted, _ := result.RowsAffected(); inserted > 0 {
+				slots++
+				recorded++
+				if scheduledAt.Equal(cfg.nextRunAt) {
+					slog.Warn("missed probe run", "config_id", cfg.id, "name", cfg.name, "scheduled_at", scheduledAt, "reason", reason)
+					s.notifyMissedRun(ctx, &cfg, scheduledAt, reason)
+				}
+			}
+			if cfg.schedule == nil {
+				break
+			}
+			scheduledAt = cfg.schedule.Next(scheduledAt)
+		}
+	}
+
+	return recorded, nil
+}
+
+func missedRunReason(cfg *overdueConfig, now time.Time) string {
+	switch {
+	case cfg.watcherPaused:
+		return missedReasonWatcherPaused
+	case !cfg.lastSeen.Valid || now.Sub(cfg.lastSeen.Time) >= watcherHealthyTimeout:
+		return missedReasonWatcherOffline
+	case !cfg.hasExecutable:
+		return missedReasonNoExecutable
+	default:
+		return missedReasonNoResult
+	}
+}
+
+func (s *Server) notifyMissedRun(ctx context.Context, cfg *overdueConfig, scheduledAt time.Time, reason string) {
+	// Paused watchers and silenced configs don't notify, same as status changes
+	if cfg.watcherPaused || cfg.silenced || len(cfg.channels) == 0 {
+		return
+	}
+	// A failing dependency, like the network of the watcher, explains it
+	parent, err := s.failingDependency(ctx, cfg.id)
+	if err != nil {
+		slog.Error("failed to check dependencies", "config_id", cfg.id, "error", err)
+	} else if parent != nil {
+		return
+	}
+	s.dispatcher.NotifyMissedRun(ctx, cfg.channels, &notify.MissedRun{
+		ProbeName:   cfg.name,
+		ScheduledAt: scheduledAt,
+		Reason:      reason,
+	})
+}
+
+func (s *Server) handleListMissedRuns(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	query := `
+		SELECT mr.id, mr.probe_config_id, pc.name, pc.watcher_id, w.name,
+		       mr.scheduled_at, mr.reason, mr.detected_at
+		FROM missed_runs mr
+		JOIN probe_configs pc ON pc.id = mr.probe_config_id
+		LEFT JOIN watchers w ON w.id = pc.watcher_id
+		WHERE 1=1
+	`
+	args := []any{}
+
+	if configID := r.URL.Query().Get("config_id"); configID != "" {
+		query += " AND mr.probe_config_id = ?"
+		args = append(args, configID)
+	}
+	if watcherID := r.URL.Query().Get("watcher"); watcherID != "" {
+		query += " AND pc.watcher_id = ?"
+		args = append(args, watcherID)
+	}
+	if since := r.URL.Query().Get("since"); since != "" {
+		query += " AND mr.scheduled_at > ?"
+		args = append(args, since)
+	}
+
+	query += " ORDER BY mr.scheduled_at DESC"
+
+	if limit := r.URL.Query().Get("limit"); limit != "" {
+		query += " LIMIT ?"
+		args = append(args, limit)
+	} else {
+		query += " LIMIT 100"
+	}
+
+	rows, err := s.db.DB().QueryContext(ctx, query, args...)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	var missedRuns []map[string]any
+	for rows.Next() {
+		var id, configID int
+		var configName string
+		var watcherID *int
+		var watcherName, reason *string
+		var scheduledAt, detectedAt db.NullTime
+
+		if err := rows.Scan(&id, &configID, &configName, &watcherID, &watcherName,
+			&scheduledAt, &reason, &detectedAt); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		missed := map[string]any{
+			"id":              id,
+			"probe_config_id": configID,
+			"config_name":     configName,
+		}
+		if watcherID != nil {
+			missed["watcher_id"] = *watcherID
+		}
+		if watcherName != nil {
+			missed["watcher_name"] = *watcherName
+		}
+		if scheduledAt.Valid {
+			missed["scheduled_at"] = scheduledAt.Time
+		}
+		if reason != nil {
+			missed["reason"] = *reason
+		}
+		if detectedAt.Valid {
+			missed["detected_at"] = detectedAt.Time
+		}
+
+		missedRuns = append(missedRuns, missed)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(missedRuns)
+}
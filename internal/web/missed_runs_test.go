@@ -0,0 +1,186 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jandubois/monitor/internal/db"
+)
+
+func TestMissedRunReason(t *testing.T) {
+	now := time.Now()
+	recent := db.NullTime{Time: now.Add(-5 * time.Second), Valid: true}
+	stale := db.NullTime{Time: now.Add(-5 * time.Minute), Valid: true}
+
+	tests := []struct {
+		name     string
+		cfg      overdueConfig
+		expected string
+	}{
+		{"paused", overdueConfig{watcherPaused: true, lastSeen: stale}, missedReasonWatcherPaused},
+		{"never seen", overdueConfig{hasExecutable: true}, missedReasonWatcherOffline},
+		{"stale heartbeat", overdueConfig{lastSeen: stale, hasExecutable: true}, missedReasonWatcherOffline},
+		{"no executable", overdueConfig{lastSeen: recent}, missedReasonNoExecutable},
+		{"online with executable", overdueConfig{lastSeen: recent, hasExecutable: true}, missedReasonNoResult},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := missedRunReason(&tt.cfg, now); got != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestDetectMissedRuns(t *testing.T) {
+	server, cleanup := testServer(t)
+	if server == nil {
+		return
+	}
+	defer cleanup()
+
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+	sqlDB := server.db.DB()
+
+	// Watcher last seen an hour ago
+	result, err := sqlDB.ExecContext(ctx, `
+		INSERT INTO watchers (name, token, approved, paused, last_seen_at)
+		VALUES ('offline-watcher', 'offline-token', 1, 0, ?)
+	`, now.Add(-time.Hour).Format(db.SQLiteTimeFormat))
+	if err != nil {
+		t.Fatalf("failed to create watcher: %v", err)
+	}
+	watcherID, _ := result.LastInsertId()
+
+	result, err = sqlDB.ExecContext(ctx, `
+		INSERT INTO probe_types (name, version, arguments) VALUES ('missed-test', '1.0.0', '{}')
+	`)
+	if err != nil {
+		t.Fatalf("failed to create probe type: %v", err)
+	}
+	probeTypeID, _ := result.LastInsertId()
+
+	// Config that was due 50 minutes ago with a 15m interval: slots at
+	// -50m, -35m, -20m are past the 5m grace period, -5m is not.
+	result, err = sqlDB.ExecContext(ctx, `
+		INSERT INTO probe_configs (probe_type_id, watcher_id, name, enabled, arguments, interval, next_run_at)
+		VALUES (?, ?, 'overdue', 1, '{}', '15m', ?)
+	`, probeTypeID, watcherID, now.Add(-50*time.Minute).Format(db.SQLiteTimeFormat))
+	if err != nil {
+		t.Fatalf("failed to create config: %v", err)
+	}
+	configID, _ := result.LastInsertId()
+
+	// Config that is not yet due
+	_, err = sqlDB.ExecContext(ctx, `
+		INSERT INTO probe_configs (probe_type_id, watcher_id, name, enabled, arguments, interval, next_run_at)
+		VALUES (?, ?, 'upcoming', 1, '{}', '15m', ?)
+	`, probeTypeID, watcherID, now.Add(10*time.Minute).Format(db.SQLiteTimeFormat))
+	if err != nil {
+		t.Fatalf("failed to create config: %v", err)
+	}
+
+	recorded, err := server.detectMissedRuns(ctx, now)
+	if err != nil {
+		t.Fatalf("detectMissedRuns failed: %v", err)
+	}
+	if recorded != 3 {
+		t.Errorf("expected 3 missed runs, got %d", recorded)
+	}
+
+	// A second pass must not duplicate records
+	recorded, err = server.detectMissedRuns(ctx, now)
+	if err != nil {
+		t.Fatalf("detectMissedRuns failed: %v", err)
+	}
+	if recorded != 0 {
+		t.Errorf("expected no new missed runs on second pass, got %d", recorded)
+	}
+
+	req := httptest.NewRequest("GET", "/api/missed-runs", nil)
+	w := httptest.NewRecorder()
+	server.handleListMissedRuns(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+
+	var resp []map[string]any
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(resp) != 3 {
+		t.Fatalf("expected 3 missed runs, got %d", len(resp))
+	}
+	for _, missed := range resp {
+		if int64(missed["probe_config_id"].(float64)) != configID {
+			t.Errorf("unexpected config in missed run: %v", missed)
+		}
+		if missed["reason"] != missedReasonWatcherOffline {
+			t.Errorf("expected reason %q, got %v", missedReasonWatcherOffline, missed["reason"])
+		}
+	}
+}
+
+func TestDetectMissedRunsLongOutage(t *testing.T) {
+	server, cleanup := testServer(t)
+	if server == nil {
+		return
+	}
+	defer cleanup()
+
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+	sqlDB := server.db.DB()
+
+	result, err := sqlDB.ExecContext(ctx, `
+		INSERT INTO watchers (name, token, approved, paused, last_seen_at)
+		VALUES ('outage-watcher', 'outage-token', 1, 0, ?)
+	`, now.Add(-3*time.Hour).Format(db.SQLiteTimeFormat))
+	if err != nil {
+		t.Fatalf("failed to create watcher: %v", err)
+	}
+	watcherID, _ := result.LastInsertId()
+	result, err = sqlDB.ExecContext(ctx, `
+		INSERT INTO probe_types (name, version, arguments) VALUES ('outage-test', '1.0.0', '{}')
+	`)
+	if err != nil {
+		t.Fatalf("failed to create probe type: %v", err)
+	}
+	probeTypeID, _ := result.LastInsertId()
+
+	// 150 slots of 1m are past the 5m grace period
+	slots := 150
+	result, err = sqlDB.ExecContext(ctx, `
+		INSERT INTO probe_configs (probe_type_id, watcher_id, name, enabled, arguments, interval, next_run_at)
+		VALUES (?, ?, 'long-outage', 1, '{}', '1m', ?)
+	`, probeTypeID, watcherID, now.Add(-time.Duration(slots+5)*time.Minute).Format(db.SQLiteTimeFormat))
+	if err != nil {
+		t.Fatalf("failed to create config: %v", err)
+	}
+	configID, _ := result.LastInsertId()
+
+	// The first pass stops at the cap, the second records the rest
+	for i, expected := range []int{maxMissedSlotsPerCheck, slots - maxMissedSlotsPerCheck, 0} {
+		recorded, err := server.detectMissedRuns(ctx, now)
+		if err != nil {
+			t.Fatalf("detectMissedRuns failed: %v", err)
+		}
+		if recorded != expected {
+			t.Errorf("pass %d: expected %d missed runs, got %d", i+1, expected, recorded)
+		}
+	}
+
+	var count int
+	var latest string
+	sqlDB.QueryRowContext(ctx, `SELECT COUNT(*), MAX(scheduled_at) FROM missed_runs WHERE probe_config_id = ?`, configID).Scan(&count, &latest)
+	if count != slots || latest != now.Add(-6*time.Minute).Format(db.SQLiteTimeFormat) {
+		t.Errorf("expected %d missed runs before the grace period, got %d up to %s", slots, count, latest)
+	}
+}
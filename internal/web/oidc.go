@@ -0,0 +1,669 @@
+package web
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jandubois/monitor/internal/config"
+)
+
+const (
+	oidcCallbackPath = "/api/oidc/callback"
+
+	// oidcCookie holds the state, nonce and PKCE verifier of a login in
+	// progress.
+	oidcCookie       = "monitor_oidc"
+	oidcLoginTimeout = 10 * time.Minute
+
+	// oidcKeyRefreshInterval limits how often an ID token signed with an
+	// unknown key makes us fetch the provider's keys again.
+	oidcKeyRefreshInterval = time.Minute
+
+	// oidcClockSkew is how far the provider's clock may be off from ours.
+	oidcClockSkew = time.Minute
+)
+
+// oidcProvider logs users in with an OpenID Connect identity provider,
+// using the authorization code flow with PKCE.
+type oidcProvider struct {
+	config *config.OIDCConfig
+	client *http.Client
+
+	mu            sync.Mutex
+	discovery     *oidcDiscovery
+	keys          map[string]crypto.PublicKey
+	keysFetchedAt time.Time
+}
+
+// oidcDiscovery is the part of the provider metadata we use.
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// newOIDCProvider checks the configuration. The provider itself is only
+// contacted at the first login, so the server starts while it is down.
+func newOIDCProvider(cfg *config.OIDCConfig) (*oidcProvider, error) {
+	if cfg.ClientID == "" {
+		return nil, errors.New("OIDC needs a client ID")
+	}
+	for group, role := range cfg.GroupRoles {
+		if !validRole(role) {
+			return nil, fmt.Errorf("OIDC group %q: role must be %s, %s or %s", group, roleViewer, roleOperator, roleAdmin)
+		}
+	}
+	if cfg.DefaultRole != "" && !validRole(cfg.DefaultRole) {
+		return nil, fmt.Errorf("OIDC default role must be %s, %s or %s", roleViewer, roleOperator, roleAdmin)
+	}
+	return &oidcProvider{
+		config: cfg,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+// getJSON fetches a JSON document from the provider.
+func (p *oidcProvider) getJSON(ctx context.Context, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// discover returns the provider metadata, fetching it on first use.
+func (p *oidcProvider) discover(ctx context.Context) (*oidcDiscovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	var d oidcDiscovery
+	issuer := strings.TrimSuffix(p.config.Issuer, "/")
+	if err := p.getJSON(ctx, issuer+"/.well-known/openid-configuration", &d); err != nil {
+		return nil, fmt.Errorf("OIDC discovery failed: %w", err)
+	}
+	if strings.TrimSuffix(d.Issuer, "/") != issuer {
+		return nil, fmt.Errorf("OIDC discovery returned issuer %q instead of %q", d.Issuer, p.config.Issuer)
+	}
+	if d.AuthorizationEndpoint == "" || d.TokenEndpoint == "" || d.JWKSURI == "" {
+		return nil, errors.New("OIDC discovery document is missing endpoints")
+	}
+	p.discovery = &d
+	return p.discovery, nil
+}
+
+// authCodeURL returns the provider URL that starts a login.
+func (p *oidcProvider) authCodeURL(ctx context.Context, redirectURI, state, nonce, verifier string) (string, error) {
+	d, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	scopes := []string{"openid"}
+	for _, scope := range p.config.Scopes {
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	challenge := sha256.Sum256([]byte(verifier))
+
+	params := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.config.ClientID},
+		"redirect_uri":          {redirectURI},
+		"scope":                 {strings.Join(scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+	sep := "?"
+	if strings.Contains(d.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return d.AuthorizationEndpoint + sep + params.Encode(), nil
+}
+
+// exchange trades an authorization code for an ID token.
+func (p *oidcProvider) exchange(ctx context.Context, redirectURI, code, verifier string) (string, error) {
+	d, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {redirectURI},
+		"client_id":     {p.config.ClientID},
+		"code_verifier": {verifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", d.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.config.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.config.ClientID), url.QueryEscape(p.config.ClientSecret))
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", err
+	}
+
+	var result struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return "", fmt.Errorf("token request failed: %s", resp.Status)
+	}
+	if result.Error != "" {
+		return "", fmt.Errorf("token request failed: %s %s", result.Error, result.ErrorDescription)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token request failed: %s", resp.Status)
+	}
+	if result.IDToken == "" {
+		return "", errors.New("token response has no ID token")
+	}
+	return result.IDToken, nil
+}
+
+// key returns the provider's signing key with the given ID. Unknown keys
+// make it fetch the keys again, since providers rotate them.
+func (p *oidcProvider) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	d, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	lookup := func() crypto.PublicKey {
+		// Providers with a single key may leave out the key ID
+		if kid == "" && len(p.keys) == 1 {
+			for _, key := range p.keys {
+				return key
+			}
+		}
+		return p.keys[kid]
+	}
+	if key := lookup(); key != nil {
+		return key, nil
+	}
+	if time.Since(p.keysFetchedAt) < oidcKeyRefreshInterval {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	var jwks struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := p.getJSON(ctx, d.JWKSURI, &jwks); err != nil {
+		return nil, fmt.Errorf("failed to fetch signing keys: %w", err)
+	}
+	p.keys = make(map[string]crypto.PublicKey)
+	p.keysFetchedAt = time.Now()
+	for _, jwk := range jwks.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			slog.Warn("skipping OIDC signing key", "kid", jwk.Kid, "error", err)
+			continue
+		}
+		p.keys[jwk.Kid] = key
+	}
+	if key := lookup(); key != nil {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// jsonWebKey is an RSA or EC public key from a JWK set.
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (k *jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	decode := func(s string) (*big.Int, error) {
+		b, err := base64.RawURLEncoding.DecodeString(s)
+		if err != nil || len(b) == 0 {
+			return nil, errors.New("invalid key parameter")
+		}
+		return new(big.Int).SetBytes(b), nil
+	}
+
+	switch k.Kty {
+	case "RSA":
+		n, err := decode(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decode(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, errors.New("invalid RSA exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decode(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decode(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+// oidcClaims are the ID token claims we check or use. The rest, including
+// the groups claim, are kept in raw.
+type oidcClaims struct {
+	Issuer            string        `json:"iss"`
+	Subject           string        `json:"sub"`
+	Audience          audienceClaim `json:"aud"`
+	AuthorizedParty   string        `json:"azp"`
+	Expiry            float64       `json:"exp"`
+	Nonce             string        `json:"nonce"`
+	PreferredUsername string        `json:"preferred_username"`
+	Email             string        `json:"email"`
+
+	raw map[string]any
+}
+
+// audienceClaim is a single audience or a list of them.
+type audienceClaim []string
+
+func (a *audienceClaim) UnmarshalJSON(b []byte) error {
+	var single string
+	if json.Unmarshal(b, &single) == nil {
+		*a = []string{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(b, &list); err != nil {
+		return err
+	}
+	*a = list
+	return nil
+}
+
+// verifyIDToken checks the signature and claims of an ID token and
+// returns its claims.
+func (p *oidcProvider) verifyIDToken(ctx context.Context, token, nonce string) (*oidcClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed ID token")
+	}
+	headerJSON, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, errors.New("malformed ID token header")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := json.Unmarshal(headerJSON, &header); err != nil {
+		return nil, errors.New("malformed ID token header")
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.New("malformed ID token signature")
+	}
+
+	key, err := p.key(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifySignature(header.Alg, key, []byte(parts[0]+"."+parts[1]), signature); err != nil {
+		return nil, err
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, errors.New("malformed ID token payload")
+	}
+	var claims oidcClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, fmt.Errorf("malformed ID token claims: %w", err)
+	}
+	if err := json.Unmarshal(payload, &claims.raw); err != nil {
+		return nil, fmt.Errorf("malformed ID token claims: %w", err)
+	}
+
+	if strings.TrimSuffix(claims.Issuer, "/") != strings.TrimSuffix(p.config.Issuer, "/") {
+		return nil, fmt.Errorf("ID token is from issuer %q", claims.Issuer)
+	}
+	if !slices.Contains(claims.Audience, p.config.ClientID) {
+		return nil, errors.New("ID token is for another client")
+	}
+	if len(claims.Audience) > 1 && claims.AuthorizedParty != "" && claims.AuthorizedParty != p.config.ClientID {
+		return nil, errors.New("ID token is for another client")
+	}
+	if time.Unix(int64(claims.Expiry), 0).Before(time.Now().Add(-oidcClockSkew)) {
+		return nil, errors.New("ID token has expired")
+	}
+	if subtle.ConstantTimeCompare([]byte(claims.Nonce), []byte(nonce)) != 1 {
+		return nil, errors.New("ID token nonce doesn't match")
+	}
+	if claims.Subject == "" {
+		return nil, errors.New("ID token has no subject")
+	}
+	return &claims, nil
+}
+
+// verifySignature checks a JWS signature. Only the asymmetric algorithms
+// providers sign ID tokens with are accepted.
+func verifySignature(alg string, key crypto.PublicKey, signed, signature []byte) error {
+	var hash crypto.Hash
+	switch alg {
+	case "RS256", "ES256":
+		hash = crypto.SHA256
+	case "RS384", "ES384":
+		hash = crypto.SHA384
+	case "RS512", "ES512":
+		hash = crypto.SHA512
+	default:
+		return fmt.Errorf("unsupported ID token algorithm %q", alg)
+	}
+	h := hash.New()
+	h.Write(signed)
+	digest := h.Sum(nil)
+
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		if !strings.HasPrefix(alg, "RS") {
+			return fmt.Errorf("algorithm %s doesn't match the RSA key", alg)
+		}
+		if rsa.VerifyPKCS1v15(key, hash, digest, signature) != nil {
+			return errors.New("invalid ID token signature")
+		}
+	case *ecdsa.PublicKey:
+		size := (key.Curve.Params().BitSize + 7) / 8
+		if !strings.HasPrefix(alg, "ES") || len(signature) != 2*size {
+			return errors.New("invalid ID token signature")
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(key, digest, r, s) {
+			return errors.New("invalid ID token signature")
+		}
+	default:
+		return errors.New("unsupported signing key")
+	}
+	return nil
+}
+
+// role returns the role for the groups in the claims: the highest role of
+// any mapped group, or the default role.
+func (p *oidcProvider) role(claims *oidcClaims) string {
+	claim := p.config.GroupsClaim
+	if claim == "" {
+		claim = "groups"
+	}
+	var groups []string
+	switch v := claims.raw[claim].(type) {
+	case string:
+		groups = []string{v}
+	case []any:
+		for _, g := range v {
+			if s, ok := g.(string); ok {
+				groups = append(groups, s)
+			}
+		}
+	}
+
+	role := p.config.DefaultRole
+	for _, group := range groups {
+		if r, ok := p.config.GroupRoles[group]; ok && (role == "" || roleAtLeast(r, role)) {
+			role = r
+		}
+	}
+	return role
+}
+
+// oidcRedirectURI returns the callback URL registered with the provider.
+func (s *Server) oidcRedirectURI(r *http.Request) string {
+	if s.config.PublicURL != "" {
+		return strings.TrimSuffix(s.config.PublicURL, "/") + oidcCallbackPath
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + oidcCallbackPath
+}
+
+// handleLoginOptions tells the login page which ways to sign in exist.
+func (s *Server) handleLoginOptions(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"oidc": s.oidc != nil})
+}
+
+func (s *Server) handleOIDCLogin(w http.ResponseWriter, r *http.Request) {
+	if s.oidc == nil {
+		http.Error(w, "single sign-on is not configured", http.StatusNotFound)
+		return
+	}
+
+	var secrets [3]string // state, nonce, PKCE verifier
+	for i := range secrets {
+		secret, err := newSecret()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		secrets[i] = secret
+	}
+	state, nonce, verifier := secrets[0], secrets[1], secrets[2]
+
+	authURL, err := s.oidc.authCodeURL(r.Context(), s.oidcRedirectURI(r), state, nonce, verifier)
+	if err != nil {
+		slog.Error("failed to start OIDC login", "error", err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	returnTo := r.URL.Query().Get("return_to")
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcCookie,
+		Value:    strings.Join([]string{state, nonce, verifier, base64.RawURLEncoding.EncodeToString([]byte(returnTo))}, "."),
+		Path:     "/api/oidc/",
+		MaxAge:   int(oidcLoginTimeout.Seconds()),
+		HttpOnly: true,
+		Secure:   s.secureCookies(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+func (s *Server) handleOIDCCallback(w http.ResponseWriter, r *http.Request) {
+	if s.oidc == nil {
+		http.Error(w, "single sign-on is not configured", http.StatusNotFound)
+		return
+	}
+	ctx := r.Context()
+
+	// Errors go back to the login page, which shows them
+	fail := func(message string, err error) {
+		slog.Warn("OIDC login failed", "reason", message, "error", err)
+		http.Redirect(w, r, "/?login_error="+url.QueryEscape(message), http.StatusFound)
+	}
+
+	cookie, err := r.Cookie(oidcCookie)
+	if err != nil {
+		fail("The login took too long or was started elsewhere. Please try again.", err)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: oidcCookie, Path: "/api/oidc/", MaxAge: -1, HttpOnly: true})
+	parts := strings.Split(cookie.Value, ".")
+	if len(parts) != 4 {
+		fail("The login took too long or was started elsewhere. Please try again.", errors.New("malformed login cookie"))
+		return
+	}
+	state, nonce, verifier := parts[0], parts[1], parts[2]
+	returnTo := "/"
+	if b, err := base64.RawURLEncoding.DecodeString(parts[3]); err == nil && isLocalPath(string(b)) {
+		returnTo = string(b)
+	}
+
+	query := r.URL.Query()
+	if e := query.Get("error"); e != "" {
+		fail("The identity provider refused the login: "+strings.TrimSpace(e+" "+query.Get("error_description")), nil)
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(query.Get("state")), []byte(state)) != 1 {
+		fail("The login took too long or was started elsewhere. Please try again.", errors.New("state mismatch"))
+		return
+	}
+
+	idToken, err := s.oidc.exchange(ctx, s.oidcRedirectURI(r), query.Get("code"), verifier)
+	if err != nil {
+		fail("The identity provider didn't confirm the login.", err)
+		return
+	}
+	claims, err := s.oidc.verifyIDToken(ctx, idToken, nonce)
+	if err != nil {
+		fail("The identity provider didn't confirm the login.", err)
+		return
+	}
+	role := s.oidc.role(claims)
+	if role == "" {
+		fail("Your account isn't in a group with access to the monitor.", fmt.Errorf("no role for subject %q", claims.Subject))
+		return
+	}
+
+	userID, username, err := s.oidcUser(ctx, claims, role)
+	if errors.Is(err, errUsernameTaken) {
+		fail("Your user name belongs to another account. Ask an admin to rename or delete it.", err)
+		return
+	}
+	if err != nil {
+		fail("The login failed.", err)
+		return
+	}
+	if err := s.startSession(w, r, userID); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	slog.Info("user logged in", "username", username, "via", "oidc", "role", role)
+	http.Redirect(w, r, returnTo, http.StatusFound)
+}
+
+// errUsernameTaken means the username of a new OIDC user belongs to a
+// local account. Linking them could hand that account to whoever controls
+// the name at the provider.
+var errUsernameTaken = errors.New("username belongs to another account")
+
+// oidcUser returns the user linked to the subject of the claims, creating
+// it on the first login. The role follows the groups at every login.
+func (s *Server) oidcUser(ctx context.Context, claims *oidcClaims, role string) (int, string, error) {
+	issuer := strings.TrimSuffix(s.config.OIDC.Issuer, "/")
+
+	var userID int
+	var username string
+	err := s.db.DB().QueryRowContext(ctx, `
+		SELECT id, username FROM users WHERE oidc_issuer = ? AND oidc_subject = ?
+	`, issuer, claims.Subject).Scan(&userID, &username)
+	if err == nil {
+		_, err = s.db.DB().ExecContext(ctx, `
+			UPDATE users SET role = ?, updated_at = datetime('now') WHERE id = ? AND role <> ?
+		`, role, userID, role)
+		return userID, username, err
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return 0, "", err
+	}
+
+	username = claims.PreferredUsername
+	if username == "" {
+		username = claims.Email
+	}
+	if username == "" {
+		username = claims.Subject
+	}
+	// An empty password hash never matches, so the account can only sign
+	// in through the provider
+	result, err := s.db.DB().ExecContext(ctx, `
+		INSERT INTO users (username, password_hash, role, oidc_issuer, oidc_subject)
+		VALUES (?, '', ?, ?, ?)
+	`, username, role, issuer, claims.Subject)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE") {
+			return 0, "", fmt.Errorf("%w: %q", errUsernameTaken, username)
+		}
+		return 0, "", err
+	}
+	id, _ := result.LastInsertId()
+	return int(id), username, nil
+}
+
+// isLocalPath reports whether a return address stays on this server, so
+// the login can't be used to redirect elsewhere.
+func isLocalPath(path string) bool {
+	return strings.HasPrefix(path, "/") && !strings.HasPrefix(path, "//") && !strings.HasPrefix(path, "/\\")
+}
@@ -0,0 +1,329 @@
+package web
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jandubois/monitor/internal/config"
+)
+
+// mockOIDC is a minimal OpenID Connect provider. Its authorize method
+// stands in for the user logging in at the provider.
+type mockOIDC struct {
+	t      *testing.T
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	mu     sync.Mutex
+	codes  map[string]mockLogin
+	claims map[string]any // Claims for the next login
+}
+
+type mockLogin struct {
+	challenge string
+	nonce     string
+	clientID  string
+}
+
+func newMockOIDC(t *testing.T) *mockOIDC {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &mockOIDC{t: t, key: key, codes: make(map[string]mockLogin)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 m.server.URL,
+			"authorization_endpoint": m.server.URL + "/authorize",
+			"token_endpoint":         m.server.URL + "/token",
+			"jwks_uri":               m.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("GET /jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "test-key",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("POST /token", m.handleToken)
+	m.server = httptest.NewServer(mux)
+	t.Cleanup(m.server.Close)
+	return m
+}
+
+// authorize checks the login request and returns the callback URL the
+// provider would redirect to.
+func (m *mockOIDC) authorize(authURL string, claims map[string]any) string {
+	m.t.Helper()
+	u, err := url.Parse(authURL)
+	if err != nil {
+		m.t.Fatal(err)
+	}
+	q := u.Query()
+	if q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" {
+		m.t.Fatalf("expected a PKCE challenge, got %v", q)
+	}
+	if !strings.Contains(" "+q.Get("scope")+" ", " openid ") {
+		m.t.Fatalf("expected the openid scope, got %q", q.Get("scope"))
+	}
+
+	code := "code-" + q.Get("state")
+	m.mu.Lock()
+	m.codes[code] = mockLogin{challenge: q.Get("code_challenge"), nonce: q.Get("nonce"), clientID: q.Get("client_id")}
+	m.claims = claims
+	m.mu.Unlock()
+	return q.Get("redirect_uri") + "?" + url.Values{"code": {code}, "state": {q.Get("state")}}.Encode()
+}
+
+func (m *mockOIDC) handleToken(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	m.mu.Lock()
+	login, ok := m.codes[r.PostForm.Get("code")]
+	delete(m.codes, r.PostForm.Get("code"))
+	claims := m.claims
+	m.mu.Unlock()
+
+	verifier := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if !ok || base64.RawURLEncoding.EncodeToString(verifier[:]) != login.challenge {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	payload := map[string]any{
+		"iss":   m.server.URL,
+		"aud":   login.clientID,
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"iat":   time.Now().Unix(),
+		"nonce": login.nonce,
+	}
+	for k, v := range claims {
+		payload[k] = v
+	}
+	json.NewEncoder(w).Encode(map[string]string{"id_token": m.sign(payload)})
+}
+
+// sign returns an RS256 JWT of the payload.
+func (m *mockOIDC) sign(payload map[string]any) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "test-key", "typ": "JWT"})
+	body, _ := json.Marshal(payload)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(body)
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, m.key, crypto.SHA256, digest[:])
+	if err != nil {
+		m.t.Fatal(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func (m *mockOIDC) config() config.OIDCConfig {
+	return config.OIDCConfig{
+		Issuer:      m.server.URL,
+		ClientID:    "monitor",
+		Scopes:      []string{"profile", "groups"},
+		GroupRoles:  map[string]string{"sre": roleOperator, "platform": roleAdmin},
+		DefaultRole: roleViewer,
+	}
+}
+
+func TestOIDCProvider(t *testing.T) {
+	mock := newMockOIDC(t)
+	cfg := mock.config()
+	p, err := newOIDCProvider(&cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	const redirectURI = "http://monitor.test/api/oidc/callback"
+
+	login := func(nonce, verifier string, claims map[string]any) (*oidcClaims, error) {
+		t.Helper()
+		authURL, err := p.authCodeURL(ctx, redirectURI, "state", "nonce", "verifier")
+		if err != nil {
+			t.Fatal(err)
+		}
+		callback, _ := url.Parse(mock.authorize(authURL, claims))
+		token, err := p.exchange(ctx, redirectURI, callback.Query().Get("code"), verifier)
+		if err != nil {
+			return nil, err
+		}
+		return p.verifyIDToken(ctx, token, nonce)
+	}
+
+	claims, err := login("nonce", "verifier", map[string]any{"sub": "u1", "preferred_username": "alice", "groups": []string{"dev", "sre"}})
+	if err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+	if claims.Subject != "u1" || claims.PreferredUsername != "alice" {
+		t.Errorf("unexpected claims: %+v", claims)
+	}
+	if role := p.role(claims); role != roleOperator {
+		t.Errorf("expected role %s, got %s", roleOperator, role)
+	}
+
+	// The code is only good with the verifier that made the challenge
+	if _, err := login("nonce", "other verifier", map[string]any{"sub": "u1"}); err == nil {
+		t.Error("expected a wrong PKCE verifier to fail")
+	}
+	if _, err := login("other nonce", "verifier", map[string]any{"sub": "u1"}); err == nil {
+		t.Error("expected a wrong nonce to fail")
+	}
+	if _, err := login("nonce", "verifier", map[string]any{"sub": "u1", "aud": "someone-else"}); err == nil {
+		t.Error("expected a token for another client to fail")
+	}
+	if _, err := login("nonce", "verifier", map[string]any{"sub": "u1", "exp": time.Now().Add(-time.Hour).Unix()}); err == nil {
+		t.Error("expected an expired token to fail")
+	}
+
+	// A tampered payload breaks the signature
+	token := mock.sign(map[string]any{"iss": mock.server.URL, "aud": "monitor", "sub": "u1", "nonce": "n", "exp": time.Now().Add(time.Hour).Unix()})
+	parts := strings.Split(token, ".")
+	forged, _ := json.Marshal(map[string]any{"iss": mock.server.URL, "aud": "monitor", "sub": "root", "nonce": "n", "exp": time.Now().Add(time.Hour).Unix()})
+	parts[1] = base64.RawURLEncoding.EncodeToString(forged)
+	if _, err := p.verifyIDToken(ctx, strings.Join(parts, "."), "n"); err == nil {
+		t.Error("expected a tampered token to fail")
+	}
+	// Unsigned tokens are never accepted
+	parts[0] = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`))
+	if _, err := p.verifyIDToken(ctx, parts[0]+"."+parts[1]+".", "n"); err == nil {
+		t.Error("expected an unsigned token to fail")
+	}
+}
+
+func TestOIDCRole(t *testing.T) {
+	tests := []struct {
+		name        string
+		defaultRole string
+		groups      any
+		expected    string
+	}{
+		{"highest group wins", "", []any{"sre", "platform"}, roleAdmin},
+		{"default role", roleViewer, []any{"dev"}, roleViewer},
+		{"default is a minimum", roleOperator, []any{"readers"}, roleOperator},
+		{"no access", "", []any{"dev"}, ""},
+		{"single group string", "", "sre", roleOperator},
+		{"no groups claim", roleViewer, nil, roleViewer},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &oidcProvider{config: &config.OIDCConfig{
+				GroupRoles:  map[string]string{"readers": roleViewer, "sre": roleOperator, "platform": roleAdmin},
+				DefaultRole: tt.defaultRole,
+			}}
+			claims := &oidcClaims{raw: map[string]any{}}
+			if tt.groups != nil {
+				claims.raw["groups"] = tt.groups
+			}
+			if got := p.role(claims); got != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestIsLocalPath(t *testing.T) {
+	for path, expected := range map[string]bool{
+		"/":                    true,
+		"/?page=failures":      true,
+		"//evil.example":       false,
+		"/\\evil.example":      false,
+		"https://evil.example": false,
+		"":                     false,
+	} {
+		if got := isLocalPath(path); got != expected {
+			t.Errorf("isLocalPath(%q): expected %v, got %v", path, expected, got)
+		}
+	}
+}
+
+func TestOIDCLogin(t *testing.T) {
+	server, cleanup := testServer(t)
+	if server == nil {
+		return
+	}
+	defer cleanup()
+
+	mock := newMockOIDC(t)
+	server.config.OIDC = mock.config()
+	server.config.PublicURL = "http://monitor.test"
+	server.oidc, _ = newOIDCProvider(&server.config.OIDC)
+	handler := server.routes()
+
+	login := func(claims map[string]any) *httptest.ResponseRecorder {
+		t.Helper()
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", "/api/oidc/login?return_to=/?page=failures", nil))
+		if w.Code != http.StatusFound {
+			t.Fatalf("expected a redirect to the provider, got %d %s", w.Code, w.Body.String())
+		}
+		callback := mock.authorize(w.Header().Get("Location"), claims)
+
+		req := httptest.NewRequest("GET", callback, nil)
+		for _, c := range w.Result().Cookies() {
+			req.AddCookie(c)
+		}
+		w = httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	w := login(map[string]any{"sub": "u1", "preferred_username": "bob", "groups": []string{"platform"}})
+	if w.Code != http.StatusFound || w.Header().Get("Location") != "/?page=failures" {
+		t.Fatalf("expected a redirect back to the app, got %d %s", w.Code, w.Header().Get("Location"))
+	}
+	var session *http.Cookie
+	for _, c := range w.Result().Cookies() {
+		if c.Name == sessionCookie && c.Value != "" {
+			session = c
+		}
+	}
+	if session == nil {
+		t.Fatal("expected a session cookie")
+	}
+
+	req := httptest.NewRequest("GET", "/api/me", nil)
+	req.AddCookie(session)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	var me map[string]any
+	json.NewDecoder(w.Body).Decode(&me)
+	if me["username"] != "bob" || me["role"] != roleAdmin || me["sso"] != true {
+		t.Errorf("unexpected user: %v", me)
+	}
+
+	// The role follows the groups at the next login
+	login(map[string]any{"sub": "u1", "preferred_username": "bob", "groups": []string{"sre"}})
+	var role string
+	server.db.DB().QueryRow(`SELECT role FROM users WHERE username = 'bob'`).Scan(&role)
+	if role != roleOperator {
+		t.Errorf("expected role %s after the groups changed, got %s", roleOperator, role)
+	}
+
+	// A local account's name can't be claimed through the provider
+	if _, err := CreateUser(context.Background(), server.db, "carol", "carol-password", roleAdmin); err != nil {
+		t.Fatal(err)
+	}
+	w = login(map[string]any{"sub": "u2", "preferred_username": "carol"})
+	if !strings.HasPrefix(w.Header().Get("Location"), "/?login_error=") {
+		t.Errorf("expected the login to fail, got %s", w.Header().Get("Location"))
+	}
+}
@@ -3,7 +3,6 @@ package web
 import (
 	"context"
 	"encoding/json"
-	"fmt"
 	"log/slog"
 	"net/http"
 	"time"
@@ -11,6 +10,7 @@ import (
 	"github.com/jandubois/monitor/internal/db"
 	"github.com/jandubois/monitor/internal/notify"
 	"github.com/jandubois/monitor/internal/probe"
+	"github.com/jandubois/monitor/internal/schedule"
 )
 
 // RegisterRequest is sent by watchers on startup.
@@ -34,8 +34,9 @@ type RegisterProbeType struct {
 
 // HeartbeatRequest is sent periodically by watchers.
 type HeartbeatRequest struct {
-	Name    string `json:"name"`
-	Version string `json:"version"`
+	Name       string `json:"name"`
+	Version    string `json:"version"`
+	QueueDepth int    `json:"queue_depth"` // Results queued on the watcher
 }
 
 // ResultRequest is sent by watchers when a probe completes.
@@ -69,7 +70,9 @@ type ProbeConfigResponse struct {
 	Subcommand     string         `json:"subcommand,omitempty"`
 	Name           string         `json:"name"`
 	Arguments      map[string]any `json:"arguments"`
-	Interval       string         `json:"interval"`
+	Interval       string         `json:"interval"` // Interval or cron expression
+	Timezone       string         `json:"timezone,omitempty"`
+	ActiveWindows  []string       `json:"active_windows,omitempty"`
 	TimeoutSeconds int            `json:"timeout_seconds"`
 	NextRunAt      *time.Time     `json:"next_run_at"`
 }
@@ -217,12 +220,19 @@ func (s *Server) handlePushHeartbeat(w http.ResponseWriter, r *http.Request) {
 	now := time.Now().UTC().Format(db.SQLiteTimeFormat)
 
 	_, err := s.db.DB().ExecContext(ctx, `
-		UPDATE watchers SET last_seen_at = ?, version = ? WHERE id = ?
-	`, now, req.Version, watcherID)
+		UPDATE watchers SET last_seen_at = ?, version = ?, queue_depth = ? WHERE id = ?
+	`, now, req.Version, req.QueueDepth, watcherID)
 	if err != nil {
 		http.Error(w, "failed to update heartbeat", http.StatusInternalServerError)
 		return
 	}
+	watcherName, _ := WatcherNameFromContext(ctx)
+	s.events.publish(eventHeartbeat, &watcherID, nil, map[string]any{
+		"watcher_id":  watcherID,
+		"name":        watcherName,
+		"version":     req.Version,
+		"queue_depth": req.QueueDepth,
+	})
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
@@ -244,7 +254,7 @@ func (s *Server) handlePushResult(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Parse next_run if provided by probe, otherwise calculate from interval
+	// Parse next_run if provided by probe, otherwise calculate from the schedule
 	var nextRunAt *time.Time
 	if req.NextRun != "" {
 		t, err := time.Parse(time.RFC3339, req.NextRun)
@@ -252,35 +262,93 @@ func (s *Server) handlePushResult(w http.ResponseWriter, r *http.Request) {
 			nextRunAt = &t
 		}
 	} else {
-		// Calculate next_run from interval
 		var intervalStr string
-		err := s.db.DB().QueryRowContext(ctx, `SELECT interval FROM probe_configs WHERE id = ?`, req.ProbeConfigID).Scan(&intervalStr)
+		var timezone *string
+		var activeWindows db.JSONStringArray
+		err := s.db.DB().QueryRowContext(ctx, `
+			SELECT interval, timezone, active_windows FROM probe_configs WHERE id = ?
+		`, req.ProbeConfigID).Scan(&intervalStr, &timezone, &activeWindows)
 		if err == nil {
-			if interval, err := parseInterval(intervalStr); err == nil && interval > 0 {
-				t := req.ExecutedAt.Add(interval)
-				nextRunAt = &t
+			if sched, err := parseSchedule(intervalStr, timezone, activeWindows); err == nil {
+				if t := sched.Next(req.ExecutedAt); !t.IsZero() {
+					nextRunAt = &t
+				}
 			}
 		}
 	}
 
+	// Results replayed from a watcher's queue can be older than what we
+	// already have; they are recorded but must not rewind the schedule or
+	// trigger notifications.
+	executedAt := req.ExecutedAt.UTC().Format(db.SQLiteTimeFormat)
+	var stale bool
+	s.db.DB().QueryRowContext(ctx, `
+		SELECT EXISTS (SELECT 1 FROM probe_results WHERE probe_config_id = ? AND executed_at > ?)
+	`, req.ProbeConfigID, executedAt).Scan(&stale)
+
+	// Metric rules can raise or replace the probe's own status
+	status, message, err := s.applyMetricRules(ctx, req.ProbeConfigID, req.ExecutedAt, probe.Status(req.Status), req.Message, req.Metrics)
+	if err != nil {
+		slog.Error("failed to apply metric rules", "probe_config_id", req.ProbeConfigID, "error", err)
+	}
+	req.Status, req.Message = string(status), message
+
+	// Values far outside the config's own history raise it to warning.
+	// Stale results would skew the baselines, so they are left out.
+	var anomaliesJSON *string
+	if !stale {
+		anomalies, err := s.checkAnomalies(ctx, req.ProbeConfigID, req.DurationMs, req.Metrics)
+		if err != nil {
+			slog.Error("failed to check anomalies", "probe_config_id", req.ProbeConfigID, "error", err)
+		}
+		if len(anomalies) > 0 {
+			status, req.Message = annotateAnomalies(status, req.Message, anomalies)
+			req.Status = string(status)
+			b, _ := json.Marshal(anomalies)
+			js := string(b)
+			anomaliesJSON = &js
+		}
+	}
+
+	// Failures while a config this one depends on fails are marked as
+	// suppressed and not notified
+	var suppressedBy *int
+	if !stale && status != probe.StatusOK {
+		suppressedBy, err = s.failingDependency(ctx, req.ProbeConfigID)
+		if err != nil {
+			slog.Error("failed to check dependencies", "probe_config_id", req.ProbeConfigID, "error", err)
+		}
+	}
+
 	// Insert result
 	metricsJSON, _ := json.Marshal(req.Metrics)
 	dataJSON, _ := json.Marshal(req.Data)
 	var nextRunAtStr *string
 	if nextRunAt != nil {
-		s := nextRunAt.UTC().Format(db.SQLiteTimeFormat)
-		nextRunAtStr = &s
+		ts := nextRunAt.UTC().Format(db.SQLiteTimeFormat)
+		nextRunAtStr = &ts
 	}
 
-	_, err := s.db.DB().ExecContext(ctx, `
-		INSERT INTO probe_results (probe_config_id, watcher_id, status, message, metrics, data, duration_ms, next_run_at, scheduled_at, executed_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-	`, req.ProbeConfigID, watcherID, req.Status, req.Message, string(metricsJSON), string(dataJSON), req.DurationMs, nextRunAtStr, req.ScheduledAt.UTC().Format(db.SQLiteTimeFormat), req.ExecutedAt.UTC().Format(db.SQLiteTimeFormat))
+	result, err := s.db.DB().ExecContext(ctx, `
+		INSERT INTO probe_results (probe_config_id, watcher_id, status, message, metrics, data, duration_ms, anomalies, suppressed_by, next_run_at, scheduled_at, executed_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, req.ProbeConfigID, watcherID, req.Status, req.Message, string(metricsJSON), string(dataJSON), req.DurationMs, anomaliesJSON, suppressedBy, nextRunAtStr, req.ScheduledAt.UTC().Format(db.SQLiteTimeFormat), executedAt)
 	if err != nil {
 		slog.Error("failed to insert result", "probe_config_id", req.ProbeConfigID, "error", err)
 		http.Error(w, "failed to record result", http.StatusInternalServerError)
 		return
 	}
+	watcherName, _ := WatcherNameFromContext(ctx)
+	s.resultsIngested.inc(watcherName, req.Status)
+	resultID, _ := result.LastInsertId()
+	s.publishResult(ctx, resultID, req.ProbeConfigID, req.Status, req.Message, req.ExecutedAt)
+
+	if stale {
+		slog.Info("recorded out-of-order result", "probe_config_id", req.ProbeConfigID, "executed_at", req.ExecutedAt)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+		return
+	}
 
 	// Update next_run_at on probe_config
 	if nextRunAtStr != nil {
@@ -294,52 +362,137 @@ func (s *Server) handlePushResult(w http.ResponseWriter, r *http.Request) {
 
 	// Check for status change and send notifications
 	s.checkStatusChangeAndNotify(ctx, req.ProbeConfigID, probe.Status(req.Status), req.Message)
+	s.evaluateComposites(ctx, req.ProbeConfigID, 0)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
 }
 
 func (s *Server) checkStatusChangeAndNotify(ctx context.Context, configID int, newStatus probe.Status, message string) {
-	// Get probe config details, watcher paused status, and previous status
+	// Get probe config details, notification state, and watcher paused status
 	var probeName string
+	var groupPath *string
+	var watcherID *int
 	var notificationChannels db.JSONIntArray
-	var prevStatus *string
+	var policyID *int
+	var confirmedStatus *string
+	var flappingSince db.NullTime
+	var filter statusFilter
 	var watcherPaused int
+	var silenced bool
+	var suppressedBy *int
 
+	now := time.Now()
 	err := s.db.DB().QueryRowContext(ctx, `
-		SELECT pc.name, pc.notification_channels,
-		       (SELECT status FROM probe_results WHERE probe_config_id = pc.id ORDER BY executed_at DESC LIMIT 1 OFFSET 1),
-		       COALESCE(w.paused, 0)
+		SELECT pc.name, pc.group_path, pc.watcher_id, pc.notification_channels, pc.escalation_policy_id,
+		       pc.confirmed_status, pc.flapping_since, pc.consecutive_results, pc.flap_window, pc.flap_threshold,
+		       COALESCE(w.paused, 0), `+silencedSQL+`, `+suppressedBySQL+`
 		FROM probe_configs pc
 		LEFT JOIN watchers w ON w.id = pc.watcher_id
 		WHERE pc.id = ?
-	`, configID).Scan(&probeName, &notificationChannels, &prevStatus, &watcherPaused)
+	`, append(silenceArgs(now), configID)...).Scan(&probeName, &groupPath, &watcherID, &notificationChannels, &policyID,
+		&confirmedStatus, &flappingSince, &filter.consecutive, &filter.flapWindow, &filter.flapThreshold,
+		&watcherPaused, &silenced, &suppressedBy)
 	if err != nil {
 		slog.Error("failed to get probe config for notification", "config_id", configID, "error", err)
 		return
 	}
 
-	// Skip notifications if watcher is paused
-	if watcherPaused != 0 {
+	recent, err := s.recentStatuses(ctx, configID, filter.history())
+	if err != nil {
+		slog.Error("failed to get recent results", "config_id", configID, "error", err)
+		return
+	}
+	var oldStatus probe.Status
+	if confirmedStatus != nil {
+		oldStatus = probe.Status(*confirmedStatus)
+	}
+	decision := filter.evaluate(recent, oldStatus, flappingSince.Valid)
+
+	// Incidents follow the confirmed status, so a blip shorter than
+	// consecutive_results neither opens nor resolves one. They are tracked
+	// even while notifications are off.
+	var incident *openIncident
+	if decision.confirmed != "" {
+		incident, err = s.trackIncident(ctx, configID, decision.confirmed, message)
+		if err != nil {
+			slog.Error("failed to track incident", "config_id", configID, "error", err)
+		}
+	}
+
+	// A suppressed failure leaves the notification state alone, so it is
+	// notified only if it outlasts the failure of the dependency
+	if suppressedBy != nil {
+		slog.Debug("suppressed status change", "config_id", configID, "name", probeName, "suppressed_by", *suppressedBy)
 		return
 	}
 
-	// Only notify on status change
-	if prevStatus != nil && probe.Status(*prevStatus) == newStatus {
+	// The state advances even while notifications are off, so changes
+	// are not notified after the fact
+	if decision.changed || decision.flapStarted || decision.flapStopped {
+		var since *string
+		if decision.flapStarted {
+			ts := now.UTC().Format(db.SQLiteTimeFormat)
+			since = &ts
+		}
+		if _, err := s.db.DB().ExecContext(ctx, `
+			UPDATE probe_configs SET confirmed_status = ?, flapping_since = ? WHERE id = ?
+		`, decision.confirmed, since, configID); err != nil {
+			slog.Error("failed to update notification state", "config_id", configID, "error", err)
+			return
+		}
+		s.events.publish(eventStatusChange, watcherID, groupPath, map[string]any{
+			"probe_config_id": configID,
+			"name":            probeName,
+			"group_path":      groupPath,
+			"watcher_id":      watcherID,
+			"old_status":      oldStatus,
+			"new_status":      decision.confirmed,
+			"message":         message,
+			"flapping":        decision.flapping,
+		})
+	}
+	if decision.flapStarted {
+		slog.Info("probe started flapping", "config_id", configID, "name", probeName, "changes", decision.changes)
+	}
+
+	// Skip notifications if watcher is paused or the config is silenced
+	if watcherPaused != 0 || silenced {
 		return
 	}
 
-	if len(notificationChannels) == 0 {
+	// The start and end of flapping replace the individual changes
+	switch {
+	case decision.flapStarted, decision.flapStopped && !decision.changed:
+		s.notifyFlapping(ctx, configID, notificationChannels, policyID, &notify.Flapping{
+			ProbeName: probeName,
+			Status:    newStatus,
+			Message:   message,
+			Changes:   decision.changes,
+			Window:    filter.flapWindow,
+			Stopped:   decision.flapStopped,
+		})
+		return
+	case !decision.changed:
 		return
 	}
 
 	change := &notify.StatusChange{
 		ProbeName: probeName,
-		NewStatus: newStatus,
+		OldStatus: oldStatus,
+		NewStatus: decision.confirmed,
 		Message:   message,
+		AckURL:    s.ackURL(incident),
+	}
+
+	// An escalation policy replaces the notification channels
+	if policyID != nil {
+		s.escalateStatusChange(ctx, configID, *policyID, change)
+		return
 	}
-	if prevStatus != nil {
-		change.OldStatus = probe.Status(*prevStatus)
+
+	if len(notificationChannels) == 0 {
+		return
 	}
 
 	s.dispatcher.NotifyStatusChange(ctx, notificationChannels, change)
@@ -364,10 +517,11 @@ func (s *Server) handlePushAlert(w http.ResponseWriter, r *http.Request) {
 	// Find or create a probe config for this external source
 	var configID int
 	var notificationChannels db.JSONIntArray
+	var policyID *int
 
 	err := s.db.DB().QueryRowContext(ctx, `
-		SELECT id, notification_channels FROM probe_configs WHERE name = ? AND watcher_id IS NULL
-	`, req.Source).Scan(&configID, &notificationChannels)
+		SELECT id, notification_channels, escalation_policy_id FROM probe_configs WHERE name = ? AND watcher_id IS NULL AND composite_mode IS NULL
+	`, req.Source).Scan(&configID, &notificationChannels, &policyID)
 	if err != nil {
 		// Create probe type and config for external alerts
 		var probeTypeID int
@@ -402,7 +556,7 @@ func (s *Server) handlePushAlert(w http.ResponseWriter, r *http.Request) {
 
 	// Insert result (no watcher_id for external alerts)
 	dataJSON, _ := json.Marshal(req.Data)
-	_, err = s.db.DB().ExecContext(ctx, `
+	result, err := s.db.DB().ExecContext(ctx, `
 		INSERT INTO probe_results (probe_config_id, status, message, data, duration_ms, scheduled_at, executed_at)
 		VALUES (?, ?, ?, ?, 0, ?, ?)
 	`, configID, req.Status, req.Message, string(dataJSON), now, now)
@@ -410,16 +564,33 @@ func (s *Server) handlePushAlert(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "failed to record alert", http.StatusInternalServerError)
 		return
 	}
+	watcherName, _ := WatcherNameFromContext(ctx)
+	s.resultsIngested.inc(watcherName, req.Status)
+	resultID, _ := result.LastInsertId()
+	s.publishResult(ctx, resultID, configID, req.Status, req.Message, time.Now())
 
-	// Notify on critical alerts
-	if probe.Status(req.Status) == probe.StatusCritical && len(notificationChannels) > 0 {
-		change := &notify.StatusChange{
-			ProbeName: req.Source,
-			NewStatus: probe.Status(req.Status),
-			Message:   req.Message,
+	// Notify on critical alerts. With an escalation policy, repeats come
+	// from the escalation instead, so only status changes are notified.
+	if policyID != nil {
+		s.checkStatusChangeAndNotify(ctx, configID, probe.Status(req.Status), req.Message)
+	} else {
+		incident, err := s.trackIncident(ctx, configID, probe.Status(req.Status), req.Message)
+		if err != nil {
+			slog.Error("failed to track incident", "config_id", configID, "error", err)
+		}
+		// Acknowledgement stops the repeats
+		acknowledged := incident != nil && incident.acknowledged
+		if probe.Status(req.Status) == probe.StatusCritical && len(notificationChannels) > 0 && !acknowledged && !s.silenced(ctx, configID) {
+			change := &notify.StatusChange{
+				ProbeName: req.Source,
+				NewStatus: probe.Status(req.Status),
+				Message:   req.Message,
+				AckURL:    s.ackURL(incident),
+			}
+			s.dispatcher.NotifyStatusChange(ctx, notificationChannels, change)
 		}
-		s.dispatcher.NotifyStatusChange(ctx, notificationChannels, change)
 	}
+	s.evaluateComposites(ctx, configID, 0)
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
@@ -450,7 +621,7 @@ func (s *Server) handlePushGetConfigs(w http.ResponseWriter, r *http.Request) {
 	// Get configs assigned to this watcher with probe type info
 	rows, err := s.db.DB().QueryContext(ctx, `
 		SELECT pc.id, pt.name, pt.version, wpt.executable_path, wpt.subcommand, pc.name, pc.arguments,
-		       pc.interval, pc.timeout_seconds, pc.next_run_at
+		       pc.interval, pc.timezone, pc.active_windows, pc.timeout_seconds, pc.next_run_at
 		FROM probe_configs pc
 		JOIN probe_types pt ON pt.id = pc.probe_type_id
 		JOIN watcher_probe_types wpt ON wpt.probe_type_id = pt.id AND wpt.watcher_id = ?
@@ -467,10 +638,12 @@ func (s *Server) handlePushGetConfigs(w http.ResponseWriter, r *http.Request) {
 		var cfg ProbeConfigResponse
 		var subcommand *string
 		var arguments db.JSONMap
+		var timezone *string
+		var activeWindows db.JSONStringArray
 		var nextRunAt db.NullTime
 		if err := rows.Scan(
 			&cfg.ID, &cfg.ProbeTypeName, &cfg.ProbeVersion, &cfg.ExecutablePath, &subcommand,
-			&cfg.Name, &arguments, &cfg.Interval, &cfg.TimeoutSeconds, &nextRunAt,
+			&cfg.Name, &arguments, &cfg.Interval, &timezone, &activeWindows, &cfg.TimeoutSeconds, &nextRunAt,
 		); err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -479,6 +652,10 @@ func (s *Server) handlePushGetConfigs(w http.ResponseWriter, r *http.Request) {
 		if subcommand != nil {
 			cfg.Subcommand = *subcommand
 		}
+		if timezone != nil {
+			cfg.Timezone = *timezone
+		}
+		cfg.ActiveWindows = activeWindows
 		if nextRunAt.Valid {
 			cfg.NextRunAt = &nextRunAt.Time
 		}
@@ -489,27 +666,22 @@ func (s *Server) handlePushGetConfigs(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(configs)
 }
 
-// parseInterval parses interval strings like "5m", "1h", "1d".
-func parseInterval(s string) (time.Duration, error) {
-	if len(s) < 2 {
-		return 0, nil
-	}
-
-	var value int
-	var unit byte
-	_, err := fmt.Sscanf(s, "%d%c", &value, &unit)
-	if err != nil {
-		return time.ParseDuration(s)
+// parseSchedule parses a probe config's schedule columns.
+func parseSchedule(interval string, timezone *string, activeWindows []string) (*schedule.Schedule, error) {
+	tz := ""
+	if timezone != nil {
+		tz = *timezone
 	}
+	return schedule.Parse(interval, tz, activeWindows)
+}
 
-	switch unit {
-	case 'm':
-		return time.Duration(value) * time.Minute, nil
-	case 'h':
-		return time.Duration(value) * time.Hour, nil
-	case 'd':
-		return time.Duration(value) * 24 * time.Hour, nil
-	default:
-		return time.ParseDuration(s)
+// formatNextRun returns the first run of sched after t in database format,
+// or nil if the schedule has no upcoming run.
+func formatNextRun(sched *schedule.Schedule, t time.Time) *string {
+	next := sched.Next(t)
+	if next.IsZero() {
+		return nil
 	}
+	s := next.UTC().Format(db.SQLiteTimeFormat)
+	return &s
 }
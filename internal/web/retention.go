@@ -0,0 +1,335 @@
+package web
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+	"math"
+	"time"
+
+	"github.com/jandubois/monitor/internal/db"
+	"github.com/jandubois/monitor/internal/probe"
+)
+
+const (
+	// rollupBucket is the size of a rollup bucket.
+	rollupBucket = time.Hour
+
+	compactionInterval = time.Hour
+
+	// compactionBatchSize bounds how many raw results are rolled up in a
+	// single transaction, so ingestion isn't blocked for long.
+	compactionBatchSize = 5000
+)
+
+// statusRank orders statuses by severity; a rollup bucket reports the worst.
+var statusRank = map[string]int{
+	string(probe.StatusOK):       0,
+	string(probe.StatusUnknown):  1,
+	string(probe.StatusWarning):  2,
+	string(probe.StatusCritical): 3,
+}
+
+// metricStats summarizes one numeric metric within a rollup bucket.
+type metricStats struct {
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+	Avg   float64 `json:"avg"`
+	Count int     `json:"count"`
+}
+
+func (m *metricStats) add(value float64) {
+	if m.Count == 0 || value < m.Min {
+		m.Min = value
+	}
+	if m.Count == 0 || value > m.Max {
+		m.Max = value
+	}
+	m.Avg += (value - m.Avg) / float64(m.Count+1)
+	m.Count++
+}
+
+func (m *metricStats) merge(other *metricStats) {
+	if other.Count == 0 {
+		return
+	}
+	if m.Count == 0 {
+		*m = *other
+		return
+	}
+	m.Min = math.Min(m.Min, other.Min)
+	m.Max = math.Max(m.Max, other.Max)
+	total := m.Count + other.Count
+	m.Avg = (m.Avg*float64(m.Count) + other.Avg*float64(other.Count)) / float64(total)
+	m.Count = total
+}
+
+// rollup is the aggregate of all results of one config in one bucket.
+type rollup struct {
+	configID     int
+	bucketStart  time.Time
+	status       string
+	statusCounts map[string]int
+	duration     metricStats
+	metrics      map[string]*metricStats
+}
+
+func newRollup(configID int, bucketStart time.Time) *rollup {
+	return &rollup{
+		configID:     configID,
+		bucketStart:  bucketStart,
+		status:       string(probe.StatusOK),
+		statusCounts: map[string]int{},
+		metrics:      map[string]*metricStats{},
+	}
+}
+
+func (r *rollup) count() int {
+	total := 0
+	for _, n := range r.statusCounts {
+		total += n
+	}
+	return total
+}
+
+func (r *rollup) addStatus(status string, n int) {
+	if _, ok := statusRank[status]; !ok {
+		status = string(probe.StatusUnknown)
+	}
+	r.statusCounts[status] += n
+	if n > 0 && statusRank[status] > statusRank[r.status] {
+		r.status = status
+	}
+}
+
+func (r *rollup) addResult(status string, durationMs int, metrics db.JSONMap) {
+	r.addStatus(status, 1)
+	r.duration.add(float64(durationMs))
+	for name, value := range metrics {
+		// Only numeric metrics can be aggregated
+		if v, ok := value.(float64); ok {
+			if r.metrics[name] == nil {
+				r.metrics[name] = &metricStats{}
+			}
+			r.metrics[name].add(v)
+		}
+	}
+}
+
+func (r *rollup) merge(other *rollup) {
+	for status, n := range other.statusCounts {
+		r.addStatus(status, n)
+	}
+	r.duration.merge(&other.duration)
+	for name, stats := range other.metrics {
+		if r.metrics[name] == nil {
+			r.metrics[name] = &metricStats{}
+		}
+		r.metrics[name].merge(stats)
+	}
+}
+
+func (s *Server) compactionLoop(ctx context.Context) {
+	ticker := time.NewTicker(compactionInterval)
+	defer ticker.Stop()
+
+	for {
+		if _, _, err := s.compactResults(ctx, time.Now().UTC()); err != nil {
+			slog.Error("result compaction failed", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// compactResults rolls raw results older than the result retention period
+// into hourly buckets and deletes them, then deletes rollups older than the
+// rollup retention period. A zero retention keeps data forever. The latest
+// result of each config is always kept raw, since status and change
+// detection depend on it. It returns the number of raw results compacted
+// and rollups deleted.
+func (s *Server) compactResults(ctx context.Context, now time.Time) (compacted, pruned int, err error) {
+	if s.config.ResultRetention > 0 {
+		// Only roll up complete buckets
+		cutoff := now.Add(-s.config.ResultRetention).Truncate(rollupBucket)
+		for {
+			n, err := s.compactBatch(ctx, cutoff)
+			compacted += n
+			if err != nil {
+				return compacted, pruned, err
+			}
+			if n < compactionBatchSize {
+				break
+			}
+		}
+		if compacted > 0 {
+			slog.Info("compacted probe results", "results", compacted, "before", cutoff)
+		}
+	}
+
+	if s.config.RollupRetention > 0 {
+		cutoff := now.Add(-s.config.RollupRetention)
+		result, err := s.db.DB().ExecContext(ctx, `
+			DELETE FROM probe_result_rollups WHERE bucket_start < ?
+		`, cutoff.Format(db.SQLiteTimeFormat))
+		if err != nil {
+			return compacted, pruned, err
+		}
+		n, _ := result.RowsAffected()
+		pruned = int(n)
+		if pruned > 0 {
+			slog.Info("pruned result rollups", "rollups", pruned, "before", cutoff)
+		}
+	}
+
+	return compacted, pruned, nil
+}
+
+// compactBatch rolls up and deletes at most compactionBatchSize raw results
+// executed before cutoff, in a single transaction.
+func (s *Server) compactBatch(ctx context.Context, cutoff time.Time) (int, error) {
+	tx, err := s.db.DB().BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT pr.id, pr.probe_config_id, pr.status, pr.duration_ms, pr.metrics, pr.executed_at
+		FROM probe_results pr
+		WHERE pr.executed_at < ?
+		  AND pr.executed_at < (SELECT MAX(executed_at) FROM probe_results
+		                        WHERE probe_config_id = pr.probe_config_id)
+		ORDER BY pr.probe_config_id, pr.executed_at
+		LIMIT ?
+	`, cutoff.Format(db.SQLiteTimeFormat), compactionBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	type bucketKey struct {
+		configID int
+		start    time.Time
+	}
+	buckets := map[bucketKey]*rollup{}
+	var ids []int
+	for rows.Next() {
+		var id, configID int
+		var status string
+		var durationMs sql.NullInt64
+		var metrics db.JSONMap
+		var executedAt db.NullTime
+		if err := rows.Scan(&id, &configID, &status, &durationMs, &metrics, &executedAt); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+
+		key := bucketKey{configID, executedAt.Time.UTC().Truncate(rollupBucket)}
+		if buckets[key] == nil {
+			buckets[key] = newRollup(key.configID, key.start)
+		}
+		buckets[key].addResult(status, int(durationMs.Int64), metrics)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	for _, r := range buckets {
+		// Buckets can span batches; fold in what an earlier batch stored
+		existing, err := loadRollup(ctx, tx, r.configID, r.bucketStart)
+		if err != nil {
+			return 0, err
+		}
+		if existing != nil {
+			existing.merge(r)
+			r = existing
+		}
+		if err := saveRollup(ctx, tx, r); err != nil {
+			return 0, err
+		}
+	}
+
+	idsJSON, _ := json.Marshal(ids)
+	if _, err := tx.ExecContext(ctx, `
+		DELETE FROM probe_results WHERE id IN (SELECT value FROM json_each(?))
+	`, string(idsJSON)); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return len(ids), nil
+}
+
+func loadRollup(ctx context.Context, tx *sql.Tx, configID int, bucketStart time.Time) (*rollup, error) {
+	var okCount, warningCount, criticalCount, unknownCount, resultCount int
+	var durationMin, durationMax sql.NullInt64
+	var durationAvg sql.NullFloat64
+	var statsJSON *string
+
+	err := tx.QueryRowContext(ctx, `
+		SELECT ok_count, warning_count, critical_count, unknown_count, result_count,
+		       duration_min_ms, duration_max_ms, duration_avg_ms, metric_stats
+		FROM probe_result_rollups
+		WHERE probe_config_id = ? AND bucket_start = ?
+	`, configID, bucketStart.Format(db.SQLiteTimeFormat)).Scan(&okCount, &warningCount, &criticalCount, &unknownCount,
+		&resultCount, &durationMin, &durationMax, &durationAvg, &statsJSON)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	r := newRollup(configID, bucketStart)
+	r.addStatus(string(probe.StatusOK), okCount)
+	r.addStatus(string(probe.StatusWarning), warningCount)
+	r.addStatus(string(probe.StatusCritical), criticalCount)
+	r.addStatus(string(probe.StatusUnknown), unknownCount)
+	if durationAvg.Valid {
+		r.duration = metricStats{
+			Min:   float64(durationMin.Int64),
+			Max:   float64(durationMax.Int64),
+			Avg:   durationAvg.Float64,
+			Count: resultCount,
+		}
+	}
+	if statsJSON != nil {
+		if err := json.Unmarshal([]byte(*statsJSON), &r.metrics); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+func saveRollup(ctx context.Context, tx *sql.Tx, r *rollup) error {
+	averages := make(map[string]float64, len(r.metrics))
+	for name, stats := range r.metrics {
+		averages[name] = stats.Avg
+	}
+	metricsJSON, _ := json.Marshal(averages)
+	statsJSON, _ := json.Marshal(r.metrics)
+
+	_, err := tx.ExecContext(ctx, `
+		INSERT OR REPLACE INTO probe_result_rollups
+			(probe_config_id, bucket_start, bucket_seconds, status, result_count,
+			 ok_count, warning_count, critical_count, unknown_count,
+			 duration_min_ms, duration_max_ms, duration_avg_ms, metrics, metric_stats)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, r.configID, r.bucketStart.Format(db.SQLiteTimeFormat), int(rollupBucket.Seconds()), r.status, r.count(),
+		r.statusCounts[string(probe.StatusOK)], r.statusCounts[string(probe.StatusWarning)],
+		r.statusCounts[string(probe.StatusCritical)], r.statusCounts[string(probe.StatusUnknown)],
+		int64(r.duration.Min), int64(r.duration.Max), r.duration.Avg, string(metricsJSON), string(statsJSON))
+	return err
+}
@@ -0,0 +1,174 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/jandubois/monitor/internal/db"
+)
+
+func TestRollupMerge(t *testing.T) {
+	bucket := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	a := newRollup(1, bucket)
+	a.addResult("ok", 100, db.JSONMap{"free_gb": 10.0, "label": "x"})
+	a.addResult("warning", 300, db.JSONMap{"free_gb": 20.0})
+
+	b := newRollup(1, bucket)
+	b.addResult("ok", 200, db.JSONMap{"free_gb": 60.0})
+	b.addResult("bogus", 200, nil)
+
+	a.merge(b)
+
+	if a.count() != 4 {
+		t.Errorf("expected 4 results, got %d", a.count())
+	}
+	if a.status != "warning" {
+		t.Errorf("expected worst status warning, got %q", a.status)
+	}
+	if a.statusCounts["ok"] != 2 || a.statusCounts["warning"] != 1 || a.statusCounts["unknown"] != 1 {
+		t.Errorf("unexpected status counts: %v", a.statusCounts)
+	}
+	if a.duration.Min != 100 || a.duration.Max != 300 || a.duration.Avg != 200 {
+		t.Errorf("unexpected duration stats: %+v", a.duration)
+	}
+
+	stats := a.metrics["free_gb"]
+	if stats == nil {
+		t.Fatal("expected free_gb stats")
+	}
+	if stats.Min != 10 || stats.Max != 60 || stats.Avg != 30 || stats.Count != 3 {
+		t.Errorf("unexpected free_gb stats: %+v", stats)
+	}
+	if _, ok := a.metrics["label"]; ok {
+		t.Error("non-numeric metric should not be rolled up")
+	}
+}
+
+func TestCompactResults(t *testing.T) {
+	server, cleanup := testServer(t)
+	if server == nil {
+		return
+	}
+	defer cleanup()
+
+	server.config.ResultRetention = 24 * time.Hour
+	server.config.RollupRetention = 7 * 24 * time.Hour
+
+	ctx := context.Background()
+	now := time.Date(2025, 6, 15, 12, 30, 0, 0, time.UTC)
+	sqlDB := server.db.DB()
+
+	result, err := sqlDB.ExecContext(ctx, `
+		INSERT INTO probe_types (name, version, arguments) VALUES ('rollup-test', '1.0.0', '{}')
+	`)
+	if err != nil {
+		t.Fatalf("failed to create probe type: %v", err)
+	}
+	probeTypeID, _ := result.LastInsertId()
+
+	result, err = sqlDB.ExecContext(ctx, `
+		INSERT INTO probe_configs (probe_type_id, name, enabled, arguments, interval)
+		VALUES (?, 'rollup-config', 1, '{}', '15m')
+	`, probeTypeID)
+	if err != nil {
+		t.Fatalf("failed to create config: %v", err)
+	}
+	configID, _ := result.LastInsertId()
+
+	insertResult := func(executedAt time.Time, status string, durationMs int, metrics string) {
+		t.Helper()
+		_, err := sqlDB.ExecContext(ctx, `
+			INSERT INTO probe_results (probe_config_id, status, message, metrics, duration_ms, executed_at)
+			VALUES (?, ?, '', ?, ?, ?)
+		`, configID, status, metrics, durationMs, executedAt.Format(db.SQLiteTimeFormat))
+		if err != nil {
+			t.Fatalf("failed to insert result: %v", err)
+		}
+	}
+
+	// Two results in an hour bucket three days ago
+	old := now.Add(-72 * time.Hour).Truncate(time.Hour)
+	insertResult(old.Add(5*time.Minute), "ok", 100, `{"free_gb": 10}`)
+	insertResult(old.Add(35*time.Minute), "critical", 300, `{"free_gb": 30}`)
+	// A bucket older than the rollup retention
+	insertResult(now.Add(-10*24*time.Hour), "ok", 100, `{"free_gb": 50}`)
+	// Recent results stay raw
+	insertResult(now.Add(-time.Hour), "ok", 50, `{"free_gb": 40}`)
+	insertResult(now.Add(-time.Minute), "ok", 50, `{"free_gb": 40}`)
+
+	compacted, _, err := server.compactResults(ctx, now)
+	if err != nil {
+		t.Fatalf("compactResults failed: %v", err)
+	}
+	if compacted != 3 {
+		t.Errorf("expected 3 compacted results, got %d", compacted)
+	}
+
+	var rawCount int
+	sqlDB.QueryRowContext(ctx, `SELECT COUNT(*) FROM probe_results WHERE probe_config_id = ?`, configID).Scan(&rawCount)
+	if rawCount != 2 {
+		t.Errorf("expected 2 raw results left, got %d", rawCount)
+	}
+
+	var rollupCount int
+	sqlDB.QueryRowContext(ctx, `SELECT COUNT(*) FROM probe_result_rollups WHERE probe_config_id = ?`, configID).Scan(&rollupCount)
+	if rollupCount != 1 {
+		t.Errorf("expected the expired rollup to be pruned, got %d rollups", rollupCount)
+	}
+
+	// Rollups are returned alongside raw results
+	req := httptest.NewRequest("GET", "/api/results?config_id="+strconv.FormatInt(configID, 10), nil)
+	w := httptest.NewRecorder()
+	server.handleQueryResults(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+
+	var resp []map[string]any
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(resp) != 3 {
+		t.Fatalf("expected 2 raw results and 1 rollup, got %d", len(resp))
+	}
+
+	rolled := resp[2]
+	if rolled["id"].(float64) >= 0 {
+		t.Errorf("expected a negative id for the rollup, got %v", rolled["id"])
+	}
+	if rolled["status"] != "critical" {
+		t.Errorf("expected worst status critical, got %v", rolled["status"])
+	}
+	if rolled["duration_ms"].(float64) != 200 {
+		t.Errorf("expected average duration 200, got %v", rolled["duration_ms"])
+	}
+	if metrics := rolled["metrics"].(map[string]any); metrics["free_gb"].(float64) != 20 {
+		t.Errorf("expected average free_gb 20, got %v", metrics["free_gb"])
+	}
+	summary, ok := rolled["rollup"].(map[string]any)
+	if !ok {
+		t.Fatalf("expected rollup summary, got %v", rolled)
+	}
+	if summary["count"].(float64) != 2 {
+		t.Errorf("expected rollup count 2, got %v", summary["count"])
+	}
+	if counts := summary["status_counts"].(map[string]any); counts["critical"].(float64) != 1 {
+		t.Errorf("unexpected status counts: %v", counts)
+	}
+
+	// A second pass finds nothing to do
+	compacted, pruned, err := server.compactResults(ctx, now)
+	if err != nil {
+		t.Fatalf("compactResults failed: %v", err)
+	}
+	if compacted != 0 || pruned != 0 {
+		t.Errorf("expected no work on second pass, got %d compacted, %d pruned", compacted, pruned)
+	}
+}
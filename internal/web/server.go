@@ -12,12 +12,21 @@ import (
 	"github.com/jandubois/monitor/internal/notify"
 )
 
+// watcherHealthyTimeout is how long a watcher may go without a heartbeat
+// before it is considered unhealthy.
+const watcherHealthyTimeout = 30 * time.Second
+
 // Server is the web backend.
 type Server struct {
 	db         *db.DB
 	config     *config.WebConfig
 	server     *http.Server
 	dispatcher *notify.Dispatcher
+	events     *eventHub
+	oidc       *oidcProvider // nil without single sign-on
+
+	resultsIngested resultCounter
+	watcherStreams  watcherStreams
 }
 
 // NewServer creates a new web server.
@@ -28,11 +37,21 @@ func NewServer(database *db.DB, cfg *config.WebConfig) (*Server, error) {
 		db:         database,
 		config:     cfg,
 		dispatcher: dispatcher,
+		events:     newEventHub(),
+	}
+	if cfg.OIDC.Issuer != "" {
+		provider, err := newOIDCProvider(&cfg.OIDC)
+		if err != nil {
+			return nil, err
+		}
+		s.oidc = provider
 	}
 	s.server = &http.Server{
 		Addr:    fmt.Sprintf(":%d", cfg.Port),
 		Handler: s.routes(),
 	}
+	// Event streams never finish on their own
+	s.server.RegisterOnShutdown(s.events.close)
 	return s, nil
 }
 
@@ -43,6 +62,11 @@ func (s *Server) Run(ctx context.Context) error {
 		slog.Error("failed to load notification channels", "error", err)
 	}
 
+	go s.missedRunLoop(ctx)
+	go s.watcherHealthLoop(ctx)
+	go s.compactionLoop(ctx)
+	go s.escalationLoop(ctx)
+
 	errCh := make(chan error, 1)
 	go func() {
 		slog.Info("web server listening", "addr", s.server.Addr)
@@ -68,6 +92,20 @@ func (s *Server) routes() http.Handler {
 	// Health check (no auth)
 	mux.HandleFunc("GET /api/health", s.handleHealth)
 
+	// Prometheus scrape endpoint (user token)
+	mux.Handle("GET /metrics", s.requireAuth(http.HandlerFunc(s.handleMetrics)))
+
+	// Login for the web frontend (no auth)
+	mux.HandleFunc("GET /api/login", s.handleLoginOptions)
+	mux.HandleFunc("POST /api/login", s.handleLogin)
+	mux.HandleFunc("GET /api/oidc/login", s.handleOIDCLogin)
+	mux.HandleFunc("GET "+oidcCallbackPath, s.handleOIDCCallback)
+	mux.HandleFunc("POST /api/logout", s.handleLogout)
+
+	// Acknowledgement links from notifications (authorized by the link's token)
+	mux.HandleFunc("GET /api/ack/{token}", s.handleAckLink)
+	mux.HandleFunc("POST /api/ack/{token}", s.handleAckLink)
+
 	// Push API (used by watchers)
 	// Registration is unauthenticated - watcher sends token in body
 	mux.HandleFunc("POST /api/push/register", s.handlePushRegister)
@@ -76,32 +114,72 @@ func (s *Server) routes() http.Handler {
 	mux.Handle("POST /api/push/result", s.requireWatcherAuth(http.HandlerFunc(s.handlePushResult)))
 	mux.Handle("POST /api/push/alert", s.requireWatcherAuth(http.HandlerFunc(s.handlePushAlert)))
 	mux.Handle("GET /api/push/configs/{watcher}", s.requireWatcherAuth(http.HandlerFunc(s.handlePushGetConfigs)))
+	mux.Handle("GET /api/push/events", s.requireWatcherAuth(http.HandlerFunc(s.handlePushEvents)))
 
 	// Watchers API
 	mux.Handle("GET /api/watchers", s.requireAuth(http.HandlerFunc(s.handleListWatchers)))
 	mux.Handle("GET /api/watchers/{id}", s.requireAuth(http.HandlerFunc(s.handleGetWatcher)))
-	mux.Handle("DELETE /api/watchers/{id}", s.requireAuth(http.HandlerFunc(s.handleDeleteWatcher)))
-	mux.Handle("PUT /api/watchers/{id}/paused", s.requireAuth(http.HandlerFunc(s.handleSetWatcherPaused)))
+	mux.Handle("DELETE /api/watchers/{id}", s.requireRole(roleAdmin, http.HandlerFunc(s.handleDeleteWatcher)))
+	mux.Handle("PUT /api/watchers/{id}/paused", s.requireRole(roleAdmin, http.HandlerFunc(s.handleSetWatcherPaused)))
+	mux.Handle("PUT /api/watchers/{id}/notification-channels", s.requireRole(roleAdmin, http.HandlerFunc(s.handleSetWatcherNotificationChannels)))
+
+	// Accounts: everyone manages their own password and API tokens,
+	// admins manage users
+	mux.Handle("GET /api/me", s.requireAuth(http.HandlerFunc(s.handleGetMe)))
+	mux.Handle("PUT /api/me/password", s.requireAuth(http.HandlerFunc(s.handleChangePassword)))
+	mux.Handle("GET /api/tokens", s.requireAuth(http.HandlerFunc(s.handleListAPITokens)))
+	mux.Handle("POST /api/tokens", s.requireAuth(http.HandlerFunc(s.handleCreateAPIToken)))
+	mux.Handle("DELETE /api/tokens/{id}", s.requireAuth(http.HandlerFunc(s.handleDeleteAPIToken)))
+	mux.Handle("GET /api/users", s.requireRole(roleAdmin, http.HandlerFunc(s.handleListUsers)))
+	mux.Handle("POST /api/users", s.requireRole(roleAdmin, http.HandlerFunc(s.handleCreateUser)))
+	mux.Handle("PUT /api/users/{id}", s.requireRole(roleAdmin, http.HandlerFunc(s.handleUpdateUser)))
+	mux.Handle("DELETE /api/users/{id}", s.requireRole(roleAdmin, http.HandlerFunc(s.handleDeleteUser)))
 
-	// API routes (with auth)
+	// API routes (with auth; changes need the operator or admin role)
 	mux.Handle("GET /api/status", s.requireAuth(http.HandlerFunc(s.handleStatus)))
 	mux.Handle("GET /api/probe-types", s.requireAuth(http.HandlerFunc(s.handleListProbeTypes)))
-	mux.Handle("POST /api/probe-types/discover", s.requireAuth(http.HandlerFunc(s.handleDiscoverProbeTypes)))
+	mux.Handle("POST /api/probe-types/discover", s.requireRole(roleOperator, http.HandlerFunc(s.handleDiscoverProbeTypes)))
 	mux.Handle("GET /api/probe-configs", s.requireAuth(http.HandlerFunc(s.handleListProbeConfigs)))
-	mux.Handle("POST /api/probe-configs", s.requireAuth(http.HandlerFunc(s.handleCreateProbeConfig)))
+	mux.Handle("POST /api/probe-configs", s.requireRole(roleOperator, http.HandlerFunc(s.handleCreateProbeConfig)))
 	mux.Handle("GET /api/probe-configs/{id}", s.requireAuth(http.HandlerFunc(s.handleGetProbeConfig)))
-	mux.Handle("PUT /api/probe-configs/{id}", s.requireAuth(http.HandlerFunc(s.handleUpdateProbeConfig)))
-	mux.Handle("DELETE /api/probe-configs/{id}", s.requireAuth(http.HandlerFunc(s.handleDeleteProbeConfig)))
-	mux.Handle("POST /api/probe-configs/{id}/run", s.requireAuth(http.HandlerFunc(s.handleRunProbeConfig)))
-	mux.Handle("PUT /api/probe-configs/{id}/enabled", s.requireAuth(http.HandlerFunc(s.handleSetProbeEnabled)))
+	mux.Handle("PUT /api/probe-configs/{id}", s.requireRole(roleOperator, http.HandlerFunc(s.handleUpdateProbeConfig)))
+	mux.Handle("DELETE /api/probe-configs/{id}", s.requireRole(roleOperator, http.HandlerFunc(s.handleDeleteProbeConfig)))
+	mux.Handle("POST /api/probe-configs/{id}/run", s.requireRole(roleOperator, http.HandlerFunc(s.handleRunProbeConfig)))
+	mux.Handle("PUT /api/probe-configs/{id}/enabled", s.requireRole(roleOperator, http.HandlerFunc(s.handleSetProbeEnabled)))
+	mux.Handle("PUT /api/probe-configs/{id}/dependencies", s.requireRole(roleOperator, http.HandlerFunc(s.handleSetDependencies)))
+	mux.Handle("POST /api/composites", s.requireRole(roleOperator, http.HandlerFunc(s.handleCreateComposite)))
+	mux.Handle("PUT /api/composites/{id}", s.requireRole(roleOperator, http.HandlerFunc(s.handleUpdateComposite)))
+	mux.Handle("GET /api/dependency-graph", s.requireAuth(http.HandlerFunc(s.handleDependencyGraph)))
+	mux.Handle("GET /api/events", s.requireAuth(http.HandlerFunc(s.handleEvents)))
+	mux.Handle("GET /api/audit", s.requireAuth(http.HandlerFunc(s.handleListAudit)))
 	mux.Handle("GET /api/results", s.requireAuth(http.HandlerFunc(s.handleQueryResults)))
 	mux.Handle("GET /api/results/{config_id}", s.requireAuth(http.HandlerFunc(s.handleGetResults)))
 	mux.Handle("GET /api/results/stats", s.requireAuth(http.HandlerFunc(s.handleResultStats)))
+	mux.Handle("GET /api/missed-runs", s.requireAuth(http.HandlerFunc(s.handleListMissedRuns)))
 	mux.Handle("GET /api/notification-channels", s.requireAuth(http.HandlerFunc(s.handleListNotificationChannels)))
-	mux.Handle("POST /api/notification-channels", s.requireAuth(http.HandlerFunc(s.handleCreateNotificationChannel)))
-	mux.Handle("PUT /api/notification-channels/{id}", s.requireAuth(http.HandlerFunc(s.handleUpdateNotificationChannel)))
-	mux.Handle("DELETE /api/notification-channels/{id}", s.requireAuth(http.HandlerFunc(s.handleDeleteNotificationChannel)))
-	mux.Handle("POST /api/notification-channels/{id}/test", s.requireAuth(http.HandlerFunc(s.handleTestNotificationChannel)))
+	mux.Handle("POST /api/notification-channels", s.requireRole(roleAdmin, http.HandlerFunc(s.handleCreateNotificationChannel)))
+	mux.Handle("PUT /api/notification-channels/{id}", s.requireRole(roleAdmin, http.HandlerFunc(s.handleUpdateNotificationChannel)))
+	mux.Handle("DELETE /api/notification-channels/{id}", s.requireRole(roleAdmin, http.HandlerFunc(s.handleDeleteNotificationChannel)))
+	mux.Handle("POST /api/notification-channels/{id}/test", s.requireRole(roleOperator, http.HandlerFunc(s.handleTestNotificationChannel)))
+	mux.Handle("GET /api/escalation-policies", s.requireAuth(http.HandlerFunc(s.handleListEscalationPolicies)))
+	mux.Handle("POST /api/escalation-policies", s.requireRole(roleAdmin, http.HandlerFunc(s.handleCreateEscalationPolicy)))
+	mux.Handle("GET /api/escalation-policies/{id}", s.requireAuth(http.HandlerFunc(s.handleGetEscalationPolicy)))
+	mux.Handle("PUT /api/escalation-policies/{id}", s.requireRole(roleAdmin, http.HandlerFunc(s.handleUpdateEscalationPolicy)))
+	mux.Handle("DELETE /api/escalation-policies/{id}", s.requireRole(roleAdmin, http.HandlerFunc(s.handleDeleteEscalationPolicy)))
+	mux.Handle("GET /api/escalations", s.requireAuth(http.HandlerFunc(s.handleListEscalations)))
+	mux.Handle("GET /api/incidents", s.requireAuth(http.HandlerFunc(s.handleListIncidents)))
+	mux.Handle("GET /api/incidents/{id}", s.requireAuth(http.HandlerFunc(s.handleGetIncident)))
+	mux.Handle("POST /api/incidents/{id}/acknowledge", s.requireRole(roleOperator, http.HandlerFunc(s.handleAcknowledgeIncident)))
+	mux.Handle("POST /api/incidents/{id}/notes", s.requireRole(roleOperator, http.HandlerFunc(s.handleAddIncidentNote)))
+	mux.Handle("POST /api/probe-configs/{id}/acknowledge", s.requireRole(roleOperator, http.HandlerFunc(s.handleAcknowledgeConfig)))
+	mux.Handle("GET /api/metric-rules", s.requireAuth(http.HandlerFunc(s.handleListMetricRules)))
+	mux.Handle("POST /api/metric-rules", s.requireRole(roleOperator, http.HandlerFunc(s.handleCreateMetricRule)))
+	mux.Handle("GET /api/metric-rules/{id}", s.requireAuth(http.HandlerFunc(s.handleGetMetricRule)))
+	mux.Handle("PUT /api/metric-rules/{id}", s.requireRole(roleOperator, http.HandlerFunc(s.handleUpdateMetricRule)))
+	mux.Handle("DELETE /api/metric-rules/{id}", s.requireRole(roleOperator, http.HandlerFunc(s.handleDeleteMetricRule)))
+	mux.Handle("GET /api/silences", s.requireAuth(http.HandlerFunc(s.handleListSilences)))
+	mux.Handle("POST /api/silences", s.requireRole(roleOperator, http.HandlerFunc(s.handleCreateSilence)))
+	mux.Handle("DELETE /api/silences/{id}", s.requireRole(roleOperator, http.HandlerFunc(s.handleDeleteSilence)))
 
 	// Serve static files for everything else (React SPA)
 	mux.Handle("/", staticHandler())
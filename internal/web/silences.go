@@ -0,0 +1,263 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jandubois/monitor/internal/db"
+)
+
+// silencedSQL is a condition that holds while an active silence matches the
+// probe config aliased pc. It takes the current time twice, as formatted by
+// silenceArgs.
+const silencedSQL = `EXISTS (
+	SELECT 1 FROM silences si
+	WHERE si.starts_at <= ? AND si.ends_at > ?
+	  AND (si.probe_config_id IS NULL OR si.probe_config_id = pc.id)
+	  AND (si.group_path IS NULL OR pc.group_path = si.group_path OR substr(pc.group_path, 1, length(si.group_path) + 1) = si.group_path || '/')
+	  AND (si.keyword IS NULL OR EXISTS (SELECT 1 FROM json_each(pc.keywords) WHERE json_each.value = si.keyword))
+	  AND (si.watcher_id IS NULL OR si.watcher_id = pc.watcher_id)
+	  AND (si.probe_type IS NULL OR si.probe_type = (SELECT name FROM probe_types WHERE id = pc.probe_type_id)))`
+
+// silenceArgs returns the query arguments for silencedSQL.
+func silenceArgs(now time.Time) []any {
+	ts := now.UTC().Format(db.SQLiteTimeFormat)
+	return []any{ts, ts}
+}
+
+// silenced reports whether an active silence matches a config. Errors are
+// logged and treated as not silenced, so notifications are not lost.
+func (s *Server) silenced(ctx context.Context, configID int) bool {
+	var silenced bool
+	err := s.db.DB().QueryRowContext(ctx, `
+		SELECT `+silencedSQL+` FROM probe_configs pc WHERE pc.id = ?
+	`, append(silenceArgs(time.Now()), configID)...).Scan(&silenced)
+	if err != nil {
+		slog.Error("failed to check silences", "config_id", configID, "error", err)
+		return false
+	}
+	return silenced
+}
+
+// silenceRequest creates a silence. At least one matcher is required; all
+// that are set must match.
+type silenceRequest struct {
+	StartsAt  *time.Time `json:"starts_at"` // Defaults to now
+	EndsAt    *time.Time `json:"ends_at"`
+	Duration  string     `json:"duration"` // Alternative to ends_at, e.g. "2h"
+	Comment   string     `json:"comment"`
+	CreatedBy string     `json:"created_by"` // Only used with the shared token
+
+	ProbeConfigID *int    `json:"probe_config_id"`
+	GroupPath     *string `json:"group_path"`
+	Keyword       *string `json:"keyword"`
+	WatcherID     *int    `json:"watcher_id"`
+	ProbeType     *string `json:"probe_type"`
+}
+
+// window returns the validated start and end of the silence.
+func (req *silenceRequest) window(now time.Time) (time.Time, time.Time, error) {
+	if req.ProbeConfigID == nil && req.GroupPath == nil && req.Keyword == nil && req.WatcherID == nil && req.ProbeType == nil {
+		return time.Time{}, time.Time{}, errors.New("at least one matcher is required: probe_config_id, group_path, keyword, watcher_id or probe_type")
+	}
+
+	start := now
+	if req.StartsAt != nil {
+		start = *req.StartsAt
+	}
+
+	var end time.Time
+	switch {
+	case req.EndsAt != nil && req.Duration != "":
+		return time.Time{}, time.Time{}, errors.New("ends_at and duration are mutually exclusive")
+	case req.EndsAt != nil:
+		end = *req.EndsAt
+	case req.Duration != "":
+		d, err := time.ParseDuration(req.Duration)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		end = start.Add(d)
+	default:
+		return time.Time{}, time.Time{}, errors.New("ends_at or duration is required")
+	}
+
+	if !end.After(start) {
+		return time.Time{}, time.Time{}, errors.New("silence must end after it starts")
+	}
+	if !end.After(now) {
+		return time.Time{}, time.Time{}, errors.New("silence must end in the future")
+	}
+	return start.UTC(), end.UTC(), nil
+}
+
+func (s *Server) handleCreateSilence(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req silenceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	start, end, err := req.window(time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// Users can't create silences in someone else's name
+	if name := callerName(ctx); name != "" {
+		req.CreatedBy = name
+	}
+
+	result, err := s.db.DB().ExecContext(ctx, `
+		INSERT INTO silences (starts_at, ends_at, comment, created_by,
+		                      probe_config_id, group_path, keyword, watcher_id, probe_type)
+		VALUES (?, ?, NULLIF(?, ''), NULLIF(?, ''), ?, ?, ?, ?, ?)
+	`, start.Format(db.SQLiteTimeFormat), end.Format(db.SQLiteTimeFormat), req.Comment, req.CreatedBy,
+		req.ProbeConfigID, req.GroupPath, req.Keyword, req.WatcherID, req.ProbeType)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	id, _ := result.LastInsertId()
+	s.audit(ctx, "create", auditSilence, int(id), nil, s.auditState(ctx, auditSilence, int(id)))
+	slog.Info("silence created", "id", id, "starts_at", start, "ends_at", end)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]any{"id": id})
+}
+
+// handleListSilences lists active and pending silences, or with ?state=
+// only active, pending or expired ones.
+func (s *Server) handleListSilences(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	now := time.Now().UTC()
+	ts := now.Format(db.SQLiteTimeFormat)
+
+	query := `
+		SELECT si.id, si.starts_at, si.ends_at, si.comment, si.created_by, si.created_at,
+		       si.probe_config_id, pc.name, si.group_path, si.keyword, si.watcher_id, w.name, si.probe_type
+		FROM silences si
+		LEFT JOIN probe_configs pc ON pc.id = si.probe_config_id
+		LEFT JOIN watchers w ON w.id = si.watcher_id
+	`
+	var args []any
+	switch r.URL.Query().Get("state") {
+	case "":
+		query += " WHERE si.ends_at > ?"
+		args = append(args, ts)
+	case "active":
+		query += " WHERE si.starts_at <= ? AND si.ends_at > ?"
+		args = append(args, ts, ts)
+	case "pending":
+		query += " WHERE si.starts_at > ?"
+		args = append(args, ts)
+	case "expired":
+		query += " WHERE si.ends_at <= ?"
+		args = append(args, ts)
+	default:
+		http.Error(w, "state must be active, pending or expired", http.StatusBadRequest)
+		return
+	}
+	query += " ORDER BY si.starts_at, si.id"
+
+	rows, err := s.db.DB().QueryContext(ctx, query, args...)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	silences := []map[string]any{}
+	for rows.Next() {
+		var id int
+		var startsAt, endsAt, createdAt db.NullTime
+		var comment, createdBy *string
+		var configID, watcherID *int
+		var configName, groupPath, keyword, watcherName, probeType *string
+		if err := rows.Scan(&id, &startsAt, &endsAt, &comment, &createdBy, &createdAt,
+			&configID, &configName, &groupPath, &keyword, &watcherID, &watcherName, &probeType); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		state := "active"
+		switch {
+		case !endsAt.Time.After(now):
+			state = "expired"
+		case startsAt.Time.After(now):
+			state = "pending"
+		}
+		silence := map[string]any{
+			"id":                id,
+			"starts_at":         startsAt.Time,
+			"ends_at":           endsAt.Time,
+			"state":             state,
+			"remaining_seconds": max(0, int(endsAt.Time.Sub(now).Seconds())),
+		}
+		if state == "pending" {
+			silence["starts_in_seconds"] = int(startsAt.Time.Sub(now).Seconds())
+		}
+		if comment != nil {
+			silence["comment"] = *comment
+		}
+		if createdBy != nil {
+			silence["created_by"] = *createdBy
+		}
+		if createdAt.Valid {
+			silence["created_at"] = createdAt.Time
+		}
+		if configID != nil {
+			silence["probe_config_id"] = *configID
+		}
+		if configName != nil {
+			silence["probe_config_name"] = *configName
+		}
+		if groupPath != nil {
+			silence["group_path"] = *groupPath
+		}
+		if keyword != nil {
+			silence["keyword"] = *keyword
+		}
+		if watcherID != nil {
+			silence["watcher_id"] = *watcherID
+		}
+		if watcherName != nil {
+			silence["watcher_name"] = *watcherName
+		}
+		if probeType != nil {
+			silence["probe_type"] = *probeType
+		}
+		silences = append(silences, silence)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(silences)
+}
+
+func (s *Server) handleDeleteSilence(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id, _ := strconv.Atoi(r.PathValue("id"))
+
+	before := s.auditState(ctx, auditSilence, id)
+	result, err := s.db.DB().ExecContext(ctx, `DELETE FROM silences WHERE id = ?`, id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	s.audit(ctx, "delete", auditSilence, id, before, nil)
+
+	slog.Info("silence deleted", "id", id)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,143 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSilenceRequestWindow(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	at := func(hours int) *time.Time {
+		t := now.Add(time.Duration(hours) * time.Hour)
+		return &t
+	}
+	keyword := "db"
+
+	req := silenceRequest{Keyword: &keyword, Duration: "2h"}
+	start, end, err := req.window(now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !start.Equal(now) || !end.Equal(now.Add(2*time.Hour)) {
+		t.Errorf("unexpected window %v - %v", start, end)
+	}
+
+	// Scheduled ahead of time
+	req = silenceRequest{Keyword: &keyword, StartsAt: at(24), Duration: "1h"}
+	start, end, err = req.window(now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !start.Equal(*at(24)) || !end.Equal(*at(25)) {
+		t.Errorf("unexpected window %v - %v", start, end)
+	}
+
+	tests := map[string]silenceRequest{
+		"no matcher":       {EndsAt: at(1)},
+		"no end":           {Keyword: &keyword},
+		"end and duration": {Keyword: &keyword, EndsAt: at(1), Duration: "1h"},
+		"bad duration":     {Keyword: &keyword, Duration: "soon"},
+		"end before start": {Keyword: &keyword, StartsAt: at(2), EndsAt: at(1)},
+		"already over":     {Keyword: &keyword, StartsAt: at(-2), EndsAt: at(-1)},
+	}
+	for name, req := range tests {
+		if _, _, err := req.window(now); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestSilenceMatching(t *testing.T) {
+	server, cleanup := testServer(t)
+	if server == nil {
+		return
+	}
+	defer cleanup()
+
+	ctx := context.Background()
+	sqlDB := server.db.DB()
+
+	if _, err := sqlDB.ExecContext(ctx, `INSERT INTO probe_types (name, version, arguments) VALUES ('silence-type', '1.0.0', '{}')`); err != nil {
+		t.Fatalf("failed to create probe type: %v", err)
+	}
+	result, err := sqlDB.ExecContext(ctx, `
+		INSERT INTO probe_configs (probe_type_id, name, interval, group_path, keywords)
+		SELECT id, 'silence-config', '1m', 'prod/db', '["postgres"]' FROM probe_types WHERE name = 'silence-type'
+	`)
+	if err != nil {
+		t.Fatalf("failed to create config: %v", err)
+	}
+	id, _ := result.LastInsertId()
+	configID := int(id)
+
+	create := func(body map[string]any) {
+		t.Helper()
+		data, _ := json.Marshal(body)
+		req := httptest.NewRequest("POST", "/api/silences", bytes.NewReader(data))
+		w := httptest.NewRecorder()
+		server.handleCreateSilence(w, req)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("expected status 201, got %d: %s", w.Code, w.Body.String())
+		}
+	}
+
+	// None matches: the group is only a prefix, the keyword differs, and _
+	// in a group is not a wildcard
+	create(map[string]any{"group_path": "prod/d", "duration": "1h"})
+	create(map[string]any{"group_path": "pr_d", "duration": "1h"})
+	create(map[string]any{"group_path": "prod", "keyword": "mysql", "duration": "1h"})
+	// Matches, but only starts tomorrow
+	create(map[string]any{"probe_type": "silence-type", "starts_at": time.Now().Add(24 * time.Hour), "duration": "1h"})
+	if server.silenced(ctx, configID) {
+		t.Fatal("expected the config not to be silenced")
+	}
+
+	create(map[string]any{"group_path": "prod", "keyword": "postgres", "duration": "1h", "comment": "upgrade"})
+	if !server.silenced(ctx, configID) {
+		t.Fatal("expected the config to be silenced")
+	}
+
+	req := httptest.NewRequest("GET", "/api/silences", nil)
+	w := httptest.NewRecorder()
+	server.handleListSilences(w, req)
+	var silences []map[string]any
+	if err := json.NewDecoder(w.Body).Decode(&silences); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(silences) != 5 {
+		t.Fatalf("expected 5 silences, got %d", len(silences))
+	}
+	pending := silences[len(silences)-1]
+	if pending["state"] != "pending" || pending["starts_in_seconds"] == nil {
+		t.Errorf("expected the last silence to be pending, got %v", pending)
+	}
+	remaining, _ := silences[0]["remaining_seconds"].(float64)
+	if remaining <= 0 || remaining > 3600 {
+		t.Errorf("unexpected remaining time %v", silences[0]["remaining_seconds"])
+	}
+
+	// Expired silences no longer apply
+	if _, err := sqlDB.ExecContext(ctx, `UPDATE silences SET ends_at = starts_at`); err != nil {
+		t.Fatalf("failed to expire silences: %v", err)
+	}
+	if server.silenced(ctx, configID) {
+		t.Error("expected expired silences not to apply")
+	}
+
+	// Users are recorded by name, whatever the request says
+	data, _ := json.Marshal(map[string]any{"keyword": "postgres", "duration": "1h", "created_by": "bob"})
+	req = httptest.NewRequest("POST", "/api/silences", bytes.NewReader(data))
+	req = req.WithContext(context.WithValue(req.Context(), identityKey, &identity{userID: 1, username: "alice", role: roleOperator}))
+	w = httptest.NewRecorder()
+	server.handleCreateSilence(w, req)
+	var createdBy string
+	sqlDB.QueryRowContext(ctx, `SELECT created_by FROM silences ORDER BY id DESC LIMIT 1`).Scan(&createdBy)
+	if w.Code != http.StatusCreated || createdBy != "alice" {
+		t.Errorf("expected a silence created by alice, got %d %q", w.Code, createdBy)
+	}
+}
@@ -0,0 +1,134 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/jandubois/monitor/internal/notify"
+	"github.com/jandubois/monitor/internal/probe"
+)
+
+// statusFilter holds the settings that keep a noisy probe from notifying
+// every status change.
+type statusFilter struct {
+	consecutive   int // Results that must agree before a change counts
+	flapWindow    int // Results considered for flap detection; 0 disables it
+	flapThreshold int // Status changes within the window that mean flapping
+}
+
+func (f *statusFilter) validate() error {
+	if f.consecutive < 1 {
+		return errors.New("consecutive_results must be at least 1")
+	}
+	if f.flapWindow == 0 && f.flapThreshold == 0 {
+		return nil
+	}
+	if f.flapWindow < 3 {
+		return errors.New("flap_window must be 0 or at least 3")
+	}
+	if f.flapThreshold < 2 || f.flapThreshold >= f.flapWindow {
+		return errors.New("flap_threshold must be at least 2 and less than flap_window")
+	}
+	return nil
+}
+
+// history returns the number of recent results evaluate needs.
+func (f *statusFilter) history() int {
+	return max(f.consecutive, f.flapWindow, 1)
+}
+
+// statusDecision is the outcome of evaluating a new result.
+type statusDecision struct {
+	confirmed   probe.Status // Status that notifications are based on
+	changed     bool         // confirmed differs from before
+	flapping    bool
+	flapStarted bool
+	flapStopped bool
+	changes     int // Status changes within the flap window
+}
+
+// evaluate decides whether the latest results confirm a status change and
+// whether the config is flapping. recent holds the latest statuses, newest
+// first. The confirmed status is frozen while flapping, so the end of
+// flapping counts as a change only if the status settled elsewhere.
+func (f *statusFilter) evaluate(recent []probe.Status, confirmed probe.Status, flapping bool) statusDecision {
+	d := statusDecision{confirmed: confirmed}
+	if len(recent) == 0 {
+		d.flapping = flapping
+		return d
+	}
+
+	if f.flapWindow > 0 {
+		window := recent[:min(len(recent), f.flapWindow)]
+		for i := 1; i < len(window); i++ {
+			if window[i] != window[i-1] {
+				d.changes++
+			}
+		}
+		// Flapping ends only below half the threshold, so a config near
+		// the threshold doesn't start and stop flapping all the time
+		if flapping {
+			d.flapping = d.changes*2 >= f.flapThreshold
+		} else {
+			d.flapping = d.changes >= f.flapThreshold
+		}
+	}
+	d.flapStarted = d.flapping && !flapping
+	d.flapStopped = flapping && !d.flapping
+	if d.flapping {
+		return d
+	}
+
+	n := max(f.consecutive, 1)
+	if len(recent) < n {
+		return d
+	}
+	for _, status := range recent[1:n] {
+		if status != recent[0] {
+			return d
+		}
+	}
+	d.confirmed = recent[0]
+	d.changed = d.confirmed != confirmed
+	return d
+}
+
+// recentStatuses returns the statuses of the latest n results of a
+// config, newest first.
+func (s *Server) recentStatuses(ctx context.Context, configID, n int) ([]probe.Status, error) {
+	rows, err := s.db.DB().QueryContext(ctx, `
+		SELECT status FROM probe_results WHERE probe_config_id = ? ORDER BY executed_at DESC LIMIT ?
+	`, configID, n)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var statuses []probe.Status
+	for rows.Next() {
+		var status string
+		if err := rows.Scan(&status); err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, probe.Status(status))
+	}
+	return statuses, rows.Err()
+}
+
+// notifyFlapping sends a flapping notification to the config's channels,
+// or to the first step of its escalation policy.
+func (s *Server) notifyFlapping(ctx context.Context, configID int, channels []int, policyID *int, flapping *notify.Flapping) {
+	if policyID != nil {
+		policy, err := s.loadEscalationPolicy(ctx, *policyID)
+		if err != nil {
+			slog.Error("failed to load escalation policy", "config_id", configID, "policy_id", *policyID, "error", err)
+			return
+		}
+		channels = policy.channels(1)
+	}
+	if len(channels) == 0 {
+		return
+	}
+	s.dispatcher.NotifyFlapping(ctx, channels, flapping)
+}
@@ -0,0 +1,113 @@
+package web
+
+import (
+	"testing"
+
+	"github.com/jandubois/monitor/internal/probe"
+)
+
+// statuses parses a compact history, oldest first, into statuses newest
+// first: o=ok, w=warning, c=critical.
+func statuses(history string) []probe.Status {
+	names := map[rune]probe.Status{'o': probe.StatusOK, 'w': probe.StatusWarning, 'c': probe.StatusCritical}
+	var result []probe.Status
+	for _, r := range history {
+		result = append([]probe.Status{names[r]}, result...)
+	}
+	return result
+}
+
+func TestStatusFilterValidate(t *testing.T) {
+	valid := []statusFilter{
+		{consecutive: 1},
+		{consecutive: 3, flapWindow: 10, flapThreshold: 4},
+	}
+	for _, f := range valid {
+		if err := f.validate(); err != nil {
+			t.Errorf("%+v: unexpected error: %v", f, err)
+		}
+	}
+
+	invalid := []statusFilter{
+		{consecutive: 0},
+		{consecutive: 1, flapWindow: 2, flapThreshold: 1},
+		{consecutive: 1, flapWindow: 10},
+		{consecutive: 1, flapThreshold: 4},
+		{consecutive: 1, flapWindow: 10, flapThreshold: 10},
+	}
+	for _, f := range invalid {
+		if err := f.validate(); err == nil {
+			t.Errorf("%+v: expected error", f)
+		}
+	}
+}
+
+func TestStatusFilterConsecutive(t *testing.T) {
+	f := statusFilter{consecutive: 3}
+
+	tests := []struct {
+		history   string
+		confirmed probe.Status
+		expected  probe.Status
+		changed   bool
+	}{
+		{history: "oc", confirmed: probe.StatusOK, expected: probe.StatusOK},
+		{history: "occ", confirmed: probe.StatusOK, expected: probe.StatusOK},
+		{history: "occc", confirmed: probe.StatusOK, expected: probe.StatusCritical, changed: true},
+		{history: "cccc", confirmed: probe.StatusCritical, expected: probe.StatusCritical},
+		{history: "cwcw", confirmed: probe.StatusOK, expected: probe.StatusOK},
+		{history: "ccco", confirmed: probe.StatusCritical, expected: probe.StatusCritical},
+		{history: "cc", confirmed: "", expected: ""},
+		{history: "ccc", confirmed: "", expected: probe.StatusCritical, changed: true},
+	}
+	for _, tt := range tests {
+		d := f.evaluate(statuses(tt.history), tt.confirmed, false)
+		if d.confirmed != tt.expected || d.changed != tt.changed {
+			t.Errorf("%s from %q: expected (%q, %v), got (%q, %v)", tt.history, tt.confirmed, tt.expected, tt.changed, d.confirmed, d.changed)
+		}
+		if d.flapping {
+			t.Errorf("%s: unexpected flapping without flap detection", tt.history)
+		}
+	}
+}
+
+func TestStatusFilterFlapping(t *testing.T) {
+	f := statusFilter{consecutive: 1, flapWindow: 6, flapThreshold: 4}
+
+	// Three changes are not enough
+	d := f.evaluate(statuses("ococ"), probe.StatusOK, false)
+	if d.flapping || !d.changed || d.changes != 3 {
+		t.Errorf("expected a plain change, got %+v", d)
+	}
+
+	// The fourth starts flapping and freezes the confirmed status
+	d = f.evaluate(statuses("ococo"), probe.StatusCritical, false)
+	if !d.flapStarted || d.changed || d.confirmed != probe.StatusCritical || d.changes != 4 {
+		t.Errorf("expected flapping to start, got %+v", d)
+	}
+
+	// Three changes in the window keep it flapping
+	d = f.evaluate(statuses("ocococcc"), probe.StatusCritical, true)
+	if !d.flapping || d.flapStarted || d.flapStopped || d.changed {
+		t.Errorf("expected flapping to continue, got %+v", d)
+	}
+
+	// One change ends it; the status settled where it was frozen
+	d = f.evaluate(statuses("ocococcccc"), probe.StatusCritical, true)
+	if !d.flapStopped || d.changed || d.changes != 1 {
+		t.Errorf("expected flapping to stop without a change, got %+v", d)
+	}
+
+	// Settling elsewhere is a change
+	d = f.evaluate(statuses("cocooooo"), probe.StatusCritical, true)
+	if !d.flapStopped || !d.changed || d.confirmed != probe.StatusOK {
+		t.Errorf("expected flapping to stop with a change, got %+v", d)
+	}
+
+	// Disabling flap detection ends flapping
+	f.flapWindow, f.flapThreshold = 0, 0
+	d = f.evaluate(statuses("ococ"), probe.StatusOK, true)
+	if !d.flapStopped || d.flapping {
+		t.Errorf("expected flapping to stop, got %+v", d)
+	}
+}
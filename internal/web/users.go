@@ -0,0 +1,539 @@
+package web
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jandubois/monitor/internal/db"
+)
+
+func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var userID int
+	var hash, role string
+	err := s.db.DB().QueryRowContext(ctx, `
+		SELECT id, password_hash, role FROM users WHERE username = ?
+	`, req.Username).Scan(&userID, &hash, &role)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		checkPassword(dummyPasswordHash(), req.Password)
+		http.Error(w, "invalid username or password", http.StatusUnauthorized)
+		return
+	}
+	if !checkPassword(hash, req.Password) {
+		slog.Warn("failed login", "username", req.Username)
+		http.Error(w, "invalid username or password", http.StatusUnauthorized)
+		return
+	}
+
+	if err := s.startSession(w, r, userID); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	slog.Info("user logged in", "username", req.Username)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"id": userID, "username": req.Username, "role": role, "auth": "session"})
+}
+
+// startSession logs a user in by setting a session cookie.
+func (s *Server) startSession(w http.ResponseWriter, r *http.Request, userID int) error {
+	ctx := r.Context()
+	secret, err := newSecret()
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	expires := now.Add(sessionDuration)
+	if _, err := s.db.DB().ExecContext(ctx, `
+		INSERT INTO sessions (token_hash, user_id, expires_at) VALUES (?, ?, ?)
+	`, hashSecret(secret), userID, expires.Format(db.SQLiteTimeFormat)); err != nil {
+		return err
+	}
+	s.db.DB().ExecContext(ctx, `UPDATE users SET last_login_at = ? WHERE id = ?`, now.Format(db.SQLiteTimeFormat), userID)
+	s.db.DB().ExecContext(ctx, `DELETE FROM sessions WHERE expires_at <= ?`, now.Format(db.SQLiteTimeFormat))
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    secret,
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   s.secureCookies(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+	return nil
+}
+
+// secureCookies reports whether cookies should only be sent over HTTPS.
+func (s *Server) secureCookies(r *http.Request) bool {
+	return r.TLS != nil || strings.HasPrefix(s.config.PublicURL, "https://")
+}
+
+func (s *Server) handleLogout(w http.ResponseWriter, r *http.Request) {
+	if c, err := r.Cookie(sessionCookie); err == nil {
+		if _, err := s.db.DB().ExecContext(r.Context(), `
+			DELETE FROM sessions WHERE token_hash = ?
+		`, hashSecret(c.Value)); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleGetMe(w http.ResponseWriter, r *http.Request) {
+	id := identityFromContext(r.Context())
+	me := map[string]any{
+		"username": id.username,
+		"role":     id.role,
+		"auth":     id.method,
+	}
+	if id.userID != 0 {
+		me["id"] = id.userID
+		// Users from the identity provider have no password to change
+		var sso bool
+		s.db.DB().QueryRowContext(r.Context(), `
+			SELECT oidc_subject IS NOT NULL FROM users WHERE id = ?
+		`, id.userID).Scan(&sso)
+		me["sso"] = sso
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(me)
+}
+
+func (s *Server) handleChangePassword(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := identityFromContext(ctx)
+	if id.userID == 0 {
+		http.Error(w, "the shared token has no password", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		CurrentPassword string `json:"current_password"`
+		NewPassword     string `json:"new_password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(req.NewPassword) < minPasswordLength {
+		http.Error(w, errShortPassword.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var hash string
+	if err := s.db.DB().QueryRowContext(ctx, `SELECT password_hash FROM users WHERE id = ?`, id.userID).Scan(&hash); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !checkPassword(hash, req.CurrentPassword) {
+		http.Error(w, "current password is wrong", http.StatusForbidden)
+		return
+	}
+
+	newHash, err := hashPassword(req.NewPassword)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// Other sessions end, this one stays
+	if err := setPassword(ctx, s.db.DB(), id.userID, newHash, id.sessionHash); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleListAPITokens(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := identityFromContext(ctx)
+
+	// Admins can list everyone's tokens with ?all=true
+	query := `
+		SELECT t.id, t.name, t.prefix, t.role, t.created_at, t.expires_at, t.last_used_at, u.username
+		FROM api_tokens t
+		JOIN users u ON u.id = t.user_id
+	`
+	var args []any
+	if r.URL.Query().Get("all") != "true" || !roleAtLeast(id.role, roleAdmin) {
+		query += " WHERE t.user_id = ?"
+		args = append(args, id.userID)
+	}
+	query += " ORDER BY t.created_at DESC, t.id DESC"
+
+	rows, err := s.db.DB().QueryContext(ctx, query, args...)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	tokens := []map[string]any{}
+	for rows.Next() {
+		var tokenID int
+		var name, prefix, username string
+		var role *string
+		var createdAt, expiresAt, lastUsedAt db.NullTime
+		if err := rows.Scan(&tokenID, &name, &prefix, &role, &createdAt, &expiresAt, &lastUsedAt, &username); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		token := map[string]any{
+			"id":       tokenID,
+			"name":     name,
+			"prefix":   prefix,
+			"username": username,
+		}
+		if role != nil {
+			token["role"] = *role
+		}
+		if createdAt.Valid {
+			token["created_at"] = createdAt.Time
+		}
+		if expiresAt.Valid {
+			token["expires_at"] = expiresAt.Time
+		}
+		if lastUsedAt.Valid {
+			token["last_used_at"] = lastUsedAt.Time
+		}
+		tokens = append(tokens, token)
+	}
+	if err := rows.Err(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tokens)
+}
+
+func (s *Server) handleCreateAPIToken(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := identityFromContext(ctx)
+	if id.userID == 0 {
+		http.Error(w, "API tokens belong to users; log in as one to create them", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		Name      string `json:"name"`
+		Role      string `json:"role"`       // Optional; at most the user's role
+		ExpiresIn string `json:"expires_in"` // Optional, e.g. "2160h"
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Name == "" {
+		http.Error(w, "name is required", http.StatusBadRequest)
+		return
+	}
+	var role *string
+	if req.Role != "" {
+		if !validRole(req.Role) {
+			http.Error(w, "role must be viewer, operator or admin", http.StatusBadRequest)
+			return
+		}
+		if !roleAtLeast(id.role, req.Role) {
+			http.Error(w, "a token can't have a higher role than its user", http.StatusForbidden)
+			return
+		}
+		role = &req.Role
+	}
+	var expiresAt *string
+	if req.ExpiresIn != "" {
+		d, err := time.ParseDuration(req.ExpiresIn)
+		if err != nil || d <= 0 {
+			http.Error(w, "expires_in must be a positive duration", http.StatusBadRequest)
+			return
+		}
+		ts := time.Now().Add(d).UTC().Format(db.SQLiteTimeFormat)
+		expiresAt = &ts
+	}
+
+	secret, err := newSecret()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	token := apiTokenPrefix + secret
+	prefix := token[:len(apiTokenPrefix)+6]
+
+	result, err := s.db.DB().ExecContext(ctx, `
+		INSERT INTO api_tokens (user_id, name, token_hash, prefix, role, expires_at) VALUES (?, ?, ?, ?, ?, ?)
+	`, id.userID, req.Name, hashSecret(token), prefix, role, expiresAt)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	tokenID, _ := result.LastInsertId()
+
+	slog.Info("API token created", "username", id.username, "name", req.Name)
+	// The token itself is only ever shown here
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]any{"id": tokenID, "token": token, "prefix": prefix})
+}
+
+func (s *Server) handleDeleteAPIToken(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := identityFromContext(ctx)
+	tokenID, _ := strconv.Atoi(r.PathValue("id"))
+
+	// Admins can revoke anyone's tokens
+	query := `DELETE FROM api_tokens WHERE id = ?`
+	args := []any{tokenID}
+	if !roleAtLeast(id.role, roleAdmin) {
+		query += " AND user_id = ?"
+		args = append(args, id.userID)
+	}
+	result, err := s.db.DB().ExecContext(ctx, query, args...)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		http.Error(w, "token not found", http.StatusNotFound)
+		return
+	}
+
+	slog.Info("API token revoked", "id", tokenID, "by", id.username)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleListUsers(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	rows, err := s.db.DB().QueryContext(ctx, `
+		SELECT id, username, role, oidc_subject IS NOT NULL, created_at, last_login_at FROM users ORDER BY username
+	`)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	users := []map[string]any{}
+	for rows.Next() {
+		var userID int
+		var username, role string
+		var sso bool
+		var createdAt, lastLoginAt db.NullTime
+		if err := rows.Scan(&userID, &username, &role, &sso, &createdAt, &lastLoginAt); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		user := map[string]any{
+			"id":       userID,
+			"username": username,
+			"role":     role,
+			"sso":      sso,
+		}
+		if createdAt.Valid {
+			user["created_at"] = createdAt.Time
+		}
+		if lastLoginAt.Valid {
+			user["last_login_at"] = lastLoginAt.Time
+		}
+		users = append(users, user)
+	}
+	if err := rows.Err(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(users)
+}
+
+func (s *Server) handleCreateUser(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+		Role     string `json:"role"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	userID, err := CreateUser(ctx, s.db, req.Username, req.Password, req.Role)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.audit(ctx, "create", auditUser, userID, nil, s.auditState(ctx, auditUser, userID))
+	slog.Info("user created", "username", req.Username, "role", req.Role, "by", identityFromContext(ctx).username)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]any{"id": userID})
+}
+
+// handleUpdateUser changes a user's role or resets their password; both
+// fields are optional.
+func (s *Server) handleUpdateUser(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, _ := strconv.Atoi(r.PathValue("id"))
+
+	var req struct {
+		Role     string `json:"role"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var role string
+	err := s.db.DB().QueryRowContext(ctx, `SELECT role FROM users WHERE id = ?`, userID).Scan(&role)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "user not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Nothing changes unless the whole request is valid
+	changeRole := req.Role != "" && req.Role != role
+	if changeRole {
+		if !validRole(req.Role) {
+			http.Error(w, "role must be viewer, operator or admin", http.StatusBadRequest)
+			return
+		}
+		if role == roleAdmin {
+			if err := s.checkNotLastAdmin(r, userID); err != nil {
+				http.Error(w, err.Error(), http.StatusConflict)
+				return
+			}
+		}
+	}
+	var hash string
+	if req.Password != "" {
+		if len(req.Password) < minPasswordLength {
+			http.Error(w, errShortPassword.Error(), http.StatusBadRequest)
+			return
+		}
+		if hash, err = hashPassword(req.Password); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	before := s.auditState(ctx, auditUser, userID)
+	tx, err := s.db.DB().BeginTx(ctx, nil)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	if changeRole {
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE users SET role = ?, updated_at = datetime('now') WHERE id = ?
+		`, req.Role, userID); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	if hash != "" {
+		if err := setPasswordTx(ctx, tx, userID, hash, ""); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if changeRole {
+		s.audit(ctx, "update", auditUser, userID, before, s.auditState(ctx, auditUser, userID))
+	}
+	if hash != "" {
+		// The password itself is never recorded
+		s.audit(ctx, "set_password", auditUser, userID, nil, nil)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleDeleteUser(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, _ := strconv.Atoi(r.PathValue("id"))
+
+	if identityFromContext(ctx).userID == userID {
+		http.Error(w, "you can't delete yourself", http.StatusConflict)
+		return
+	}
+	if err := s.checkNotLastAdmin(r, userID); err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	before := s.auditState(ctx, auditUser, userID)
+	// Sessions and API tokens go with the user
+	result, err := s.db.DB().ExecContext(ctx, `DELETE FROM users WHERE id = ?`, userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		http.Error(w, "user not found", http.StatusNotFound)
+		return
+	}
+	s.audit(ctx, "delete", auditUser, userID, before, nil)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// checkNotLastAdmin refuses to remove the admin role from the only admin
+// unless the shared token is configured, so someone can still manage users.
+func (s *Server) checkNotLastAdmin(r *http.Request, userID int) error {
+	if s.config.AuthToken != "" {
+		return nil
+	}
+	var others int
+	if err := s.db.DB().QueryRowContext(r.Context(), `
+		SELECT COUNT(*) FROM users WHERE role = 'admin' AND id <> ?
+	`, userID).Scan(&others); err != nil {
+		return err
+	}
+	if others == 0 {
+		return errors.New("this is the only admin")
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package web
+
+import (
+	"net/http"
+	"sync"
+)
+
+// watcherStreams counts the open config channels of each watcher. A
+// watcher holds one to hear about its config changes and run triggers as
+// they happen, which works behind NAT where a callback URL can't.
+type watcherStreams struct {
+	mu    sync.Mutex
+	count map[int]int
+}
+
+func (ws *watcherStreams) add(watcherID, delta int) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	if ws.count == nil {
+		ws.count = make(map[int]int)
+	}
+	ws.count[watcherID] += delta
+	if ws.count[watcherID] <= 0 {
+		delete(ws.count, watcherID)
+	}
+}
+
+func (ws *watcherStreams) connected(watcherID int) bool {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	return ws.count[watcherID] > 0
+}
+
+// handlePushEvents streams the config changes and run triggers of the
+// authenticated watcher. The watcher keeps polling its configs as a
+// fallback, just less often while the stream is open.
+func (s *Server) handlePushEvents(w http.ResponseWriter, r *http.Request) {
+	watcherID, ok := WatcherIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "watcher not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	s.watcherStreams.add(watcherID, 1)
+	defer s.watcherStreams.add(watcherID, -1)
+
+	filter := eventFilter{
+		watcherID: watcherID,
+		types:     map[string]bool{eventConfigChange: true, eventTrigger: true},
+	}
+	s.streamEvents(w, r, &filter, 0)
+}
+
+// triggerOverChannel asks a watcher to run a config now if the watcher
+// holds a config channel. It reports whether it did.
+func (s *Server) triggerOverChannel(watcherID *int, configID int) bool {
+	if watcherID == nil || !s.watcherStreams.connected(*watcherID) {
+		return false
+	}
+	s.events.publish(eventTrigger, watcherID, nil, map[string]any{
+		"probe_config_id": configID,
+		"watcher_id":      *watcherID,
+	})
+	return true
+}
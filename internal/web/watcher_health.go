@@ -0,0 +1,189 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jandubois/monitor/internal/db"
+	"github.com/jandubois/monitor/internal/notify"
+)
+
+const (
+	// defaultWatcherDownAfter is how long a watcher may go without a
+	// heartbeat before it is reported down. It is deliberately longer than
+	// watcherHealthyTimeout so a short network blip doesn't page anyone.
+	defaultWatcherDownAfter = 2 * time.Minute
+
+	watcherHealthCheckInterval = 15 * time.Second
+)
+
+// watcherHealth is the heartbeat state of an approved, unpaused watcher.
+type watcherHealth struct {
+	id        int
+	name      string
+	lastSeen  db.NullTime
+	downSince db.NullTime
+	channels  db.JSONIntArray
+}
+
+func (s *Server) watcherHealthLoop(ctx context.Context) {
+	ticker := time.NewTicker(watcherHealthCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.checkWatcherHealth(ctx, time.Now().UTC()); err != nil {
+				slog.Error("watcher health check failed", "error", err)
+			}
+		}
+	}
+}
+
+// checkWatcherHealth marks watchers down whose last heartbeat is older than
+// the configured threshold, and up again once heartbeats are back within
+// watcherHealthyTimeout. Each transition is notified exactly once; the state
+// lives in watchers.down_since so it survives restarts. It returns the number
+// of transitions.
+func (s *Server) checkWatcherHealth(ctx context.Context, now time.Time) (int, error) {
+	downAfter := s.config.WatcherDownAfter
+	if downAfter <= 0 {
+		downAfter = defaultWatcherDownAfter
+	}
+	if downAfter < watcherHealthyTimeout {
+		downAfter = watcherHealthyTimeout
+	}
+
+	// Paused watchers are expected to be quiet; unapproved ones aren't ours yet
+	rows, err := s.db.DB().QueryContext(ctx, `
+		SELECT id, name, last_seen_at, down_since, notification_channels
+		FROM watchers
+		WHERE approved = 1 AND paused = 0
+	`)
+	if err != nil {
+		return 0, err
+	}
+
+	var watchers []watcherHealth
+	for rows.Next() {
+		var wh watcherHealth
+		if err := rows.Scan(&wh.id, &wh.name, &wh.lastSeen, &wh.downSince, &wh.channels); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		watchers = append(watchers, wh)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	transitions := 0
+	for _, wh := range watchers {
+		silence := time.Duration(0)
+		if wh.lastSeen.Valid {
+			silence = now.Sub(wh.lastSeen.Time)
+		}
+
+		switch {
+		case !wh.downSince.Valid && wh.lastSeen.Valid && silence >= downAfter:
+			result, err := s.db.DB().ExecContext(ctx, `
+				UPDATE watchers SET down_since = ? WHERE id = ? AND down_since IS NULL
+			`, wh.lastSeen.Time.UTC().Format(db.SQLiteTimeFormat), wh.id)
+			if err != nil {
+				return transitions, err
+			}
+			if n, _ := result.RowsAffected(); n == 0 {
+				continue
+			}
+			transitions++
+
+			slog.Warn("watcher down", "watcher", wh.name, "last_seen", wh.lastSeen.Time)
+			s.events.publish(eventWatcherOffline, &wh.id, nil, map[string]any{
+				"watcher_id":   wh.id,
+				"name":         wh.name,
+				"last_seen_at": wh.lastSeen.Time.UTC(),
+			})
+			s.notifyWatcherHealth(ctx, &wh, &notify.WatcherHealthChange{
+				WatcherName: wh.name,
+				LastSeen:    wh.lastSeen.Time,
+			})
+
+		case wh.downSince.Valid && wh.lastSeen.Valid && silence < watcherHealthyTimeout:
+			result, err := s.db.DB().ExecContext(ctx, `
+				UPDATE watchers SET down_since = NULL WHERE id = ? AND down_since IS NOT NULL
+			`, wh.id)
+			if err != nil {
+				return transitions, err
+			}
+			if n, _ := result.RowsAffected(); n == 0 {
+				continue
+			}
+			transitions++
+
+			downtime := wh.lastSeen.Time.Sub(wh.downSince.Time)
+			slog.Info("watcher recovered", "watcher", wh.name, "downtime", downtime)
+			s.events.publish(eventWatcherOnline, &wh.id, nil, map[string]any{
+				"watcher_id":       wh.id,
+				"name":             wh.name,
+				"last_seen_at":     wh.lastSeen.Time.UTC(),
+				"downtime_seconds": int(downtime.Seconds()),
+			})
+			s.notifyWatcherHealth(ctx, &wh, &notify.WatcherHealthChange{
+				WatcherName: wh.name,
+				Online:      true,
+				LastSeen:    wh.lastSeen.Time,
+				Downtime:    downtime,
+			})
+		}
+	}
+
+	return transitions, nil
+}
+
+func (s *Server) notifyWatcherHealth(ctx context.Context, wh *watcherHealth, change *notify.WatcherHealthChange) {
+	if len(wh.channels) == 0 {
+		return
+	}
+	s.dispatcher.NotifyWatcherHealth(ctx, wh.channels, change)
+}
+
+func (s *Server) handleSetWatcherNotificationChannels(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id, _ := strconv.Atoi(r.PathValue("id"))
+
+	var req struct {
+		NotificationChannels []int `json:"notification_channels"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	channelsJSON, _ := json.Marshal(req.NotificationChannels)
+	before := s.auditState(ctx, auditWatcher, id)
+	result, err := s.db.DB().ExecContext(ctx, `
+		UPDATE watchers SET notification_channels = ? WHERE id = ?
+	`, string(channelsJSON), id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		http.Error(w, "watcher not found", http.StatusNotFound)
+		return
+	}
+
+	s.audit(ctx, "update", auditWatcher, id, before, s.auditState(ctx, auditWatcher, id))
+	slog.Info("watcher notification channels updated", "id", id, "channels", req.NotificationChannels)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"notification_channels": req.NotificationChannels})
+}
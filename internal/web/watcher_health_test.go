@@ -0,0 +1,145 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/jandubois/monitor/internal/db"
+)
+
+func TestCheckWatcherHealth(t *testing.T) {
+	server, cleanup := testServer(t)
+	if server == nil {
+		return
+	}
+	defer cleanup()
+
+	ctx := context.Background()
+	now := time.Now().UTC()
+	sqlDB := server.db.DB()
+
+	insertWatcher := func(name string, paused int, lastSeen time.Time) int64 {
+		t.Helper()
+		result, err := sqlDB.ExecContext(ctx, `
+			INSERT INTO watchers (name, token, approved, paused, last_seen_at)
+			VALUES (?, ?, 1, ?, ?)
+		`, name, name+"-token", paused, lastSeen.Format(db.SQLiteTimeFormat))
+		if err != nil {
+			t.Fatalf("failed to create watcher: %v", err)
+		}
+		id, _ := result.LastInsertId()
+		return id
+	}
+
+	healthyID := insertWatcher("healthy", 0, now.Add(-5*time.Second))
+	blipID := insertWatcher("blip", 0, now.Add(-time.Minute))
+	deadID := insertWatcher("dead", 0, now.Add(-10*time.Minute))
+	pausedID := insertWatcher("paused", 1, now.Add(-10*time.Minute))
+
+	downSince := func(id int64) db.NullTime {
+		t.Helper()
+		var ts db.NullTime
+		if err := sqlDB.QueryRowContext(ctx, `SELECT down_since FROM watchers WHERE id = ?`, id).Scan(&ts); err != nil {
+			t.Fatalf("failed to read watcher: %v", err)
+		}
+		return ts
+	}
+
+	transitions, err := server.checkWatcherHealth(ctx, now)
+	if err != nil {
+		t.Fatalf("checkWatcherHealth failed: %v", err)
+	}
+	if transitions != 1 {
+		t.Errorf("expected 1 transition, got %d", transitions)
+	}
+	if !downSince(deadID).Valid {
+		t.Error("expected dead watcher to be marked down")
+	}
+	for _, id := range []int64{healthyID, blipID, pausedID} {
+		if downSince(id).Valid {
+			t.Errorf("watcher %d should not be marked down", id)
+		}
+	}
+
+	// Still down: no repeated transition
+	transitions, err = server.checkWatcherHealth(ctx, now)
+	if err != nil {
+		t.Fatalf("checkWatcherHealth failed: %v", err)
+	}
+	if transitions != 0 {
+		t.Errorf("expected no transitions on second pass, got %d", transitions)
+	}
+
+	// Heartbeat comes back
+	if _, err := sqlDB.ExecContext(ctx, `UPDATE watchers SET last_seen_at = ? WHERE id = ?`,
+		now.Format(db.SQLiteTimeFormat), deadID); err != nil {
+		t.Fatalf("failed to update watcher: %v", err)
+	}
+	transitions, err = server.checkWatcherHealth(ctx, now)
+	if err != nil {
+		t.Fatalf("checkWatcherHealth failed: %v", err)
+	}
+	if transitions != 1 {
+		t.Errorf("expected 1 recovery transition, got %d", transitions)
+	}
+	if downSince(deadID).Valid {
+		t.Error("expected recovered watcher to be cleared")
+	}
+}
+
+func TestSetWatcherNotificationChannels(t *testing.T) {
+	server, cleanup := testServer(t)
+	if server == nil {
+		return
+	}
+	defer cleanup()
+
+	ctx := context.Background()
+	result, err := server.db.DB().ExecContext(ctx, `
+		INSERT INTO watchers (name, token, approved) VALUES ('channels-watcher', 'channels-token', 1)
+	`)
+	if err != nil {
+		t.Fatalf("failed to create watcher: %v", err)
+	}
+	id, _ := result.LastInsertId()
+	idStr := strconv.FormatInt(id, 10)
+
+	body, _ := json.Marshal(map[string]any{"notification_channels": []int{3, 5}})
+	req := httptest.NewRequest("PUT", "/api/watchers/"+idStr+"/notification-channels", bytes.NewReader(body))
+	req.SetPathValue("id", idStr)
+	w := httptest.NewRecorder()
+	server.handleSetWatcherNotificationChannels(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	req = httptest.NewRequest("GET", "/api/watchers/"+idStr, nil)
+	req.SetPathValue("id", idStr)
+	w = httptest.NewRecorder()
+	server.handleGetWatcher(w, req)
+
+	var resp map[string]any
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	channels, ok := resp["notification_channels"].([]any)
+	if !ok || len(channels) != 2 || channels[0].(float64) != 3 || channels[1].(float64) != 5 {
+		t.Errorf("unexpected notification_channels: %v", resp["notification_channels"])
+	}
+
+	// Unknown watcher
+	req = httptest.NewRequest("PUT", "/api/watchers/999999/notification-channels", bytes.NewReader(body))
+	req.SetPathValue("id", "999999")
+	w = httptest.NewRecorder()
+	server.handleSetWatcherNotificationChannels(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404, got %d", w.Code)
+	}
+}
@@ -6,7 +6,9 @@ import { Dashboard } from './pages/Dashboard';
 import { ProbeDetail } from './pages/ProbeDetail';
 import { Config } from './pages/Config';
 import { Failures } from './pages/Failures';
-import type { ProbeConfig } from './api/types';
+import { Account } from './pages/Account';
+import { LiveUpdates } from './components/LiveUpdates';
+import type { CurrentUser, ProbeConfig } from './api/types';
 
 const queryClient = new QueryClient({
   defaultOptions: {
@@ -17,22 +19,24 @@ const queryClient = new QueryClient({
   },
 });
 
-type Page = 'dashboard' | 'config' | 'detail' | 'failures';
+type Page = 'dashboard' | 'config' | 'detail' | 'failures' | 'account';
 
 function App() {
-  const [authenticated, setAuthenticated] = useState(false);
+  const [user, setUser] = useState<CurrentUser | null>(null);
   const [page, setPage] = useState<Page>('dashboard');
   const [selectedConfig, setSelectedConfig] = useState<ProbeConfig | null>(null);
-  const [loading, setLoading] = useState(() => !!api.getToken());
+  const [loading, setLoading] = useState(true);
+
+  // A stored API token or a session cookie from an earlier login may still
+  // be valid
+  const loadUser = () =>
+    api.getMe()
+      .then(setUser)
+      .catch(() => setUser(null))
+      .finally(() => setLoading(false));
 
   useEffect(() => {
-    const token = api.getToken();
-    if (token) {
-      api.getStatus()
-        .then(() => setAuthenticated(true))
-        .catch(() => api.clearToken())
-        .finally(() => setLoading(false));
-    }
+    loadUser();
   }, []);
 
   if (loading) {
@@ -43,10 +47,17 @@ function App() {
     );
   }
 
-  if (!authenticated) {
-    return <Login onLogin={() => setAuthenticated(true)} />;
+  if (!user) {
+    return <Login onLogin={loadUser} />;
   }
 
+  const handleLogout = async () => {
+    await api.logout().catch(() => api.clearToken());
+    queryClient.clear();
+    setUser(null);
+    setPage('dashboard');
+  };
+
   const handleProbeClick = (config: ProbeConfig) => {
     setSelectedConfig(config);
     setPage('detail');
@@ -60,6 +71,7 @@ function App() {
 
   return (
     <QueryClientProvider client={queryClient}>
+      <LiveUpdates />
       <div className="min-h-screen bg-gray-100">
         {page === 'detail' && selectedConfig ? (
           <ProbeDetail
@@ -68,6 +80,8 @@ function App() {
           />
         ) : page === 'config' ? (
           <Config onBack={() => setPage('dashboard')} />
+        ) : page === 'account' ? (
+          <Account user={user} onBack={() => setPage('dashboard')} />
         ) : page === 'failures' ? (
           <Failures
             onBack={() => setPage('dashboard')}
@@ -78,6 +92,8 @@ function App() {
             onProbeClick={handleProbeClick}
             onConfigClick={() => setPage('config')}
             onFailuresClick={() => setPage('failures')}
+            onAccountClick={() => setPage('account')}
+            onLogout={handleLogout}
           />
         )}
       </div>
@@ -51,9 +51,21 @@ describe('ApiClient', () => {
       api.setToken('test-token');
     });
 
-    it('throws error when not authenticated', async () => {
+    it('relies on the session cookie without a token', async () => {
       api.clearToken();
-      await expect(api.getStatus()).rejects.toThrow('Not authenticated');
+      const fetchSpy = vi.spyOn(globalThis, 'fetch').mockResolvedValueOnce(
+        new Response(JSON.stringify({ server_name: 'test' }), {
+          status: 200,
+          headers: { 'Content-Type': 'application/json' },
+        })
+      );
+
+      await api.getStatus();
+
+      const headers = fetchSpy.mock.calls[0][1]?.headers as Record<string, string>;
+      expect(headers['Authorization']).toBeUndefined();
+
+      fetchSpy.mockRestore();
     });
 
     it('makes request with correct authorization header', async () => {
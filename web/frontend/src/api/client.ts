@@ -3,13 +3,40 @@ import type {
   ProbeConfig,
   ProbeResult,
   NotificationChannel,
+  EscalationPolicy,
+  Escalation,
+  Incident,
+  MetricRule,
+  Silence,
+  CompositeMode,
+  DependencyGraph,
   SystemStatus,
   ResultStats,
   Watcher,
   WatcherDetail,
   ProbeConfigFilters,
+  ServerEvent,
+  Role,
+  CurrentUser,
+  ApiToken,
+  AuditEntry,
+  AuditTargetType,
+  User,
 } from './types';
 
+interface CompositeParams {
+  name: string;
+  enabled: boolean;
+  mode: CompositeMode;
+  quorum?: number;
+  members: number[];
+  notification_channels?: number[];
+  escalation_policy_id?: number;
+  consecutive_results?: number;
+  group_path?: string;
+  keywords?: string[];
+}
+
 class ApiClient {
   private token: string | null = null;
 
@@ -30,16 +57,18 @@ class ApiClient {
     localStorage.removeItem('auth_token');
   }
 
-  private async request<T>(path: string, options: RequestInit = {}): Promise<T> {
+  // Requests send the API token if one was entered; otherwise the session
+  // cookie from a password login authenticates them.
+  private authHeaders(): Record<string, string> {
     const token = this.getToken();
-    if (!token) {
-      throw new Error('Not authenticated');
-    }
+    return token ? { 'Authorization': `Bearer ${token}` } : {};
+  }
 
+  private async request<T>(path: string, options: RequestInit = {}): Promise<T> {
     const response = await fetch(`/api${path}`, {
       ...options,
       headers: {
-        'Authorization': `Bearer ${token}`,
+        ...this.authHeaders(),
         'Content-Type': 'application/json',
         ...options.headers,
       },
@@ -60,6 +89,85 @@ class ApiClient {
     return response.json();
   }
 
+  // Accounts
+  async login(username: string, password: string): Promise<CurrentUser> {
+    return this.request('/login', {
+      method: 'POST',
+      body: JSON.stringify({ username, password }),
+    });
+  }
+
+  async getLoginOptions(): Promise<{ oidc: boolean }> {
+    return this.request('/login');
+  }
+
+  // Single sign-on leaves the app for the identity provider, which sends
+  // the browser back with a session cookie
+  oidcLoginUrl(returnTo = '/'): string {
+    return `/api/oidc/login?return_to=${encodeURIComponent(returnTo)}`;
+  }
+
+  async logout(): Promise<void> {
+    await this.request('/logout', { method: 'POST' });
+    this.clearToken();
+  }
+
+  async getMe(): Promise<CurrentUser> {
+    return this.request('/me');
+  }
+
+  async changePassword(currentPassword: string, newPassword: string): Promise<void> {
+    return this.request('/me/password', {
+      method: 'PUT',
+      body: JSON.stringify({ current_password: currentPassword, new_password: newPassword }),
+    });
+  }
+
+  async getApiTokens(all?: boolean): Promise<ApiToken[]> {
+    return this.request(`/tokens${all ? '?all=true' : ''}`);
+  }
+
+  async createApiToken(params: {
+    name: string;
+    role?: Role;
+    expires_in?: string;
+  }): Promise<{ id: number; token: string; prefix: string }> {
+    return this.request('/tokens', {
+      method: 'POST',
+      body: JSON.stringify(params),
+    });
+  }
+
+  async deleteApiToken(id: number): Promise<void> {
+    return this.request(`/tokens/${id}`, {
+      method: 'DELETE',
+    });
+  }
+
+  async getUsers(): Promise<User[]> {
+    return this.request('/users');
+  }
+
+  async createUser(user: { username: string; password: string; role: Role }): Promise<{ id: number }> {
+    return this.request('/users', {
+      method: 'POST',
+      body: JSON.stringify(user),
+    });
+  }
+
+  async updateUser(id: number, changes: { role?: Role; password?: string }): Promise<void> {
+    return this.request(`/users/${id}`, {
+      method: 'PUT',
+      body: JSON.stringify(changes),
+    });
+  }
+
+  async deleteUser(id: number): Promise<void> {
+    return this.request(`/users/${id}`, {
+      method: 'DELETE',
+    });
+  }
+
   async getStatus(): Promise<SystemStatus> {
     return this.request('/status');
   }
@@ -121,8 +229,15 @@ class ApiClient {
     enabled: boolean;
     arguments: Record<string, unknown>;
     interval: string;
+    timezone?: string;
+    active_windows?: string[];
     timeout_seconds: number;
     notification_channels: number[];
+    escalation_policy_id?: number;
+    consecutive_results?: number;
+    flap_window?: number;
+    flap_threshold?: number;
+    anomaly_threshold?: number;
     group_path?: string;
     keywords?: string[];
   }): Promise<{ id: number }> {
@@ -138,8 +253,15 @@ class ApiClient {
     enabled: boolean;
     arguments: Record<string, unknown>;
     interval: string;
+    timezone?: string;
+    active_windows?: string[];
     timeout_seconds: number;
     notification_channels: number[];
+    escalation_policy_id?: number;
+    consecutive_results?: number;
+    flap_window?: number;
+    flap_threshold?: number;
+    anomaly_threshold?: number;
     group_path?: string;
     keywords?: string[];
   }): Promise<void> {
@@ -223,6 +345,222 @@ class ApiClient {
       method: 'POST',
     });
   }
+
+  // Escalation Policies
+  async getEscalationPolicies(): Promise<EscalationPolicy[]> {
+    return this.request('/escalation-policies');
+  }
+
+  async createEscalationPolicy(policy: Omit<EscalationPolicy, 'id'>): Promise<{ id: number }> {
+    return this.request('/escalation-policies', {
+      method: 'POST',
+      body: JSON.stringify(policy),
+    });
+  }
+
+  async updateEscalationPolicy(id: number, policy: Omit<EscalationPolicy, 'id'>): Promise<void> {
+    return this.request(`/escalation-policies/${id}`, {
+      method: 'PUT',
+      body: JSON.stringify(policy),
+    });
+  }
+
+  async deleteEscalationPolicy(id: number): Promise<void> {
+    return this.request(`/escalation-policies/${id}`, {
+      method: 'DELETE',
+    });
+  }
+
+  async getEscalations(): Promise<Escalation[]> {
+    return this.request('/escalations');
+  }
+
+  async acknowledgeProbeConfig(configId: number): Promise<void> {
+    return this.request(`/probe-configs/${configId}/acknowledge`, {
+      method: 'POST',
+    });
+  }
+
+  // Incidents
+  async getIncidents(params?: {
+    state?: 'open' | 'resolved';
+    config_id?: number;
+    since?: string;
+    limit?: number;
+    offset?: number;
+  }): Promise<Incident[]> {
+    const searchParams = new URLSearchParams();
+    if (params?.state) searchParams.set('state', params.state);
+    if (params?.config_id) searchParams.set('config_id', String(params.config_id));
+    if (params?.since) searchParams.set('since', params.since);
+    if (params?.limit) searchParams.set('limit', String(params.limit));
+    if (params?.offset) searchParams.set('offset', String(params.offset));
+    const query = searchParams.toString();
+    return this.request(`/incidents${query ? `?${query}` : ''}`);
+  }
+
+  async acknowledgeIncident(id: number, note?: string): Promise<void> {
+    return this.request(`/incidents/${id}/acknowledge`, {
+      method: 'POST',
+      body: JSON.stringify({ note }),
+    });
+  }
+
+  async addIncidentNote(id: number, note: string): Promise<{ id: number }> {
+    return this.request(`/incidents/${id}/notes`, {
+      method: 'POST',
+      body: JSON.stringify({ note }),
+    });
+  }
+
+  // Audit log
+  async getAuditLog(params?: {
+    target_type?: AuditTargetType;
+    target_id?: number;
+    actor?: string;
+    action?: string;
+    since?: string;
+    until?: string;
+    limit?: number;
+    offset?: number;
+  }): Promise<AuditEntry[]> {
+    const searchParams = new URLSearchParams();
+    if (params?.target_type) searchParams.set('target_type', params.target_type);
+    if (params?.target_id) searchParams.set('target_id', String(params.target_id));
+    if (params?.actor) searchParams.set('actor', params.actor);
+    if (params?.action) searchParams.set('action', params.action);
+    if (params?.since) searchParams.set('since', params.since);
+    if (params?.until) searchParams.set('until', params.until);
+    if (params?.limit) searchParams.set('limit', String(params.limit));
+    if (params?.offset) searchParams.set('offset', String(params.offset));
+    const query = searchParams.toString();
+    return this.request(`/audit${query ? `?${query}` : ''}`);
+  }
+
+  // Metric Rules
+  async getMetricRules(configId?: number): Promise<MetricRule[]> {
+    return this.request(`/metric-rules${configId ? `?config_id=${configId}` : ''}`);
+  }
+
+  async createMetricRule(rule: Omit<MetricRule, 'id'>): Promise<{ id: number }> {
+    return this.request('/metric-rules', {
+      method: 'POST',
+      body: JSON.stringify(rule),
+    });
+  }
+
+  async updateMetricRule(id: number, rule: Omit<MetricRule, 'id'>): Promise<void> {
+    return this.request(`/metric-rules/${id}`, {
+      method: 'PUT',
+      body: JSON.stringify(rule),
+    });
+  }
+
+  async deleteMetricRule(id: number): Promise<void> {
+    return this.request(`/metric-rules/${id}`, {
+      method: 'DELETE',
+    });
+  }
+
+  // Silences
+  async getSilences(state?: 'active' | 'pending' | 'expired'): Promise<Silence[]> {
+    return this.request(`/silences${state ? `?state=${state}` : ''}`);
+  }
+
+  async createSilence(silence: {
+    starts_at?: string;
+    ends_at?: string;
+    duration?: string;
+    comment?: string;
+    created_by?: string;
+    probe_config_id?: number;
+    group_path?: string;
+    keyword?: string;
+    watcher_id?: number;
+    probe_type?: string;
+  }): Promise<{ id: number }> {
+    return this.request('/silences', {
+      method: 'POST',
+      body: JSON.stringify(silence),
+    });
+  }
+
+  async deleteSilence(id: number): Promise<void> {
+    return this.request(`/silences/${id}`, {
+      method: 'DELETE',
+    });
+  }
+
+  // Dependencies and composites
+  async setDependencies(configId: number, dependsOn: number[]): Promise<{ depends_on: number[] }> {
+    return this.request(`/probe-configs/${configId}/dependencies`, {
+      method: 'PUT',
+      body: JSON.stringify({ depends_on: dependsOn }),
+    });
+  }
+
+  async getDependencyGraph(configId?: number): Promise<DependencyGraph> {
+    return this.request(`/dependency-graph${configId ? `?config_id=${configId}` : ''}`);
+  }
+
+  async createComposite(composite: CompositeParams): Promise<{ id: number }> {
+    return this.request('/composites', {
+      method: 'POST',
+      body: JSON.stringify(composite),
+    });
+  }
+
+  async updateComposite(id: number, composite: CompositeParams): Promise<void> {
+    return this.request(`/composites/${id}`, {
+      method: 'PUT',
+      body: JSON.stringify(composite),
+    });
+  }
+
+  // Live updates. EventSource can't send the Authorization header, so the
+  // stream is read with fetch. Resolves when the server ends the stream.
+  async streamEvents(
+    lastEventId: string | undefined,
+    onEvent: (event: ServerEvent) => void,
+    signal: AbortSignal,
+  ): Promise<void> {
+    const headers = this.authHeaders();
+    if (lastEventId) {
+      headers['Last-Event-ID'] = lastEventId;
+    }
+    const response = await fetch('/api/events', { headers, signal });
+    if (!response.ok || !response.body) {
+      throw new Error(`API error: ${response.status}`);
+    }
+
+    const reader = response.body.pipeThrough(new TextDecoderStream()).getReader();
+    let buffer = '';
+    for (;;) {
+      const { value, done } = await reader.read();
+      if (done) {
+        return;
+      }
+      buffer += value;
+      let end;
+      while ((end = buffer.indexOf('\n\n')) >= 0) {
+        const block = buffer.slice(0, end);
+        buffer = buffer.slice(end + 2);
+        const event: Partial<ServerEvent> = {};
+        for (const line of block.split('\n')) {
+          const colon = line.indexOf(':');
+          if (colon <= 0) continue;
+          const field = line.slice(0, colon);
+          const fieldValue = line.slice(colon + 1).trimStart();
+          if (field === 'id') event.id = fieldValue;
+          else if (field === 'event') event.type = fieldValue as ServerEvent['type'];
+          else if (field === 'data') event.data = JSON.parse(fieldValue);
+        }
+        if (event.type) {
+          onEvent(event as ServerEvent);
+        }
+      }
+    }
+  }
 }
 
 export const api = new ApiClient();
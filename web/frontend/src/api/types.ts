@@ -11,6 +11,9 @@ export interface Watcher {
   registered_at: string;
   probe_type_count: number;
   config_count: number;
+  notification_channels: number[] | null;
+  down_since?: string;
+  queue_depth: number;
 }
 
 export interface WatcherDetail extends Watcher {
@@ -47,9 +50,22 @@ export interface ProbeConfig {
   name: string;
   enabled: boolean;
   arguments: Record<string, unknown>;
-  interval: string;
+  interval: string; // Interval ("5m") or cron expression ("0 6 * * 1-5")
+  timezone?: string;
+  active_windows?: string[] | null;
   timeout_seconds: number;
   notification_channels: number[];
+  escalation_policy_id?: number; // Replaces notification_channels when set
+  consecutive_results: number; // Results that must agree before a status change is notified
+  flap_window: number; // Results considered for flap detection; 0 disables it
+  flap_threshold: number; // Status changes within the window that mean flapping
+  anomaly_threshold: number; // Standard deviations from the baseline that flag a value; 0 disables it
+  composite_mode?: CompositeMode; // Set for composites, whose status is computed from members
+  composite_quorum?: number;
+  members?: number[]; // Composites only; returned by getProbeConfig
+  depends_on?: number[]; // Returned by getProbeConfig
+  suppressed_by?: number; // Failing dependency of the latest result
+  flapping_since?: string;
   next_run_at?: string;
   group_path?: string;
   keywords?: string[];
@@ -61,7 +77,7 @@ export interface ProbeConfig {
 }
 
 export interface ProbeResult {
-  id: number;
+  id: number; // Negative for rollups
   probe_config_id: number;
   config_name?: string;
   watcher_id?: number;
@@ -74,6 +90,27 @@ export interface ProbeResult {
   scheduled_at: string;
   executed_at: string;
   recorded_at: string;
+  rollup?: ResultRollup;
+  anomalies?: ResultAnomaly[];
+  suppressed_by?: number; // Failing config this result depends on
+}
+
+// Metric value or duration that deviated from the config's rolling baseline
+export interface ResultAnomaly {
+  metric: string;
+  value: number;
+  baseline: number;
+  stddev: number;
+  score: number; // Deviation in standard deviations
+}
+
+// Hourly aggregate returned in place of raw results older than the retention period
+export interface ResultRollup {
+  bucket_seconds: number;
+  count: number;
+  status_counts: Record<ProbeStatus, number>;
+  duration_ms: { min: number; max: number; avg: number };
+  metrics: Record<string, { min: number; max: number; avg: number; count: number }>;
 }
 
 export interface NotificationChannel {
@@ -84,6 +121,82 @@ export interface NotificationChannel {
   enabled: boolean;
 }
 
+export interface EscalationStep {
+  delay_minutes: number;
+  channel_ids: number[];
+}
+
+export interface EscalationPolicy {
+  id: number;
+  name: string;
+  steps: EscalationStep[];
+  repeat_minutes: number; // 0 disables repeats
+}
+
+export interface Escalation {
+  probe_config_id: number;
+  probe_config_name: string;
+  policy_id: number;
+  policy_name: string;
+  started_at: string;
+  steps_notified: number;
+  last_notified_at?: string;
+  acknowledged_at?: string;
+}
+
+export interface IncidentNote {
+  id: number;
+  author?: string;
+  note: string;
+  created_at: string;
+}
+
+export interface Incident {
+  id: number;
+  probe_config_id: number;
+  probe_config_name: string;
+  status: ProbeStatus; // critical once critical, otherwise the latest status
+  message?: string;
+  opened_at: string;
+  acknowledged_at?: string;
+  acknowledged_by?: string;
+  resolved_at?: string;
+  duration_seconds: number;
+  notes: IncidentNote[];
+}
+
+export interface MetricRule {
+  id: number;
+  probe_config_id?: number;
+  probe_type?: string; // Applies to all configs of the type
+  metric: string;
+  operator: '<' | '<=' | '>' | '>=' | '==' | '!=';
+  threshold: number;
+  for?: string; // How long the condition must hold, e.g. "10m"
+  status: 'warning' | 'critical';
+  mode: 'derive' | 'override'; // Raise or replace the probe's own status
+}
+
+export interface Silence {
+  id: number;
+  starts_at: string;
+  ends_at: string;
+  state: 'active' | 'pending' | 'expired';
+  remaining_seconds: number;
+  starts_in_seconds?: number;
+  comment?: string;
+  created_by?: string;
+  created_at?: string;
+  // Matchers: all that are set must match
+  probe_config_id?: number;
+  probe_config_name?: string;
+  group_path?: string; // Includes subgroups
+  keyword?: string;
+  watcher_id?: number;
+  watcher_name?: string;
+  probe_type?: string;
+}
+
 export interface WatcherStatus {
   name: string;
   healthy: boolean;
@@ -114,3 +227,98 @@ export interface ProbeConfigFilters {
   group?: string;
   keywords?: string;
 }
+
+export type CompositeMode = 'all' | 'any' | 'quorum';
+
+export interface DependencyGraph {
+  nodes: DependencyNode[];
+  edges: DependencyEdge[];
+}
+
+export interface DependencyNode {
+  id: number;
+  name: string;
+  status?: ProbeStatus;
+  composite_mode?: CompositeMode;
+  suppressed_by?: number;
+}
+
+// Points from a config to one it depends on, or from a composite to a member
+export interface DependencyEdge {
+  from: number;
+  to: number;
+  kind: 'depends_on' | 'member';
+}
+
+export type ServerEventType =
+  | 'result'
+  | 'status_change'
+  | 'heartbeat'
+  | 'watcher_online'
+  | 'watcher_offline'
+  | 'config_change'
+  | 'reset';
+
+// A message from the /api/events stream
+export interface ServerEvent {
+  id?: string;
+  type: ServerEventType;
+  data: Record<string, unknown>;
+}
+
+export type Role = 'viewer' | 'operator' | 'admin';
+
+// The caller as returned by /api/me. The shared AUTH_TOKEN has no user id.
+export interface CurrentUser {
+  id?: number;
+  username: string;
+  role: Role;
+  auth: 'session' | 'token' | 'shared-token';
+  sso?: boolean; // Signed in through the identity provider, so no password
+}
+
+export interface User {
+  id: number;
+  username: string;
+  role: Role;
+  sso: boolean;
+  created_at?: string;
+  last_login_at?: string;
+}
+
+// A personal API token. The token itself is only shown when it is created;
+// the prefix identifies it afterwards.
+export interface ApiToken {
+  id: number;
+  name: string;
+  prefix: string;
+  username: string;
+  role?: Role;
+  created_at?: string;
+  expires_at?: string;
+  last_used_at?: string;
+}
+
+export type AuditTargetType =
+  | 'probe_config'
+  | 'watcher'
+  | 'notification_channel'
+  | 'escalation_policy'
+  | 'metric_rule'
+  | 'silence'
+  | 'user';
+
+// A recorded change. before and after hold only the fields that changed;
+// creates have no before and deletes no after.
+export interface AuditEntry {
+  id: number;
+  created_at: string;
+  actor: string;
+  actor_role?: Role;
+  action: string;
+  target_type: AuditTargetType;
+  target_id?: number;
+  target_name?: string;
+  before?: Record<string, unknown>;
+  after?: Record<string, unknown>;
+}
@@ -0,0 +1,131 @@
+import { useState } from 'react';
+import { useQuery, useMutation, useQueryClient } from '@tanstack/react-query';
+import { api } from '../api/client';
+import type { ProbeConfig, DependencyNode } from '../api/types';
+import { StatusBadge } from './StatusBadge';
+
+interface DependenciesSectionProps {
+  config: ProbeConfig;
+}
+
+export function DependenciesSection({ config }: DependenciesSectionProps) {
+  const queryClient = useQueryClient();
+  const [adding, setAdding] = useState('');
+  const [error, setError] = useState<string | null>(null);
+
+  const { data: graph } = useQuery({
+    queryKey: ['dependencyGraph', config.id],
+    queryFn: () => api.getDependencyGraph(config.id),
+  });
+
+  const { data: configs } = useQuery({
+    queryKey: ['probeConfigs'],
+    queryFn: () => api.getProbeConfigs(),
+  });
+
+  const nodes = new Map<number, DependencyNode>(graph?.nodes.map((n) => [n.id, n]) ?? []);
+  const edges = graph?.edges ?? [];
+  const dependsOn = edges.filter((e) => e.kind === 'depends_on' && e.from === config.id).map((e) => e.to);
+  const dependents = edges.filter((e) => e.kind === 'depends_on' && e.to === config.id).map((e) => e.from);
+  const members = edges.filter((e) => e.kind === 'member' && e.from === config.id).map((e) => e.to);
+  const composites = edges.filter((e) => e.kind === 'member' && e.to === config.id).map((e) => e.from);
+  const isComposite = !!config.composite_mode;
+  const linked = isComposite ? members : dependsOn;
+
+  const invalidate = () => {
+    setAdding('');
+    setError(null);
+    queryClient.invalidateQueries({ queryKey: ['dependencyGraph'] });
+    queryClient.invalidateQueries({ queryKey: ['probeConfigs'] });
+  };
+
+  const setLinked = useMutation({
+    mutationFn: (ids: number[]) => isComposite
+      ? api.updateComposite(config.id, {
+          name: config.name,
+          enabled: config.enabled,
+          mode: config.composite_mode!,
+          quorum: config.composite_quorum,
+          members: ids,
+          notification_channels: config.notification_channels,
+          escalation_policy_id: config.escalation_policy_id,
+          consecutive_results: config.consecutive_results,
+          group_path: config.group_path,
+          keywords: config.keywords,
+        })
+      : api.setDependencies(config.id, ids),
+    onSuccess: invalidate,
+    onError: (err: Error) => setError(err.message),
+  });
+
+  const renderNode = (id: number, onRemove?: () => void) => {
+    const node = nodes.get(id);
+    return (
+      <div key={id} className="py-1 flex items-center justify-between text-sm">
+        <div className="flex items-center gap-2">
+          {node?.status && <StatusBadge status={node.status} size="sm" />}
+          <span>{node?.name ?? `#${id}`}</span>
+          {node?.composite_mode && <span className="text-xs text-gray-400">composite ({node.composite_mode})</span>}
+        </div>
+        {onRemove && (
+          <button onClick={onRemove} className="text-red-600 hover:text-red-800">
+            Remove
+          </button>
+        )}
+      </div>
+    );
+  };
+
+  const candidates = configs?.filter((c) => c.id !== config.id && !linked.includes(c.id)) ?? [];
+
+  return (
+    <div className="bg-white rounded-lg shadow p-6 mb-6 border border-gray-200">
+      <h2 className="text-lg font-semibold mb-4">Dependencies</h2>
+
+      <h3 className="text-sm font-medium text-gray-700">
+        {isComposite
+          ? `Members (${config.composite_mode}${config.composite_mode === 'quorum' ? ` of ${config.composite_quorum}` : ''})`
+          : 'Depends on'}
+      </h3>
+      {linked.length === 0 ? (
+        <p className="text-sm text-gray-500 mb-2">
+          {isComposite ? 'No members.' : 'None. Failures are always notified.'}
+        </p>
+      ) : (
+        <div className="divide-y mb-2">
+          {linked.map((id) => renderNode(id, () => setLinked.mutate(linked.filter((l) => l !== id))))}
+        </div>
+      )}
+      <form
+        onSubmit={(e) => { e.preventDefault(); if (adding) setLinked.mutate([...linked, Number(adding)]); }}
+        className="flex items-center gap-2 text-sm mb-4"
+      >
+        <select value={adding} onChange={(e) => setAdding(e.target.value)} className="border rounded px-2 py-1">
+          <option value="">Select a probe...</option>
+          {candidates.map((c) => <option key={c.id} value={c.id}>{c.name}</option>)}
+        </select>
+        <button
+          type="submit"
+          disabled={!adding || setLinked.isPending}
+          className="px-3 py-1 bg-green-600 text-white rounded hover:bg-green-700 disabled:opacity-50"
+        >
+          Add
+        </button>
+        {error && <span className="text-red-600">{error}</span>}
+      </form>
+
+      {dependents.length > 0 && (
+        <>
+          <h3 className="text-sm font-medium text-gray-700">Depended on by</h3>
+          <div className="divide-y mb-4">{dependents.map((id) => renderNode(id))}</div>
+        </>
+      )}
+      {composites.length > 0 && (
+        <>
+          <h3 className="text-sm font-medium text-gray-700">Member of</h3>
+          <div className="divide-y">{composites.map((id) => renderNode(id))}</div>
+        </>
+      )}
+    </div>
+  );
+}
@@ -0,0 +1,62 @@
+import { useQuery } from '@tanstack/react-query';
+import { api } from '../api/client';
+import type { AuditEntry, ProbeConfig } from '../api/types';
+
+interface HistorySectionProps {
+  config: ProbeConfig;
+}
+
+const formatValue = (value: unknown) => {
+  if (value === undefined || value === null || value === '') return '—';
+  return typeof value === 'string' ? value : JSON.stringify(value);
+};
+
+const describeChanges = (entry: AuditEntry) => {
+  // Creates record the whole config, which the page already shows
+  if (entry.action !== 'update') return [];
+  const fields = Object.keys(entry.after ?? {}).sort();
+  return fields.map((field) => ({
+    field,
+    before: formatValue(entry.before?.[field]),
+    after: formatValue(entry.after?.[field]),
+  }));
+};
+
+export function HistorySection({ config }: HistorySectionProps) {
+  // Config ids are reused after a delete, so skip entries of earlier configs
+  const { data: entries } = useQuery({
+    queryKey: ['audit', config.id],
+    queryFn: () => api.getAuditLog({
+      target_type: 'probe_config',
+      target_id: config.id,
+      since: config.created_at,
+      limit: 50,
+    }),
+  });
+
+  if (!entries?.length) {
+    return null;
+  }
+
+  return (
+    <div className="bg-white rounded-lg shadow border border-gray-200 mb-6">
+      <h2 className="text-lg font-semibold p-4 border-b">History</h2>
+      <div className="divide-y">
+        {entries.map((entry) => (
+          <div key={entry.id} className="p-4 text-sm">
+            <div className="flex items-center gap-2">
+              <span className="font-medium text-gray-900">{entry.action}</span>
+              <span className="text-gray-600">by {entry.actor}</span>
+              <span className="ml-auto text-gray-500">{new Date(entry.created_at).toLocaleString()}</span>
+            </div>
+            {describeChanges(entry).map((change) => (
+              <div key={change.field} className="mt-1 font-mono text-xs text-gray-600 break-all">
+                {change.field}: <span className="text-red-700">{change.before}</span> → <span className="text-green-700">{change.after}</span>
+              </div>
+            ))}
+          </div>
+        ))}
+      </div>
+    </div>
+  );
+}
@@ -0,0 +1,68 @@
+import { useEffect } from 'react';
+import { useQueryClient } from '@tanstack/react-query';
+import { api } from '../api/client';
+import type { ServerEvent } from '../api/types';
+
+// Delay before reconnecting after the stream ends or fails
+const RECONNECT_DELAY = 5000;
+
+// LiveUpdates subscribes to the server's event stream and refreshes the
+// affected queries, so pages update as soon as results come in.
+export function LiveUpdates() {
+  const queryClient = useQueryClient();
+
+  useEffect(() => {
+    const controller = new AbortController();
+    let lastEventId: string | undefined;
+
+    const handleEvent = (event: ServerEvent) => {
+      if (event.id) {
+        lastEventId = event.id;
+      }
+      switch (event.type) {
+        case 'result':
+          queryClient.invalidateQueries({ queryKey: ['probeConfigs'] });
+          queryClient.invalidateQueries({ queryKey: ['probeResults', event.data.probe_config_id] });
+          queryClient.invalidateQueries({ queryKey: ['stats'] });
+          queryClient.invalidateQueries({ queryKey: ['recentFailures'] });
+          queryClient.invalidateQueries({ queryKey: ['failures'] });
+          queryClient.invalidateQueries({ queryKey: ['dependencyGraph'] });
+          break;
+        case 'status_change':
+          queryClient.invalidateQueries({ queryKey: ['incidents'] });
+          break;
+        case 'heartbeat':
+        case 'watcher_online':
+        case 'watcher_offline':
+          queryClient.invalidateQueries({ queryKey: ['watchers'] });
+          queryClient.invalidateQueries({ queryKey: ['status'] });
+          break;
+        case 'config_change':
+          queryClient.invalidateQueries({ queryKey: ['probeConfigs'] });
+          queryClient.invalidateQueries({ queryKey: ['dependencyGraph'] });
+          queryClient.invalidateQueries({ queryKey: ['audit'] });
+          break;
+        case 'reset':
+          // Events were lost while disconnected
+          queryClient.invalidateQueries();
+          break;
+      }
+    };
+
+    const run = async () => {
+      while (!controller.signal.aborted) {
+        try {
+          await api.streamEvents(lastEventId, handleEvent, controller.signal);
+        } catch {
+          // Retried below; the regular polling covers the gap
+        }
+        await new Promise((resolve) => setTimeout(resolve, RECONNECT_DELAY));
+      }
+    };
+    run();
+
+    return () => controller.abort();
+  }, [queryClient]);
+
+  return null;
+}
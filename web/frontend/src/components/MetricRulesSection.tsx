@@ -0,0 +1,146 @@
+import { useState } from 'react';
+import { useQuery, useMutation, useQueryClient } from '@tanstack/react-query';
+import { api } from '../api/client';
+import type { MetricRule } from '../api/types';
+
+interface MetricRulesSectionProps {
+  configId: number;
+  metricKeys: string[];
+}
+
+const operators: MetricRule['operator'][] = ['<', '<=', '>', '>=', '==', '!='];
+
+export function MetricRulesSection({ configId, metricKeys }: MetricRulesSectionProps) {
+  const queryClient = useQueryClient();
+  const [metric, setMetric] = useState('');
+  const [operator, setOperator] = useState<MetricRule['operator']>('<');
+  const [threshold, setThreshold] = useState('');
+  const [forDuration, setForDuration] = useState('');
+  const [status, setStatus] = useState<MetricRule['status']>('warning');
+  const [mode, setMode] = useState<MetricRule['mode']>('derive');
+  const [error, setError] = useState<string | null>(null);
+
+  const { data: rules } = useQuery({
+    queryKey: ['metricRules', configId],
+    queryFn: () => api.getMetricRules(configId),
+  });
+
+  const createMutation = useMutation({
+    mutationFn: () => api.createMetricRule({
+      probe_config_id: configId,
+      metric,
+      operator,
+      threshold: Number(threshold),
+      for: forDuration || undefined,
+      status,
+      mode,
+    }),
+    onSuccess: () => {
+      setMetric('');
+      setThreshold('');
+      setForDuration('');
+      setError(null);
+      queryClient.invalidateQueries({ queryKey: ['metricRules', configId] });
+    },
+    onError: (err: Error) => setError(err.message),
+  });
+
+  const deleteMutation = useMutation({
+    mutationFn: (id: number) => api.deleteMetricRule(id),
+    onSuccess: () => {
+      queryClient.invalidateQueries({ queryKey: ['metricRules', configId] });
+    },
+  });
+
+  return (
+    <div className="bg-white rounded-lg shadow p-6 mb-6 border border-gray-200">
+      <h2 className="text-lg font-semibold mb-4">Alert Rules</h2>
+
+      {rules?.length === 0 ? (
+        <p className="text-gray-500 mb-4">No rules. The probe's own status is used as is.</p>
+      ) : (
+        <div className="divide-y mb-4">
+          {rules?.map((rule) => (
+            <div key={rule.id} className="py-2 flex items-center justify-between text-sm">
+              <div>
+                <span className="font-mono">
+                  {rule.metric} {rule.operator} {rule.threshold}
+                </span>
+                {rule.for && <span className="text-gray-500"> for {rule.for}</span>}
+                <span className="text-gray-500"> &rarr; {rule.status}</span>
+                {rule.mode === 'override' && (
+                  <span className="ml-2 text-xs px-2 py-0.5 rounded bg-gray-200 text-gray-600">override</span>
+                )}
+                {rule.probe_type && (
+                  <span className="ml-2 text-xs text-gray-400">all {rule.probe_type} probes</span>
+                )}
+              </div>
+              <button
+                onClick={() => deleteMutation.mutate(rule.id)}
+                className="text-red-600 hover:text-red-800"
+              >
+                Delete
+              </button>
+            </div>
+          ))}
+        </div>
+      )}
+
+      <form
+        onSubmit={(e) => { e.preventDefault(); createMutation.mutate(); }}
+        className="flex flex-wrap items-center gap-2 text-sm"
+      >
+        <input
+          list={`metrics-${configId}`}
+          value={metric}
+          onChange={(e) => setMetric(e.target.value)}
+          placeholder="Metric"
+          className="border rounded px-2 py-1"
+          required
+        />
+        <datalist id={`metrics-${configId}`}>
+          {metricKeys.map((key) => <option key={key} value={key} />)}
+        </datalist>
+        <select value={operator} onChange={(e) => setOperator(e.target.value as MetricRule['operator'])} className="border rounded px-2 py-1">
+          {operators.map((op) => <option key={op} value={op}>{op}</option>)}
+        </select>
+        <input
+          type="number"
+          step="any"
+          value={threshold}
+          onChange={(e) => setThreshold(e.target.value)}
+          placeholder="Threshold"
+          className="border rounded px-2 py-1 w-28"
+          required
+        />
+        <input
+          value={forDuration}
+          onChange={(e) => setForDuration(e.target.value)}
+          placeholder="For, e.g. 10m"
+          className="border rounded px-2 py-1 w-28"
+        />
+        <select value={status} onChange={(e) => setStatus(e.target.value as MetricRule['status'])} className="border rounded px-2 py-1">
+          <option value="warning">warning</option>
+          <option value="critical">critical</option>
+        </select>
+        <select
+          value={mode}
+          onChange={(e) => setMode(e.target.value as MetricRule['mode'])}
+          className="border rounded px-2 py-1"
+          title="Derive raises the probe's own status; override replaces it"
+        >
+          <option value="derive">derive</option>
+          <option value="override">override</option>
+        </select>
+        <button
+          type="submit"
+          disabled={createMutation.isPending}
+          className="px-3 py-1 bg-green-600 text-white rounded hover:bg-green-700 disabled:opacity-50"
+        >
+          Add Rule
+        </button>
+        {error && <p className="w-full text-red-600">{error}</p>}
+      </form>
+    </div>
+  );
+}
@@ -55,6 +55,12 @@ export function ProbeCard({ config, onStatusClick, onEdit, onRerun, onPauseToggl
             {isPaused && (
               <span className="text-xs px-1.5 py-0.5 bg-gray-200 text-gray-600 rounded">paused</span>
             )}
+            {config.flapping_since && (
+              <span className="text-xs px-1.5 py-0.5 bg-orange-100 text-orange-700 rounded">flapping</span>
+            )}
+            {config.suppressed_by && (
+              <span className="text-xs px-1.5 py-0.5 bg-gray-200 text-gray-600 rounded">suppressed</span>
+            )}
           </div>
           <p className="text-sm text-gray-500">{config.probe_type_name}</p>
         </div>
@@ -18,7 +18,13 @@ export function ProbeConfigForm({ probeTypes, watchers, editingConfig, initialPr
   const [watcherId, setWatcherId] = useState<number | undefined>(editingConfig?.watcher_id ?? watchers[0]?.id);
   const [enabled, setEnabled] = useState(editingConfig?.enabled ?? true);
   const [interval, setInterval] = useState(editingConfig?.interval ?? '5m');
+  const [timezone, setTimezone] = useState(editingConfig?.timezone ?? '');
+  const [activeWindows, setActiveWindows] = useState(editingConfig?.active_windows?.join(', ') ?? '');
   const [timeout, setTimeout] = useState(editingConfig?.timeout_seconds ?? 60);
+  const [consecutiveResults, setConsecutiveResults] = useState(editingConfig?.consecutive_results ?? 1);
+  const [flapWindow, setFlapWindow] = useState(editingConfig?.flap_window ?? 0);
+  const [flapThreshold, setFlapThreshold] = useState(editingConfig?.flap_threshold ?? 0);
+  const [anomalyThreshold, setAnomalyThreshold] = useState(editingConfig?.anomaly_threshold ?? 0);
   const [groupPath, setGroupPath] = useState(editingConfig?.group_path ?? '');
   const [keywords, setKeywords] = useState(editingConfig?.keywords?.join(', ') ?? '');
   const [args, setArgs] = useState<Record<string, string>>(
@@ -80,6 +86,11 @@ export function ProbeConfigForm({ probeTypes, watchers, editingConfig, initialPr
       .map((k) => k.trim())
       .filter((k) => k.length > 0);
 
+    const windowsList = activeWindows
+      .split(',')
+      .map((w) => w.trim())
+      .filter((w) => w.length > 0);
+
     try {
       if (editingConfig) {
         await api.updateProbeConfig(editingConfig.id, {
@@ -88,8 +99,15 @@ export function ProbeConfigForm({ probeTypes, watchers, editingConfig, initialPr
           enabled,
           arguments: typedArgs,
           interval,
+          timezone: timezone || undefined,
+          active_windows: windowsList.length > 0 ? windowsList : undefined,
           timeout_seconds: timeout,
+          consecutive_results: consecutiveResults,
+          flap_window: flapWindow,
+          flap_threshold: flapThreshold,
+          anomaly_threshold: anomalyThreshold,
           notification_channels: editingConfig.notification_channels,
+          escalation_policy_id: editingConfig.escalation_policy_id,
           group_path: groupPath || undefined,
           keywords: keywordsList.length > 0 ? keywordsList : undefined,
         });
@@ -106,7 +124,13 @@ export function ProbeConfigForm({ probeTypes, watchers, editingConfig, initialPr
           enabled,
           arguments: typedArgs,
           interval,
+          timezone: timezone || undefined,
+          active_windows: windowsList.length > 0 ? windowsList : undefined,
           timeout_seconds: timeout,
+          consecutive_results: consecutiveResults,
+          flap_window: flapWindow,
+          flap_threshold: flapThreshold,
+          anomaly_threshold: anomalyThreshold,
           notification_channels: [],
           group_path: groupPath || undefined,
           keywords: keywordsList.length > 0 ? keywordsList : undefined,
@@ -189,12 +213,17 @@ export function ProbeConfigForm({ probeTypes, watchers, editingConfig, initialPr
               </div>
 
               <div>
-                <label className="block text-sm font-medium text-gray-700 mb-1">Interval</label>
-                <select
+                <label className="block text-sm font-medium text-gray-700 mb-1">Interval or cron</label>
+                <input
+                  type="text"
+                  list="interval-presets"
                   value={interval}
                   onChange={(e) => setInterval(e.target.value)}
                   className="w-full px-3 py-2 border rounded focus:ring-2 focus:ring-blue-500"
-                >
+                  placeholder="e.g., 5m or 0 6 * * 1-5"
+                  required
+                />
+                <datalist id="interval-presets">
                   <option value="1m">1 minute</option>
                   <option value="5m">5 minutes</option>
                   <option value="15m">15 minutes</option>
@@ -202,7 +231,29 @@ export function ProbeConfigForm({ probeTypes, watchers, editingConfig, initialPr
                   <option value="1h">1 hour</option>
                   <option value="6h">6 hours</option>
                   <option value="1d">1 day</option>
-                </select>
+                </datalist>
+              </div>
+
+              <div>
+                <label className="block text-sm font-medium text-gray-700 mb-1">Timezone</label>
+                <input
+                  type="text"
+                  value={timezone}
+                  onChange={(e) => setTimezone(e.target.value)}
+                  className="w-full px-3 py-2 border rounded focus:ring-2 focus:ring-blue-500"
+                  placeholder="UTC"
+                />
+              </div>
+
+              <div>
+                <label className="block text-sm font-medium text-gray-700 mb-1">Active windows</label>
+                <input
+                  type="text"
+                  value={activeWindows}
+                  onChange={(e) => setActiveWindows(e.target.value)}
+                  className="w-full px-3 py-2 border rounded focus:ring-2 focus:ring-blue-500"
+                  placeholder="e.g., 08:00-22:00"
+                />
               </div>
 
               <div>
@@ -217,6 +268,55 @@ export function ProbeConfigForm({ probeTypes, watchers, editingConfig, initialPr
                 />
               </div>
 
+              <div>
+                <label className="block text-sm font-medium text-gray-700 mb-1">Consecutive results</label>
+                <input
+                  type="number"
+                  value={consecutiveResults}
+                  onChange={(e) => setConsecutiveResults(Number(e.target.value))}
+                  min={1}
+                  className="w-full px-3 py-2 border rounded focus:ring-2 focus:ring-blue-500"
+                  title="Results that must agree before a status change is notified"
+                />
+              </div>
+
+              <div>
+                <label className="block text-sm font-medium text-gray-700 mb-1">Flap window</label>
+                <input
+                  type="number"
+                  value={flapWindow}
+                  onChange={(e) => setFlapWindow(Number(e.target.value))}
+                  min={0}
+                  className="w-full px-3 py-2 border rounded focus:ring-2 focus:ring-blue-500"
+                  title="Results considered for flap detection; 0 disables it"
+                />
+              </div>
+
+              <div>
+                <label className="block text-sm font-medium text-gray-700 mb-1">Flap threshold</label>
+                <input
+                  type="number"
+                  value={flapThreshold}
+                  onChange={(e) => setFlapThreshold(Number(e.target.value))}
+                  min={0}
+                  className="w-full px-3 py-2 border rounded focus:ring-2 focus:ring-blue-500"
+                  title="Status changes within the window that mean the probe is flapping"
+                />
+              </div>
+
+              <div>
+                <label className="block text-sm font-medium text-gray-700 mb-1">Anomaly threshold (σ)</label>
+                <input
+                  type="number"
+                  value={anomalyThreshold}
+                  onChange={(e) => setAnomalyThreshold(Number(e.target.value))}
+                  min={0}
+                  step="any"
+                  className="w-full px-3 py-2 border rounded focus:ring-2 focus:ring-blue-500"
+                  title="Standard deviations from the probe's own history that flag a metric or duration; 0 disables it"
+                />
+              </div>
+
               <div className="col-span-2">
                 <label className="block text-sm font-medium text-gray-700 mb-1">Keywords</label>
                 <input
@@ -65,6 +65,12 @@ export function ProbeRow({ config, isRunning, onClick, onEdit, onRerun, onPauseT
               {isPaused && (
                 <span className="text-xs px-1.5 py-0.5 bg-gray-200 text-gray-600 rounded flex-shrink-0">paused</span>
               )}
+              {config.flapping_since && (
+                <span className="text-xs px-1.5 py-0.5 bg-orange-100 text-orange-700 rounded flex-shrink-0">flapping</span>
+              )}
+              {config.suppressed_by && (
+                <span className="text-xs px-1.5 py-0.5 bg-gray-200 text-gray-600 rounded flex-shrink-0">suppressed</span>
+              )}
             </div>
             <div className="text-sm text-gray-500 flex-shrink-0">
               <span>{formatRelativeTime(config.last_executed_at)}</span>
@@ -0,0 +1,170 @@
+import { useState } from 'react';
+import { useQuery, useMutation, useQueryClient } from '@tanstack/react-query';
+import { api } from '../api/client';
+import type { ProbeConfig, ProbeType, Silence, Watcher } from '../api/types';
+
+interface SilencesSectionProps {
+  configs: ProbeConfig[];
+  watchers: Watcher[];
+  probeTypes: ProbeType[];
+}
+
+const formatDuration = (seconds: number) => {
+  if (seconds < 60) return `${seconds}s`;
+  const minutes = Math.floor(seconds / 60);
+  if (minutes < 60) return `${minutes}m`;
+  const hours = Math.floor(minutes / 60);
+  if (hours < 24) return `${hours}h ${minutes % 60}m`;
+  return `${Math.floor(hours / 24)}d ${hours % 24}h`;
+};
+
+const describeMatchers = (s: Silence) => {
+  const parts: string[] = [];
+  if (s.probe_config_id) parts.push(`probe ${s.probe_config_name ?? s.probe_config_id}`);
+  if (s.group_path) parts.push(`group ${s.group_path}`);
+  if (s.keyword) parts.push(`keyword ${s.keyword}`);
+  if (s.watcher_id) parts.push(`watcher ${s.watcher_name ?? s.watcher_id}`);
+  if (s.probe_type) parts.push(`type ${s.probe_type}`);
+  return parts.join(', ');
+};
+
+const emptyForm = {
+  probe_config_id: '',
+  group_path: '',
+  keyword: '',
+  watcher_id: '',
+  probe_type: '',
+  starts_at: '',
+  duration: '1h',
+  comment: '',
+};
+
+export function SilencesSection({ configs, watchers, probeTypes }: SilencesSectionProps) {
+  const queryClient = useQueryClient();
+  const [showForm, setShowForm] = useState(false);
+  const [form, setForm] = useState(emptyForm);
+  const [error, setError] = useState<string | null>(null);
+
+  const { data: silences } = useQuery({
+    queryKey: ['silences'],
+    queryFn: () => api.getSilences(),
+    refetchInterval: 30000,
+  });
+
+  const createMutation = useMutation({
+    mutationFn: () => api.createSilence({
+      probe_config_id: form.probe_config_id ? Number(form.probe_config_id) : undefined,
+      group_path: form.group_path || undefined,
+      keyword: form.keyword || undefined,
+      watcher_id: form.watcher_id ? Number(form.watcher_id) : undefined,
+      probe_type: form.probe_type || undefined,
+      starts_at: form.starts_at ? new Date(form.starts_at).toISOString() : undefined,
+      duration: form.duration,
+      comment: form.comment || undefined,
+    }),
+    onSuccess: () => {
+      setShowForm(false);
+      setForm(emptyForm);
+      setError(null);
+      queryClient.invalidateQueries({ queryKey: ['silences'] });
+    },
+    onError: (err: Error) => setError(err.message),
+  });
+
+  const deleteMutation = useMutation({
+    mutationFn: (id: number) => api.deleteSilence(id),
+    onSuccess: () => {
+      queryClient.invalidateQueries({ queryKey: ['silences'] });
+    },
+  });
+
+  const set = (field: keyof typeof emptyForm) =>
+    (e: React.ChangeEvent<HTMLInputElement | HTMLSelectElement>) => setForm({ ...form, [field]: e.target.value });
+
+  const probeTypeNames = [...new Set(probeTypes.map((pt) => pt.name))];
+
+  return (
+    <div className="bg-white rounded-lg shadow p-6 mb-6 border border-gray-200">
+      <div className="flex items-center justify-between mb-4">
+        <h2 className="text-lg font-semibold">Silences</h2>
+        <button
+          onClick={() => setShowForm(!showForm)}
+          className="px-4 py-2 bg-blue-600 text-white rounded hover:bg-blue-700"
+        >
+          {showForm ? 'Cancel' : 'Add Silence'}
+        </button>
+      </div>
+
+      {showForm && (
+        <form
+          onSubmit={(e) => { e.preventDefault(); createMutation.mutate(); }}
+          className="border rounded p-3 bg-gray-50 mb-4 grid grid-cols-2 gap-3 text-sm"
+        >
+          <select value={form.probe_config_id} onChange={set('probe_config_id')} className="border rounded px-2 py-1">
+            <option value="">Any probe</option>
+            {configs.map((cfg) => <option key={cfg.id} value={cfg.id}>{cfg.name}</option>)}
+          </select>
+          <select value={form.watcher_id} onChange={set('watcher_id')} className="border rounded px-2 py-1">
+            <option value="">Any watcher</option>
+            {watchers.map((w) => <option key={w.id} value={w.id}>{w.name}</option>)}
+          </select>
+          <select value={form.probe_type} onChange={set('probe_type')} className="border rounded px-2 py-1">
+            <option value="">Any probe type</option>
+            {probeTypeNames.map((name) => <option key={name} value={name}>{name}</option>)}
+          </select>
+          <input value={form.group_path} onChange={set('group_path')} placeholder="Group (includes subgroups)" className="border rounded px-2 py-1" />
+          <input value={form.keyword} onChange={set('keyword')} placeholder="Keyword" className="border rounded px-2 py-1" />
+          <input value={form.comment} onChange={set('comment')} placeholder="Comment" className="border rounded px-2 py-1" />
+          <label className="flex items-center gap-2">
+            Starts
+            <input type="datetime-local" value={form.starts_at} onChange={set('starts_at')} className="border rounded px-2 py-1 flex-1" />
+          </label>
+          <label className="flex items-center gap-2">
+            Duration
+            <input value={form.duration} onChange={set('duration')} placeholder="e.g. 2h30m" className="border rounded px-2 py-1 flex-1" />
+          </label>
+          {error && <p className="col-span-2 text-red-600">{error}</p>}
+          <div className="col-span-2">
+            <button
+              type="submit"
+              disabled={createMutation.isPending}
+              className="px-4 py-2 bg-green-600 text-white rounded hover:bg-green-700 disabled:opacity-50"
+            >
+              Create
+            </button>
+          </div>
+        </form>
+      )}
+
+      {silences?.length === 0 ? (
+        <p className="text-gray-500">No active or scheduled silences.</p>
+      ) : (
+        <div className="divide-y">
+          {silences?.map((s) => (
+            <div key={s.id} className="py-3 flex items-center justify-between">
+              <div>
+                <span className="font-medium">{describeMatchers(s)}</span>
+                {s.state === 'pending' ? (
+                  <span className="ml-2 text-xs px-2 py-0.5 rounded bg-gray-200 text-gray-600">
+                    starts in {formatDuration(s.starts_in_seconds ?? 0)}
+                  </span>
+                ) : (
+                  <span className="ml-2 text-xs px-2 py-0.5 rounded bg-purple-100 text-purple-700">
+                    {formatDuration(s.remaining_seconds)} left
+                  </span>
+                )}
+                {s.comment && <span className="ml-2 text-sm text-gray-500">{s.comment}</span>}
+              </div>
+              <button
+                onClick={() => deleteMutation.mutate(s.id)}
+                className="text-red-600 hover:text-red-800 text-sm"
+              >
+                {s.state === 'pending' ? 'Cancel' : 'End'}
+              </button>
+            </div>
+          ))}
+        </div>
+      )}
+    </div>
+  );
+}
@@ -0,0 +1,322 @@
+import { useState, useEffect } from 'react';
+import { useQuery, useMutation, useQueryClient } from '@tanstack/react-query';
+import { api } from '../api/client';
+import type { CurrentUser, Role } from '../api/types';
+
+interface AccountProps {
+  user: CurrentUser;
+  onBack: () => void;
+}
+
+const ROLES: Role[] = ['viewer', 'operator', 'admin'];
+
+const inputClass = 'px-2 py-1 text-sm border border-gray-300 rounded';
+const buttonClass = 'px-3 py-1 text-sm font-medium text-white bg-blue-600 rounded hover:bg-blue-700 disabled:opacity-50';
+
+function formatTime(time?: string) {
+  return time ? new Date(time).toLocaleString() : '—';
+}
+
+export function Account({ user, onBack }: AccountProps) {
+  useEffect(() => {
+    const handleKeyDown = (e: KeyboardEvent) => {
+      if (e.key === 'Escape') {
+        onBack();
+      }
+    };
+    window.addEventListener('keydown', handleKeyDown);
+    return () => window.removeEventListener('keydown', handleKeyDown);
+  }, [onBack]);
+
+  return (
+    <div className="p-6">
+      <div className="mb-6 flex items-center gap-4">
+        <button
+          onClick={onBack}
+          className="text-gray-600 hover:text-gray-900"
+        >
+          ← Back
+        </button>
+        <h1 className="text-2xl font-bold text-gray-900">Account</h1>
+      </div>
+
+      <div className="mb-8 bg-white rounded-lg shadow p-6">
+        <div className="text-sm text-gray-500">Signed in as</div>
+        <div className="mt-1 text-lg font-medium text-gray-900">
+          {user.username} <span className="text-sm font-normal text-gray-500">({user.role})</span>
+        </div>
+        {user.auth === 'shared-token' && (
+          <div className="mt-2 text-sm text-gray-600">
+            The shared auth token has no user account. Create users with <code>monitor user add</code>.
+          </div>
+        )}
+      </div>
+
+      {user.auth === 'session' && !user.sso && <PasswordSection />}
+      {user.id !== undefined && <TokensSection user={user} />}
+      {user.role === 'admin' && <UsersSection user={user} />}
+    </div>
+  );
+}
+
+function PasswordSection() {
+  const [current, setCurrent] = useState('');
+  const [next, setNext] = useState('');
+  const [message, setMessage] = useState('');
+
+  const mutation = useMutation({
+    mutationFn: () => api.changePassword(current, next),
+    onSuccess: () => {
+      setCurrent('');
+      setNext('');
+      setMessage('Password changed. Your other sessions were signed out.');
+    },
+    onError: () => setMessage('Could not change the password. Check the current password; the new one needs at least 8 characters.'),
+  });
+
+  return (
+    <div className="mb-8">
+      <h2 className="text-lg font-semibold text-gray-900 mb-3">Password</h2>
+      <form
+        className="bg-white rounded-lg shadow p-6 flex flex-wrap items-center gap-2"
+        onSubmit={(e) => {
+          e.preventDefault();
+          setMessage('');
+          mutation.mutate();
+        }}
+      >
+        <input
+          type="password"
+          autoComplete="current-password"
+          placeholder="Current password"
+          value={current}
+          onChange={(e) => setCurrent(e.target.value)}
+          className={inputClass}
+          required
+        />
+        <input
+          type="password"
+          autoComplete="new-password"
+          placeholder="New password"
+          value={next}
+          onChange={(e) => setNext(e.target.value)}
+          className={inputClass}
+          minLength={8}
+          required
+        />
+        <button type="submit" disabled={mutation.isPending} className={buttonClass}>
+          Change Password
+        </button>
+        {message && <div className="w-full text-sm text-gray-600">{message}</div>}
+      </form>
+    </div>
+  );
+}
+
+function TokensSection({ user }: { user: CurrentUser }) {
+  const queryClient = useQueryClient();
+  const [name, setName] = useState('');
+  const [role, setRole] = useState<Role | ''>('');
+  const [expiresIn, setExpiresIn] = useState('');
+  const [created, setCreated] = useState<string | null>(null);
+
+  const { data: tokens } = useQuery({
+    queryKey: ['apiTokens'],
+    queryFn: () => api.getApiTokens(user.role === 'admin'),
+  });
+
+  const createMutation = useMutation({
+    mutationFn: () => api.createApiToken({
+      name,
+      role: role || undefined,
+      expires_in: expiresIn || undefined,
+    }),
+    onSuccess: (result) => {
+      setCreated(result.token);
+      setName('');
+      queryClient.invalidateQueries({ queryKey: ['apiTokens'] });
+    },
+  });
+
+  const deleteMutation = useMutation({
+    mutationFn: (id: number) => api.deleteApiToken(id),
+    onSuccess: () => queryClient.invalidateQueries({ queryKey: ['apiTokens'] }),
+  });
+
+  return (
+    <div className="mb-8">
+      <h2 className="text-lg font-semibold text-gray-900 mb-3">API Tokens</h2>
+      <div className="bg-white rounded-lg shadow divide-y divide-gray-200">
+        <form
+          className="px-6 py-4 flex flex-wrap items-center gap-2"
+          onSubmit={(e) => {
+            e.preventDefault();
+            setCreated(null);
+            createMutation.mutate();
+          }}
+        >
+          <input
+            type="text"
+            placeholder="Token name"
+            value={name}
+            onChange={(e) => setName(e.target.value)}
+            className={inputClass}
+            required
+          />
+          <select value={role} onChange={(e) => setRole(e.target.value as Role | '')} className={inputClass}>
+            <option value="">Role: same as mine</option>
+            {ROLES.map((r) => <option key={r} value={r}>{r}</option>)}
+          </select>
+          <select value={expiresIn} onChange={(e) => setExpiresIn(e.target.value)} className={inputClass}>
+            <option value="">Never expires</option>
+            <option value="720h">Expires in 30 days</option>
+            <option value="2160h">Expires in 90 days</option>
+            <option value="8760h">Expires in 1 year</option>
+          </select>
+          <button type="submit" disabled={createMutation.isPending} className={buttonClass}>
+            Create Token
+          </button>
+          {createMutation.isError && (
+            <div className="w-full text-sm text-red-600">Could not create the token.</div>
+          )}
+        </form>
+        {created && (
+          <div className="px-6 py-4 bg-yellow-50 text-sm">
+            <div className="text-gray-700">Copy the new token now; it won't be shown again:</div>
+            <code className="mt-1 block break-all font-mono text-gray-900">{created}</code>
+          </div>
+        )}
+        {tokens?.map((token) => (
+          <div key={token.id} className="px-6 py-3 flex items-center gap-3 text-sm">
+            <span className="font-medium text-gray-900">{token.name}</span>
+            <code className="text-gray-500">{token.prefix}…</code>
+            {token.username !== user.username && <span className="text-gray-500">{token.username}</span>}
+            {token.role && <span className="text-gray-500">{token.role}</span>}
+            <span className="ml-auto text-gray-500">
+              Last used {formatTime(token.last_used_at)}
+              {token.expires_at ? ` · expires ${formatTime(token.expires_at)}` : ''}
+            </span>
+            <button
+              onClick={() => {
+                if (confirm(`Revoke the token "${token.name}"?`)) {
+                  deleteMutation.mutate(token.id);
+                }
+              }}
+              className="text-red-600 hover:text-red-800"
+            >
+              Revoke
+            </button>
+          </div>
+        ))}
+      </div>
+    </div>
+  );
+}
+
+function UsersSection({ user }: { user: CurrentUser }) {
+  const queryClient = useQueryClient();
+  const [username, setUsername] = useState('');
+  const [password, setPassword] = useState('');
+  const [role, setRole] = useState<Role>('viewer');
+
+  const { data: users } = useQuery({
+    queryKey: ['users'],
+    queryFn: () => api.getUsers(),
+  });
+
+  const createMutation = useMutation({
+    mutationFn: () => api.createUser({ username, password, role }),
+    onSuccess: () => {
+      setUsername('');
+      setPassword('');
+      queryClient.invalidateQueries({ queryKey: ['users'] });
+    },
+  });
+
+  const updateMutation = useMutation({
+    mutationFn: ({ id, role }: { id: number; role: Role }) => api.updateUser(id, { role }),
+    onSuccess: () => queryClient.invalidateQueries({ queryKey: ['users'] }),
+  });
+
+  const deleteMutation = useMutation({
+    mutationFn: (id: number) => api.deleteUser(id),
+    onSuccess: () => {
+      queryClient.invalidateQueries({ queryKey: ['users'] });
+      queryClient.invalidateQueries({ queryKey: ['apiTokens'] });
+    },
+  });
+
+  return (
+    <div className="mb-8">
+      <h2 className="text-lg font-semibold text-gray-900 mb-3">Users</h2>
+      <div className="bg-white rounded-lg shadow divide-y divide-gray-200">
+        <form
+          className="px-6 py-4 flex flex-wrap items-center gap-2"
+          onSubmit={(e) => {
+            e.preventDefault();
+            createMutation.mutate();
+          }}
+        >
+          <input
+            type="text"
+            placeholder="Username"
+            value={username}
+            onChange={(e) => setUsername(e.target.value)}
+            className={inputClass}
+            required
+          />
+          <input
+            type="password"
+            autoComplete="new-password"
+            placeholder="Password"
+            value={password}
+            onChange={(e) => setPassword(e.target.value)}
+            className={inputClass}
+            minLength={8}
+            required
+          />
+          <select value={role} onChange={(e) => setRole(e.target.value as Role)} className={inputClass}>
+            {ROLES.map((r) => <option key={r} value={r}>{r}</option>)}
+          </select>
+          <button type="submit" disabled={createMutation.isPending} className={buttonClass}>
+            Add User
+          </button>
+          {createMutation.isError && (
+            <div className="w-full text-sm text-red-600">Could not add the user.</div>
+          )}
+        </form>
+        {(updateMutation.isError || deleteMutation.isError) && (
+          <div className="px-6 py-2 text-sm text-red-600">
+            The change was rejected; there must always be an admin.
+          </div>
+        )}
+        {users?.map((u) => (
+          <div key={u.id} className="px-6 py-3 flex items-center gap-3 text-sm">
+            <span className="font-medium text-gray-900">{u.username}</span>
+            {u.sso && <span className="text-gray-500">single sign-on</span>}
+            <select
+              value={u.role}
+              onChange={(e) => updateMutation.mutate({ id: u.id, role: e.target.value as Role })}
+              className={inputClass}
+            >
+              {ROLES.map((r) => <option key={r} value={r}>{r}</option>)}
+            </select>
+            <span className="ml-auto text-gray-500">Last login {formatTime(u.last_login_at)}</span>
+            {u.id !== user.id && (
+              <button
+                onClick={() => {
+                  if (confirm(`Delete the user "${u.username}" and their API tokens?`)) {
+                    deleteMutation.mutate(u.id);
+                  }
+                }}
+                className="text-red-600 hover:text-red-800"
+              >
+                Delete
+              </button>
+            )}
+          </div>
+        ))}
+      </div>
+    </div>
+  );
+}
@@ -2,6 +2,7 @@ import { useState, useEffect } from 'react';
 import { useQuery, useMutation, useQueryClient } from '@tanstack/react-query';
 import { api } from '../api/client';
 import { ProbeConfigForm } from '../components/ProbeConfigForm';
+import { SilencesSection } from '../components/SilencesSection';
 import type { ProbeConfig } from '../api/types';
 
 interface ConfigProps {
@@ -133,6 +134,9 @@ export function Config({ onBack }: ConfigProps) {
         )}
       </div>
 
+      {/* Silences Section */}
+      <SilencesSection configs={configs ?? []} watchers={watchers ?? []} probeTypes={probeTypes ?? []} />
+
       {/* Probe Types Section */}
       <div className="bg-white rounded-lg shadow p-6 mb-6 border border-gray-200">
         <div className="flex items-center justify-between mb-4">
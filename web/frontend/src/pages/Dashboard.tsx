@@ -19,9 +19,11 @@ interface DashboardProps {
   onProbeClick: (config: ProbeConfig) => void;
   onConfigClick: () => void;
   onFailuresClick: () => void;
+  onAccountClick: () => void;
+  onLogout: () => void;
 }
 
-export function Dashboard({ onProbeClick, onConfigClick, onFailuresClick }: DashboardProps) {
+export function Dashboard({ onProbeClick, onConfigClick, onFailuresClick, onAccountClick, onLogout }: DashboardProps) {
   const [editingConfig, setEditingConfig] = useState<ProbeConfig | null>(null);
   const [keywordFilter, setKeywordFilter] = useState('');
   const [runningProbes, setRunningProbes] = useState<Set<number>>(new Set());
@@ -198,12 +200,26 @@ export function Dashboard({ onProbeClick, onConfigClick, onFailuresClick }: Dash
         <h1 className="text-2xl font-bold text-gray-900">
           Monitor Dashboard{status?.server_name ? ` on ${status.server_name}` : ''}
         </h1>
-        <button
-          onClick={onConfigClick}
-          className="px-4 py-2 bg-gray-800 text-white rounded hover:bg-gray-700"
-        >
-          Configure
-        </button>
+        <div className="flex items-center gap-2">
+          <button
+            onClick={onAccountClick}
+            className="px-4 py-2 text-gray-700 border border-gray-300 rounded hover:bg-gray-50"
+          >
+            Account
+          </button>
+          <button
+            onClick={onLogout}
+            className="px-4 py-2 text-gray-700 border border-gray-300 rounded hover:bg-gray-50"
+          >
+            Sign Out
+          </button>
+          <button
+            onClick={onConfigClick}
+            className="px-4 py-2 bg-gray-800 text-white rounded hover:bg-gray-700"
+          >
+            Configure
+          </button>
+        </div>
       </div>
 
       <div className="grid grid-cols-1 md:grid-cols-4 gap-4 mb-6">
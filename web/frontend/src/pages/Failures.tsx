@@ -1,7 +1,7 @@
 import { useState, useEffect } from 'react';
-import { useQuery } from '@tanstack/react-query';
+import { useQuery, useMutation, useQueryClient } from '@tanstack/react-query';
 import { api } from '../api/client';
-import type { ProbeResult } from '../api/types';
+import type { Incident, ProbeResult } from '../api/types';
 
 interface FailuresProps {
   onBack: () => void;
@@ -9,9 +9,21 @@ interface FailuresProps {
 }
 
 const PAGE_SIZE = 20;
+const INCIDENT_LIMIT = 20;
+
+const formatDuration = (seconds: number) => {
+  if (seconds < 60) return `${seconds}s`;
+  const minutes = Math.floor(seconds / 60);
+  if (minutes < 60) return `${minutes}m`;
+  const hours = Math.floor(minutes / 60);
+  if (hours < 24) return `${hours}h ${minutes % 60}m`;
+  return `${Math.floor(hours / 24)}d ${hours % 24}h`;
+};
 
 export function Failures({ onBack, onProbeClick }: FailuresProps) {
   const [page, setPage] = useState(0);
+  const [noteDrafts, setNoteDrafts] = useState<Record<number, string>>({});
+  const queryClient = useQueryClient();
 
   useEffect(() => {
     const handleKeyDown = (e: KeyboardEvent) => {
@@ -34,6 +46,25 @@ export function Failures({ onBack, onProbeClick }: FailuresProps) {
     refetchInterval: 30000,
   });
 
+  const { data: incidents } = useQuery({
+    queryKey: ['incidents'],
+    queryFn: () => api.getIncidents({ limit: INCIDENT_LIMIT }),
+    refetchInterval: 30000,
+  });
+
+  const acknowledgeMutation = useMutation({
+    mutationFn: (id: number) => api.acknowledgeIncident(id),
+    onSuccess: () => queryClient.invalidateQueries({ queryKey: ['incidents'] }),
+  });
+
+  const noteMutation = useMutation({
+    mutationFn: ({ id, note }: { id: number; note: string }) => api.addIncidentNote(id, note),
+    onSuccess: (_, { id }) => {
+      setNoteDrafts((drafts) => ({ ...drafts, [id]: '' }));
+      queryClient.invalidateQueries({ queryKey: ['incidents'] });
+    },
+  });
+
   const formatTime = (timestamp: string) => {
     const date = new Date(timestamp);
     const now = new Date();
@@ -76,6 +107,82 @@ export function Failures({ onBack, onProbeClick }: FailuresProps) {
         <h1 className="text-2xl font-bold text-gray-900">Failure Log</h1>
       </div>
 
+      {incidents && incidents.length > 0 && (
+        <div className="mb-8">
+          <h2 className="text-lg font-semibold text-gray-900 mb-3">Incidents</h2>
+          <div className="bg-white rounded-lg shadow divide-y divide-gray-200">
+            {incidents.map((incident: Incident) => (
+              <div key={incident.id} className="px-6 py-4">
+                <div className="flex items-center gap-3">
+                  <span
+                    className={`inline-flex px-2 py-0.5 text-xs font-medium rounded border ${statusColor(
+                      incident.status
+                    )}`}
+                  >
+                    {incident.status}
+                  </span>
+                  <button
+                    onClick={() => onProbeClick(incident.probe_config_id)}
+                    className="text-sm font-medium text-gray-900 hover:underline"
+                  >
+                    {incident.probe_config_name}
+                  </button>
+                  <span className="text-sm text-gray-500">
+                    {formatTime(incident.opened_at)} · {formatDuration(incident.duration_seconds)}
+                    {incident.resolved_at ? '' : ' and ongoing'}
+                  </span>
+                  <span className="ml-auto text-sm">
+                    {incident.resolved_at ? (
+                      <span className="text-green-700">Resolved</span>
+                    ) : incident.acknowledged_at ? (
+                      <span className="text-gray-500">
+                        Acknowledged{incident.acknowledged_by ? ` by ${incident.acknowledged_by}` : ''}
+                      </span>
+                    ) : (
+                      <button
+                        onClick={() => acknowledgeMutation.mutate(incident.id)}
+                        disabled={acknowledgeMutation.isPending}
+                        className="px-3 py-1 text-sm font-medium text-white bg-blue-600 rounded hover:bg-blue-700 disabled:opacity-50"
+                      >
+                        Acknowledge
+                      </button>
+                    )}
+                  </span>
+                </div>
+                {incident.message && (
+                  <div className="mt-1 text-sm text-gray-600 truncate">{incident.message}</div>
+                )}
+                {incident.notes.map((note) => (
+                  <div key={note.id} className="mt-1 text-sm text-gray-700">
+                    <span className="text-gray-500">
+                      {formatTime(note.created_at)}
+                      {note.author ? ` ${note.author}` : ''}:
+                    </span>{' '}
+                    {note.note}
+                  </div>
+                ))}
+                <form
+                  className="mt-2 flex gap-2"
+                  onSubmit={(e) => {
+                    e.preventDefault();
+                    const note = (noteDrafts[incident.id] ?? '').trim();
+                    if (note) noteMutation.mutate({ id: incident.id, note });
+                  }}
+                >
+                  <input
+                    type="text"
+                    value={noteDrafts[incident.id] ?? ''}
+                    onChange={(e) => setNoteDrafts((drafts) => ({ ...drafts, [incident.id]: e.target.value }))}
+                    placeholder="Add a note"
+                    className="flex-1 px-2 py-1 text-sm border border-gray-300 rounded"
+                  />
+                </form>
+              </div>
+            ))}
+          </div>
+        </div>
+      )}
+
       {isLoading ? (
         <div className="text-center py-12 text-gray-500">Loading failures...</div>
       ) : failures?.length === 0 ? (
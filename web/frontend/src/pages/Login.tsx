@@ -1,4 +1,4 @@
-import { useState } from 'react';
+import { useState, useEffect } from 'react';
 import { api } from '../api/client';
 
 interface LoginProps {
@@ -6,9 +6,23 @@ interface LoginProps {
 }
 
 export function Login({ onLogin }: LoginProps) {
+  const [useToken, setUseToken] = useState(false);
+  const [username, setUsername] = useState('');
+  const [password, setPassword] = useState('');
   const [token, setToken] = useState('');
-  const [error, setError] = useState('');
+  // A failed single sign-on comes back with the reason in the URL
+  const [error, setError] = useState(() => new URLSearchParams(window.location.search).get('login_error') ?? '');
   const [loading, setLoading] = useState(false);
+  const [oidc, setOidc] = useState(false);
+
+  useEffect(() => {
+    if (new URLSearchParams(window.location.search).has('login_error')) {
+      window.history.replaceState(null, '', window.location.pathname);
+    }
+    api.getLoginOptions()
+      .then((options) => setOidc(options.oidc))
+      .catch(() => setOidc(false));
+  }, []);
 
   const handleSubmit = async (e: React.FormEvent) => {
     e.preventDefault();
@@ -16,37 +30,77 @@ export function Login({ onLogin }: LoginProps) {
     setLoading(true);
 
     try {
-      api.setToken(token);
-      await api.getStatus();
+      if (useToken) {
+        api.setToken(token);
+        await api.getMe();
+      } else {
+        api.clearToken();
+        await api.login(username, password);
+      }
       onLogin();
     } catch {
-      setError('Invalid token');
+      setError(useToken ? 'Invalid token' : 'Invalid username or password');
       api.clearToken();
     } finally {
       setLoading(false);
     }
   };
 
+  const inputClass = 'w-full px-3 py-2 border border-gray-300 rounded-md focus:outline-none focus:ring-2 focus:ring-blue-500';
+
   return (
     <div className="min-h-screen flex items-center justify-center bg-gray-100">
       <div className="bg-white p-8 rounded-lg shadow-md w-full max-w-md">
         <h1 className="text-2xl font-bold text-center text-gray-900 mb-6">Monitor</h1>
 
         <form onSubmit={handleSubmit}>
-          <div className="mb-4">
-            <label htmlFor="token" className="block text-sm font-medium text-gray-700 mb-1">
-              API Token
-            </label>
-            <input
-              id="token"
-              type="password"
-              value={token}
-              onChange={(e) => setToken(e.target.value)}
-              className="w-full px-3 py-2 border border-gray-300 rounded-md focus:outline-none focus:ring-2 focus:ring-blue-500"
-              placeholder="Enter your token"
-              required
-            />
-          </div>
+          {useToken ? (
+            <div className="mb-4">
+              <label htmlFor="token" className="block text-sm font-medium text-gray-700 mb-1">
+                API Token
+              </label>
+              <input
+                id="token"
+                type="password"
+                value={token}
+                onChange={(e) => setToken(e.target.value)}
+                className={inputClass}
+                placeholder="Enter your token"
+                required
+              />
+            </div>
+          ) : (
+            <>
+              <div className="mb-4">
+                <label htmlFor="username" className="block text-sm font-medium text-gray-700 mb-1">
+                  Username
+                </label>
+                <input
+                  id="username"
+                  type="text"
+                  autoComplete="username"
+                  value={username}
+                  onChange={(e) => setUsername(e.target.value)}
+                  className={inputClass}
+                  required
+                />
+              </div>
+              <div className="mb-4">
+                <label htmlFor="password" className="block text-sm font-medium text-gray-700 mb-1">
+                  Password
+                </label>
+                <input
+                  id="password"
+                  type="password"
+                  autoComplete="current-password"
+                  value={password}
+                  onChange={(e) => setPassword(e.target.value)}
+                  className={inputClass}
+                  required
+                />
+              </div>
+            </>
+          )}
 
           {error && (
             <div className="mb-4 text-sm text-red-600">{error}</div>
@@ -60,6 +114,23 @@ export function Login({ onLogin }: LoginProps) {
             {loading ? 'Signing in...' : 'Sign In'}
           </button>
         </form>
+
+        {oidc && (
+          <a
+            href={api.oidcLoginUrl()}
+            className="mt-4 block w-full py-2 px-4 text-center text-gray-700 border border-gray-300 rounded-md hover:bg-gray-50"
+          >
+            Sign in with single sign-on
+          </a>
+        )}
+
+        <button
+          type="button"
+          onClick={() => { setUseToken(!useToken); setError(''); }}
+          className="mt-4 w-full text-sm text-blue-600 hover:underline"
+        >
+          {useToken ? 'Sign in with a password' : 'Use an API token instead'}
+        </button>
       </div>
     </div>
   );
@@ -6,6 +6,9 @@ import rehypeRaw from 'rehype-raw';
 import { api } from '../api/client';
 import { StatusBadge } from '../components/StatusBadge';
 import { ProbeConfigForm } from '../components/ProbeConfigForm';
+import { MetricRulesSection } from '../components/MetricRulesSection';
+import { DependenciesSection } from '../components/DependenciesSection';
+import { HistorySection } from '../components/HistorySection';
 import type { ProbeConfig, ProbeResult } from '../api/types';
 
 interface ProbeDetailProps {
@@ -172,6 +175,12 @@ export function ProbeDetail({ config: initialConfig, onBack, onConfigUpdated }:
         </div>
       )}
 
+      <MetricRulesSection configId={config.id} metricKeys={metricKeys} />
+
+      <DependenciesSection config={config} />
+
+      <HistorySection config={config} />
+
       <div className="bg-white rounded-lg shadow border border-gray-200">
         <h2 className="text-lg font-semibold p-4 border-b">Recent Results</h2>
         {isLoading ? (
@@ -183,7 +192,26 @@ export function ProbeDetail({ config: initialConfig, onBack, onConfigUpdated }:
             {results?.slice(0, 20).map((result: ProbeResult) => (
               <div key={result.id} className="p-4">
                 <div className="flex items-center justify-between mb-2">
-                  <StatusBadge status={result.status} size="sm" />
+                  <div className="flex items-center gap-2">
+                    <StatusBadge status={result.status} size="sm" />
+                    {result.anomalies?.map((a) => (
+                      <span
+                        key={a.metric}
+                        className="text-xs px-2 py-0.5 rounded bg-yellow-100 text-yellow-800"
+                        title={`${a.score.toFixed(1)}σ from baseline ${a.baseline.toPrecision(4)} ± ${a.stddev.toPrecision(2)}`}
+                      >
+                        anomalous {a.metric}
+                      </span>
+                    ))}
+                    {result.suppressed_by && (
+                      <span
+                        className="text-xs px-2 py-0.5 rounded bg-gray-200 text-gray-600"
+                        title="A probe this one depends on was failing, so this result was not notified"
+                      >
+                        suppressed
+                      </span>
+                    )}
+                  </div>
                   <span className="text-sm text-gray-500">{formatDate(result.executed_at)}</span>
                 </div>
                 <div className="text-sm text-gray-700 prose prose-sm max-w-none">